@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	vtcp "github.com/vTCP-Foundation/vtcpd-test-suite/pkg/testsuite"
+)
+
+const nodeReadyTimeout = 60 * time.Second
+
+func runUp(statePath string, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: up <topology.yaml>")
+	}
+	if _, err := os.Stat(statePath); err == nil {
+		return fmt.Errorf("cluster is already running (state file %s exists), run 'down' first", statePath)
+	}
+
+	topology, err := loadTopology(args[0])
+	if err != nil {
+		return err
+	}
+
+	state := &clusterState{
+		NodeImageName: topology.NodeImageName,
+		NetworkName:   topology.NetworkName,
+	}
+	cluster, err := vtcp.NewDetachedCluster(context.Background(), state.settings())
+	if err != nil {
+		return err
+	}
+
+	nodes := make(map[string]*vtcp.Node)
+	for _, topologyNode := range topology.Nodes {
+		node := vtcp.NewNode(nil, topologyNode.IP, topologyNode.Alias)
+		if err := cluster.StartNode(context.Background(), node, topology.Valgrind); err != nil {
+			return fmt.Errorf("failed to run %s: %v", node.Alias, err)
+		}
+		nodes[node.Alias] = node
+
+		// The state is saved after every node, so that 'down' can clean up a partially started cluster.
+		state.Nodes = append(state.Nodes, stateNode{Alias: node.Alias, IPAddress: node.IPAddress, ContainerID: node.ContainerID})
+		if err := saveState(statePath, state); err != nil {
+			return err
+		}
+		fmt.Printf("Node %s is running : [%s : %s]\n", node.Alias, node.IPAddress, node.ContainerID)
+	}
+
+	for _, topologyNode := range topology.Nodes {
+		if err := nodes[topologyNode.Alias].WaitForReady(nil, nodeReadyTimeout); err != nil {
+			return fmt.Errorf("node %s failed to become ready: %v", topologyNode.Alias, err)
+		}
+	}
+
+	for _, line := range topology.SettlementLines {
+		if err := openSettlementLine(nodes[line.From], nodes[line.To], line.Equivalent, line.Amount); err != nil {
+			return fmt.Errorf("settlement line %s -> %s: %v", line.From, line.To, err)
+		}
+		fmt.Printf("Settlement line %s -> %s (equivalent %s, amount %s) is opened\n", line.From, line.To, line.Equivalent, line.Amount)
+	}
+
+	return printStatus(cluster, state)
+}
+
+// openSettlementLine opens the channel between the nodes (if there is none yet) and the settlement line.
+func openSettlementLine(from, to *vtcp.Node, equivalent, amount string) error {
+	if channelInfo, err := from.GetChannelInfoByAddress(to); err != nil || channelInfo.ChannelConfirmed != vtcp.ChannelConfirmed {
+		if err := from.InitChannel(to); err != nil {
+			return err
+		}
+	}
+
+	if err := from.InitSettlementLine(to, equivalent); err != nil {
+		return err
+	}
+	// Same pause as CreateAndSetSettlementLine: the line must be initialized on both sides first.
+	time.Sleep(3 * time.Second)
+
+	statusCode, err := from.UpdateSettlementLine(to, equivalent, amount)
+	if err != nil {
+		return err
+	}
+	if statusCode != http.StatusOK {
+		return fmt.Errorf("set-settlement-line request failed with status: %d", statusCode)
+	}
+	return nil
+}
+
+func runStatus(statePath string, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: status")
+	}
+	state, err := loadState(statePath)
+	if err != nil {
+		return err
+	}
+	cluster, err := state.cluster()
+	if err != nil {
+		return err
+	}
+	return printStatus(cluster, state)
+}
+
+func printStatus(cluster *vtcp.Cluster, state *clusterState) error {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "ALIAS\tIP\tCONTAINER\tSTATE\tNODE PORT\tCLI PORT\tCLI URL")
+	for _, node := range state.nodes() {
+		status, err := cluster.InspectNode(context.Background(), node)
+		if err != nil {
+			fmt.Fprintf(writer, "%s\t%s\t%.12s\t%v\t\t\t\n", node.Alias, node.IPAddress, node.ContainerID, err)
+			continue
+		}
+		cliURL := ""
+		if status.CLIHostPort != "" {
+			cliURL = fmt.Sprintf("http://127.0.0.1:%s/api/v1/node/", status.CLIHostPort)
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%.12s\t%s\t%s\t%s\t%s\n",
+			node.Alias, node.IPAddress, node.ContainerID, status.State, status.NodeHostPort, status.CLIHostPort, cliURL)
+	}
+	return writer.Flush()
+}
+
+func runExec(statePath string, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: exec <alias> <cmd> [args...]")
+	}
+	state, err := loadState(statePath)
+	if err != nil {
+		return err
+	}
+	node, err := state.node(args[0])
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command("docker", append([]string{"exec", "-i", node.ContainerID}, args[1:]...)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func runNetem(statePath string, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: netem <alias> [options] | netem <alias> clear")
+	}
+	state, err := loadState(statePath)
+	if err != nil {
+		return err
+	}
+	node, err := state.node(args[0])
+	if err != nil {
+		return err
+	}
+	cluster, err := state.cluster()
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 2 && args[1] == "clear" {
+		return cluster.RemoveNetworkConditions(node, "")
+	}
+
+	conditions := vtcp.NetworkConditions{}
+	flags := flag.NewFlagSet("netem", flag.ContinueOnError)
+	flags.StringVar(&conditions.Bandwidth, "bandwidth", "", "bandwidth limit (e.g. 1mbit, 100kbit)")
+	flags.IntVar(&conditions.DelayMs, "delay", 0, "delay in milliseconds")
+	flags.IntVar(&conditions.JitterMs, "jitter", 0, "delay jitter in milliseconds")
+	flags.Float64Var(&conditions.LossPercent, "loss", 0, "packet loss percentage")
+	flags.Float64Var(&conditions.DuplicatePercent, "duplicate", 0, "packet duplication percentage")
+	flags.Float64Var(&conditions.CorruptPercent, "corrupt", 0, "packet corruption percentage")
+	flags.Float64Var(&conditions.ReorderPercent, "reorder", 0, "packet reordering percentage")
+	flags.IntVar(&conditions.ReorderGap, "reorder-gap", 0, "reordering gap (default 5)")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	return cluster.ConfigureNetworkConditions(node, &conditions, "")
+}
+
+func runFlag(statePath string, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: flag <alias> <flag> [-address alias|address] [-amount amount]")
+	}
+	state, err := loadState(statePath)
+	if err != nil {
+		return err
+	}
+	node, err := state.node(args[0])
+	if err != nil {
+		return err
+	}
+
+	testingFlag, ok := vtcp.TestingFlagsByName[args[1]]
+	if !ok {
+		testingFlag, err = strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("unknown testing flag %s", args[1])
+		}
+	}
+
+	flags := flag.NewFlagSet("flag", flag.ContinueOnError)
+	address := flags.String("address", "", "alias or address of the node the flag applies to")
+	amount := flags.String("amount", "", "amount the flag applies to")
+	if err := flags.Parse(args[2:]); err != nil {
+		return err
+	}
+
+	// Tests pass the IP address of the contractor, allow to use its alias instead.
+	if contractor, err := state.node(*address); err == nil {
+		*address = contractor.IPAddress
+	}
+
+	if err := node.SendTestingFlag(testingFlag, *address, *amount); err != nil {
+		return err
+	}
+	fmt.Printf("Testing flag %d is set on node %s\n", testingFlag, node.Alias)
+	return nil
+}
+
+func runDown(statePath string, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: down")
+	}
+	state, err := loadState(statePath)
+	if err != nil {
+		return err
+	}
+	cluster, err := state.cluster()
+	if err != nil {
+		return err
+	}
+
+	failed := false
+	for _, node := range state.nodes() {
+		if err := cluster.RemoveNode(context.Background(), node); err != nil {
+			fmt.Fprintf(os.Stderr, "Node %s: %v\n", node.Alias, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("Node %s is removed\n", node.Alias)
+	}
+	if failed {
+		return fmt.Errorf("some nodes could not be removed, state file %s is kept", statePath)
+	}
+	return os.Remove(statePath)
+}
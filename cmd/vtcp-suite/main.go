@@ -0,0 +1,87 @@
+// Command vtcp-suite brings up vtcpd clusters for manual debugging.
+//
+// It reuses the test suite's cluster machinery, but keeps the cluster running between invocations:
+// the started nodes are stored in a state file, so that later commands can find them.
+// The report command runs the test suite with reporting enabled, the rerun command runs it and tells flaky tests
+// from consistently failing ones.
+//
+// Usage:
+//
+//	vtcp-suite [-state file] up <topology.yaml>
+//	vtcp-suite [-state file] status
+//	vtcp-suite [-state file] exec <alias> <cmd> [args...]
+//	vtcp-suite [-state file] netem <alias> [-bandwidth rate] [-delay ms] [-jitter ms] [-loss %] ... | clear
+//	vtcp-suite [-state file] flag <alias> <flag> [-address alias|address] [-amount amount]
+//	vtcp-suite [-state file] down
+//	vtcp-suite report [-report dir] [-run regexp] [-timeout d] [packages...]
+//	vtcp-suite rerun [-attempts n] [-report dir] [-history file] [-run regexp] [-timeout d] [packages...]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+const defaultStateFile = ".vtcp-suite.json"
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: vtcp-suite [-state file] <command> [arguments]
+
+Commands:
+  up <topology.yaml>          start the nodes described in the topology file
+  status                      print nodes, container states and CLI host ports
+  exec <alias> <cmd> [args]   run a command inside the node's container
+  netem <alias> [options]     apply network conditions to the node ("netem <alias> clear" removes them)
+  flag <alias> <flag>         set a testing flag (name like FlagForbidSendInitMessage, or number)
+  down                        stop and remove all nodes of the cluster
+  report [options] [packages] run the tests and write the reports with the output of the failed tests
+                              (default packages ./tests/...)
+  rerun [options] [packages]  run the tests, rerun the failed ones and classify them passed, flaky or
+                              consistently failing (default packages ./tests/...)
+
+Flags:
+`)
+	flag.PrintDefaults()
+}
+
+func main() {
+	statePath := flag.String("state", defaultStateFile, "path to the file with the state of the running cluster")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	command, args := flag.Arg(0), flag.Args()[1:]
+
+	var err error
+	switch command {
+	case "up":
+		err = runUp(*statePath, args)
+	case "status":
+		err = runStatus(*statePath, args)
+	case "exec":
+		err = runExec(*statePath, args)
+	case "netem":
+		err = runNetem(*statePath, args)
+	case "flag":
+		err = runFlag(*statePath, args)
+	case "down":
+		err = runDown(*statePath, args)
+	case "report":
+		err = runReport(args)
+	case "rerun":
+		err = runRerun(args)
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "vtcp-suite %s: %v\n", command, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	vtcp "github.com/vTCP-Foundation/vtcpd-test-suite/pkg/testsuite"
+)
+
+// runReport runs the tests with reporting enabled. Unlike a plain go test run, the reports get the output of
+// the failed tests, so that the JUnit failures say why the tests failed.
+func runReport(args []string) error {
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	reportDir := flags.String("report", os.Getenv("VTCP_REPORT_DIR"), "report directory (default reports, or VTCP_REPORT_DIR)")
+	run := flags.String("run", "", "run only the tests matching the regexp (go test -run)")
+	timeout := flags.String("timeout", "", "timeout of the go test invocation (go test -timeout)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *reportDir == "" {
+		*reportDir = "reports"
+	}
+	// go test runs every package in its own directory, relative paths would end up there.
+	absReportDir, err := filepath.Abs(*reportDir)
+	if err != nil {
+		return err
+	}
+	packages := flags.Args()
+	if len(packages) == 0 {
+		packages = []string{defaultTestPackages}
+	}
+
+	var goTestArgs []string
+	if *timeout != "" {
+		goTestArgs = append(goTestArgs, "-timeout", *timeout)
+	}
+	if *run != "" {
+		goTestArgs = append(goTestArgs, "-run", *run)
+	}
+
+	fmt.Printf("Running %s\n", strings.Join(packages, " "))
+	testRun, err := goTest(absReportDir, append(goTestArgs, packages...)...)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, result := range testRun.results {
+		if result.Status == vtcp.TestStatusFailed {
+			failed++
+		}
+	}
+	for _, pkg := range testRun.brokenPackages {
+		fmt.Fprintf(os.Stderr, "Package %s failed outside of tests (build error or setup failure)\n", pkg)
+	}
+	if len(testRun.brokenPackages) > 0 {
+		return fmt.Errorf("%d package(s) failed outside of tests", len(testRun.brokenPackages))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d test(s) failed, see %s", failed, *reportDir)
+	}
+	return nil
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	vtcp "github.com/vTCP-Foundation/vtcpd-test-suite/pkg/testsuite"
+)
+
+const (
+	defaultRerunAttempts   = 3
+	defaultHistoryFile     = ".vtcp-flaky-history.json"
+	defaultTestPackages    = "./tests/..."
+	rerunReportDirName     = "reruns"
+	maxTestEventLineLength = 16 * 1024 * 1024
+)
+
+// testEvent is a line of the go test -json output.
+type testEvent struct {
+	Action  string  `json:"Action"`
+	Package string  `json:"Package"`
+	Test    string  `json:"Test"`
+	Elapsed float64 `json:"Elapsed"`
+	Output  string  `json:"Output"`
+}
+
+// testResult is the outcome of a top-level test.
+type testResult struct {
+	Package     string
+	Name        string
+	Status      string
+	DurationSec float64
+}
+
+func (r testResult) subsystem() string {
+	return path.Base(r.Package)
+}
+
+// goTestRun is the outcome of a go test invocation.
+type goTestRun struct {
+	results []testResult
+	// brokenPackages are the packages that failed without a failed test.
+	brokenPackages []string
+}
+
+// runRerun runs the tests and reruns every failed test, each time on a fresh cluster, to tell flaky tests
+// from consistently failing ones. Every attempt writes its reports and artifacts into its own directory.
+func runRerun(args []string) error {
+	flags := flag.NewFlagSet("rerun", flag.ContinueOnError)
+	attempts := flags.Int("attempts", defaultRerunAttempts, "number of reruns of every failed test")
+	reportDir := flags.String("report", os.Getenv("VTCP_REPORT_DIR"), "report directory (default reports, or VTCP_REPORT_DIR)")
+	historyPath := flags.String("history", defaultHistoryFile, "file with the flakiness history of the tests")
+	run := flags.String("run", "", "run only the tests matching the regexp (go test -run)")
+	timeout := flags.String("timeout", "", "timeout of every go test invocation (go test -timeout)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *attempts < 1 {
+		return fmt.Errorf("-attempts must be at least 1")
+	}
+	if *reportDir == "" {
+		*reportDir = "reports"
+	}
+	// go test runs every package in its own directory, relative paths would end up there.
+	absReportDir, err := filepath.Abs(*reportDir)
+	if err != nil {
+		return err
+	}
+	packages := flags.Args()
+	if len(packages) == 0 {
+		packages = []string{defaultTestPackages}
+	}
+
+	var goTestArgs []string
+	if *timeout != "" {
+		goTestArgs = append(goTestArgs, "-timeout", *timeout)
+	}
+
+	fmt.Printf("Running %s\n", strings.Join(packages, " "))
+	initialArgs := append([]string(nil), goTestArgs...)
+	if *run != "" {
+		initialArgs = append(initialArgs, "-run", *run)
+	}
+	initialRun, err := goTest(absReportDir, append(initialArgs, packages...)...)
+	if err != nil {
+		return err
+	}
+	results, brokenPackages := initialRun.results, initialRun.brokenPackages
+
+	flakiness := make(map[string]map[string]*vtcp.TestFlakiness) // subsystem -> test -> flakiness
+	for _, result := range results {
+		testFlakiness := &vtcp.TestFlakiness{
+			Attempts: []vtcp.TestAttempt{{Status: result.Status, DurationSec: result.DurationSec}},
+		}
+		if result.Status == vtcp.TestStatusFailed {
+			for attempt := 1; attempt <= *attempts; attempt++ {
+				fmt.Printf("Rerunning %s (attempt %d of %d)\n", result.Name, attempt, *attempts)
+				testAttempt, err := rerunTest(absReportDir, goTestArgs, result, attempt)
+				if err != nil {
+					return err
+				}
+				testFlakiness.Attempts = append(testFlakiness.Attempts, testAttempt)
+			}
+		}
+		testFlakiness.Classification = vtcp.ClassifyAttempts(testFlakiness.Attempts)
+
+		if flakiness[result.subsystem()] == nil {
+			flakiness[result.subsystem()] = make(map[string]*vtcp.TestFlakiness)
+		}
+		flakiness[result.subsystem()][result.Name] = testFlakiness
+	}
+
+	for subsystem, tests := range flakiness {
+		if err := vtcp.AddFlakinessToReport(absReportDir, subsystem, tests); err != nil {
+			return err
+		}
+	}
+
+	history, err := vtcp.LoadFlakinessHistory(*historyPath)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	historyEntries := make(map[testResult]*vtcp.FlakinessHistoryEntry)
+	for _, result := range results {
+		if result.Status == vtcp.TestStatusSkipped {
+			continue
+		}
+		classification := flakiness[result.subsystem()][result.Name].Classification
+		historyEntries[result] = history.Record(result.subsystem(), result.Name, classification, now)
+	}
+	if err := history.Save(*historyPath); err != nil {
+		return err
+	}
+
+	failing := printFlakiness(results, flakiness, historyEntries)
+	for _, pkg := range brokenPackages {
+		fmt.Fprintf(os.Stderr, "Package %s failed outside of tests (build error or setup failure)\n", pkg)
+	}
+	if len(brokenPackages) > 0 {
+		return fmt.Errorf("%d package(s) failed outside of tests", len(brokenPackages))
+	}
+	if failing > 0 {
+		return fmt.Errorf("%d test(s) failed at every attempt", failing)
+	}
+	return nil
+}
+
+// rerunTest runs the single test again with its own report directory.
+func rerunTest(reportDir string, goTestArgs []string, result testResult, attempt int) (vtcp.TestAttempt, error) {
+	relativeDir := filepath.Join(rerunReportDirName, result.subsystem(), result.Name, fmt.Sprintf("attempt-%d", attempt))
+	args := append(append([]string(nil), goTestArgs...), "-run", "^"+regexp.QuoteMeta(result.Name)+"$", result.Package)
+	run, err := goTest(filepath.Join(reportDir, relativeDir), args...)
+	if err != nil {
+		return vtcp.TestAttempt{}, err
+	}
+
+	// A test that didn't report a result (e.g. the package failed to start) failed.
+	testAttempt := vtcp.TestAttempt{Status: vtcp.TestStatusFailed, ReportDir: relativeDir}
+	for _, rerun := range run.results {
+		if rerun.Name == result.Name {
+			testAttempt.Status = rerun.Status
+			testAttempt.DurationSec = rerun.DurationSec
+		}
+	}
+	return testAttempt, nil
+}
+
+// goTest runs go test -json with the report directory set, passes the test output through and returns the results
+// of the top-level tests. The output of the failed tests is added to their entries of the reports.
+func goTest(reportDir string, args ...string) (*goTestRun, error) {
+	cmd := exec.Command("go", append([]string{"test", "-json", "-count=1"}, args...)...)
+	cmd.Env = append(os.Environ(), "VTCP_REPORT_DIR="+reportDir)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to run go test: %w", err)
+	}
+
+	run := &goTestRun{}
+	failedTests := make(map[string]bool)
+	var failedPackages []string
+	output := make(map[string]map[string][]string)        // subsystem -> test -> output lines
+	failureOutput := make(map[string]map[string][]string) // the same, of the failed tests
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxTestEventLineLength)
+	for scanner.Scan() {
+		var event testEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			fmt.Println(scanner.Text())
+			continue
+		}
+		subsystem := path.Base(event.Package)
+		switch event.Action {
+		case "output":
+			fmt.Print(event.Output)
+			if event.Test != "" {
+				if output[subsystem] == nil {
+					output[subsystem] = make(map[string][]string)
+				}
+				// The output of a subtest belongs to its top-level test as well.
+				topLevel, _, _ := strings.Cut(event.Test, "/")
+				for _, name := range uniqueNames(event.Test, topLevel) {
+					output[subsystem][name] = appendOutputLine(output[subsystem][name], event.Output)
+				}
+			}
+		case "pass", "fail", "skip":
+			if event.Test == "" {
+				if event.Action == "fail" {
+					failedPackages = append(failedPackages, event.Package)
+				}
+				continue
+			}
+			if event.Action == "fail" {
+				if failureOutput[subsystem] == nil {
+					failureOutput[subsystem] = make(map[string][]string)
+				}
+				failureOutput[subsystem][event.Test] = output[subsystem][event.Test]
+			}
+			delete(output[subsystem], event.Test)
+			if strings.Contains(event.Test, "/") {
+				continue
+			}
+			status := map[string]string{"pass": vtcp.TestStatusPassed, "fail": vtcp.TestStatusFailed, "skip": vtcp.TestStatusSkipped}[event.Action]
+			run.results = append(run.results, testResult{Package: event.Package, Name: event.Test, Status: status, DurationSec: event.Elapsed})
+			if status == vtcp.TestStatusFailed {
+				failedTests[event.Package] = true
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, fmt.Errorf("failed to read go test output: %w", err)
+	}
+	// go test exits with an error when tests fail, the results tell that already.
+	if err := cmd.Wait(); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return nil, fmt.Errorf("go test failed: %w", err)
+		}
+	}
+
+	for _, pkg := range failedPackages {
+		if !failedTests[pkg] {
+			run.brokenPackages = append(run.brokenPackages, pkg)
+		}
+	}
+	for subsystem, tests := range failureOutput {
+		if err := vtcp.AddFailureOutputToReport(reportDir, subsystem, tests); err != nil {
+			return nil, err
+		}
+	}
+	return run, nil
+}
+
+func uniqueNames(name, topLevel string) []string {
+	if name == topLevel {
+		return []string{name}
+	}
+	return []string{name, topLevel}
+}
+
+// appendOutputLine adds a line of the test output, without the framework's own lines (=== RUN, --- FAIL, ...).
+func appendOutputLine(lines []string, line string) []string {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "=== ") || strings.HasPrefix(trimmed, "--- ") {
+		return lines
+	}
+	return append(lines, trimmed)
+}
+
+// printFlakiness prints the classification of every test that was not skipped and returns the number of
+// consistently failing tests.
+func printFlakiness(results []testResult, flakiness map[string]map[string]*vtcp.TestFlakiness,
+	history map[testResult]*vtcp.FlakinessHistoryEntry) int {
+	sorted := append([]testResult(nil), results...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Package != sorted[j].Package {
+			return sorted[i].Package < sorted[j].Package
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	failing := 0
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "SUBSYSTEM\tTEST\tCLASSIFICATION\tATTEMPTS\tHISTORY")
+	for _, result := range sorted {
+		entry, ok := history[result]
+		if !ok {
+			continue
+		}
+		testFlakiness := flakiness[result.subsystem()][result.Name]
+		if testFlakiness.Classification == vtcp.FlakinessFailing {
+			failing++
+		}
+		statuses := make([]string, 0, len(testFlakiness.Attempts))
+		for _, attempt := range testFlakiness.Attempts {
+			statuses = append(statuses, attempt.Status)
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\tflaky in %d of %d runs (%.0f%%)\n", result.subsystem(), result.Name,
+			testFlakiness.Classification, strings.Join(statuses, ","), entry.Flaky, entry.Runs, 100*entry.FlakyRate())
+	}
+	writer.Flush()
+	return failing
+}
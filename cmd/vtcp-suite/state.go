@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/viper"
+
+	vtcp "github.com/vTCP-Foundation/vtcpd-test-suite/pkg/testsuite"
+)
+
+// Topology describes the cluster to bring up. Keys follow tests/conf.yaml naming.
+type Topology struct {
+	NodeImageName   string                   `mapstructure:"nodeImageName"`
+	NetworkName     string                   `mapstructure:"networkName"`
+	Valgrind        bool                     `mapstructure:"valgrind"`
+	Nodes           []TopologyNode           `mapstructure:"nodes"`
+	SettlementLines []TopologySettlementLine `mapstructure:"settlementLines"`
+}
+
+type TopologyNode struct {
+	Alias string `mapstructure:"alias"`
+	IP    string `mapstructure:"ip"`
+}
+
+// TopologySettlementLine is a settlement line opened by From towards To
+// (the same as From.CreateChannelAndSettlementLineAndCheck(To, ...) in tests).
+type TopologySettlementLine struct {
+	From       string `mapstructure:"from"`
+	To         string `mapstructure:"to"`
+	Equivalent string `mapstructure:"equivalent"`
+	Amount     string `mapstructure:"amount"`
+}
+
+func loadTopology(path string) (*Topology, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read topology file %s: %w", path, err)
+	}
+
+	var topology Topology
+	if err := v.Unmarshal(&topology); err != nil {
+		return nil, fmt.Errorf("failed to parse topology file %s: %w", path, err)
+	}
+
+	if topology.NodeImageName == "" || topology.NetworkName == "" {
+		return nil, fmt.Errorf("topology file %s: nodeImageName and networkName are required", path)
+	}
+	if len(topology.Nodes) == 0 {
+		return nil, fmt.Errorf("topology file %s: no nodes defined", path)
+	}
+
+	aliases := make(map[string]bool)
+	for _, node := range topology.Nodes {
+		if node.Alias == "" || node.IP == "" {
+			return nil, fmt.Errorf("topology file %s: every node must have alias and ip", path)
+		}
+		if aliases[node.Alias] {
+			return nil, fmt.Errorf("topology file %s: duplicate node alias %s", path, node.Alias)
+		}
+		aliases[node.Alias] = true
+	}
+	for _, line := range topology.SettlementLines {
+		if !aliases[line.From] || !aliases[line.To] {
+			return nil, fmt.Errorf("topology file %s: settlement line %s -> %s refers to unknown node", path, line.From, line.To)
+		}
+	}
+
+	return &topology, nil
+}
+
+// clusterState is persisted between invocations.
+// Sudo password is intentionally not stored: it is read from VTCP_SUDO_PASSWORD when needed.
+type clusterState struct {
+	NodeImageName string      `json:"node_image_name"`
+	NetworkName   string      `json:"network_name"`
+	Nodes         []stateNode `json:"nodes"`
+}
+
+type stateNode struct {
+	Alias       string `json:"alias"`
+	IPAddress   string `json:"ip_address"`
+	ContainerID string `json:"container_id"`
+}
+
+func loadState(path string) (*clusterState, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no running cluster found (state file %s does not exist), run 'up' first", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file %s: %w", path, err)
+	}
+
+	var state clusterState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+func saveState(path string, state *clusterState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write state file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (s *clusterState) settings() *vtcp.ClusterSettings {
+	return &vtcp.ClusterSettings{
+		NodeImageName: s.NodeImageName,
+		NetworkName:   s.NetworkName,
+		SudoPassword:  os.Getenv("VTCP_SUDO_PASSWORD"),
+	}
+}
+
+func (s *clusterState) cluster() (*vtcp.Cluster, error) {
+	return vtcp.NewDetachedCluster(context.Background(), s.settings())
+}
+
+// nodes restores the nodes of the running cluster.
+func (s *clusterState) nodes() []*vtcp.Node {
+	nodes := make([]*vtcp.Node, 0, len(s.Nodes))
+	for _, stored := range s.Nodes {
+		node := vtcp.NewNode(nil, stored.IPAddress, stored.Alias)
+		node.ContainerID = stored.ContainerID
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+func (s *clusterState) node(alias string) (*vtcp.Node, error) {
+	for _, node := range s.nodes() {
+		if node.Alias == alias {
+			return node, nil
+		}
+	}
+	return nil, fmt.Errorf("node %s is not part of the running cluster", alias)
+}
@@ -1,7 +1,15 @@
 package conf
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -11,45 +19,205 @@ type ClusterSettings struct {
 	NodeImageName string `yaml:"nodeImageName"`
 	NetworkName   string `yaml:"networkName"`
 	SudoPassword  string `yaml:"sudoPassword"`
+	ReportDir     string `yaml:"reportDir"`
+	// PreviousNodeImageName is the image of the previous vtcpd release, used by the upgrade tests.
+	PreviousNodeImageName string `yaml:"previousNodeImageName"`
+	// DatabaseConfig is VTCPD_DATABASE_CONFIG passed to the nodes, the image default (SQLite) if empty.
+	DatabaseConfig string `yaml:"databaseConfig"`
+	// StepTimeout is the deadline of every node API call and docker operation, e.g. "30s".
+	StepTimeout time.Duration `yaml:"stepTimeout"`
 }
 
 const (
-	configFileName = "conf"
-	configFileType = "yaml"
+	defaultConfigPath = "../conf.yaml" // relative to the test package directory
+	configFileType    = "yaml"
+
+	// configPathFlag and configPathEnv select the config file explicitly. Unlike the default one,
+	// an explicitly selected file must exist.
+	configPathFlag = "vtcp.config"
+	configPathEnv  = "VTCP_CONFIG"
+
+	flagPrefix = "vtcp."
 )
 
+// setting describes a configuration key on every layer.
+type setting struct {
+	key          string   // key in the config file
+	env          []string // environment variables, the first one set wins
+	flag         string   // go test flag, without the "vtcp." prefix
+	defaultValue string
+	required     bool
+	usage        string
+}
+
+// settings are applied in order of precedence (lowest to highest):
+// defaults, the config file, VTCP_* environment variables, go test flags (go test ./tests/... -args -vtcp.image=...).
+var settings = []setting{
+	{key: "nodeImageName", env: []string{"VTCP_NODE_IMAGE"}, flag: "image", required: true,
+		usage: "docker image of the nodes"},
+	{key: "networkName", env: []string{"VTCP_NETWORK_NAME"}, flag: "network", defaultValue: "vtcpd-test-network", required: true,
+		usage: "docker network of the cluster"},
+	{key: "sudoPassword", env: []string{"VTCP_SUDO_PASSWORD"}, flag: "sudo-password",
+		usage: "sudo password for network configuration commands (tc, ip)"},
+	{key: "reportDir", env: []string{"VTCP_REPORT_DIR"}, flag: "report-dir",
+		usage: "directory for JUnit XML / JSON reports and node artifacts"},
+	{key: "previousNodeImageName", env: []string{"VTCP_PREVIOUS_NODE_IMAGE"}, flag: "previous-image",
+		usage: "docker image of the previous vtcpd release, for the upgrade tests"},
+	{key: "databaseConfig", env: []string{"VTCP_DATABASE_CONFIG", "VTCPD_DATABASE_CONFIG"}, flag: "database-config",
+		usage: "database config of the nodes (sqlite3:///io, postgresql://...)"},
+	{key: "stepTimeout", env: []string{"VTCP_STEP_TIMEOUT"}, flag: "step-timeout",
+		usage: "deadline of every node API call and docker operation, e.g. 30s (default 60s)"},
+}
+
 var (
+	loadOnce sync.Once
 	// effectiveConfig holds the final configuration after loading.
 	effectiveConfig ClusterSettings
+	loadErr         error
 )
 
-// init is automatically called once when the package is imported.
-// It loads configurations using Viper with the following order of precedence (highest to lowest):
+// init registers the go test flags, so that the test binary accepts them.
+// Their values are read from the command line by Load, tests read the configuration before flags are parsed.
 func init() {
-	v := viper.New()
+	flag.String(configPathFlag, "", "path to the test suite config file (default "+defaultConfigPath+")")
+	for _, s := range settings {
+		flag.String(flagPrefix+s.flag, "", s.usage)
+	}
+}
+
+// Load builds the configuration from the layers, args are the command line arguments (without the program name).
+// Unknown keys in the config file and missing required values are errors.
+func Load(args []string) (ClusterSettings, error) {
+	flags, err := parseFlags(args)
+	if err != nil {
+		return ClusterSettings{}, err
+	}
 
-	// Configure to read from a configuration file
-	v.SetConfigName(configFileName)
+	v := viper.New()
 	v.SetConfigType(configFileType)
-	v.AddConfigPath("../") // Look for config in the current directory
+	for _, s := range settings {
+		if s.defaultValue != "" {
+			v.SetDefault(s.key, s.defaultValue)
+		}
+	}
 
-	// Attempt to read the configuration file
+	configPath, explicit := flags[configPathFlag], true
+	if configPath == "" {
+		configPath = os.Getenv(configPathEnv)
+	}
+	if configPath == "" {
+		configPath, explicit = defaultConfigPath, false
+	}
+	v.SetConfigFile(configPath)
 	if err := v.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			log.Printf("INFO: Configuration file (%s.%s) not found.", configFileName, configFileType)
-		} else {
-			log.Printf("WARNING: Error reading configuration file: %v. ", err)
+		var pathErr *os.PathError
+		if explicit || !errors.As(err, &pathErr) {
+			return ClusterSettings{}, fmt.Errorf("failed to read config file %s: %w", configPath, err)
 		}
+		log.Printf("INFO: Configuration file %s not found, using defaults, environment and flags.", configPath)
 	} else {
-		log.Printf("INFO: Successfully loaded configuration from %s.%s", configFileName, configFileType)
+		log.Printf("INFO: Successfully loaded configuration from %s", configPath)
+		if err := checkUnknownKeys(v.AllKeys(), configPath); err != nil {
+			return ClusterSettings{}, err
+		}
+	}
+
+	for _, s := range settings {
+		v.BindEnv(append([]string{s.key}, s.env...)...)
+		if value, ok := flags[flagPrefix+s.flag]; ok {
+			v.Set(s.key, value)
+		}
+	}
+
+	var config ClusterSettings
+	if err := v.Unmarshal(&config); err != nil {
+		return ClusterSettings{}, fmt.Errorf("failed to parse configuration: %w", err)
 	}
 
-	if err := v.Unmarshal(&effectiveConfig); err != nil {
-		log.Printf("ERROR: Failed to unmarshal config: %v", err)
+	var missing []string
+	for _, s := range settings {
+		if s.required && v.GetString(s.key) == "" {
+			missing = append(missing, fmt.Sprintf("%s (env %s, flag -%s%s)",
+				s.key, strings.Join(s.env, "/"), flagPrefix, s.flag))
+		}
+	}
+	if len(missing) > 0 {
+		return ClusterSettings{}, fmt.Errorf("missing required settings: %s", strings.Join(missing, "; "))
+	}
+	return config, nil
+}
+
+// checkUnknownKeys reports keys of the config file that don't match any setting, typos included.
+// Viper lowercases the keys.
+func checkUnknownKeys(keys []string, configPath string) error {
+	known := make(map[string]bool, len(settings))
+	for _, s := range settings {
+		known[strings.ToLower(s.key)] = true
+	}
+	var unknown []string
+	for _, key := range keys {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	return fmt.Errorf("unknown keys in config file %s: %s (known keys: %s)",
+		configPath, strings.Join(unknown, ", "), strings.Join(settingKeys(), ", "))
+}
+
+func settingKeys() []string {
+	keys := make([]string, 0, len(settings))
+	for _, s := range settings {
+		keys = append(keys, s.key)
+	}
+	return keys
+}
+
+// parseFlags picks the -vtcp.* flags from the command line ("-vtcp.image=x", "--vtcp.image x"),
+// other flags belong to the test binary and are skipped.
+func parseFlags(args []string) (map[string]string, error) {
+	flags := make(map[string]string)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+		name := strings.TrimLeft(arg, "-")
+		if name == arg || !strings.HasPrefix(name, flagPrefix) {
+			continue
+		}
+		name, value, hasValue := strings.Cut(name, "=")
+		if !hasValue {
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("flag -%s needs a value", name)
+			}
+			i++
+			value = args[i]
+		}
+		if name != configPathFlag && !isSettingFlag(name) {
+			return nil, fmt.Errorf("unknown flag -%s", name)
+		}
+		flags[name] = value
+	}
+	return flags, nil
+}
+
+func isSettingFlag(name string) bool {
+	for _, s := range settings {
+		if flagPrefix+s.flag == name {
+			return true
+		}
 	}
+	return false
 }
 
-// GetConfig returns the loaded and processed cluster configuration.
-func GetConfig() ClusterSettings {
-	return effectiveConfig
+// GetConfig returns the configuration built from the command line of the process, it is loaded once.
+func GetConfig() (ClusterSettings, error) {
+	loadOnce.Do(func() {
+		effectiveConfig, loadErr = Load(os.Args[1:])
+	})
+	return effectiveConfig, loadErr
 }
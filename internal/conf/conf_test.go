@@ -0,0 +1,208 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv unsets the environment of every setting, so that the tests don't depend on the caller's environment.
+func clearEnv(t *testing.T) {
+	t.Setenv(configPathEnv, "")
+	for _, s := range settings {
+		for _, env := range s.env {
+			t.Setenv(env, "")
+		}
+	}
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "conf.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadLayers(t *testing.T) {
+	configPath := writeConfigFile(t, `
+nodeImageName: image-from-file
+networkName: network-from-file
+sudoPassword: password-from-file
+stepTimeout: 30s
+`)
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want ClusterSettings
+	}{
+		{
+			name: "defaults and environment",
+			env:  map[string]string{"VTCP_NODE_IMAGE": "image-from-env"},
+			want: ClusterSettings{NodeImageName: "image-from-env", NetworkName: "vtcpd-test-network"},
+		},
+		{
+			name: "config file overrides defaults",
+			args: []string{"-vtcp.config=" + configPath},
+			want: ClusterSettings{NodeImageName: "image-from-file", NetworkName: "network-from-file",
+				SudoPassword: "password-from-file", StepTimeout: 30 * time.Second},
+		},
+		{
+			name: "environment overrides the config file",
+			env:  map[string]string{"VTCP_NETWORK_NAME": "network-from-env", "VTCP_STEP_TIMEOUT": "45s"},
+			args: []string{"-vtcp.config", configPath},
+			want: ClusterSettings{NodeImageName: "image-from-file", NetworkName: "network-from-env",
+				SudoPassword: "password-from-file", StepTimeout: 45 * time.Second},
+		},
+		{
+			name: "flags override the environment",
+			env:  map[string]string{"VTCP_CONFIG": configPath, "VTCP_NETWORK_NAME": "network-from-env"},
+			args: []string{"-test.v", "-vtcp.network=network-from-flag", "--vtcp.image", "image-from-flag"},
+			want: ClusterSettings{NodeImageName: "image-from-flag", NetworkName: "network-from-flag",
+				SudoPassword: "password-from-file", StepTimeout: 30 * time.Second},
+		},
+		{
+			name: "legacy database environment variable",
+			env:  map[string]string{"VTCP_NODE_IMAGE": "image-from-env", "VTCPD_DATABASE_CONFIG": "sqlite3:///io"},
+			want: ClusterSettings{NodeImageName: "image-from-env", NetworkName: "vtcpd-test-network",
+				DatabaseConfig: "sqlite3:///io"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clearEnv(t)
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			got, err := Load(test.args)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("settings mismatch.\nExpected: %+v\nGot:      %+v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		env     map[string]string
+		args    []string
+		wantErr string
+	}{
+		{
+			name:    "missing required setting",
+			config:  "networkName: network\n",
+			wantErr: "missing required settings: nodeImageName",
+		},
+		{
+			name:    "missing explicit config file",
+			args:    []string{"-vtcp.config=" + filepath.Join(os.TempDir(), "vtcp-suite-missing", "conf.yaml")},
+			wantErr: "failed to read config file",
+		},
+		{
+			name:    "unknown flag",
+			env:     map[string]string{"VTCP_NODE_IMAGE": "image"},
+			args:    []string{"-vtcp.imag=image"},
+			wantErr: "unknown flag -vtcp.imag",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clearEnv(t)
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			args := test.args
+			if test.config != "" {
+				args = append(args, "-vtcp.config="+writeConfigFile(t, test.config))
+			}
+			_, err := Load(args)
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("expected an error containing %q, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadReportsEveryUnknownKey(t *testing.T) {
+	clearEnv(t)
+	configPath := writeConfigFile(t, "nodeImageName: image\nnodeImage: typo\nreportdirectory: reports\n")
+	_, err := Load([]string{"-vtcp.config=" + configPath})
+	if err == nil || !strings.Contains(err.Error(), "unknown keys in config file") {
+		t.Fatalf("expected an unknown keys error, got %v", err)
+	}
+	for _, key := range []string{"nodeimage", "reportdirectory"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("expected the error to mention %q: %v", key, err)
+		}
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "forms of the flags",
+			args: []string{"-vtcp.image=a", "--vtcp.network", "b", "-vtcp.config=c"},
+			want: map[string]string{"vtcp.image": "a", "vtcp.network": "b", "vtcp.config": "c"},
+		},
+		{
+			name: "flags of the test binary are skipped",
+			args: []string{"-test.v=true", "-test.run", "TestX", "-vtcp.report-dir=reports"},
+			want: map[string]string{"vtcp.report-dir": "reports"},
+		},
+		{
+			name: "arguments after -- are not flags",
+			args: []string{"-vtcp.image=a", "--", "-vtcp.network=b"},
+			want: map[string]string{"vtcp.image": "a"},
+		},
+		{
+			name: "the last value wins",
+			args: []string{"-vtcp.image=a", "-vtcp.image=b"},
+			want: map[string]string{"vtcp.image": "b"},
+		},
+		{
+			name:    "missing value",
+			args:    []string{"-vtcp.image"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown flag",
+			args:    []string{"-vtcp.images=a"},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseFlags(test.args)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("flags mismatch.\nExpected: %v\nGot:      %v", test.want, got)
+			}
+		})
+	}
+}
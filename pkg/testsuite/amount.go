@@ -0,0 +1,139 @@
+package testsuite
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Amount is an arbitrary-precision integer amount (balance, max flow, commission, etc.).
+// vtcpd amounts don't fit into int64, so they are kept as big integers.
+// Amounts are immutable, arithmetic returns a new value. The zero value is 0.
+type Amount struct {
+	value *big.Int
+}
+
+// NewAmount returns the amount equal to the value.
+func NewAmount(value int64) Amount {
+	return Amount{value: big.NewInt(value)}
+}
+
+// ParseAmount parses a decimal integer, e.g. "1000" or "-500".
+func ParseAmount(s string) (Amount, error) {
+	value, ok := new(big.Int).SetString(strings.TrimSpace(s), 10)
+	if !ok {
+		return Amount{}, fmt.Errorf("invalid amount %q", s)
+	}
+	return Amount{value: value}, nil
+}
+
+// MustParseAmount is ParseAmount for literals, it panics if s is not an amount.
+func MustParseAmount(s string) Amount {
+	amount, err := ParseAmount(s)
+	if err != nil {
+		panic(err)
+	}
+	return amount
+}
+
+func (a Amount) big() *big.Int {
+	if a.value == nil {
+		return new(big.Int)
+	}
+	return a.value
+}
+
+// BigInt returns a copy of the amount as big.Int.
+func (a Amount) BigInt() *big.Int {
+	return new(big.Int).Set(a.big())
+}
+
+func (a Amount) Add(b Amount) Amount {
+	return Amount{value: new(big.Int).Add(a.big(), b.big())}
+}
+
+func (a Amount) Sub(b Amount) Amount {
+	return Amount{value: new(big.Int).Sub(a.big(), b.big())}
+}
+
+func (a Amount) Mul(b Amount) Amount {
+	return Amount{value: new(big.Int).Mul(a.big(), b.big())}
+}
+
+// Quo returns a / b truncated towards zero. It panics if b is zero.
+func (a Amount) Quo(b Amount) Amount {
+	return Amount{value: new(big.Int).Quo(a.big(), b.big())}
+}
+
+func (a Amount) Neg() Amount {
+	return Amount{value: new(big.Int).Neg(a.big())}
+}
+
+func (a Amount) Abs() Amount {
+	return Amount{value: new(big.Int).Abs(a.big())}
+}
+
+// Cmp returns -1, 0 or +1 if a is less than, equal to or greater than b.
+func (a Amount) Cmp(b Amount) int {
+	return a.big().Cmp(b.big())
+}
+
+func (a Amount) Equal(b Amount) bool {
+	return a.Cmp(b) == 0
+}
+
+func (a Amount) LessThan(b Amount) bool {
+	return a.Cmp(b) < 0
+}
+
+func (a Amount) GreaterThan(b Amount) bool {
+	return a.Cmp(b) > 0
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of the amount.
+func (a Amount) Sign() int {
+	return a.big().Sign()
+}
+
+func (a Amount) IsZero() bool {
+	return a.Sign() == 0
+}
+
+func (a Amount) String() string {
+	return a.big().String()
+}
+
+// MarshalJSON writes the amount as a string, the way vtcpd-cli returns amounts.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// UnmarshalJSON accepts both a string and a number.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*a = Amount{}
+		return nil
+	}
+	text := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+	}
+	amount, err := ParseAmount(text)
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
+
+// amountsEqual compares an expected amount written as a string with the actual one.
+// An expectation that is not a number never matches.
+func amountsEqual(expected string, actual Amount) bool {
+	expectedAmount, err := ParseAmount(expected)
+	return err == nil && expectedAmount.Equal(actual)
+}
@@ -0,0 +1,161 @@
+package testsuite
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{input: "0", expected: "0"},
+		{input: "1000", expected: "1000"},
+		{input: "-500", expected: "-500"},
+		{input: " 42\n", expected: "42"},
+		// Beyond uint64
+		{input: "340282366920938463463374607431768211456", expected: "340282366920938463463374607431768211456"},
+		{input: "", wantErr: true},
+		{input: "abc", wantErr: true},
+		{input: "1.5", wantErr: true},
+		{input: "1e5", wantErr: true},
+		{input: "0x10", wantErr: true},
+	}
+
+	for _, test := range tests {
+		amount, err := ParseAmount(test.input)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %s", test.input, amount)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.input, err)
+			continue
+		}
+		if amount.String() != test.expected {
+			t.Errorf("%q: amount mismatch.\nExpected: %s\nGot:      %s", test.input, test.expected, amount)
+		}
+	}
+}
+
+func TestMustParseAmountPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic on an invalid amount")
+		}
+	}()
+	MustParseAmount("ten")
+}
+
+func TestAmountUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected string
+		wantErr  bool
+	}{
+		{name: "string", data: `{"amount": "1000"}`, expected: "1000"},
+		{name: "number", data: `{"amount": 1000}`, expected: "1000"},
+		{name: "negative number", data: `{"amount": -7}`, expected: "-7"},
+		{name: "large number", data: `{"amount": 18446744073709551616}`, expected: "18446744073709551616"},
+		{name: "null", data: `{"amount": null}`, expected: "0"},
+		{name: "missing", data: `{}`, expected: "0"},
+		{name: "invalid string", data: `{"amount": "abc"}`, wantErr: true},
+		{name: "empty string", data: `{"amount": ""}`, wantErr: true},
+		{name: "fractional number", data: `{"amount": 1.5}`, wantErr: true},
+		{name: "bool", data: `{"amount": true}`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var result struct {
+				Amount Amount `json:"amount"`
+			}
+			err := json.Unmarshal([]byte(test.data), &result)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", result.Amount)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Amount.String() != test.expected {
+				t.Errorf("amount mismatch.\nExpected: %s\nGot:      %s", test.expected, result.Amount)
+			}
+		})
+	}
+}
+
+func TestAmountMarshalJSON(t *testing.T) {
+	data, err := json.Marshal(struct {
+		Amount Amount  `json:"amount"`
+		Limit  *Amount `json:"limit"`
+	}{Amount: NewAmount(-12)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := `{"amount":"-12","limit":null}`; string(data) != expected {
+		t.Errorf("JSON mismatch.\nExpected: %s\nGot:      %s", expected, data)
+	}
+}
+
+func TestAmountArithmetic(t *testing.T) {
+	a, b := NewAmount(7), NewAmount(-3)
+	var zero Amount
+
+	tests := []struct {
+		name     string
+		result   Amount
+		expected string
+	}{
+		{name: "add", result: a.Add(b), expected: "4"},
+		{name: "sub", result: a.Sub(b), expected: "10"},
+		{name: "mul", result: a.Mul(b), expected: "-21"},
+		{name: "quo truncates towards zero", result: a.Quo(b), expected: "-2"},
+		{name: "neg", result: b.Neg(), expected: "3"},
+		{name: "abs", result: b.Abs(), expected: "3"},
+		{name: "zero value", result: zero.Add(a), expected: "7"},
+		{name: "beyond int64", result: MustParseAmount("9223372036854775807").Add(NewAmount(1)), expected: "9223372036854775808"},
+	}
+
+	for _, test := range tests {
+		if test.result.String() != test.expected {
+			t.Errorf("%s: result mismatch.\nExpected: %s\nGot:      %s", test.name, test.expected, test.result)
+		}
+	}
+
+	if a.String() != "7" || b.String() != "-3" {
+		t.Errorf("arithmetic changed the operands: %s, %s", a, b)
+	}
+}
+
+func TestAmountComparison(t *testing.T) {
+	var zero Amount
+	small, large := NewAmount(-1), NewAmount(10)
+
+	if small.Cmp(large) != -1 || large.Cmp(small) != 1 || large.Cmp(NewAmount(10)) != 0 {
+		t.Errorf("Cmp mismatch for %s and %s", small, large)
+	}
+	if !small.LessThan(large) || small.GreaterThan(large) || !large.Equal(MustParseAmount("10")) {
+		t.Errorf("comparison mismatch for %s and %s", small, large)
+	}
+	if !zero.IsZero() || !zero.Equal(NewAmount(0)) || zero.Sign() != 0 || zero.String() != "0" {
+		t.Errorf("zero value is not 0: %s", zero)
+	}
+	if small.Sign() != -1 || large.Sign() != 1 {
+		t.Errorf("Sign mismatch for %s and %s", small, large)
+	}
+	large.BigInt().SetInt64(0)
+	if large.String() != "10" {
+		t.Errorf("BigInt doesn't return a copy: %s", large)
+	}
+	if !amountsEqual("10", large) || amountsEqual("abc", zero) {
+		t.Errorf("amountsEqual mismatch")
+	}
+}
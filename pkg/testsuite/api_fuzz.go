@@ -0,0 +1,471 @@
+package testsuite
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"text/tabwriter"
+	"time"
+)
+
+// vtcpd-cli API fuzzing.
+//
+// Requests are built from the known endpoints with valid values, one parameter or the path mutated at a time:
+// malformed addresses, amounts, equivalents, channel IDs and crypto keys, unicode and overlong input.
+// After every request the node must still be alive (vtcpd running and the API answering), and the request must
+// get a defined 4xx status. Mutations that a parser may reasonably accept are lenient: any status but 5xx.
+
+// APIParamKind is the kind of value a parameter takes, it selects the mutations applied to it.
+type APIParamKind string
+
+const (
+	APIParamAddress    APIParamKind = "contractor_address"
+	APIParamAmount     APIParamKind = "amount"
+	APIParamEquivalent APIParamKind = "equivalent"
+	APIParamChannelID  APIParamKind = "channel_id"
+	APIParamCryptoKey  APIParamKind = "crypto_key"
+	APIParamCount      APIParamKind = "count" // offsets, page sizes and timestamps
+)
+
+// APIParam is a path or query parameter of an endpoint.
+type APIParam struct {
+	Name string
+	Kind APIParamKind
+	// InPath parameters fill the {Name} placeholder of the endpoint path.
+	InPath bool
+	// Optional query parameters are only sent when mutated.
+	Optional bool
+}
+
+// APIEndpoint is an endpoint of the vtcpd-cli API.
+type APIEndpoint struct {
+	Name   string
+	Method string
+	Path   string // with {name} placeholders for the path parameters
+	Params []APIParam
+}
+
+func pathParam(name string, kind APIParamKind) APIParam {
+	return APIParam{Name: name, Kind: kind, InPath: true}
+}
+
+func queryParam(name string, kind APIParamKind) APIParam {
+	return APIParam{Name: name, Kind: kind}
+}
+
+func optionalParam(name string, kind APIParamKind) APIParam {
+	return APIParam{Name: name, Kind: kind, Optional: true}
+}
+
+var historyFilterParams = []APIParam{
+	optionalParam("date_from_unix_timestamp", APIParamCount),
+	optionalParam("date_to_unix_timestamp", APIParamCount),
+	optionalParam("amount_from", APIParamAmount),
+	optionalParam("amount_to", APIParamAmount),
+	optionalParam("contractor_address", APIParamAddress),
+}
+
+// APIEndpoints are the endpoints of the vtcpd-cli API used by the suite.
+var APIEndpoints = []APIEndpoint{
+	{Name: "init channel", Method: http.MethodPost, Path: "/api/v1/node/contractors/init-channel/", Params: []APIParam{
+		queryParam("contractor_address", APIParamAddress), optionalParam("contractor_id", APIParamChannelID),
+		optionalParam("crypto_key", APIParamCryptoKey)}},
+	{Name: "channel", Method: http.MethodGet, Path: "/api/v1/node/channels/{channel_id}/", Params: []APIParam{
+		pathParam("channel_id", APIParamChannelID)}},
+	{Name: "channel by address", Method: http.MethodGet, Path: "/api/v1/node/channel-by-address/", Params: []APIParam{
+		queryParam("contractor_address", APIParamAddress)}},
+	{Name: "contractors", Method: http.MethodGet, Path: "/api/v1/node/contractors/"},
+	{Name: "init settlement line", Method: http.MethodPost, Path: "/api/v1/node/contractors/{channel_id}/init-settlement-line/{equivalent}/",
+		Params: []APIParam{pathParam("channel_id", APIParamChannelID), pathParam("equivalent", APIParamEquivalent)}},
+	{Name: "set settlement line", Method: http.MethodPut, Path: "/api/v1/node/contractors/{channel_id}/settlement-lines/{equivalent}/",
+		Params: []APIParam{pathParam("channel_id", APIParamChannelID), pathParam("equivalent", APIParamEquivalent),
+			queryParam("amount", APIParamAmount)}},
+	{Name: "settlement line by address", Method: http.MethodGet, Path: "/api/v1/node/contractors/settlement-line-by-address/{equivalent}/",
+		Params: []APIParam{pathParam("equivalent", APIParamEquivalent), queryParam("contractor_address", APIParamAddress)}},
+	{Name: "settlement lines", Method: http.MethodGet, Path: "/api/v1/node/contractors/settlement-lines/{equivalent}/",
+		Params: []APIParam{pathParam("equivalent", APIParamEquivalent)}},
+	{Name: "close incoming settlement line", Method: http.MethodDelete, Path: "/api/v1/node/contractors/{channel_id}/close-incoming-settlement-line/{equivalent}/",
+		Params: []APIParam{pathParam("channel_id", APIParamChannelID), pathParam("equivalent", APIParamEquivalent)}},
+	{Name: "keys sharing", Method: http.MethodPut, Path: "/api/v1/node/contractors/{channel_id}/keys-sharing/{equivalent}/",
+		Params: []APIParam{pathParam("channel_id", APIParamChannelID), pathParam("equivalent", APIParamEquivalent)}},
+	{Name: "payment", Method: http.MethodPost, Path: "/api/v1/node/contractors/transactions/{equivalent}/", Params: []APIParam{
+		pathParam("equivalent", APIParamEquivalent), queryParam("contractor_address", APIParamAddress),
+		queryParam("amount", APIParamAmount)}},
+	{Name: "exchange payment", Method: http.MethodPost, Path: "/api/v1/node/contractors/transactions/exchange/{equivalent}/",
+		Params: []APIParam{pathParam("equivalent", APIParamEquivalent), queryParam("contractor_address", APIParamAddress),
+			queryParam("amount", APIParamAmount), queryParam("exchange_equivalent", APIParamEquivalent)}},
+	{Name: "max flow", Method: http.MethodGet, Path: "/api/v1/node/contractors/transactions/max/{equivalent}/", Params: []APIParam{
+		pathParam("equivalent", APIParamEquivalent), queryParam("contractor_address", APIParamAddress)}},
+	{Name: "exchange max flow", Method: http.MethodGet, Path: "/api/v1/node/contractors/transactions/exchange/max/{equivalent}/",
+		Params: []APIParam{pathParam("equivalent", APIParamEquivalent), queryParam("contractor_address", APIParamAddress),
+			queryParam("exchange_equivalent", APIParamEquivalent)}},
+	{Name: "estimate payment", Method: http.MethodGet, Path: "/api/v1/node/contractors/transactions/estimate/payment/{equivalent}/{receiver_equivalent}/",
+		Params: []APIParam{pathParam("equivalent", APIParamEquivalent), pathParam("receiver_equivalent", APIParamEquivalent),
+			queryParam("contractor_address", APIParamAddress), queryParam("receive_amount", APIParamAmount)}},
+	{Name: "estimate receive", Method: http.MethodGet, Path: "/api/v1/node/contractors/transactions/estimate/receive/{equivalent}/{receiver_equivalent}/",
+		Params: []APIParam{pathParam("equivalent", APIParamEquivalent), pathParam("receiver_equivalent", APIParamEquivalent),
+			queryParam("contractor_address", APIParamAddress), queryParam("payment_amount", APIParamAmount)}},
+	{Name: "exchange rate", Method: http.MethodGet, Path: "/api/v1/node/rates/{equivalent}/{receiver_equivalent}/", Params: []APIParam{
+		pathParam("equivalent", APIParamEquivalent), pathParam("receiver_equivalent", APIParamEquivalent)}},
+	{Name: "exchange rates", Method: http.MethodGet, Path: "/api/v1/node/rates/"},
+	{Name: "delete exchange rate", Method: http.MethodDelete, Path: "/api/v1/node/rates/{equivalent}/{receiver_equivalent}/",
+		Params: []APIParam{pathParam("equivalent", APIParamEquivalent), pathParam("receiver_equivalent", APIParamEquivalent)}},
+	{Name: "history payments", Method: http.MethodGet, Path: "/api/v1/node/history/transactions/payments/{offset}/{count}/{equivalent}/",
+		Params: append([]APIParam{pathParam("offset", APIParamCount), pathParam("count", APIParamCount),
+			pathParam("equivalent", APIParamEquivalent)}, historyFilterParams...)},
+	{Name: "history additional payments", Method: http.MethodGet, Path: "/api/v1/node/history/transactions/payments/additional/{offset}/{count}/{equivalent}/",
+		Params: append([]APIParam{pathParam("offset", APIParamCount), pathParam("count", APIParamCount),
+			pathParam("equivalent", APIParamEquivalent)}, historyFilterParams...)},
+	{Name: "history payments all equivalents", Method: http.MethodGet, Path: "/api/v1/node/history/transactions/payments-all/{offset}/{count}/",
+		Params: append([]APIParam{pathParam("offset", APIParamCount), pathParam("count", APIParamCount)}, historyFilterParams...)},
+}
+
+// APIFuzzValues are the valid values of every parameter kind, the parameters that are not mutated take them.
+type APIFuzzValues map[APIParamKind]string
+
+// APIMutation is an invalid value of a parameter.
+type APIMutation struct {
+	Name  string
+	Value string
+	// Lenient mutations may be accepted by a parser, only a 5xx status or a crash is a failure.
+	Lenient bool
+}
+
+var commonMutations = []APIMutation{
+	{Name: "empty", Value: ""},
+	{Name: "space", Value: " "},
+	{Name: "NUL", Value: "\x00"},
+	{Name: "CRLF", Value: "\r\n"},
+	{Name: "percent", Value: "%"},
+	{Name: "dot segments", Value: "../../"},
+	{Name: "quote", Value: "' OR '1'='1"},
+	{Name: "format string", Value: "%s%s%n"},
+	{Name: "latin-1", Value: "\xff\xfe"},
+	{Name: "emoji", Value: "\U0001F4A5"},
+	{Name: "right-to-left override", Value: "‮1"},
+	{Name: "arabic-indic digits", Value: "١٢٣"},
+	{Name: "fullwidth digits", Value: "１２"},
+	{Name: "overlong digits", Value: strings.Repeat("9", 4096)},
+	{Name: "overlong text", Value: strings.Repeat("x", 4096)},
+}
+
+var kindMutations = map[APIParamKind][]APIMutation{
+	APIParamAddress: {
+		{Name: "no address", Value: "12-"},
+		{Name: "no host", Value: "12-:2000"},
+		{Name: "invalid IP", Value: "12-256.1.1.1:2000"},
+		{Name: "port out of range", Value: "12-1.2.3.4:70000"},
+		{Name: "negative port", Value: "12-1.2.3.4:-1"},
+		{Name: "two ports", Value: "12-1.2.3.4:2000:2000"},
+		{Name: "unknown address type", Value: "13-1.2.3.4:2000"},
+		{Name: "no address type", Value: "1.2.3.4:2000"},
+		{Name: "IPv6", Value: "12-::1:2000"},
+	},
+	APIParamAmount: {
+		{Name: "negative", Value: "-1"},
+		{Name: "fraction", Value: "1.5"},
+		{Name: "exponent", Value: "1e3"},
+		{Name: "letters", Value: "abc"},
+		{Name: "2^256", Value: "115792089237316195423570985008687907853269984665640564039457584007913129639936"},
+		{Name: "zero", Value: "0", Lenient: true},
+		{Name: "plus sign", Value: "+1", Lenient: true},
+		{Name: "hex", Value: "0x10", Lenient: true},
+	},
+	APIParamEquivalent: {
+		{Name: "negative", Value: "-1"},
+		{Name: "2^32", Value: "4294967296"},
+		{Name: "fraction", Value: "1.5"},
+		{Name: "letters", Value: "abc"},
+		{Name: "unknown", Value: "99999", Lenient: true},
+		{Name: "hex", Value: "0x10", Lenient: true},
+	},
+	APIParamChannelID: {
+		{Name: "negative", Value: "-1"},
+		{Name: "2^32", Value: "4294967296"},
+		{Name: "fraction", Value: "1.5"},
+		{Name: "letters", Value: "abc"},
+		{Name: "unknown", Value: "999999", Lenient: true},
+	},
+	APIParamCryptoKey: {
+		{Name: "short", Value: "00"},
+		{Name: "odd length", Value: "abc"},
+		{Name: "not hex", Value: "zz"},
+		{Name: "overlong hex", Value: strings.Repeat("0f", 4096)},
+	},
+	APIParamCount: {
+		{Name: "negative", Value: "-1"},
+		{Name: "fraction", Value: "1.5"},
+		{Name: "letters", Value: "abc"},
+		{Name: "2^64", Value: "18446744073709551616"},
+		{Name: "2^32", Value: "4294967296", Lenient: true},
+	},
+}
+
+// APIMutations returns the mutations applied to the parameters of the kind.
+func APIMutations(kind APIParamKind) []APIMutation {
+	return append(append([]APIMutation(nil), kindMutations[kind]...), commonMutations...)
+}
+
+// pathMutations change the structure of the path. Routers differ in how they normalize paths, so they are lenient.
+var pathMutations = []struct {
+	name   string
+	mutate func(path string) string
+}{
+	{"no trailing slash", func(path string) string { return strings.TrimSuffix(path, "/") }},
+	{"double slashes", func(path string) string { return strings.ReplaceAll(path, "/", "//") }},
+	{"extra segment", func(path string) string { return path + "fuzz/" }},
+	{"dot segment", func(path string) string { return path + "../" }},
+	{"upper case", func(path string) string { return strings.ToUpper(path) }},
+}
+
+// APIFuzzRequest is a single mutated request.
+type APIFuzzRequest struct {
+	Endpoint string
+	Mutation string
+	Method   string
+	Path     string // escaped
+	Query    url.Values
+	Lenient  bool
+}
+
+func (r APIFuzzRequest) String() string {
+	if len(r.Query) == 0 {
+		return fmt.Sprintf("%s %s", r.Method, r.Path)
+	}
+	return fmt.Sprintf("%s %s?%s", r.Method, r.Path, r.Query.Encode())
+}
+
+// request builds the request of the endpoint with the valid values and the mutated ones replacing them.
+// Optional query parameters are sent only if mutated.
+func (e APIEndpoint) request(values APIFuzzValues, mutated map[string]APIMutation) APIFuzzRequest {
+	request := APIFuzzRequest{Endpoint: e.Name, Method: e.Method, Path: e.Path, Query: url.Values{}}
+	var names []string
+	for _, param := range e.Params {
+		value := values[param.Kind]
+		mutation, isMutated := mutated[param.Name]
+		if isMutated {
+			value = mutation.Value
+			names = append(names, fmt.Sprintf("%s %s", param.Name, mutation.Name))
+			request.Lenient = request.Lenient || mutation.Lenient
+		} else if param.Optional {
+			continue
+		}
+		if param.InPath {
+			request.Path = strings.ReplaceAll(request.Path, "{"+param.Name+"}", url.PathEscape(value))
+		} else {
+			request.Query.Add(param.Name, value)
+		}
+	}
+	request.Mutation = strings.Join(names, ", ")
+	return request
+}
+
+// APIFuzzRequests mutates every parameter of every endpoint with every mutation of its kind, one at a time,
+// and the path of every endpoint.
+func APIFuzzRequests(endpoints []APIEndpoint, values APIFuzzValues) []APIFuzzRequest {
+	var requests []APIFuzzRequest
+	for _, endpoint := range endpoints {
+		for _, param := range endpoint.Params {
+			if !param.InPath && !param.Optional {
+				request := endpoint.request(values, nil)
+				request.Query.Del(param.Name)
+				request.Mutation = fmt.Sprintf("%s missing", param.Name)
+				requests = append(requests, request)
+			}
+			for _, mutation := range APIMutations(param.Kind) {
+				requests = append(requests, endpoint.request(values, map[string]APIMutation{param.Name: mutation}))
+			}
+		}
+		for _, pathMutation := range pathMutations {
+			request := endpoint.request(values, nil)
+			request.Path = pathMutation.mutate(request.Path)
+			request.Mutation = pathMutation.name
+			request.Lenient = true
+			requests = append(requests, request)
+		}
+	}
+	return requests
+}
+
+// FuzzSeed returns the seed of the random fuzzing of a test: the value of envVar if it is set, otherwise a new one.
+// The seed is logged, so that a failed run can be reproduced.
+func FuzzSeed(t *testing.T, envVar string) int64 {
+	seed := time.Now().UnixNano()
+	if value := os.Getenv(envVar); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			t.Fatalf("invalid %s: %v", envVar, err)
+		}
+		seed = parsed
+	}
+	t.Logf("seed: %d (%s=%d to reproduce)", seed, envVar, seed)
+	return seed
+}
+
+// RandomAPIFuzzRequests builds count requests to random endpoints, each with up to three parameters mutated.
+// Mutations are picked from the known ones or are random strings, which are lenient.
+func RandomAPIFuzzRequests(rng *rand.Rand, endpoints []APIEndpoint, values APIFuzzValues, count int) []APIFuzzRequest {
+	requests := make([]APIFuzzRequest, 0, count)
+	for len(requests) < count {
+		endpoint := endpoints[rng.Intn(len(endpoints))]
+		if len(endpoint.Params) == 0 {
+			continue
+		}
+		mutated := make(map[string]APIMutation)
+		for i := rng.Intn(3); i >= 0; i-- {
+			param := endpoint.Params[rng.Intn(len(endpoint.Params))]
+			if rng.Intn(3) == 0 {
+				mutated[param.Name] = APIMutation{Name: "random", Value: randomAPIValue(rng), Lenient: true}
+				continue
+			}
+			mutations := APIMutations(param.Kind)
+			mutated[param.Name] = mutations[rng.Intn(len(mutations))]
+		}
+		requests = append(requests, endpoint.request(values, mutated))
+	}
+	return requests
+}
+
+// randomAPIValue returns a random string of ASCII, control and multibyte characters, occasionally overlong.
+func randomAPIValue(rng *rand.Rand) string {
+	alphabet := []rune("0123456789-+.:eEx/%&=?#\\\"' \t\x00\x7fé‮١１\U0001F4A5")
+	length := rng.Intn(32)
+	if rng.Intn(10) == 0 {
+		length = 1024 + rng.Intn(4096)
+	}
+	value := make([]rune, length)
+	for i := range value {
+		value[i] = alphabet[rng.Intn(len(alphabet))]
+	}
+	return string(value)
+}
+
+// APIFuzzFailure is a request the node handled wrong.
+type APIFuzzFailure struct {
+	Request APIFuzzRequest
+	Status  int
+	Body    string
+	Problem string
+}
+
+// APIFuzzReport sums up a fuzzing run.
+type APIFuzzReport struct {
+	Requests int
+	Statuses map[int]int
+	Failures []APIFuzzFailure
+	// Crash is the request after which the node stopped being alive, the run stops there.
+	Crash *APIFuzzFailure
+}
+
+func (r *APIFuzzReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d requests, %d failures", r.Requests, len(r.Failures))
+	if r.Crash != nil {
+		fmt.Fprintf(&sb, ", the node crashed after %s %s: %s", r.Crash.Request.Endpoint,
+			truncateFuzzText(r.Crash.Request.String()), r.Crash.Problem)
+	}
+	sb.WriteString("\n")
+
+	statuses := make([]int, 0, len(r.Statuses))
+	for status := range r.Statuses {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	sb.WriteString("statuses:")
+	for _, status := range statuses {
+		fmt.Fprintf(&sb, " %d x%d", status, r.Statuses[status])
+	}
+	sb.WriteString("\n")
+
+	if len(r.Failures) == 0 {
+		return sb.String()
+	}
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ENDPOINT\tMUTATION\tSTATUS\tPROBLEM\tREQUEST\tBODY\t")
+	for _, failure := range r.Failures {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t\n", failure.Request.Endpoint, failure.Request.Mutation, failure.Status,
+			failure.Problem, truncateFuzzText(failure.Request.String()), truncateFuzzText(failure.Body))
+	}
+	w.Flush()
+	return sb.String()
+}
+
+// truncateFuzzText quotes the text for the report, overlong input is cut.
+func truncateFuzzText(text string) string {
+	const maxLength = 160
+	if len(text) > maxLength {
+		return fmt.Sprintf("%q... (%d bytes)", text[:maxLength], len(text))
+	}
+	return fmt.Sprintf("%q", text)
+}
+
+// FuzzAPI sends the requests to the node, in order, checking after every request that the node is alive.
+// A request fails when it gets no response or a 5xx status, or a status other than 4xx if it is not lenient.
+func (n *Node) FuzzAPI(requests []APIFuzzRequest) (*APIFuzzReport, error) {
+	if err := n.CheckAlive(); err != nil {
+		return nil, fmt.Errorf("node is not alive before fuzzing: %w", err)
+	}
+
+	report := &APIFuzzReport{Statuses: make(map[int]int)}
+	for _, request := range requests {
+		report.Requests++
+		fail := func(status int, body, problem string, args ...any) {
+			report.Failures = append(report.Failures, APIFuzzFailure{Request: request, Status: status, Body: body, Problem: fmt.Sprintf(problem, args...)})
+		}
+
+		status, body, err := n.apiRequest(request.Method, request.Path, request.Query)
+		if aliveErr := n.CheckAlive(); aliveErr != nil {
+			fail(status, body, "node is down after the request: %v", aliveErr)
+			report.Crash = &report.Failures[len(report.Failures)-1]
+			return report, nil
+		}
+		switch {
+		case err != nil:
+			fail(status, body, "request failed: %v", err)
+		case status >= 500:
+			report.Statuses[status]++
+			fail(status, body, "server error")
+		case !request.Lenient && (status < 400 || status >= 500):
+			report.Statuses[status]++
+			fail(status, body, "expected a 4xx status")
+		default:
+			report.Statuses[status]++
+		}
+	}
+	return report, nil
+}
+
+// FuzzAPIAndCheck runs FuzzAPI and fails the test on a crash or any failed request.
+func (n *Node) FuzzAPIAndCheck(t *testing.T, requests []APIFuzzRequest) *APIFuzzReport {
+	report, err := n.FuzzAPI(requests)
+	if err != nil {
+		t.Fatalf("Node %s: %v", n.Alias, err)
+	}
+	if len(report.Failures) > 0 {
+		t.Fatalf("Node %s: API fuzzing failed: %s", n.Alias, report)
+	}
+	t.Logf("Node %s: API fuzzing: %s", n.Alias, report)
+	return report
+}
+
+// apiRequest sends a request with the raw path and parameters and returns the status and the body.
+func (n *Node) apiRequest(method, path string, params url.Values) (int, string, error) {
+	requestURL := fmt.Sprintf("http://%s:%d%s", n.IPAddress, n.CLIPort, path)
+	if len(params) > 0 {
+		requestURL += "?" + params.Encode()
+	}
+	resp, err := n.doAPIRequest(n.runContext(), method, requestURL)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body), err
+}
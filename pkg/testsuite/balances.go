@@ -0,0 +1,221 @@
+package testsuite
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"text/tabwriter"
+	"time"
+)
+
+// Balance snapshots.
+//
+// A snapshot holds the balance of every settlement line of every node of the cluster in one equivalent.
+// Comparing snapshots taken around an operation shows exactly which lines moved, including lines that are not
+// on the expected payment path.
+
+// BalanceEdge is a settlement line as seen by Node: its balance with Contractor. Both are node aliases,
+// a contractor that is not a node of the cluster is named by its address.
+type BalanceEdge struct {
+	Node       string
+	Contractor string
+}
+
+// Edge returns the settlement line of node with contractor.
+func Edge(node, contractor *Node) BalanceEdge {
+	return BalanceEdge{Node: node.Alias, Contractor: contractor.Alias}
+}
+
+// Reverse returns the same settlement line as seen by the contractor.
+func (e BalanceEdge) Reverse() BalanceEdge {
+	return BalanceEdge{Node: e.Contractor, Contractor: e.Node}
+}
+
+func (e BalanceEdge) String() string {
+	return fmt.Sprintf("%s -> %s", e.Node, e.Contractor)
+}
+
+// BalanceSnapshot is the balances of all settlement lines of the cluster in an equivalent.
+type BalanceSnapshot struct {
+	Equivalent string
+	TakenAt    time.Time
+	Balances   map[BalanceEdge]Amount
+}
+
+// SnapshotBalances reads the settlement lines of every node of the cluster.
+func (c *Cluster) SnapshotBalances(equivalent string) (*BalanceSnapshot, error) {
+	c.mu.Lock()
+	nodes := append([]*Node(nil), c.nodes...)
+	c.mu.Unlock()
+
+	aliases := make(map[string]string, len(nodes))
+	for _, node := range nodes {
+		aliases[node.GetIpAndPort()] = node.Alias
+	}
+
+	snapshot := &BalanceSnapshot{
+		Equivalent: equivalent,
+		TakenAt:    time.Now(),
+		Balances:   make(map[BalanceEdge]Amount),
+	}
+	for _, node := range nodes {
+		settlementLines, err := node.GetSettlementLines(equivalent)
+		if err != nil {
+			return nil, fmt.Errorf("Node %s: %w", node.Alias, err)
+		}
+		for _, settlementLine := range settlementLines {
+			contractor, ok := aliases[settlementLine.ContractorAddress]
+			if !ok {
+				contractor = settlementLine.ContractorAddress
+			}
+			snapshot.Balances[BalanceEdge{Node: node.Alias, Contractor: contractor}] = settlementLine.Balance
+		}
+	}
+	return snapshot, nil
+}
+
+// SnapshotBalancesAndCheck is SnapshotBalances that fails the test on error.
+func (c *Cluster) SnapshotBalancesAndCheck(t *testing.T, equivalent string) *BalanceSnapshot {
+	snapshot, err := c.SnapshotBalances(equivalent)
+	if err != nil {
+		t.Fatalf("failed to snapshot balances: %v", err)
+	}
+	return snapshot
+}
+
+// Edges returns the settlement lines of the snapshot, sorted.
+func (s *BalanceSnapshot) Edges() []BalanceEdge {
+	edges := make([]BalanceEdge, 0, len(s.Balances))
+	for edge := range s.Balances {
+		edges = append(edges, edge)
+	}
+	sortEdges(edges)
+	return edges
+}
+
+// Balance returns the balance of the line, zero if the line is not in the snapshot.
+func (s *BalanceSnapshot) Balance(edge BalanceEdge) Amount {
+	return s.Balances[edge]
+}
+
+// PathDeltas returns the deltas of a payment of amount along the path of nodes, from the payer to the receiver,
+// with no commissions: every node on the path pays amount to the next one.
+func PathDeltas(amount Amount, path ...*Node) map[BalanceEdge]Amount {
+	deltas := make(map[BalanceEdge]Amount)
+	AddPathDeltas(deltas, amount, path...)
+	return deltas
+}
+
+// AddPathDeltas adds the deltas of a payment along the path to deltas, e.g. for each path of a multipath payment.
+func AddPathDeltas(deltas map[BalanceEdge]Amount, amount Amount, path ...*Node) {
+	for i := 0; i+1 < len(path); i++ {
+		edge := Edge(path[i], path[i+1])
+		deltas[edge] = deltas[edge].Sub(amount)
+	}
+}
+
+// BalanceDelta is the movement of a settlement line between two snapshots.
+type BalanceDelta struct {
+	Edge     BalanceEdge
+	Before   Amount
+	After    Amount
+	Expected Amount
+}
+
+// Actual returns how much the balance moved.
+func (d BalanceDelta) Actual() Amount {
+	return d.After.Sub(d.Before)
+}
+
+// Matches reports whether the balance moved as expected.
+func (d BalanceDelta) Matches() bool {
+	return d.Actual().Equal(d.Expected)
+}
+
+// CompareBalances pairs the lines of both snapshots with the expected deltas. A delta expected for a line applies to
+// both its sides: the contractor's balance is expected to move by the opposite amount, unless it is given too.
+// Lines without an expected delta are expected not to move. A line missing in a snapshot has zero balance there.
+func CompareBalances(before, after *BalanceSnapshot, expected map[BalanceEdge]Amount) []BalanceDelta {
+	expectedDeltas := make(map[BalanceEdge]Amount, 2*len(expected))
+	for edge, delta := range expected {
+		expectedDeltas[edge] = delta
+	}
+	for edge, delta := range expected {
+		if _, ok := expected[edge.Reverse()]; !ok {
+			expectedDeltas[edge.Reverse()] = delta.Neg()
+		}
+	}
+
+	edges := make(map[BalanceEdge]bool)
+	for edge := range before.Balances {
+		edges[edge] = true
+	}
+	for edge := range after.Balances {
+		edges[edge] = true
+	}
+	for edge := range expectedDeltas {
+		edges[edge] = true
+	}
+
+	deltas := make([]BalanceDelta, 0, len(edges))
+	for edge := range edges {
+		deltas = append(deltas, BalanceDelta{
+			Edge:     edge,
+			Before:   before.Balance(edge),
+			After:    after.Balance(edge),
+			Expected: expectedDeltas[edge],
+		})
+	}
+	sort.Slice(deltas, func(i, j int) bool { return edgeLess(deltas[i].Edge, deltas[j].Edge) })
+	return deltas
+}
+
+// AssertDeltas fails the test if any settlement line moved other than expected, see CompareBalances.
+// All lines are reported, the mismatching ones are marked.
+func AssertDeltas(t *testing.T, before, after *BalanceSnapshot, expected map[BalanceEdge]Amount) {
+	t.Helper()
+	if before.Equivalent != after.Equivalent {
+		t.Fatalf("balance snapshots are taken in different equivalents: %s and %s", before.Equivalent, after.Equivalent)
+	}
+
+	deltas := CompareBalances(before, after, expected)
+	mismatches := 0
+	for _, delta := range deltas {
+		if !delta.Matches() {
+			mismatches++
+		}
+	}
+	if mismatches > 0 {
+		t.Fatalf("%d settlement line(s) moved unexpectedly in equivalent %s\n%s",
+			mismatches, before.Equivalent, FormatBalanceDeltas(deltas))
+	}
+}
+
+// FormatBalanceDeltas renders the deltas as a table.
+func FormatBalanceDeltas(deltas []BalanceDelta) string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "LINE\tBEFORE\tAFTER\tDELTA\tEXPECTED\t")
+	for _, delta := range deltas {
+		marker := ""
+		if !delta.Matches() {
+			marker = "MISMATCH"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			delta.Edge, delta.Before, delta.After, delta.Actual(), delta.Expected, marker)
+	}
+	w.Flush()
+	return sb.String()
+}
+
+func edgeLess(a, b BalanceEdge) bool {
+	if a.Node != b.Node {
+		return a.Node < b.Node
+	}
+	return a.Contractor < b.Contractor
+}
+
+func sortEdges(edges []BalanceEdge) {
+	sort.Slice(edges, func(i, j int) bool { return edgeLess(edges[i], edges[j]) })
+}
@@ -1,11 +1,15 @@
 package testsuite
 
 import (
+	"archive/tar"
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"path"
 	"regexp"
 	"strconv"
 	"strings"
@@ -16,20 +20,41 @@ import (
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/docker/go-connections/nat"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type ClusterSettings struct {
 	NodeImageName string
 	NetworkName   string
 	SudoPassword  string
+	// ReportDir is the directory where JUnit XML / JSON reports and node artifacts are written.
+	// Empty means reporting is disabled.
+	ReportDir string
+	// DatabaseConfig is VTCPD_DATABASE_CONFIG passed to the nodes.
+	// Empty means the VTCPD_DATABASE_CONFIG environment variable, or the image default if it's not set either.
+	DatabaseConfig string
+	// StepTimeout is the deadline of every node API call and docker operation of the tests.
+	// Zero means DefaultStepTimeout.
+	StepTimeout time.Duration
 }
 
 type Cluster struct {
 	cli       *client.Client
-	ctx       context.Context
 	networkID string
 	settings  *ClusterSettings
+	// stepTimeout is the deadline of every node API call and docker operation, see SetStepTimeout.
+	stepTimeout time.Duration
+
+	// Bookkeeping used by the test reporter.
+	mu                sync.Mutex
+	report            *TestReportEntry
+	nodes             []*Node
+	networkConditions []NetworkConditionsRecord
+	steps             []TestEvent // step log of the test, see Step
+	traceCtx          context.Context
+	testSpan          trace.Span // root span of the test, see startTestSpan
 }
 
 func NewCluster(ctx context.Context, t *testing.T, settings *ClusterSettings) (*Cluster, error) {
@@ -39,50 +64,189 @@ func NewCluster(ctx context.Context, t *testing.T, settings *ClusterSettings) (*
 	}
 
 	cluster := &Cluster{
-		cli:      cli,
-		ctx:      ctx,
-		settings: settings,
+		cli:         cli,
+		settings:    settings,
+		stepTimeout: settings.stepTimeout(),
+		report:      newTestReportEntry(t),
 	}
+	cluster.startTestSpan(t)
+	cluster.recordAssertions(t)
 
-	networkID, err := cluster.initNetwork(t)
+	spanCtx, span := cluster.startSpan(ctx, "setup network")
+	networkID, created, err := cluster.initNetwork(spanCtx)
+	endSpan(span, err)
 	if err != nil {
+		cluster.endTestSpan(t, TestStatusFailed)
 		// The error from initNetwork should already include the specific network name.
 		// Wrap the error to provide context from NewCluster.
 		return nil, fmt.Errorf("failed to create cluster using network name '%s': %w", cluster.settings.NetworkName, err)
 	}
+	if !created {
+		t.Logf("Network %s already exists.", cluster.settings.NetworkName)
+	}
 
-	t.Cleanup(func() {})
+	// Registered before any node is started, so it runs after all node cleanups
+	// (artifacts are already collected at that point).
+	t.Cleanup(func() {
+		cluster.writeReport(t)
+	})
+
+	cluster.networkID = networkID
+	return cluster, nil
+}
+
+// NewDetachedCluster creates a cluster that is not bound to a test.
+// Nodes started through it are not removed automatically and must be removed with RemoveNode.
+// It is used by tools (e.g. cmd/vtcp-suite) that keep the cluster running between invocations.
+func NewDetachedCluster(ctx context.Context, settings *ClusterSettings) (*Cluster, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create docker client: %v", err)
+	}
+
+	cluster := &Cluster{
+		cli:         cli,
+		settings:    settings,
+		stepTimeout: settings.stepTimeout(),
+	}
+
+	networkID, _, err := cluster.initNetwork(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cluster using network name '%s': %w", cluster.settings.NetworkName, err)
+	}
 
 	cluster.networkID = networkID
 	return cluster, nil
 }
 
 func (c *Cluster) RunNode(ctx context.Context, t *testing.T, wg *sync.WaitGroup, node *Node, valgrind bool) (err error) {
-	// Get VTCPD_DATABASE_CONFIG from environment and add it to node.Env if it exists
-	envVars := node.Env
-	if dbConfig := os.Getenv("VTCPD_DATABASE_CONFIG"); dbConfig != "" {
+	ctx, span := c.startSpan(ctx, "start node", AttributeNodeAlias.String(node.Alias))
+	err = c.StartNode(ctx, node, valgrind)
+	endSpan(span, err)
+	if err != nil {
+		return err
+	}
+	c.recordAPICalls(t, node)
+
+	// Automatically stop and remove container when test finishes.
+	// Helps prevent boilerplate code in tests.
+	// The container is removed even if the node's context is already cancelled.
+	cleanupCtx := context.WithoutCancel(node.runContext())
+	t.Cleanup(func() {
+		spanCtx, span := c.startSpan(cleanupCtx, "remove node", AttributeNodeAlias.String(node.Alias))
+		err := c.RemoveNode(spanCtx, node)
+		endSpan(span, err)
+		if err != nil {
+			t.Logf("%v", err)
+		}
+	})
+
+	// Cleanups run in LIFO order, so artifacts are collected while the container still exists.
+	t.Cleanup(func() {
+		spanCtx, span := c.startSpan(cleanupCtx, "collect artifacts", AttributeNodeAlias.String(node.Alias))
+		c.collectNodeArtifacts(spanCtx, t, node, valgrind)
+		span.End()
+	})
+
+	return nil
+}
+
+// StartNode creates and starts the node's container. The node's operations run under ctx afterwards.
+// Unlike RunNode, the container is not removed automatically.
+func (c *Cluster) StartNode(ctx context.Context, node *Node, valgrind bool) (err error) {
+	dbConfig := c.databaseConfig()
+	node.valgrind, node.databaseConfig = valgrind, dbConfig
+
+	stepCtx, cancel := c.stepContext(ctx)
+	defer cancel()
+	containerID, err := c.createNodeContainer(stepCtx, node, c.nodeImage(node), dbConfig)
+	if err != nil {
+		return c.stepTimeoutError(stepCtx, node, "container create", err)
+	}
+	// The container is not known to the caller until it starts: don't leave it behind if that fails.
+	// The step deadline may be the failure, so removal doesn't run under it.
+	defer func() {
+		if err != nil {
+			c.cli.ContainerRemove(context.WithoutCancel(ctx), containerID, container.RemoveOptions{Force: true})
+		}
+	}()
+
+	if len(node.ConfigOptions) > 0 {
+		config, err := c.imageConfig(stepCtx, node, c.nodeImage(node), dbConfig)
+		if err != nil {
+			return c.stepTimeoutError(stepCtx, node, "config generation", fmt.Errorf("Node %s: %v", node.Alias, err))
+		}
+		if err := node.applyConfigOptions(config); err != nil {
+			return err
+		}
+		if err := c.copyNodeConfig(stepCtx, containerID, config); err != nil {
+			return c.stepTimeoutError(stepCtx, node, "config copy", fmt.Errorf("Node %s: %v", node.Alias, err))
+		}
+	}
+
+	// Start container
+	if err := c.cli.ContainerStart(stepCtx, containerID, container.StartOptions{}); err != nil {
+		return c.stepTimeoutError(stepCtx, node, "container start", fmt.Errorf("failed to start container: %v", err))
+	}
+
+	node.ContainerID = containerID
+	node.Storage = NewStorageInspector(node, dbConfig)
+	c.bindNode(ctx, node)
+
+	c.mu.Lock()
+	c.nodes = append(c.nodes, node)
+	c.mu.Unlock()
+
+	return nil
+}
+
+// databaseConfig returns VTCPD_DATABASE_CONFIG passed to the nodes' containers.
+func (c *Cluster) databaseConfig() string {
+	if c.settings.DatabaseConfig != "" {
+		return c.settings.DatabaseConfig
+	}
+	return os.Getenv("VTCPD_DATABASE_CONFIG")
+}
+
+// nodeImage returns the image the node runs: its own override or the cluster one.
+func (c *Cluster) nodeImage(node *Node) string {
+	if node.Image != "" {
+		return node.Image
+	}
+	return c.settings.NodeImageName
+}
+
+// nodeContainerEnv returns the environment of the node's container.
+func nodeContainerEnv(node *Node, dbConfig string) []string {
+	// Add VTCPD_DATABASE_CONFIG from environment to node.Env if it exists
+	envVars := append([]string(nil), node.Env...)
+	if dbConfig != "" {
 		envVars = append(envVars, fmt.Sprintf("VTCPD_DATABASE_CONFIG=%s", dbConfig))
 	}
 
 	// Add valgrind environment variable based on parameter
-	if valgrind {
+	if node.valgrind {
 		envVars = append(envVars, "VALGRIND_ENABLED=true")
 		// Explicitly set VALGRIND_OPTS to ensure proper logging
 		envVars = append(envVars, "VALGRIND_OPTS=--leak-check=full --track-origins=yes --log-file=/vtcp/valgrind.log")
 	} else {
 		envVars = append(envVars, "VALGRIND_ENABLED=false")
 	}
+	return envVars
+}
 
+// createNodeContainer creates the node's container from the image, without starting it.
+func (c *Cluster) createNodeContainer(ctx context.Context, node *Node, image string, dbConfig string) (string, error) {
 	// Create container
-	resp, err := c.cli.ContainerCreate(c.ctx,
+	resp, err := c.cli.ContainerCreate(ctx,
 		&container.Config{
-			Image: c.settings.NodeImageName,
+			Image: image,
 			ExposedPorts: nat.PortSet{
 				nat.Port(strconv.Itoa(int(node.NodePort))):    struct{}{},
 				nat.Port(strconv.Itoa(int(node.CLIPort))):     struct{}{},
 				nat.Port(strconv.Itoa(int(node.CLIPortTest))): struct{}{},
 			},
-			Env: envVars,
+			Env: nodeContainerEnv(node, dbConfig),
 		},
 		&container.HostConfig{
 			NetworkMode: container.NetworkMode(c.networkID),
@@ -115,37 +279,160 @@ func (c *Cluster) RunNode(ctx context.Context, t *testing.T, wg *sync.WaitGroup,
 		"",
 	)
 	if err != nil {
-		return fmt.Errorf("failed to create container: %v", err)
+		return "", fmt.Errorf("failed to create container: %v", err)
 	}
+	return resp.ID, nil
+}
 
-	// Start container
-	if err := c.cli.ContainerStart(c.ctx, resp.ID, container.StartOptions{}); err != nil {
-		return fmt.Errorf("failed to start container: %v", err)
+// imageConfigMarker separates the entrypoint's own output from the config in the output of imageConfig.
+const imageConfigMarker = "--- vtcp-suite: conf.json ---"
+
+// imageConfig runs the image's entrypoint in a throwaway container with the node's environment and returns
+// the conf.json it generates, so the node's options are applied to exactly the config the image would write.
+func (c *Cluster) imageConfig(ctx context.Context, node *Node, image string, dbConfig string) (*NodeConfig, error) {
+	resp, err := c.cli.ContainerCreate(ctx,
+		&container.Config{
+			Image: image,
+			Env:   nodeContainerEnv(node, dbConfig),
+			Cmd:   []string{"sh", "-c", fmt.Sprintf("echo '%s' && cat %s", imageConfigMarker, NodeConfigPath)},
+		},
+		&container.HostConfig{NetworkMode: "none"},
+		nil,
+		nil,
+		"",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create config container: %v", err)
 	}
+	defer c.cli.ContainerRemove(context.WithoutCancel(ctx), resp.ID, container.RemoveOptions{Force: true})
 
-	node.ContainerID = resp.ID
+	if err := c.cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return nil, fmt.Errorf("failed to start config container: %v", err)
+	}
+	var exitCode int64
+	statusCh, errCh := c.cli.ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		return nil, fmt.Errorf("failed to wait for config container: %v", err)
+	case status := <-statusCh:
+		exitCode = status.StatusCode
+	}
 
-	// Automatically stop and remove container when test finishes.
-	// Helps prevent boilerplate code in tests.
-	t.Cleanup(func() {
-		secondsToWait := 5
-		if err := c.cli.ContainerStop(c.ctx, resp.ID, container.StopOptions{Timeout: &secondsToWait}); err != nil {
-			t.Logf("failed to stop container: %v", err)
-		}
-		if err := c.cli.ContainerRemove(c.ctx, resp.ID, container.RemoveOptions{}); err != nil {
-			t.Logf("failed to remove container: %v", err)
-		}
-	})
+	logs, err := c.cli.ContainerLogs(ctx, resp.ID, container.LogsOptions{ShowStdout: true, ShowStderr: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config container output: %v", err)
+	}
+	defer logs.Close()
+	var stdout, stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, &stderr, logs); err != nil {
+		return nil, fmt.Errorf("failed to read config container output: %v", err)
+	}
+	if exitCode != 0 {
+		return nil, fmt.Errorf("image entrypoint exited with code %d: %s", exitCode, strings.TrimSpace(stderr.String()))
+	}
+
+	_, generated, found := strings.Cut(stdout.String(), imageConfigMarker+"\n")
+	if !found {
+		return nil, fmt.Errorf("image entrypoint didn't run the command: %s", strings.TrimSpace(stdout.String()))
+	}
+	config := &NodeConfig{}
+	if err := json.Unmarshal([]byte(generated), config); err != nil {
+		return nil, fmt.Errorf("failed to parse the config generated by image %s: %v", image, err)
+	}
+	return config, nil
+}
+
+// copyNodeConfig puts conf.json into the created container, the entrypoint keeps it instead of generating one.
+func (c *Cluster) copyNodeConfig(ctx context.Context, containerID string, config *NodeConfig) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %v", err)
+	}
+
+	var archive bytes.Buffer
+	writer := tar.NewWriter(&archive)
+	header := &tar.Header{Name: path.Base(NodeConfigPath), Mode: 0o644, Size: int64(len(data)), ModTime: time.Now()}
+	if err := writer.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to archive config: %v", err)
+	}
+	if _, err := writer.Write(data); err != nil {
+		return fmt.Errorf("failed to archive config: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to archive config: %v", err)
+	}
 
+	if err := c.cli.CopyToContainer(ctx, containerID, path.Dir(NodeConfigPath), &archive, container.CopyToContainerOptions{}); err != nil {
+		return fmt.Errorf("failed to copy config into container: %v", err)
+	}
+	return nil
+}
+
+// RemoveNode stops and removes the node's container.
+func (c *Cluster) RemoveNode(ctx context.Context, node *Node) error {
+	ctx, cancel := c.stepContext(ctx)
+	defer cancel()
+	secondsToWait := 5
+	// Removal is attempted even if the container could not be stopped gracefully.
+	stopErr := c.cli.ContainerStop(ctx, node.ContainerID, container.StopOptions{Timeout: &secondsToWait})
+	if err := c.cli.ContainerRemove(ctx, node.ContainerID, container.RemoveOptions{Force: stopErr != nil}); err != nil {
+		if stopErr != nil {
+			err = fmt.Errorf("failed to stop container: %v; failed to remove container: %v", stopErr, err)
+		} else {
+			err = fmt.Errorf("failed to remove container: %v", err)
+		}
+		return c.stepTimeoutError(ctx, node, "container remove", err)
+	}
 	return nil
 }
 
+// NodeStatus describes the state of a node's container as reported by Docker.
+type NodeStatus struct {
+	State string
+	// Host ports the node's container ports are published on. Empty if not published.
+	NodeHostPort string
+	CLIHostPort  string
+}
+
+// InspectNode returns the container state and the published host ports of the node.
+func (c *Cluster) InspectNode(ctx context.Context, node *Node) (*NodeStatus, error) {
+	ctx, cancel := c.stepContext(ctx)
+	defer cancel()
+	info, err := c.cli.ContainerInspect(ctx, node.ContainerID)
+	if err != nil {
+		return nil, c.stepTimeoutError(ctx, node, "container inspect", fmt.Errorf("failed to inspect container of node %s: %v", node.Alias, err))
+	}
+
+	status := &NodeStatus{}
+	if info.State != nil {
+		status.State = info.State.Status
+	}
+
+	hostPort := func(port uint16) string {
+		if info.NetworkSettings == nil {
+			return ""
+		}
+		bindings := info.NetworkSettings.Ports[nat.Port(fmt.Sprintf("%d/tcp", port))]
+		if len(bindings) == 0 {
+			return ""
+		}
+		return bindings[0].HostPort
+	}
+	status.NodeHostPort = hostPort(node.NodePort)
+	status.CLIHostPort = hostPort(node.CLIPort)
+	return status, nil
+}
+
 func (c *Cluster) RunNodes(ctx context.Context, t *testing.T, nodes []*Node, valgrind bool) {
+	ctx, span := c.startSpan(ctx, "run nodes", AttributeNodeCount.Int(len(nodes)))
+	defer span.End()
+
 	wg := sync.WaitGroup{}
 	{
 		for _, node := range nodes {
 			err := c.RunNode(ctx, t, &wg, node, valgrind)
 			if err != nil {
+				endSpan(span, err)
 				t.Fatalf("failed to run %s: %v", node.Alias, err)
 			}
 		}
@@ -161,7 +448,8 @@ func (c *Cluster) RunNodes(ctx context.Context, t *testing.T, nodes []*Node, val
 	timeout := 60 * time.Second
 
 	for _, node := range nodes {
-		if err := node.WaitForReady(t, timeout); err != nil {
+		if err := c.waitForReady(ctx, t, node, timeout); err != nil {
+			endSpan(span, err)
 			t.Fatalf("Node %s failed to become ready: %v", node.Alias, err)
 		}
 	}
@@ -169,9 +457,13 @@ func (c *Cluster) RunNodes(ctx context.Context, t *testing.T, nodes []*Node, val
 
 func (c *Cluster) RunSingleNode(ctx context.Context, t *testing.T, node *Node, valgrind bool) {
 	// No need for goroutine when running a single node
+	ctx, span := c.startSpan(ctx, "run nodes", AttributeNodeCount.Int(1))
+	defer span.End()
+
 	var wg sync.WaitGroup // Dummy WaitGroup as RunNode expects one
 	err := c.RunNode(ctx, t, &wg, node, valgrind)
 	if err != nil {
+		endSpan(span, err)
 		t.Fatalf("failed to run %s: %v", node.Alias, err)
 	}
 
@@ -179,19 +471,31 @@ func (c *Cluster) RunSingleNode(ctx context.Context, t *testing.T, node *Node, v
 
 	// Wait for node to be ready with health check
 	timeout := 60 * time.Second
-	if err := node.WaitForReady(t, timeout); err != nil {
+	if err := c.waitForReady(ctx, t, node, timeout); err != nil {
+		endSpan(span, err)
 		t.Fatalf("Node %s failed to become ready: %v", node.Alias, err)
 	}
 }
 
+// waitForReady waits for the node as WaitForReady, traced as a phase of the cluster setup.
+func (c *Cluster) waitForReady(ctx context.Context, t *testing.T, node *Node, timeout time.Duration) error {
+	ctx, span := c.startSpan(ctx, "wait for ready", AttributeNodeAlias.String(node.Alias))
+	err := node.WaitForReadyCtx(ctx, t, timeout)
+	endSpan(span, err)
+	return err
+}
+
 func (c *Cluster) StopSingleNode(ctx context.Context, t *testing.T, node *Node) {
 	if node.ContainerID == "" {
 		t.Logf("Node %s has no container ID, skipping stop.", node.Alias)
 		return
 	}
+	ctx, cancel := c.stepContext(ctx)
+	defer cancel()
 	secondsToWait := 5
-	if err := c.cli.ContainerStop(c.ctx, node.ContainerID, container.StopOptions{Timeout: &secondsToWait}); err != nil {
-		t.Logf("failed to stop container for node %s (ID: %s): %v", node.Alias, node.ContainerID, err)
+	if err := c.cli.ContainerStop(ctx, node.ContainerID, container.StopOptions{Timeout: &secondsToWait}); err != nil {
+		t.Logf("failed to stop container for node %s (ID: %s): %v", node.Alias, node.ContainerID,
+			c.stepTimeoutError(ctx, node, "container stop", err))
 	}
 	// Note: ContainerRemove is handled by t.Cleanup in RunNode
 	t.Logf("Stopped container for node %s (ID: %s)", node.Alias, node.ContainerID)
@@ -203,20 +507,24 @@ func (c *Cluster) StopNodes(ctx context.Context, t *testing.T, nodes []*Node) {
 	}
 }
 
-func (c *Cluster) initNetwork(t *testing.T) (string, error) {
+// initNetwork returns the ID of the cluster network, creating the network if it does not exist yet.
+// created reports whether the network was created by this call.
+func (c *Cluster) initNetwork(ctx context.Context) (networkID string, created bool, err error) {
+	ctx, cancel := c.stepContext(ctx)
+	defer cancel()
+
 	// Try to inspect the network by name to see if it exists.
-	networkResource, inspectErr := c.cli.NetworkInspect(c.ctx, c.settings.NetworkName, network.InspectOptions{})
+	networkResource, inspectErr := c.cli.NetworkInspect(ctx, c.settings.NetworkName, network.InspectOptions{})
 	if inspectErr == nil {
-		t.Logf("Network %s already exists.", c.settings.NetworkName)
-		return networkResource.ID, nil
+		return networkResource.ID, false, nil
 	} else if !client.IsErrNotFound(inspectErr) {
 		// Inspect failed for a reason other than "not found", which is an issue.
-		return "", fmt.Errorf("failed to inspect network %s prior to creation: %v", c.settings.NetworkName, inspectErr)
+		return "", false, fmt.Errorf("failed to inspect network %s prior to creation: %v", c.settings.NetworkName, inspectErr)
 	}
 	// If inspectErr was client.IsErrNotFound(inspectErr), network doesn't exist, which is good. We proceed to create.
 
 	// Now, attempt to create the network.
-	resp, createErr := c.cli.NetworkCreate(c.ctx, c.settings.NetworkName, network.CreateOptions{
+	resp, createErr := c.cli.NetworkCreate(ctx, c.settings.NetworkName, network.CreateOptions{
 		Driver: "bridge",
 		IPAM: &network.IPAM{
 			Driver: "default",
@@ -228,51 +536,58 @@ func (c *Cluster) initNetwork(t *testing.T) (string, error) {
 		},
 	})
 	if createErr != nil {
-		return "", fmt.Errorf("failed to create network %s: %v", c.settings.NetworkName, createErr)
+		return "", false, fmt.Errorf("failed to create network %s: %v", c.settings.NetworkName, createErr)
 	}
-	return resp.ID, nil
+	return resp.ID, true, nil
 }
 
 // NetworkConditions defines network simulation parameters
 type NetworkConditions struct {
 	// Bandwidth limit (e.g., "1mbit", "100kbit", "10mbit", "1gbit"). Empty means no limit.
-	Bandwidth string
+	Bandwidth string `json:"bandwidth,omitempty"`
 	// Delay in milliseconds (e.g., 100 for 100ms delay). 0 means no delay.
-	DelayMs int
+	DelayMs int `json:"delay_ms,omitempty"`
 	// Jitter in milliseconds - random variation in delay (e.g., 10 for ±10ms). 0 means no jitter.
-	JitterMs int
+	JitterMs int `json:"jitter_ms,omitempty"`
 	// Packet loss percentage (e.g., 10.0 for 10%). 0 means no loss.
-	LossPercent float64
+	LossPercent float64 `json:"loss_percent,omitempty"`
 	// Packet duplication percentage (e.g., 1.0 for 1%). 0 means no duplication.
-	DuplicatePercent float64
+	DuplicatePercent float64 `json:"duplicate_percent,omitempty"`
 	// Packet corruption percentage (e.g., 0.1 for 0.1%). 0 means no corruption.
-	CorruptPercent float64
+	CorruptPercent float64 `json:"corrupt_percent,omitempty"`
 	// Packet reordering percentage (e.g., 25 for 25%). 0 means no reordering.
-	ReorderPercent float64
+	ReorderPercent float64 `json:"reorder_percent,omitempty"`
 	// Gap for reordering - how many packets to delay for reordering (default: 5).
-	ReorderGap int
+	ReorderGap int `json:"reorder_gap,omitempty"`
 }
 
-// executeSudoCommand executes a command with sudo, using password if configured
-func (c *Cluster) executeSudoCommand(args []string) error {
+// executeSudoCommand executes a command for the node with sudo, using password if configured,
+// with the step deadline
+func (c *Cluster) executeSudoCommand(ctx context.Context, node *Node, args []string) error {
+	ctx, cancel := c.stepContext(ctx)
+	defer cancel()
+
 	var cmd *exec.Cmd
 	var stdin bytes.Buffer
 
 	if c.settings.SudoPassword != "" {
 		// Use sudo -S to read password from stdin
 		sudoArgs := append([]string{"-S"}, args...)
-		cmd = exec.Command("sudo", sudoArgs...)
+		cmd = exec.CommandContext(ctx, "sudo", sudoArgs...)
 		stdin.WriteString(c.settings.SudoPassword + "\n")
 		cmd.Stdin = &stdin
 	} else {
 		// Use sudo without password (will prompt interactively if needed)
-		cmd = exec.Command("sudo", args...)
+		cmd = exec.CommandContext(ctx, "sudo", args...)
 	}
 
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
 	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return &StepTimeoutError{NodeAlias: node.Alias, Method: "sudo", Endpoint: strings.Join(args, " "), Timeout: node.stepDeadline()}
+		}
 		return fmt.Errorf("sudo command failed: %v. Command: 'sudo %s'. Stderr: %s",
 			err, strings.Join(args, " "), stderr.String())
 	}
@@ -291,8 +606,11 @@ func (c *Cluster) ConfigureNetworkConditions(node *Node, conditions *NetworkCond
 	}
 
 	// 1. Get ifindex of the interface inside the container
-	dockerCmd := exec.Command("docker", "exec", node.ContainerID, "cat", fmt.Sprintf("/sys/class/net/%s/ifindex", containerInterfaceName))
-	cmdOutput, err := dockerCmd.Output()
+	ctx := node.runContext()
+	stepCtx, cancel := node.stepContext(ctx)
+	defer cancel()
+	dockerCmd := exec.CommandContext(stepCtx, "docker", "exec", node.ContainerID, "cat", fmt.Sprintf("/sys/class/net/%s/ifindex", containerInterfaceName))
+	cmdOutput, err := node.dockerOutput(stepCtx, dockerCmd)
 	if err != nil {
 		errMsg := fmt.Sprintf("failed to get ifindex for %s in container %s (%s)", containerInterfaceName, node.Alias, node.ContainerID)
 		if exitErr, ok := err.(*exec.ExitError); ok {
@@ -303,7 +621,7 @@ func (c *Cluster) ConfigureNetworkConditions(node *Node, conditions *NetworkCond
 	containerIfindexStr := strings.TrimSpace(string(cmdOutput))
 
 	// 2. Find host veth interface linked to the container's interface index
-	ipCmd := exec.Command("ip", "-o", "link")
+	ipCmd := exec.CommandContext(stepCtx, "ip", "-o", "link")
 	cmdOutput, err = ipCmd.Output()
 	if err != nil {
 		errMsg := "failed to list host interfaces using 'ip -o link'"
@@ -332,7 +650,7 @@ func (c *Cluster) ConfigureNetworkConditions(node *Node, conditions *NetworkCond
 	// 3. Clear any existing qdisc first
 	clearArgs := []string{"tc", "qdisc", "del", "dev", hostVethInterface, "root"}
 	// Ignore errors as there might not be any existing qdisc - this is normal
-	c.executeSudoCommand(clearArgs)
+	c.executeSudoCommand(ctx, node, clearArgs)
 
 	// 4. Configure bandwidth limitation if specified
 	if conditions.Bandwidth != "" {
@@ -346,7 +664,7 @@ func (c *Cluster) ConfigureNetworkConditions(node *Node, conditions *NetworkCond
 			"tbf", "rate", conditions.Bandwidth, "burst", burst, "latency", latency,
 		}
 
-		if err := c.executeSudoCommand(tbfArgs); err != nil {
+		if err := c.executeSudoCommand(ctx, node, tbfArgs); err != nil {
 			return fmt.Errorf("failed to configure bandwidth limit for node %s: %v", node.Alias, err)
 		}
 
@@ -359,7 +677,7 @@ func (c *Cluster) ConfigureNetworkConditions(node *Node, conditions *NetworkCond
 			netemArgs := []string{"tc", "qdisc", "add", "dev", hostVethInterface, "parent", parentHandle, "handle", netemHandle, "netem"}
 			netemArgs = append(netemArgs, c.buildNetemParams(conditions)...)
 
-			if err := c.executeSudoCommand(netemArgs); err != nil {
+			if err := c.executeSudoCommand(ctx, node, netemArgs); err != nil {
 				return fmt.Errorf("failed to configure netem conditions for node %s: %v", node.Alias, err)
 			}
 		}
@@ -369,11 +687,13 @@ func (c *Cluster) ConfigureNetworkConditions(node *Node, conditions *NetworkCond
 		netemArgs = append(netemArgs, c.buildNetemParams(conditions)...)
 
 		println(fmt.Sprintf("Executing netem command: %s", strings.Join(netemArgs, " ")))
-		if err := c.executeSudoCommand(netemArgs); err != nil {
+		if err := c.executeSudoCommand(ctx, node, netemArgs); err != nil {
 			return fmt.Errorf("failed to configure netem conditions for node %s: %v", node.Alias, err)
 		}
 	}
 
+	c.recordNetworkConditions(node, conditions)
+
 	time.Sleep(2 * time.Second) // Allow time for changes to take effect
 	return nil
 }
@@ -434,14 +754,17 @@ func (c *Cluster) RemoveNetworkConditions(node *Node, containerInterfaceName str
 	}
 
 	// Get the host veth interface (reusing the same logic as in ConfigureNetworkConditions)
-	dockerCmd := exec.Command("docker", "exec", node.ContainerID, "cat", fmt.Sprintf("/sys/class/net/%s/ifindex", containerInterfaceName))
-	cmdOutput, err := dockerCmd.Output()
+	ctx := node.runContext()
+	stepCtx, cancel := node.stepContext(ctx)
+	defer cancel()
+	dockerCmd := exec.CommandContext(stepCtx, "docker", "exec", node.ContainerID, "cat", fmt.Sprintf("/sys/class/net/%s/ifindex", containerInterfaceName))
+	cmdOutput, err := node.dockerOutput(stepCtx, dockerCmd)
 	if err != nil {
 		return fmt.Errorf("failed to get ifindex for %s in container %s: %v", containerInterfaceName, node.Alias, err)
 	}
 	containerIfindexStr := strings.TrimSpace(string(cmdOutput))
 
-	ipCmd := exec.Command("ip", "-o", "link")
+	ipCmd := exec.CommandContext(stepCtx, "ip", "-o", "link")
 	cmdOutput, err = ipCmd.Output()
 	if err != nil {
 		return fmt.Errorf("failed to list host interfaces: %v", err)
@@ -462,9 +785,11 @@ func (c *Cluster) RemoveNetworkConditions(node *Node, containerInterfaceName str
 		return fmt.Errorf("could not find host veth interface for container %s", node.ContainerID)
 	}
 
+	c.recordNetworkConditions(node, nil)
+
 	// Remove all qdisc rules
 	clearArgs := []string{"tc", "qdisc", "del", "dev", hostVethInterface, "root"}
-	if err := c.executeSudoCommand(clearArgs); err != nil {
+	if err := c.executeSudoCommand(ctx, node, clearArgs); err != nil {
 		// It's okay if this fails - there might not be any rules configured
 		return nil
 	}
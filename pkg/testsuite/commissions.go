@@ -0,0 +1,230 @@
+package testsuite
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"text/tabwriter"
+)
+
+// Commission accounting.
+//
+// An intermediate node charges the commission of its config once per payment, however many paths of the payment
+// go through it. The receiver gets the paid amount, the coordinator pays the amount and every commission.
+// A node's commission is its net balance movement: the sum of the deltas of all its settlement lines.
+
+// CommissionRole is the part a node played in a payment.
+type CommissionRole string
+
+const (
+	CommissionRoleCoordinator  CommissionRole = "coordinator"
+	CommissionRoleIntermediate CommissionRole = "intermediate"
+	CommissionRoleReceiver     CommissionRole = "receiver"
+	CommissionRoleUninvolved   CommissionRole = "uninvolved"
+)
+
+// CommissionPayment is a finished payment whose commissions are verified.
+type CommissionPayment struct {
+	Coordinator *Node
+	Receiver    *Node
+	Equivalent  string
+	Amount      Amount // received by the receiver
+	// TransactionUUID enables the history checks, they are skipped if it is empty.
+	TransactionUUID string
+}
+
+// NodeCommissionCheck is the expected and actual accounting of a node in a payment.
+type NodeCommissionCheck struct {
+	Node       *Node
+	Role       CommissionRole
+	Configured Amount // commission of the node's config in the payment equivalent
+	Expected   Amount // expected net balance movement
+	Actual     Amount // net balance movement
+	// HistoryRecord is whether the node's history has a record of the transaction, nil if not checked.
+	HistoryRecord *bool
+	Problems      []string
+}
+
+// CommissionReport is the accounting of every node of the cluster in a payment.
+type CommissionReport struct {
+	Payment CommissionPayment
+	Nodes   []NodeCommissionCheck
+}
+
+// VerifyCommissions works out the commission every intermediate node should have taken in the payment from the
+// nodes' configs and checks it against the balance deltas between the snapshots and against the nodes' history.
+// Intermediate nodes are the nodes (other than the coordinator and the receiver) whose settlement lines moved.
+func (c *Cluster) VerifyCommissions(payment CommissionPayment, before, after *BalanceSnapshot) (*CommissionReport, error) {
+	if before.Equivalent != payment.Equivalent || after.Equivalent != payment.Equivalent {
+		return nil, fmt.Errorf("balance snapshots are taken in %s and %s, the payment is in %s",
+			before.Equivalent, after.Equivalent, payment.Equivalent)
+	}
+
+	c.mu.Lock()
+	nodes := append([]*Node(nil), c.nodes...)
+	c.mu.Unlock()
+
+	netDeltas := make(map[string]Amount)
+	moved := make(map[string]bool)
+	for _, delta := range CompareBalances(before, after, nil) {
+		actual := delta.Actual()
+		netDeltas[delta.Edge.Node] = netDeltas[delta.Edge.Node].Add(actual)
+		if !actual.IsZero() {
+			moved[delta.Edge.Node] = true
+		}
+	}
+
+	report := &CommissionReport{Payment: payment}
+	totalCommission := NewAmount(0)
+	for _, node := range nodes {
+		check := NodeCommissionCheck{
+			Node:       node,
+			Role:       CommissionRoleUninvolved,
+			Configured: node.Commission(payment.Equivalent),
+			Actual:     netDeltas[node.Alias],
+		}
+		switch {
+		case node == payment.Coordinator:
+			check.Role = CommissionRoleCoordinator
+		case node == payment.Receiver:
+			check.Role = CommissionRoleReceiver
+			check.Expected = payment.Amount
+		case moved[node.Alias]:
+			check.Role = CommissionRoleIntermediate
+			check.Expected = check.Configured
+			totalCommission = totalCommission.Add(check.Configured)
+		}
+		report.Nodes = append(report.Nodes, check)
+	}
+
+	for i := range report.Nodes {
+		check := &report.Nodes[i]
+		if check.Role == CommissionRoleCoordinator {
+			check.Expected = payment.Amount.Add(totalCommission).Neg()
+		}
+		check.Problems = check.balanceProblems()
+
+		if payment.TransactionUUID == "" {
+			continue
+		}
+		found, err := check.Node.hasPaymentRecord(check.Role, payment)
+		if err != nil {
+			return nil, err
+		}
+		if check.Role != CommissionRoleUninvolved || found {
+			check.HistoryRecord = &found
+		}
+		switch {
+		case check.Role == CommissionRoleUninvolved && found:
+			check.Problems = append(check.Problems, "has a history record of the transaction, but its balances didn't move")
+		case check.Role != CommissionRoleUninvolved && !found:
+			check.Problems = append(check.Problems, "has no history record of the transaction")
+		}
+	}
+	return report, nil
+}
+
+func (check *NodeCommissionCheck) balanceProblems() []string {
+	if check.Actual.Equal(check.Expected) {
+		return nil
+	}
+	if check.Role != CommissionRoleIntermediate {
+		return []string{fmt.Sprintf("balance moved by %s, expected %s", check.Actual, check.Expected)}
+	}
+	switch {
+	case check.Configured.IsZero():
+		return []string{fmt.Sprintf("charged %s with no commission configured", check.Actual)}
+	case check.Actual.IsZero():
+		return []string{fmt.Sprintf("didn't charge the configured commission %s", check.Configured)}
+	default:
+		return []string{fmt.Sprintf("charged %s instead of the configured commission %s", check.Actual, check.Configured)}
+	}
+}
+
+// hasPaymentRecord looks for the transaction in the node's history: intermediate nodes keep it in the
+// additional payments history, the coordinator and the receiver in the payments history.
+func (n *Node) hasPaymentRecord(role CommissionRole, payment CommissionPayment) (bool, error) {
+	filter := HistoryFilter{Equivalent: payment.Equivalent}
+	histories := []func(HistoryFilter) (*PaymentHistoryPage, error){n.GetPaymentHistory, n.GetAdditionalPaymentHistory}
+	switch role {
+	case CommissionRoleCoordinator, CommissionRoleReceiver:
+		histories = histories[:1]
+	case CommissionRoleIntermediate:
+		histories = histories[1:]
+	}
+	for _, getPage := range histories {
+		for record, err := range iterateHistory(filter, getPage) {
+			if err != nil {
+				return false, fmt.Errorf("Node %s: %w", n.Alias, err)
+			}
+			if record.TransactionUUID == payment.TransactionUUID {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// HasProblems reports whether any node charged, paid or received other than expected.
+func (r *CommissionReport) HasProblems() bool {
+	for _, check := range r.Nodes {
+		if len(check.Problems) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Commissions returns the commission taken by every intermediate node, by alias.
+func (r *CommissionReport) Commissions() map[string]Amount {
+	commissions := make(map[string]Amount)
+	for _, check := range r.Nodes {
+		if check.Role == CommissionRoleIntermediate {
+			commissions[check.Node.Alias] = check.Actual
+		}
+	}
+	return commissions
+}
+
+func (r *CommissionReport) String() string {
+	nodes := append([]NodeCommissionCheck(nil), r.Nodes...)
+	sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].Node.Alias < nodes[j].Node.Alias })
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Payment %s -> %s of %s in equivalent %s", r.Payment.Coordinator.Alias, r.Payment.Receiver.Alias,
+		r.Payment.Amount, r.Payment.Equivalent)
+	if r.Payment.TransactionUUID != "" {
+		fmt.Fprintf(&sb, " (transaction %s)", r.Payment.TransactionUUID)
+	}
+	sb.WriteString("\n")
+
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tROLE\tCONFIGURED\tEXPECTED\tACTUAL\tHISTORY\tPROBLEMS\t")
+	for _, check := range nodes {
+		history := "-"
+		if check.HistoryRecord != nil {
+			history = fmt.Sprintf("%t", *check.HistoryRecord)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", check.Node.Alias, check.Role, check.Configured,
+			check.Expected, check.Actual, history, strings.Join(check.Problems, "; "))
+	}
+	w.Flush()
+	return sb.String()
+}
+
+// CheckCommissions verifies the commissions of the payment and fails the test on any mismatch.
+func (c *Cluster) CheckCommissions(t *testing.T, payment CommissionPayment, before, after *BalanceSnapshot) *CommissionReport {
+	t.Helper()
+	report, err := c.VerifyCommissions(payment, before, after)
+	if err != nil {
+		t.Fatalf("failed to verify commissions: %v", err)
+	}
+	if report.HasProblems() {
+		c.RecordAssertion("commissions", fmt.Errorf("accounting is wrong\n%s", report))
+		t.Fatalf("commission accounting is wrong\n%s", report)
+	}
+	c.RecordAssertion("commissions", nil)
+	t.Logf("%s", report)
+	return report
+}
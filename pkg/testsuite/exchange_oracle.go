@@ -0,0 +1,363 @@
+package testsuite
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Exchange rate model.
+//
+// A rate is set on the exchanging node as value and shift. The payment engine converts an amount of the "from"
+// equivalent into value·10^-shift times as much of the "to" equivalent (value=15, shift=1 is 1.5), rounding the
+// result down, so the node never pays out more than it was paid. The amount needed to get a given output is rounded
+// up. min/max exchange amounts limit the exchanged amount in the "from" equivalent, an expired rate is not used.
+//
+// real_rate reported by the rates API is the rate between whole units: value·10^-shift scaled by the decimals
+// of the equivalents, 10^(decimals from - decimals to).
+//
+// Every intermediate node adds its commission to what it has to receive. An exchanging node charges it in the
+// equivalent it receives, on top of the exchanged amount. No line of the path can carry more than its capacity.
+
+// EquivalentDecimals are the decimals vtcpd uses for the equivalents of the test suite.
+var EquivalentDecimals = map[string]int{
+	"101":  2,
+	"1001": 8,
+	"1002": 8,
+	"2002": 6,
+}
+
+// MaxRealRateDecimals is the precision of real_rate accepted by the rates API.
+const MaxRealRateDecimals = 16
+
+var (
+	ErrExchangeBelowMin      = errors.New("exchanged amount is below the min exchange amount")
+	ErrExchangeAboveMax      = errors.New("exchanged amount is above the max exchange amount")
+	ErrExchangeRateExpired   = errors.New("exchange rate is expired")
+	ErrAmountBelowCommission = errors.New("amount doesn't cover the commission")
+	ErrLineCapacityExceeded  = errors.New("amount exceeds the settlement line capacity")
+)
+
+// ExchangeRate is an exchange rate of a node.
+type ExchangeRate struct {
+	From  string
+	To    string
+	Value *big.Int
+	Shift int16
+	Min   *Amount // nil if there is no limit
+	Max   *Amount
+	// ExpiresAt is the zero time for a rate that doesn't expire.
+	ExpiresAt time.Time
+}
+
+// NewExchangeRate returns the rate from value and shift, with no limits and no expiry.
+func NewExchangeRate(from, to string, value int64, shift int16) ExchangeRate {
+	return ExchangeRate{From: from, To: to, Value: big.NewInt(value), Shift: shift}
+}
+
+// ExchangeRateFromItem converts a rate returned by the rates API.
+func ExchangeRateFromItem(item RateItem) (ExchangeRate, error) {
+	value, ok := new(big.Int).SetString(item.Value, 10)
+	if !ok {
+		return ExchangeRate{}, fmt.Errorf("invalid rate value %q", item.Value)
+	}
+	rate := ExchangeRate{From: item.EquivalentFrom, To: item.EquivalentTo, Value: value, Shift: item.Shift,
+		Min: item.MinExchangeAmount, Max: item.MaxExchangeAmount}
+	if item.ExpiresAtUnixMicroseconds != "" && item.ExpiresAtUnixMicroseconds != "0" {
+		expiresAt, err := strconv.ParseInt(item.ExpiresAtUnixMicroseconds, 10, 64)
+		if err != nil {
+			return ExchangeRate{}, fmt.Errorf("invalid expiry %q: %v", item.ExpiresAtUnixMicroseconds, err)
+		}
+		rate.ExpiresAt = time.UnixMicro(expiresAt)
+	}
+	return rate, nil
+}
+
+func parseOptionalAmount(s string) (*Amount, error) {
+	if s == "" {
+		return nil, nil
+	}
+	amount, err := ParseAmount(s)
+	if err != nil {
+		return nil, err
+	}
+	return &amount, nil
+}
+
+// factor is value·10^-shift, the multiplier of the exchanged amount.
+func (r ExchangeRate) factor() *big.Rat {
+	factor := new(big.Rat).SetInt(r.Value)
+	power := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs16(r.Shift))), nil))
+	if r.Shift >= 0 {
+		return factor.Quo(factor, power)
+	}
+	return factor.Mul(factor, power)
+}
+
+func abs16(v int16) int32 {
+	if v < 0 {
+		return -int32(v)
+	}
+	return int32(v)
+}
+
+// Convert returns the output of exchanging the amount, rounded down.
+func (r ExchangeRate) Convert(amount Amount) Amount {
+	output := new(big.Rat).Mul(new(big.Rat).SetInt(amount.big()), r.factor())
+	return Amount{value: new(big.Int).Quo(output.Num(), output.Denom())}
+}
+
+// Required returns the least amount that exchanges into at least output, rounded up.
+func (r ExchangeRate) Required(output Amount) Amount {
+	factor := r.factor()
+	if factor.Sign() == 0 {
+		panic("exchange rate is zero")
+	}
+	input := new(big.Rat).Quo(new(big.Rat).SetInt(output.big()), factor)
+	quotient, remainder := new(big.Int).QuoRem(input.Num(), input.Denom(), new(big.Int))
+	if remainder.Sign() > 0 {
+		quotient.Add(quotient, big.NewInt(1))
+	}
+	return Amount{value: quotient}
+}
+
+// Allows checks the exchanged amount against the limits and the rate against its expiry at the moment.
+func (r ExchangeRate) Allows(amount Amount, at time.Time) error {
+	if !r.ExpiresAt.IsZero() && !at.Before(r.ExpiresAt) {
+		return fmt.Errorf("%w: %s -> %s expired at %v", ErrExchangeRateExpired, r.From, r.To, r.ExpiresAt)
+	}
+	if r.Min != nil && amount.LessThan(*r.Min) {
+		return fmt.Errorf("%w: %s < %s", ErrExchangeBelowMin, amount, *r.Min)
+	}
+	if r.Max != nil && amount.GreaterThan(*r.Max) {
+		return fmt.Errorf("%w: %s > %s", ErrExchangeAboveMax, amount, *r.Max)
+	}
+	return nil
+}
+
+// RealRate returns real_rate as reported by the rates API for the rate between equivalents with the decimals.
+func (r ExchangeRate) RealRate(decimalsFrom, decimalsTo int) string {
+	exponent := decimalsFrom - decimalsTo - int(r.Shift)
+	digits := r.Value.String()
+	if exponent >= 0 {
+		if r.Value.Sign() == 0 {
+			return "0"
+		}
+		return digits + strings.Repeat("0", exponent)
+	}
+	negative := strings.HasPrefix(digits, "-")
+	digits = strings.TrimPrefix(digits, "-")
+	fraction := -exponent
+	if len(digits) <= fraction {
+		digits = strings.Repeat("0", fraction-len(digits)+1) + digits
+	}
+	whole, decimals := digits[:len(digits)-fraction], strings.TrimRight(digits[len(digits)-fraction:], "0")
+	result := whole
+	if decimals != "" {
+		result += "." + decimals
+	}
+	if negative && result != "0" {
+		result = "-" + result
+	}
+	return result
+}
+
+// ParseRealRate converts real_rate into value and shift the way the rates API does: the shift is the number
+// of decimal places of the rate between the smallest units.
+func ParseRealRate(realRate string, decimalsFrom, decimalsTo int) (string, int16, error) {
+	whole, decimals, _ := strings.Cut(strings.TrimSpace(realRate), ".")
+	if whole == "" || strings.ContainsAny(whole+decimals, "+-eE") {
+		return "", 0, fmt.Errorf("invalid real rate %q", realRate)
+	}
+	if len(decimals) > MaxRealRateDecimals {
+		return "", 0, fmt.Errorf("real rate %q has more than %d decimal places", realRate, MaxRealRateDecimals)
+	}
+	value, ok := new(big.Int).SetString(whole+decimals, 10)
+	if !ok {
+		return "", 0, fmt.Errorf("invalid real rate %q", realRate)
+	}
+
+	shift := len(decimals) + decimalsFrom - decimalsTo
+	ten := big.NewInt(10)
+	for shift < 0 {
+		value.Mul(value, ten)
+		shift++
+	}
+	remainder := new(big.Int)
+	for shift > 0 && value.Sign() != 0 {
+		quotient, _ := new(big.Int).QuoRem(value, ten, remainder)
+		if remainder.Sign() != 0 {
+			break
+		}
+		value = quotient
+		shift--
+	}
+	if value.Sign() == 0 {
+		shift = 0
+	}
+	if shift > 32767 {
+		return "", 0, fmt.Errorf("real rate %q is out of range", realRate)
+	}
+	return value.String(), int16(shift), nil
+}
+
+// ExchangeHop is an intermediate node of a payment path.
+type ExchangeHop struct {
+	Node *Node
+	// Commission is charged in the equivalent the node receives.
+	Commission Amount
+	// Rate is nil if the node passes the payment on in the same equivalent.
+	Rate *ExchangeRate
+}
+
+// ExchangePath is a payment path from the coordinator to the receiver.
+type ExchangePath struct {
+	Coordinator *Node
+	Receiver    *Node
+	Hops        []ExchangeHop
+	// PayerEquivalent is the equivalent the coordinator pays in.
+	PayerEquivalent string
+	// Capacities are the most the lines of the path can carry, by the paying node's edge.
+	// Lines that are not listed are not limited.
+	Capacities map[BalanceEdge]Amount
+}
+
+// ExchangeTransfer is what a node pays the next one on the path.
+type ExchangeTransfer struct {
+	Edge       BalanceEdge // the paying node's line with the next node
+	Equivalent string
+	Amount     Amount
+}
+
+// NewExchangeHop describes an intermediate node with the commission of its config in the equivalent it receives
+// and an optional rate.
+func NewExchangeHop(node *Node, receivedEquivalent string, rate *ExchangeRate) ExchangeHop {
+	return ExchangeHop{Node: node, Commission: node.Commission(receivedEquivalent), Rate: rate}
+}
+
+// EstimatePayment predicts the amount the coordinator pays for the receiver to get receive,
+// as the payment estimation API does.
+func (p ExchangePath) EstimatePayment(receive Amount, at time.Time) (Amount, error) {
+	transfers, err := p.Transfers(receive, at)
+	if err != nil {
+		return Amount{}, err
+	}
+	return transfers[0].Amount, nil
+}
+
+// EstimateReceive predicts the amount the receiver gets when the coordinator pays payment,
+// as the receive estimation API does.
+func (p ExchangePath) EstimateReceive(payment Amount, at time.Time) (Amount, error) {
+	amount := payment
+	for i, hop := range p.Hops {
+		if err := p.carries(i, amount); err != nil {
+			return Amount{}, err
+		}
+		if amount.LessThan(hop.Commission) || amount.Equal(hop.Commission) {
+			return Amount{}, fmt.Errorf("Node %s: %w: %s <= %s", hop.Node.Alias, ErrAmountBelowCommission, amount, hop.Commission)
+		}
+		amount = amount.Sub(hop.Commission)
+		if hop.Rate == nil {
+			continue
+		}
+		if err := hop.Rate.Allows(amount, at); err != nil {
+			return Amount{}, fmt.Errorf("Node %s: %w", hop.Node.Alias, err)
+		}
+		amount = hop.Rate.Convert(amount)
+	}
+	if err := p.carries(len(p.Hops), amount); err != nil {
+		return Amount{}, err
+	}
+	return amount, nil
+}
+
+// Transfers predicts what every node of the path pays the next one for the receiver to get receive,
+// from the coordinator's debit to the receiver's credit.
+func (p ExchangePath) Transfers(receive Amount, at time.Time) ([]ExchangeTransfer, error) {
+	// Equivalents of the lines, forward from the payer
+	equivalents := make([]string, len(p.Hops)+1)
+	equivalents[0] = p.PayerEquivalent
+	for i, hop := range p.Hops {
+		equivalents[i+1] = equivalents[i]
+		if hop.Rate != nil {
+			if hop.Rate.From != equivalents[i] {
+				return nil, fmt.Errorf("Node %s: receives %s, but exchanges %s -> %s",
+					hop.Node.Alias, equivalents[i], hop.Rate.From, hop.Rate.To)
+			}
+			equivalents[i+1] = hop.Rate.To
+		}
+	}
+
+	transfers := make([]ExchangeTransfer, len(p.Hops)+1)
+	amount := receive
+	for i := len(p.Hops); i >= 0; i-- {
+		if err := p.carries(i, amount); err != nil {
+			return nil, err
+		}
+		transfers[i] = ExchangeTransfer{Edge: p.edge(i), Equivalent: equivalents[i], Amount: amount}
+		if i == 0 {
+			break
+		}
+		hop := p.Hops[i-1]
+		if hop.Rate != nil {
+			amount = hop.Rate.Required(amount)
+			if err := hop.Rate.Allows(amount, at); err != nil {
+				return nil, fmt.Errorf("Node %s: %w", hop.Node.Alias, err)
+			}
+		}
+		amount = amount.Add(hop.Commission)
+	}
+	return transfers, nil
+}
+
+// edge returns the line of the i-th payment of the path, counting from the coordinator's.
+func (p ExchangePath) edge(i int) BalanceEdge {
+	payer, receiver := p.Coordinator, p.Receiver
+	if i > 0 {
+		payer = p.Hops[i-1].Node
+	}
+	if i < len(p.Hops) {
+		receiver = p.Hops[i].Node
+	}
+	return Edge(payer, receiver)
+}
+
+// carries checks the amount of the i-th payment of the path against the capacity of its line.
+func (p ExchangePath) carries(i int, amount Amount) error {
+	capacity, ok := p.Capacities[p.edge(i)]
+	if ok && amount.GreaterThan(capacity) {
+		return fmt.Errorf("%s: %w: %s > %s", p.edge(i), ErrLineCapacityExceeded, amount, capacity)
+	}
+	return nil
+}
+
+// Deltas returns the expected balance deltas of the path in the equivalent, for AssertDeltas.
+func (p ExchangePath) Deltas(receive Amount, equivalent string, at time.Time) (map[BalanceEdge]Amount, error) {
+	transfers, err := p.Transfers(receive, at)
+	if err != nil {
+		return nil, err
+	}
+	deltas := make(map[BalanceEdge]Amount)
+	for _, transfer := range transfers {
+		if transfer.Equivalent == equivalent {
+			deltas[transfer.Edge] = deltas[transfer.Edge].Sub(transfer.Amount)
+		}
+	}
+	return deltas, nil
+}
+
+// ExpectedEstimateStatus is the status the estimation API is expected to return for an oracle error.
+func ExpectedEstimateStatus(err error) int {
+	switch {
+	case err == nil:
+		return StatusOK
+	case errors.Is(err, ErrExchangeRateExpired):
+		// An expired rate is dropped, as if it was never set
+		return StatusNoPaymentRoutes
+	default:
+		return StatusInsufficientFunds
+	}
+}
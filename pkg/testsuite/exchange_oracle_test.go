@@ -0,0 +1,320 @@
+package testsuite
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExchangeRateConvert(t *testing.T) {
+	tests := []struct {
+		value    int64
+		shift    int16
+		amount   int64
+		expected int64
+	}{
+		{value: 2, shift: 0, amount: 190, expected: 380},
+		{value: 15, shift: 1, amount: 10, expected: 15},
+		// 4.5 is rounded down
+		{value: 15, shift: 1, amount: 3, expected: 4},
+		{value: 333, shift: 3, amount: 1000, expected: 333},
+		// 332.667
+		{value: 333, shift: 3, amount: 999, expected: 332},
+		// 0.07 of 10 is less than one unit
+		{value: 7, shift: 2, amount: 10, expected: 0},
+		{value: 2, shift: -1, amount: 3, expected: 60},
+	}
+
+	for _, test := range tests {
+		rate := NewExchangeRate("1001", "2002", test.value, test.shift)
+		if output := rate.Convert(NewAmount(test.amount)); !output.Equal(NewAmount(test.expected)) {
+			t.Errorf("value=%d shift=%d, %d: converted amount mismatch.\nExpected: %d\nGot:      %s",
+				test.value, test.shift, test.amount, test.expected, output)
+		}
+	}
+}
+
+func TestExchangeRateRequired(t *testing.T) {
+	tests := []struct {
+		value    int64
+		shift    int16
+		output   int64
+		expected int64
+	}{
+		{value: 2, shift: 0, output: 400, expected: 200},
+		// 200.5 is rounded up
+		{value: 2, shift: 0, output: 401, expected: 201},
+		{value: 15, shift: 1, output: 15, expected: 10},
+		// 2.67
+		{value: 15, shift: 1, output: 4, expected: 3},
+		{value: 333, shift: 3, output: 333, expected: 1000},
+		// 996.997
+		{value: 333, shift: 3, output: 332, expected: 997},
+		{value: 2, shift: -1, output: 61, expected: 4},
+	}
+
+	for _, test := range tests {
+		rate := NewExchangeRate("1001", "2002", test.value, test.shift)
+		required := rate.Required(NewAmount(test.output))
+		if !required.Equal(NewAmount(test.expected)) {
+			t.Errorf("value=%d shift=%d, %d: required amount mismatch.\nExpected: %d\nGot:      %s",
+				test.value, test.shift, test.output, test.expected, required)
+		}
+		// The required amount is the least one that gives the output
+		if rate.Convert(required).LessThan(NewAmount(test.output)) {
+			t.Errorf("value=%d shift=%d: %s converts into less than %d", test.value, test.shift, required, test.output)
+		}
+		if less := required.Sub(NewAmount(1)); !rate.Convert(less).LessThan(NewAmount(test.output)) {
+			t.Errorf("value=%d shift=%d: %s is not the least amount for %d", test.value, test.shift, required, test.output)
+		}
+	}
+}
+
+func TestExchangeRateAllows(t *testing.T) {
+	min, max := NewAmount(50), NewAmount(1500)
+	now := time.Now()
+	rate := ExchangeRate{From: "1001", To: "2002", Value: big.NewInt(1), Min: &min, Max: &max, ExpiresAt: now.Add(time.Minute)}
+
+	tests := []struct {
+		name    string
+		amount  int64
+		at      time.Time
+		wantErr error
+	}{
+		{name: "min", amount: 50, at: now},
+		{name: "max", amount: 1500, at: now},
+		{name: "below min", amount: 49, at: now, wantErr: ErrExchangeBelowMin},
+		{name: "above max", amount: 1501, at: now, wantErr: ErrExchangeAboveMax},
+		{name: "expired", amount: 100, at: now.Add(time.Minute), wantErr: ErrExchangeRateExpired},
+	}
+
+	for _, test := range tests {
+		if err := rate.Allows(NewAmount(test.amount), test.at); !errors.Is(err, test.wantErr) || (err != nil) != (test.wantErr != nil) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.wantErr, err)
+		}
+	}
+}
+
+func TestExchangeRateRealRate(t *testing.T) {
+	tests := []struct {
+		value        string
+		shift        int16
+		decimalsFrom int
+		decimalsTo   int
+		expected     string
+	}{
+		{value: "11207154", shift: 4, decimalsFrom: 8, decimalsTo: 6, expected: "112071.54"},
+		{value: "112071", shift: 2, decimalsFrom: 8, decimalsTo: 6, expected: "112071"},
+		{value: "2", shift: 0, decimalsFrom: 8, decimalsTo: 8, expected: "2"},
+		{value: "15", shift: 1, decimalsFrom: 8, decimalsTo: 8, expected: "1.5"},
+		{value: "1", shift: 0, decimalsFrom: 2, decimalsTo: 8, expected: "0.000001"},
+		{value: "5", shift: -1, decimalsFrom: 8, decimalsTo: 8, expected: "50"},
+		{value: "0", shift: 3, decimalsFrom: 8, decimalsTo: 8, expected: "0"},
+	}
+
+	for _, test := range tests {
+		value, _ := new(big.Int).SetString(test.value, 10)
+		rate := ExchangeRate{Value: value, Shift: test.shift}
+		if realRate := rate.RealRate(test.decimalsFrom, test.decimalsTo); realRate != test.expected {
+			t.Errorf("value=%s shift=%d: real rate mismatch.\nExpected: %s\nGot:      %s", test.value, test.shift, test.expected, realRate)
+		}
+	}
+}
+
+func TestParseRealRate(t *testing.T) {
+	tests := []struct {
+		realRate      string
+		decimalsFrom  int
+		decimalsTo    int
+		expectedValue string
+		expectedShift int16
+		wantErr       bool
+	}{
+		{realRate: "112071.54", decimalsFrom: 8, decimalsTo: 6, expectedValue: "11207154", expectedShift: 4},
+		{realRate: "2", decimalsFrom: 8, decimalsTo: 8, expectedValue: "2", expectedShift: 0},
+		// Trailing zeros don't add to the shift
+		{realRate: "1.50", decimalsFrom: 8, decimalsTo: 8, expectedValue: "15", expectedShift: 1},
+		{realRate: "0.000001", decimalsFrom: 2, decimalsTo: 8, expectedValue: "1", expectedShift: 0},
+		{realRate: "100", decimalsFrom: 6, decimalsTo: 8, expectedValue: "10000", expectedShift: 0},
+		{realRate: "0", decimalsFrom: 8, decimalsTo: 8, expectedValue: "0", expectedShift: 0},
+		{realRate: "0.12345678901234567", decimalsFrom: 8, decimalsTo: 8, wantErr: true},
+		{realRate: "-1", decimalsFrom: 8, decimalsTo: 8, wantErr: true},
+		{realRate: "1e5", decimalsFrom: 8, decimalsTo: 8, wantErr: true},
+		{realRate: ".5", decimalsFrom: 8, decimalsTo: 8, wantErr: true},
+		{realRate: "abc", decimalsFrom: 8, decimalsTo: 8, wantErr: true},
+	}
+
+	for _, test := range tests {
+		value, shift, err := ParseRealRate(test.realRate, test.decimalsFrom, test.decimalsTo)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected an error, got value=%s shift=%d", test.realRate, value, shift)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.realRate, err)
+			continue
+		}
+		if value != test.expectedValue || shift != test.expectedShift {
+			t.Errorf("%q: native rate mismatch.\nExpected: value=%s shift=%d\nGot:      value=%s shift=%d",
+				test.realRate, test.expectedValue, test.expectedShift, value, shift)
+		}
+
+		// The rate reads back as the same real rate
+		native, _ := new(big.Int).SetString(value, 10)
+		rate := ExchangeRate{Value: native, Shift: shift}
+		if !sameDecimal(test.realRate, rate.RealRate(test.decimalsFrom, test.decimalsTo)) {
+			t.Errorf("%q: reads back as %s", test.realRate, rate.RealRate(test.decimalsFrom, test.decimalsTo))
+		}
+	}
+}
+
+// exchangePath is A -> B(commission 10) -> X(exchange 1001 -> 2002 at 2) -> C, the lines of the payer's
+// equivalent can carry 2000 and the receiver's one 4000.
+func exchangePath(rate ExchangeRate) ExchangePath {
+	a, b, x, c := &Node{Alias: "A"}, &Node{Alias: "B"}, &Node{Alias: "X"}, &Node{Alias: "C"}
+	return ExchangePath{
+		Coordinator: a,
+		Receiver:    c,
+		Hops: []ExchangeHop{
+			{Node: b, Commission: NewAmount(10)},
+			{Node: x, Rate: &rate},
+		},
+		PayerEquivalent: "1001",
+		Capacities: map[BalanceEdge]Amount{
+			Edge(a, b): NewAmount(2000),
+			Edge(b, x): NewAmount(2000),
+			Edge(x, c): NewAmount(4000),
+		},
+	}
+}
+
+func TestExchangePathEstimateReceive(t *testing.T) {
+	min, max := NewAmount(100), NewAmount(1000)
+	limited := NewExchangeRate("1001", "2002", 2, 0)
+	limited.Min, limited.Max = &min, &max
+
+	tests := []struct {
+		name     string
+		rate     ExchangeRate
+		payment  int64
+		expected int64
+		wantErr  error
+	}{
+		{name: "exchange", rate: NewExchangeRate("1001", "2002", 2, 0), payment: 200, expected: 380},
+		{name: "rounded down", rate: NewExchangeRate("1001", "2002", 15, 1), payment: 13, expected: 4},
+		{name: "commission", rate: NewExchangeRate("1001", "2002", 2, 0), payment: 10, wantErr: ErrAmountBelowCommission},
+		{name: "payer line", rate: NewExchangeRate("1001", "2002", 2, 0), payment: 2001, wantErr: ErrLineCapacityExceeded},
+		{name: "receiver line", rate: NewExchangeRate("1001", "2002", 3, 0), payment: 1500, wantErr: ErrLineCapacityExceeded},
+		{name: "below min", rate: limited, payment: 100, wantErr: ErrExchangeBelowMin},
+		{name: "above max", rate: limited, payment: 1100, wantErr: ErrExchangeAboveMax},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			receive, err := exchangePath(test.rate).EstimateReceive(NewAmount(test.payment), time.Now())
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("expected %v, got %v", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !receive.Equal(NewAmount(test.expected)) {
+				t.Errorf("received amount mismatch.\nExpected: %d\nGot:      %s", test.expected, receive)
+			}
+		})
+	}
+}
+
+func TestExchangePathTransfers(t *testing.T) {
+	path := exchangePath(NewExchangeRate("1001", "2002", 2, 0))
+	a, b, x, c := path.Coordinator, path.Hops[0].Node, path.Hops[1].Node, path.Receiver
+
+	transfers, err := path.Transfers(NewAmount(401), time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// X needs 200.5 rounded up, B adds its commission
+	expected := []ExchangeTransfer{
+		{Edge: Edge(a, b), Equivalent: "1001", Amount: NewAmount(211)},
+		{Edge: Edge(b, x), Equivalent: "1001", Amount: NewAmount(201)},
+		{Edge: Edge(x, c), Equivalent: "2002", Amount: NewAmount(401)},
+	}
+	if len(transfers) != len(expected) {
+		t.Fatalf("transfers mismatch.\nExpected: %v\nGot:      %v", expected, transfers)
+	}
+	for i := range expected {
+		if transfers[i].Edge != expected[i].Edge || transfers[i].Equivalent != expected[i].Equivalent ||
+			!transfers[i].Amount.Equal(expected[i].Amount) {
+			t.Errorf("transfer %d mismatch.\nExpected: %v\nGot:      %v", i, expected[i], transfers[i])
+		}
+	}
+
+	payment, err := path.EstimatePayment(NewAmount(401), time.Now())
+	if err != nil || !payment.Equal(NewAmount(211)) {
+		t.Errorf("expected a payment of 211, got %s, %v", payment, err)
+	}
+
+	deltas, err := path.Deltas(NewAmount(401), "1001", time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectedDeltas := map[BalanceEdge]string{Edge(a, b): "-211", Edge(b, x): "-201"}
+	actualDeltas := make(map[BalanceEdge]string)
+	for edge, delta := range deltas {
+		actualDeltas[edge] = delta.String()
+	}
+	if !reflect.DeepEqual(actualDeltas, expectedDeltas) {
+		t.Errorf("deltas mismatch.\nExpected: %v\nGot:      %v", expectedDeltas, actualDeltas)
+	}
+}
+
+func TestExchangePathTransfersErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    ExchangePath
+		receive int64
+		wantErr error
+	}{
+		{name: "payer line", path: exchangePath(NewExchangeRate("1001", "2002", 1, 0)), receive: 1991, wantErr: ErrLineCapacityExceeded},
+		{name: "receiver line", path: exchangePath(NewExchangeRate("1001", "2002", 4, 0)), receive: 4001, wantErr: ErrLineCapacityExceeded},
+		{name: "expired rate", path: exchangePath(ExchangeRate{From: "1001", To: "2002", Value: big.NewInt(2),
+			ExpiresAt: time.Now().Add(-time.Second)}), receive: 100, wantErr: ErrExchangeRateExpired},
+	}
+
+	for _, test := range tests {
+		if _, err := test.path.Transfers(NewAmount(test.receive), time.Now()); !errors.Is(err, test.wantErr) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.wantErr, err)
+		}
+	}
+
+	// The exchanging node receives the payer's equivalent, not the rate's one
+	path := exchangePath(NewExchangeRate("1002", "2002", 2, 0))
+	if _, err := path.Transfers(NewAmount(100), time.Now()); err == nil {
+		t.Errorf("expected an error for a rate of another equivalent")
+	}
+}
+
+func TestExpectedEstimateStatus(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected int
+	}{
+		{err: nil, expected: StatusOK},
+		{err: ErrExchangeRateExpired, expected: StatusNoPaymentRoutes},
+		{err: ErrExchangeAboveMax, expected: StatusInsufficientFunds},
+		{err: ErrLineCapacityExceeded, expected: StatusInsufficientFunds},
+	}
+
+	for _, test := range tests {
+		if status := ExpectedEstimateStatus(test.err); status != test.expected {
+			t.Errorf("%v: expected status %d, got %d", test.err, test.expected, status)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package testsuite
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"text/tabwriter"
+	"time"
+)
+
+// Fault-injection matrix.
+//
+// For every selected testing flag and every node role, a fresh cluster is built, the flag is set on the node
+// playing the role and the payment is made. Whatever the payment status is, the cluster must end up consistent:
+// settlement lines are in sync, no serialized transactions are left, and the nodes agree on the payment state.
+
+const (
+	FaultRoleCoordinator  = "coordinator"
+	FaultRoleIntermediate = "intermediate"
+	FaultRoleReceiver     = "receiver"
+)
+
+// FaultRole is a node of the topology the flags are set on.
+type FaultRole struct {
+	Name      string
+	NodeIndex int
+}
+
+// FaultMatrix describes the matrix to run.
+type FaultMatrix struct {
+	// Setup builds the topology on a fresh cluster, it is called for every cell of the matrix.
+	Setup func(t *testing.T) ([]*Node, *Cluster)
+
+	Roles []FaultRole
+	// Flags to inject, every flag of TestingFlagsByName if empty.
+	Flags []uint64
+
+	Coordinator int // index of the paying node
+	Receiver    int // index of the receiving node
+	Equivalent  string
+	Amount      string
+
+	// SettleTime is waited after the payment before checking the invariants (recovery, observing, etc.).
+	SettleTime time.Duration
+
+	// ReportDir is where the matrix report is written, the report is only logged if empty.
+	ReportDir string
+}
+
+// FaultMatrixCell is the outcome of one flag set on one role.
+type FaultMatrixCell struct {
+	Flag            string  `json:"flag"`
+	Role            string  `json:"role"`
+	StatusCode      int     `json:"status_code"`
+	TransactionUUID string  `json:"transaction_uuid,omitempty"`
+	Passed          bool    `json:"passed"`
+	DurationSec     float64 `json:"duration_sec"`
+}
+
+// FaultMatrixReport is the outcome of the whole matrix.
+type FaultMatrixReport struct {
+	Name  string            `json:"name"`
+	Flags []string          `json:"flags"`
+	Roles []string          `json:"roles"`
+	Cells []FaultMatrixCell `json:"cells"`
+}
+
+func (m FaultMatrix) flags() []uint64 {
+	if len(m.Flags) > 0 {
+		return m.Flags
+	}
+	flags := make([]uint64, 0, len(TestingFlagsByName))
+	for _, flag := range TestingFlagsByName {
+		flags = append(flags, flag)
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i] < flags[j] })
+	return flags
+}
+
+// RunFaultMatrix runs every cell of the matrix as a subtest and returns the report.
+// The report is logged, and saved as <ReportDir>/fault-matrix-<test>.json if ReportDir is set.
+func RunFaultMatrix(t *testing.T, matrix FaultMatrix) *FaultMatrixReport {
+	report := &FaultMatrixReport{Name: t.Name()}
+	for _, role := range matrix.Roles {
+		report.Roles = append(report.Roles, role.Name)
+	}
+
+	for _, flag := range matrix.flags() {
+		flagName := testingFlagName(flag)
+		report.Flags = append(report.Flags, flagName)
+
+		for _, role := range matrix.Roles {
+			cell := FaultMatrixCell{Flag: flagName, Role: role.Name}
+			started := time.Now()
+			cell.Passed = t.Run(flagName+"@"+role.Name, func(t *testing.T) {
+				runFaultMatrixCell(t, matrix, flag, role, &cell)
+			})
+			cell.DurationSec = time.Since(started).Seconds()
+			report.Cells = append(report.Cells, cell)
+		}
+	}
+
+	t.Log("\n" + report.String())
+	if matrix.ReportDir != "" {
+		if err := report.write(matrix.ReportDir); err != nil {
+			t.Logf("failed to write fault matrix report: %v", err)
+		}
+	}
+	return report
+}
+
+func runFaultMatrixCell(t *testing.T, matrix FaultMatrix, flag uint64, role FaultRole, cell *FaultMatrixCell) {
+	nodes, cluster := matrix.Setup(t)
+	coordinator, receiver := nodes[matrix.Coordinator], nodes[matrix.Receiver]
+
+	cluster.LogTimelineOnFailure(t)
+	nodes[role.NodeIndex].Testing.Apply(NewTestingFlags(flag), "", "")
+
+	transactionUUID, statusCode, body, err := coordinator.CreateTransaction(receiver, matrix.Equivalent, matrix.Amount)
+	cell.StatusCode, cell.TransactionUUID = statusCode, transactionUUID
+	if statusCode == 0 {
+		t.Fatalf("payment failed: %v", err)
+	}
+	t.Logf("payment status: %d, body: %s", statusCode, body)
+
+	time.Sleep(matrix.SettleTime)
+
+	CheckSettlementLineForSyncBatch(t, nodes, matrix.Equivalent, 0)
+	for _, node := range nodes {
+		node.CheckSerializedTransaction(t, false, 0)
+	}
+	checkPaymentStateConsistency(t, nodes)
+}
+
+// checkPaymentStateConsistency checks that the nodes which still keep the payment transaction
+// agree on its observing state.
+func checkPaymentStateConsistency(t *testing.T, nodes []*Node) {
+	states := make(map[string][]string)
+	for _, node := range nodes {
+		state, err := node.storage().LatestPaymentTransactionState()
+		if err != nil {
+			t.Fatalf("Node %s: Error querying payment transaction state. Error: %v", node.Alias, err)
+		}
+		if state == "" {
+			continue
+		}
+		states[state] = append(states[state], node.Alias)
+	}
+
+	if len(states) > 1 {
+		var details []string
+		for state, aliases := range states {
+			details = append(details, fmt.Sprintf("%s: %s", state, strings.Join(aliases, ", ")))
+		}
+		sort.Strings(details)
+		t.Fatalf("nodes disagree on the payment transaction state (state: nodes): %s", strings.Join(details, "; "))
+	}
+}
+
+// String renders the matrix: a row per flag, a column per role, every cell is "ok" or "FAIL" and the payment status.
+func (r *FaultMatrixReport) String() string {
+	cells := make(map[string]FaultMatrixCell, len(r.Cells))
+	for _, cell := range r.Cells {
+		cells[cell.Flag+"@"+cell.Role] = cell
+	}
+
+	var builder strings.Builder
+	writer := tabwriter.NewWriter(&builder, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(writer, "FLAG\t%s\n", strings.Join(r.Roles, "\t"))
+	for _, flag := range r.Flags {
+		row := []string{flag}
+		for _, role := range r.Roles {
+			cell, ok := cells[flag+"@"+role]
+			switch {
+			case !ok:
+				row = append(row, "-")
+			case cell.Passed:
+				row = append(row, fmt.Sprintf("ok (%d)", cell.StatusCode))
+			default:
+				row = append(row, fmt.Sprintf("FAIL (%d)", cell.StatusCode))
+			}
+		}
+		fmt.Fprintln(writer, strings.Join(row, "\t"))
+	}
+	writer.Flush()
+	return builder.String()
+}
+
+func (r *FaultMatrixReport) write(reportDir string) error {
+	if err := os.MkdirAll(reportDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create report directory %s: %w", reportDir, err)
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal fault matrix report: %w", err)
+	}
+	path := filepath.Join(reportDir, "fault-matrix-"+sanitizeFileName(r.Name)+".json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write fault matrix report %s: %w", path, err)
+	}
+	return nil
+}
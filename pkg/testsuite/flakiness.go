@@ -0,0 +1,187 @@
+package testsuite
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// Flaky test detection.
+//
+// The rerun mode of vtcp-suite runs the tests, reruns every failed test several times, each run on a fresh cluster,
+// and classifies the test by the outcomes. The classification and the attempts are added to the test's entry of
+// the subsystem report, and the classifications are accumulated per test in a history file across runs.
+
+const (
+	FlakinessPassed       = "passed"               // passed at the first attempt
+	FlakinessFlaky        = "flaky"                // failed, then passed at least once on rerun
+	FlakinessFailing      = "consistently_failing" // failed at every attempt
+	FlakinessInconclusive = "inconclusive"         // skipped, or failed once and skipped at every rerun
+
+	// maxRecentFlakiness is the number of the latest classifications kept per test in the history.
+	maxRecentFlakiness = 20
+)
+
+// TestAttempt is a single run of a test.
+type TestAttempt struct {
+	Status      string  `json:"status"`
+	DurationSec float64 `json:"duration_sec"`
+	// ReportDir holds the reports and the node artifacts of the attempt, relative to the report directory.
+	// It is empty for the first attempt, whose report is the report directory itself.
+	ReportDir string `json:"report_dir,omitempty"`
+}
+
+// TestFlakiness is the classification of a test and the attempts it is based on, first attempt first.
+type TestFlakiness struct {
+	Classification string        `json:"classification"`
+	Attempts       []TestAttempt `json:"attempts"`
+}
+
+// ClassifyAttempts classifies a test by its attempts: passed if the first attempt passed, flaky if any rerun passed,
+// consistently failing if every attempt failed. Skipped attempts tell nothing about the test and are left out,
+// a test with less than two failed attempts besides the skipped ones is inconclusive.
+func ClassifyAttempts(attempts []TestAttempt) string {
+	failed, skipped := 0, 0
+	for i, attempt := range attempts {
+		switch attempt.Status {
+		case TestStatusFailed:
+			failed++
+		case TestStatusSkipped:
+			skipped++
+		default:
+			if i == 0 {
+				return FlakinessPassed
+			}
+			return FlakinessFlaky
+		}
+	}
+	if skipped > 0 && failed < 2 {
+		return FlakinessInconclusive
+	}
+	return FlakinessFailing
+}
+
+// AddFlakinessToReport sets the flakiness of the tests, by name, in the subsystem report of reportDir and rewrites
+// its JSON and JUnit files. Tests that have no entry in the report (e.g. they don't run a cluster) get one.
+func AddFlakinessToReport(reportDir, subsystem string, flakiness map[string]*TestFlakiness) error {
+	report, err := readTestReport(reportDir, subsystem)
+	if err != nil {
+		return err
+	}
+
+	found := make(map[string]bool)
+	for i := range report.Tests {
+		entry := &report.Tests[i]
+		if testFlakiness, ok := flakiness[entry.Name]; ok {
+			entry.Flakiness = testFlakiness
+			found[entry.Name] = true
+		}
+	}
+	names := make([]string, 0, len(flakiness))
+	for name := range flakiness {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		testFlakiness := flakiness[name]
+		if found[name] || len(testFlakiness.Attempts) == 0 {
+			continue
+		}
+		report.Tests = append(report.Tests, TestReportEntry{
+			Name:        name,
+			Subsystem:   subsystem,
+			Status:      testFlakiness.Attempts[0].Status,
+			DurationSec: testFlakiness.Attempts[0].DurationSec,
+			Flakiness:   testFlakiness,
+		})
+	}
+
+	return writeTestReportFiles(reportDir, report)
+}
+
+// FlakinessHistoryEntry is the accumulated classifications of a test.
+type FlakinessHistoryEntry struct {
+	Runs         int       `json:"runs"`
+	Passed       int       `json:"passed"`
+	Flaky        int       `json:"flaky"`
+	Failing      int       `json:"consistently_failing"`
+	Inconclusive int       `json:"inconclusive"`
+	LastRunAt    time.Time `json:"last_run_at"`
+	// Recent are the classifications of the latest runs, oldest first.
+	Recent []string `json:"recent"`
+}
+
+// FlakyRate returns the share of the runs in which the test turned out flaky.
+func (e *FlakinessHistoryEntry) FlakyRate() float64 {
+	if e.Runs == 0 {
+		return 0
+	}
+	return float64(e.Flaky) / float64(e.Runs)
+}
+
+// FlakinessHistory is the content of the history file, tests are keyed by "<subsystem>/<test>".
+type FlakinessHistory struct {
+	Tests map[string]*FlakinessHistoryEntry `json:"tests"`
+}
+
+// LoadFlakinessHistory reads the history file, a missing file is an empty history.
+func LoadFlakinessHistory(path string) (*FlakinessHistory, error) {
+	history := &FlakinessHistory{Tests: make(map[string]*FlakinessHistoryEntry)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read flakiness history %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, history); err != nil {
+		return nil, fmt.Errorf("failed to parse flakiness history %s: %w", path, err)
+	}
+	if history.Tests == nil {
+		history.Tests = make(map[string]*FlakinessHistoryEntry)
+	}
+	return history, nil
+}
+
+// Record adds a classification of the test and returns the updated entry.
+func (h *FlakinessHistory) Record(subsystem, test, classification string, at time.Time) *FlakinessHistoryEntry {
+	key := subsystem + "/" + test
+	entry, ok := h.Tests[key]
+	if !ok {
+		entry = &FlakinessHistoryEntry{}
+		h.Tests[key] = entry
+	}
+
+	entry.Runs++
+	switch classification {
+	case FlakinessPassed:
+		entry.Passed++
+	case FlakinessFlaky:
+		entry.Flaky++
+	case FlakinessFailing:
+		entry.Failing++
+	case FlakinessInconclusive:
+		entry.Inconclusive++
+	}
+	entry.LastRunAt = at
+	entry.Recent = append(entry.Recent, classification)
+	if len(entry.Recent) > maxRecentFlakiness {
+		entry.Recent = entry.Recent[len(entry.Recent)-maxRecentFlakiness:]
+	}
+	return entry
+}
+
+// Save writes the history file.
+func (h *FlakinessHistory) Save(path string) error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal flakiness history: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write flakiness history %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package testsuite
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClassifyAttempts(t *testing.T) {
+	tests := []struct {
+		statuses string
+		want     string
+	}{
+		{statuses: "passed", want: FlakinessPassed},
+		{statuses: "failed,passed", want: FlakinessFlaky},
+		{statuses: "failed,skipped,passed", want: FlakinessFlaky},
+		{statuses: "failed,failed,failed", want: FlakinessFailing},
+		{statuses: "failed,skipped,failed", want: FlakinessFailing},
+		{statuses: "failed,skipped,skipped", want: FlakinessInconclusive},
+		{statuses: "skipped", want: FlakinessInconclusive},
+	}
+
+	for _, test := range tests {
+		t.Run(test.statuses, func(t *testing.T) {
+			var attempts []TestAttempt
+			for _, status := range strings.Split(test.statuses, ",") {
+				attempts = append(attempts, TestAttempt{Status: status})
+			}
+			if got := ClassifyAttempts(attempts); got != test.want {
+				t.Errorf("Classification mismatch. Expected: %s, got: %s", test.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package testsuite
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// History API.
+//
+// Every history endpoint is paginated by offset and count in the path, and most of them are bound to an equivalent.
+// HistoryFilter carries the page and the optional filters, which are passed to the node as query parameters.
+
+const DefaultHistoryPageSize = 10
+
+// Query parameters of the history endpoints of vtcpd-cli (the filters of TestHistoryPaymentsFilters).
+const (
+	historyParamDateFrom   = "date_from_unix_timestamp"
+	historyParamDateTo     = "date_to_unix_timestamp"
+	historyParamAmountFrom = "amount_from"
+	historyParamAmountTo   = "amount_to"
+	historyParamContractor = "contractor_address"
+)
+
+// HistoryFilter selects history records. Zero values mean "no filter".
+type HistoryFilter struct {
+	Equivalent string // Ignored by the all-equivalents history
+	Offset     int
+	Count      int // Page size, DefaultHistoryPageSize if zero
+
+	From time.Time
+	To   time.Time
+
+	AmountFrom string
+	AmountTo   string
+
+	Contractor *Node
+}
+
+func (f HistoryFilter) pageSize() int {
+	if f.Count <= 0 {
+		return DefaultHistoryPageSize
+	}
+	return f.Count
+}
+
+func (f HistoryFilter) query() string {
+	values := url.Values{}
+	if !f.From.IsZero() {
+		values.Set(historyParamDateFrom, strconv.FormatInt(f.From.UnixMicro(), 10))
+	}
+	if !f.To.IsZero() {
+		values.Set(historyParamDateTo, strconv.FormatInt(f.To.UnixMicro(), 10))
+	}
+	if f.AmountFrom != "" {
+		values.Set(historyParamAmountFrom, f.AmountFrom)
+	}
+	if f.AmountTo != "" {
+		values.Set(historyParamAmountTo, f.AmountTo)
+	}
+	if f.Contractor != nil {
+		values.Set(historyParamContractor, f.Contractor.GetIPAddressForRequests())
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	return "?" + values.Encode()
+}
+
+// PaymentHistoryRecord is a record of payments history.
+type PaymentHistoryRecord struct {
+	TransactionUUID           string      `json:"transaction_uuid"`
+	UnixTimestampMicroseconds int64       `json:"unix_timestamp_microseconds"`
+	Contractor                string      `json:"contractor"`
+	OperationDirection        string      `json:"operation_direction"`
+	Amount                    Amount      `json:"amount"`
+	BalanceAfterOperation     Amount      `json:"balance_after_operation"`
+	Equivalent                json.Number `json:"equivalent"` // Present in the all-equivalents history only
+	Payload                   string      `json:"payload"`
+}
+
+// Time returns the moment the record was created.
+func (r PaymentHistoryRecord) Time() time.Time {
+	return time.UnixMicro(r.UnixTimestampMicroseconds)
+}
+
+// SettlementLineHistoryRecord is a record of settlement lines (trust lines) history.
+type SettlementLineHistoryRecord struct {
+	TransactionUUID           string `json:"transaction_uuid"`
+	UnixTimestampMicroseconds int64  `json:"unix_timestamp_microseconds"`
+	Contractor                string `json:"contractor"`
+	OperationDirection        string `json:"operation_direction"`
+	Amount                    Amount `json:"amount"`
+}
+
+// HistoryPage is a single page of history records.
+type HistoryPage[T any] struct {
+	Count   int `json:"count"`
+	Records []T `json:"records"`
+}
+
+// PaymentHistoryPage is a single page of payments history.
+type PaymentHistoryPage = HistoryPage[PaymentHistoryRecord]
+
+// SettlementLineHistoryPage is a single page of settlement lines history.
+type SettlementLineHistoryPage = HistoryPage[SettlementLineHistoryRecord]
+
+// historyURL returns the URL of the history endpoint at route: the page follows the route and, for the endpoints bound
+// to an equivalent, the equivalent follows the page.
+func (f HistoryFilter) historyURL(n *Node, route string, byEquivalent bool) (string, error) {
+	if f.Offset < 0 {
+		return "", fmt.Errorf("history filter: negative offset %d", f.Offset)
+	}
+	path := fmt.Sprintf("%s/%d/%d/", route, f.Offset, f.pageSize())
+	if byEquivalent {
+		if f.Equivalent == "" {
+			return "", fmt.Errorf("history filter: no equivalent for %s", route)
+		}
+		path += url.PathEscape(f.Equivalent) + "/"
+	}
+	return fmt.Sprintf("http://%s:%d/api/v1/node/history/%s%s", n.IPAddress, n.CLIPort, path, f.query()), nil
+}
+
+func getHistoryPage[T any](n *Node, name, route string, byEquivalent bool, filter HistoryFilter) (*HistoryPage[T], error) {
+	url, err := filter.historyURL(n, route, byEquivalent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build %s request: %w", name, err)
+	}
+
+	resp, err := n.doAPIRequest(n.runContext(), http.MethodGet, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send %s request: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s request failed with status: %d, body: %s", name, resp.StatusCode, string(bodyBytes))
+	}
+
+	var result struct {
+		Data HistoryPage[T] `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode %s response: %w", name, err)
+	}
+	return &result.Data, nil
+}
+
+// iterateHistory pages through the history until a page shorter than the page size is returned.
+func iterateHistory[T any](filter HistoryFilter, getPage func(filter HistoryFilter) (*HistoryPage[T], error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			page, err := getPage(filter)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, record := range page.Records {
+				if !yield(record, nil) {
+					return
+				}
+			}
+			if len(page.Records) < filter.pageSize() {
+				return
+			}
+			filter.Offset += len(page.Records)
+		}
+	}
+}
+
+// GetPaymentHistory returns a page of payments history in filter.Equivalent.
+func (n *Node) GetPaymentHistory(filter HistoryFilter) (*PaymentHistoryPage, error) {
+	return getHistoryPage[PaymentHistoryRecord](n, "history payments", "transactions/payments", true, filter)
+}
+
+// GetAdditionalPaymentHistory returns a page of payments the node took part in as an intermediate node.
+func (n *Node) GetAdditionalPaymentHistory(filter HistoryFilter) (*PaymentHistoryPage, error) {
+	return getHistoryPage[PaymentHistoryRecord](n, "history additional payments", "transactions/payments/additional", true, filter)
+}
+
+// GetPaymentHistoryAllEquivalents returns a page of payments history in all equivalents.
+func (n *Node) GetPaymentHistoryAllEquivalents(filter HistoryFilter) (*PaymentHistoryPage, error) {
+	return getHistoryPage[PaymentHistoryRecord](n, "history payments all equivalents", "transactions/payments-all", false, filter)
+}
+
+// GetSettlementLineHistory returns a page of settlement lines history in filter.Equivalent.
+func (n *Node) GetSettlementLineHistory(filter HistoryFilter) (*SettlementLineHistoryPage, error) {
+	return getHistoryPage[SettlementLineHistoryRecord](n, "history settlement lines", "transactions/settlement-lines", true, filter)
+}
+
+// GetTrustLineHistory returns a page of trust lines history in filter.Equivalent
+// (the endpoint kept by nodes for trust lines operations).
+func (n *Node) GetTrustLineHistory(filter HistoryFilter) (*SettlementLineHistoryPage, error) {
+	return getHistoryPage[SettlementLineHistoryRecord](n, "history trust lines", "transactions/trust-lines", true, filter)
+}
+
+// PaymentHistory iterates over the whole payments history matching the filter, starting at filter.Offset.
+func (n *Node) PaymentHistory(filter HistoryFilter) iter.Seq2[PaymentHistoryRecord, error] {
+	return iterateHistory(filter, n.GetPaymentHistory)
+}
+
+// AdditionalPaymentHistory iterates over the whole additional payments history matching the filter.
+func (n *Node) AdditionalPaymentHistory(filter HistoryFilter) iter.Seq2[PaymentHistoryRecord, error] {
+	return iterateHistory(filter, n.GetAdditionalPaymentHistory)
+}
+
+// PaymentHistoryAllEquivalents iterates over the whole payments history in all equivalents matching the filter.
+func (n *Node) PaymentHistoryAllEquivalents(filter HistoryFilter) iter.Seq2[PaymentHistoryRecord, error] {
+	return iterateHistory(filter, n.GetPaymentHistoryAllEquivalents)
+}
+
+// SettlementLineHistory iterates over the whole settlement lines history matching the filter.
+func (n *Node) SettlementLineHistory(filter HistoryFilter) iter.Seq2[SettlementLineHistoryRecord, error] {
+	return iterateHistory(filter, n.GetSettlementLineHistory)
+}
+
+// TrustLineHistory iterates over the whole trust lines history matching the filter.
+func (n *Node) TrustLineHistory(filter HistoryFilter) iter.Seq2[SettlementLineHistoryRecord, error] {
+	return iterateHistory(filter, n.GetTrustLineHistory)
+}
+
+// collectHistory reads the whole history into a slice, failing the test on error.
+func collectHistory[T any](t *testing.T, n *Node, history iter.Seq2[T, error]) []T {
+	var records []T
+	for record, err := range history {
+		if err != nil {
+			t.Fatalf("Node %s: %v", n.Alias, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// HistoryPayments returns the page of payments history selected by the filter.
+func (n *Node) HistoryPayments(t *testing.T, filter HistoryFilter) *PaymentHistoryPage {
+	page, err := n.GetPaymentHistory(filter)
+	if err != nil {
+		t.Fatalf("Node %s: %v", n.Alias, err)
+	}
+	return page
+}
+
+// HistoryAdditionalPayments returns the page of additional payments history selected by the filter.
+func (n *Node) HistoryAdditionalPayments(t *testing.T, filter HistoryFilter) *PaymentHistoryPage {
+	page, err := n.GetAdditionalPaymentHistory(filter)
+	if err != nil {
+		t.Fatalf("Node %s: %v", n.Alias, err)
+	}
+	return page
+}
+
+// HistoryPaymentsAllEquivalents returns the page of payments history in all equivalents selected by the filter.
+func (n *Node) HistoryPaymentsAllEquivalents(t *testing.T, filter HistoryFilter) *PaymentHistoryPage {
+	page, err := n.GetPaymentHistoryAllEquivalents(filter)
+	if err != nil {
+		t.Fatalf("Node %s: %v", n.Alias, err)
+	}
+	return page
+}
+
+// AllHistoryPayments returns every payments history record matching the filter.
+func (n *Node) AllHistoryPayments(t *testing.T, filter HistoryFilter) []PaymentHistoryRecord {
+	return collectHistory(t, n, n.PaymentHistory(filter))
+}
+
+// AllHistorySettlementLines returns every settlement lines history record matching the filter.
+func (n *Node) AllHistorySettlementLines(t *testing.T, filter HistoryFilter) []SettlementLineHistoryRecord {
+	return collectHistory(t, n, n.SettlementLineHistory(filter))
+}
+
+// AllHistoryTrustLines returns every trust lines history record matching the filter.
+func (n *Node) AllHistoryTrustLines(t *testing.T, filter HistoryFilter) []SettlementLineHistoryRecord {
+	return collectHistory(t, n, n.TrustLineHistory(filter))
+}
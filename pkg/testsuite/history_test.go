@@ -0,0 +1,76 @@
+package testsuite
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistoryURL(t *testing.T) {
+	node := &Node{IPAddress: "10.0.0.2", CLIPort: 3000, NodePort: 2000}
+	contractor := &Node{IPAddress: "10.0.0.3", NodePort: 2000}
+	from := time.UnixMicro(1700000000000000)
+
+	tests := []struct {
+		name         string
+		route        string
+		byEquivalent bool
+		filter       HistoryFilter
+		want         string
+		wantErr      bool
+	}{
+		{
+			name:         "default page",
+			route:        "transactions/payments",
+			byEquivalent: true,
+			filter:       HistoryFilter{Equivalent: "1"},
+			want:         "http://10.0.0.2:3000/api/v1/node/history/transactions/payments/0/10/1/",
+		},
+		{
+			name:         "page and filters",
+			route:        "transactions/payments/additional",
+			byEquivalent: true,
+			filter: HistoryFilter{Equivalent: "2", Offset: 20, Count: 5, From: from, AmountFrom: "100", AmountTo: "500",
+				Contractor: contractor},
+			want: "http://10.0.0.2:3000/api/v1/node/history/transactions/payments/additional/20/5/2/" +
+				"?amount_from=100&amount_to=500&contractor_address=12-10.0.0.3%3A2000&date_from_unix_timestamp=1700000000000000",
+		},
+		{
+			name:   "all equivalents ignore the equivalent",
+			route:  "transactions/payments-all",
+			filter: HistoryFilter{Equivalent: "1", To: from},
+			want:   "http://10.0.0.2:3000/api/v1/node/history/transactions/payments-all/0/10/?date_to_unix_timestamp=1700000000000000",
+		},
+		{
+			name:         "no equivalent",
+			route:        "transactions/settlement-lines",
+			byEquivalent: true,
+			filter:       HistoryFilter{},
+			wantErr:      true,
+		},
+		{
+			name:         "negative offset",
+			route:        "transactions/payments",
+			byEquivalent: true,
+			filter:       HistoryFilter{Equivalent: "1", Offset: -1},
+			wantErr:      true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.filter.historyURL(node, test.route, test.byEquivalent)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got URL %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("URL mismatch.\nExpected: %s\nGot:      %s", test.want, got)
+			}
+		})
+	}
+}
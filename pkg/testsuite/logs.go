@@ -0,0 +1,435 @@
+package testsuite
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Structured access to the nodes' operations.log.
+//
+// A log line is parsed into timestamp, level, subsystem (the bracketed part, usually the transaction name and UUID),
+// transaction UUID and message. Every part is optional: lines that don't follow the format keep the whole line
+// as the message, so matching by message still works for them.
+
+// LogEntry is a parsed line of operations.log.
+type LogEntry struct {
+	NodeAlias       string
+	Timestamp       time.Time // zero if the line has no timestamp
+	Level           string
+	Subsystem       string
+	TransactionUUID string
+	Message         string
+	Raw             string
+	ReceivedAt      time.Time // when the watcher has read the line
+	Step            string    // the LogWatcher step that was current when the line was read
+}
+
+var (
+	logTimestampRegexp = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}:\d{2}(?:\.\d+)?)`)
+	logLevelRegexp     = regexp.MustCompile(`^([A-Z]{4,9})\b`)
+	logSubsystemRegexp = regexp.MustCompile(`^\[([^\]]*)\]`)
+	logUUIDRegexp      = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+)
+
+// ErrLogTimeout is returned by LogWatcher.Wait when no matching line appears in time.
+var ErrLogTimeout = errors.New("no matching log line")
+
+// RecoveringTimeout is how long a participant may take to start recovering a transaction whose votes didn't arrive:
+// the votes timeout and a recovery period of slack.
+const RecoveringTimeout = (WaitingParticipantsVotesSec + NodePaymentRecoveryTimePeriodSec) * time.Second
+
+// logFieldSeparators are trimmed between the parts of a line.
+const logFieldSeparators = " \t|:"
+
+// ParseLogLine parses a line of operations.log.
+func ParseLogLine(nodeAlias, line string) LogEntry {
+	entry := LogEntry{NodeAlias: nodeAlias, Raw: line}
+	rest := strings.TrimSpace(line)
+
+	if match := logTimestampRegexp.FindString(rest); match != "" {
+		layout := "2006-01-02 15:04:05.999999999"
+		if strings.Contains(match, "T") {
+			layout = "2006-01-02T15:04:05.999999999"
+		}
+		if timestamp, err := time.Parse(layout, match); err == nil {
+			entry.Timestamp = timestamp
+			rest = strings.TrimLeft(rest[len(match):], logFieldSeparators)
+		}
+	}
+
+	if match := logLevelRegexp.FindStringSubmatch(rest); match != nil {
+		entry.Level = match[1]
+		rest = strings.TrimLeft(rest[len(match[0]):], logFieldSeparators)
+	}
+
+	if match := logSubsystemRegexp.FindStringSubmatch(rest); match != nil {
+		entry.Subsystem = strings.TrimSpace(match[1])
+		rest = strings.TrimLeft(rest[len(match[0]):], logFieldSeparators)
+	}
+
+	if uuid := logUUIDRegexp.FindString(entry.Subsystem); uuid != "" {
+		entry.TransactionUUID = strings.ToLower(uuid)
+	} else if uuid := logUUIDRegexp.FindString(rest); uuid != "" {
+		entry.TransactionUUID = strings.ToLower(uuid)
+	}
+
+	entry.Message = strings.TrimSpace(rest)
+	return entry
+}
+
+// LogMatcher selects log entries.
+type LogMatcher func(entry LogEntry) bool
+
+// LogContains matches entries whose raw line contains the text.
+func LogContains(text string) LogMatcher {
+	return func(entry LogEntry) bool {
+		return strings.Contains(entry.Raw, text)
+	}
+}
+
+// LogMatches matches entries whose raw line matches the regular expression.
+func LogMatches(pattern *regexp.Regexp) LogMatcher {
+	return func(entry LogEntry) bool {
+		return pattern.MatchString(entry.Raw)
+	}
+}
+
+// LogTransaction matches entries of the transaction.
+// The UUID is also searched in the raw line, as some messages mention other transactions.
+func LogTransaction(transactionUUID string) LogMatcher {
+	transactionUUID = strings.ToLower(transactionUUID)
+	return func(entry LogEntry) bool {
+		return entry.TransactionUUID == transactionUUID || strings.Contains(strings.ToLower(entry.Raw), transactionUUID)
+	}
+}
+
+// LogLevel matches entries of the level (INFO, WARNING, ERROR, ...).
+func LogLevel(level string) LogMatcher {
+	return func(entry LogEntry) bool {
+		return strings.EqualFold(entry.Level, level)
+	}
+}
+
+// LogSubsystem matches entries whose subsystem contains the text.
+func LogSubsystem(text string) LogMatcher {
+	return func(entry LogEntry) bool {
+		return strings.Contains(entry.Subsystem, text)
+	}
+}
+
+// LogRecovering matches the line with which a participant starts recovering the transaction.
+func LogRecovering(transactionUUID string) LogMatcher {
+	return LogAll(LogTransaction(transactionUUID), LogContains(LogMessageRecoveringLogMessage))
+}
+
+// LogAll matches entries matched by every matcher.
+func LogAll(matchers ...LogMatcher) LogMatcher {
+	return func(entry LogEntry) bool {
+		for _, matcher := range matchers {
+			if !matcher(entry) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// ReadLogs is ReadLogsCtx under the context the node was run with.
+func (n *Node) ReadLogs() ([]LogEntry, error) {
+	return n.ReadLogsCtx(n.runContext())
+}
+
+// ReadLogsCtx reads and parses the whole operations.log of the node at once.
+func (n *Node) ReadLogsCtx(ctx context.Context) ([]LogEntry, error) {
+	if n.ContainerID == "" {
+		return nil, fmt.Errorf("Node %s: ContainerID is not set, cannot read logs", n.Alias)
+	}
+
+	ctx, cancel := n.stepContext(ctx)
+	defer cancel()
+	output, err := n.dockerOutput(ctx, exec.CommandContext(ctx, "docker", "exec", n.ContainerID, "cat", DefaultOperationsLogPath))
+	if err != nil {
+		return nil, fmt.Errorf("Node %s: failed to read %s: %v", n.Alias, DefaultOperationsLogPath, err)
+	}
+
+	var entries []LogEntry
+	now := time.Now()
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		entry := ParseLogLine(n.Alias, line)
+		entry.ReceivedAt = now
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// nodeLog is the log of one node collected by a LogWatcher.
+type nodeLog struct {
+	node      *Node
+	entries   []LogEntry
+	stepStart int // index of the first entry of the current step
+	tailErr   error
+	tailPID   string // pid of tail in the container, empty until it is started
+}
+
+// LogWatcher tails operations.log of the nodes in the background.
+//
+// Assertions are scoped to the current step: WaitForLog and AssertNoLog only look at the lines read since
+// the last Step call (or since the watcher was started), so messages of previous steps don't satisfy them.
+type LogWatcher struct {
+	t      *testing.T
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// cluster gets the steps into its step log, nil for watchers not started by a cluster.
+	cluster *Cluster
+
+	mu      sync.Mutex
+	step    string
+	logs    map[string]*nodeLog
+	updated chan struct{} // closed and replaced every time a line is read
+}
+
+// NewLogWatcher starts tailing the logs of the nodes. The watcher is stopped when the test finishes.
+func NewLogWatcher(t *testing.T, nodes ...*Node) *LogWatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &LogWatcher{
+		t:       t,
+		ctx:     ctx,
+		cancel:  cancel,
+		logs:    make(map[string]*nodeLog),
+		updated: make(chan struct{}),
+	}
+	for _, node := range nodes {
+		w.Watch(node)
+	}
+	t.Cleanup(w.Stop)
+	return w
+}
+
+// WatchLogs starts a LogWatcher for every node of the cluster.
+func (c *Cluster) WatchLogs(t *testing.T) *LogWatcher {
+	c.mu.Lock()
+	nodes := append([]*Node(nil), c.nodes...)
+	c.mu.Unlock()
+	w := NewLogWatcher(t, nodes...)
+	w.cluster = c
+	return w
+}
+
+// Watch starts tailing the node's log, if it is not watched yet (e.g. for a node started after the watcher).
+func (w *LogWatcher) Watch(node *Node) {
+	w.mu.Lock()
+	if _, ok := w.logs[node.Alias]; ok {
+		w.mu.Unlock()
+		return
+	}
+	w.logs[node.Alias] = &nodeLog{node: node}
+	w.mu.Unlock()
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		err := w.tail(w.ctx, node)
+		if w.ctx.Err() != nil {
+			return
+		}
+		w.mu.Lock()
+		w.logs[node.Alias].tailErr = err
+		w.notify()
+		w.mu.Unlock()
+	}()
+}
+
+// tail follows the log by name, so it survives vtcpd restarts and a log file that doesn't exist yet.
+// The shell prints its pid before replacing itself with tail, so that Stop can kill tail in the container:
+// killing docker exec leaves the process running.
+func (w *LogWatcher) tail(ctx context.Context, node *Node) error {
+	shellCommand := fmt.Sprintf("echo $$ && exec tail -n +1 -F %s", DefaultOperationsLogPath)
+	cmd := exec.CommandContext(ctx, "docker", "exec", node.ContainerID, "sh", "-c", shellCommand)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start tail: %w", err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	if scanner.Scan() {
+		w.mu.Lock()
+		w.logs[node.Alias].tailPID = strings.TrimSpace(scanner.Text())
+		w.mu.Unlock()
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		w.append(ParseLogLine(node.Alias, line))
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("tail exited: %w", err)
+	}
+	return fmt.Errorf("tail exited")
+}
+
+func (w *LogWatcher) append(entry LogEntry) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	entry.ReceivedAt = time.Now()
+	entry.Step = w.step
+	nodeLog := w.logs[entry.NodeAlias]
+	nodeLog.entries = append(nodeLog.entries, entry)
+	w.notify()
+}
+
+// notify wakes up the waiters, w.mu must be held.
+func (w *LogWatcher) notify() {
+	close(w.updated)
+	w.updated = make(chan struct{})
+}
+
+// Stop stops tailing. It is called automatically when the test finishes.
+func (w *LogWatcher) Stop() {
+	w.mu.Lock()
+	logs := make([]nodeLog, 0, len(w.logs))
+	for _, nodeLog := range w.logs {
+		logs = append(logs, *nodeLog)
+	}
+	w.mu.Unlock()
+	for _, nodeLog := range logs {
+		if nodeLog.tailPID != "" {
+			nodeLog.node.killTail(nodeLog.tailPID)
+		}
+	}
+	w.cancel()
+	w.wg.Wait()
+}
+
+// killTail kills the tail process of a LogWatcher in the node's container. Errors are ignored: the container
+// may be gone already.
+func (n *Node) killTail(pid string) {
+	ctx, cancel := n.stepContext(context.Background())
+	defer cancel()
+	_ = n.dockerRun(ctx, exec.CommandContext(ctx, "docker", "exec", n.ContainerID, "kill", pid))
+}
+
+// Step starts a new step: later assertions ignore the lines read so far.
+func (w *LogWatcher) Step(name string) {
+	if w.cluster != nil {
+		w.cluster.Step(name)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.step = name
+	for _, nodeLog := range w.logs {
+		nodeLog.stepStart = len(nodeLog.entries)
+	}
+}
+
+// Entries returns every line read from the node's log so far.
+func (w *LogWatcher) Entries(node *Node) []LogEntry {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	nodeLog, ok := w.logs[node.Alias]
+	if !ok {
+		return nil
+	}
+	return append([]LogEntry(nil), nodeLog.entries...)
+}
+
+// StepEntries returns the lines read from the node's log during the current step.
+func (w *LogWatcher) StepEntries(node *Node) []LogEntry {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	nodeLog, ok := w.logs[node.Alias]
+	if !ok {
+		return nil
+	}
+	return append([]LogEntry(nil), nodeLog.entries[nodeLog.stepStart:]...)
+}
+
+// findInStep returns the first entry of the current step matching the matcher,
+// and a channel closed when the next line is read.
+func (w *LogWatcher) findInStep(node *Node, matcher LogMatcher) (*LogEntry, <-chan struct{}, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	nodeLog, ok := w.logs[node.Alias]
+	if !ok {
+		return nil, nil, fmt.Errorf("node %s is not watched", node.Alias)
+	}
+	for i := nodeLog.stepStart; i < len(nodeLog.entries); i++ {
+		if matcher(nodeLog.entries[i]) {
+			entry := nodeLog.entries[i]
+			return &entry, nil, nil
+		}
+	}
+	if nodeLog.tailErr != nil {
+		return nil, nil, fmt.Errorf("node %s: log is not tailed anymore: %v", node.Alias, nodeLog.tailErr)
+	}
+	return nil, w.updated, nil
+}
+
+// Wait waits until a line matching the matcher appears in the node's log during the current step.
+func (w *LogWatcher) Wait(node *Node, matcher LogMatcher, timeout time.Duration) (LogEntry, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		entry, updated, err := w.findInStep(node, matcher)
+		if err != nil {
+			return LogEntry{}, err
+		}
+		if entry != nil {
+			return *entry, nil
+		}
+		select {
+		case <-updated:
+		case <-deadline.C:
+			return LogEntry{}, fmt.Errorf("node %s: %w within %v", node.Alias, ErrLogTimeout, timeout)
+		}
+	}
+}
+
+// WaitForLog waits until a line matching the matcher appears in the node's log during the current step
+// and fails the test otherwise.
+func (w *LogWatcher) WaitForLog(node *Node, matcher LogMatcher, timeout time.Duration) LogEntry {
+	w.t.Helper()
+	entry, err := w.Wait(node, matcher, timeout)
+	if err != nil {
+		w.t.Fatalf("%v (step '%s')", err, w.currentStep())
+	}
+	return entry
+}
+
+// AssertNoLog fails the test if a line matching the matcher appears in the node's log during the current step.
+// The log keeps being watched for the given duration, zero checks only the lines read so far.
+func (w *LogWatcher) AssertNoLog(node *Node, matcher LogMatcher, duration time.Duration) {
+	w.t.Helper()
+	entry, err := w.Wait(node, matcher, duration)
+	if err == nil {
+		w.t.Fatalf("Node %s: unexpected log line in step '%s': %s", node.Alias, w.currentStep(), entry.Raw)
+	}
+	if !errors.Is(err, ErrLogTimeout) {
+		w.t.Fatalf("%v", err)
+	}
+}
+
+func (w *LogWatcher) currentStep() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.step
+}
@@ -0,0 +1,93 @@
+package testsuite
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLogLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want LogEntry
+	}{
+		{
+			name: "full line",
+			line: "2024-05-06 12:34:56.789012 INFO [CoordinatorPaymentTA: 0F1E2D3C-4B5A-6978-8796-A5B4C3D2E1F0] runVotesRecoveryParentStage",
+			want: LogEntry{
+				Timestamp:       time.Date(2024, 5, 6, 12, 34, 56, 789012000, time.UTC),
+				Level:           "INFO",
+				Subsystem:       "CoordinatorPaymentTA: 0F1E2D3C-4B5A-6978-8796-A5B4C3D2E1F0",
+				TransactionUUID: "0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0",
+				Message:         "runVotesRecoveryParentStage",
+			},
+		},
+		{
+			name: "T timestamp without fraction",
+			line: "2024-05-06T12:34:56 ERROR [Core] failed to bind",
+			want: LogEntry{
+				Timestamp: time.Date(2024, 5, 6, 12, 34, 56, 0, time.UTC),
+				Level:     "ERROR",
+				Subsystem: "Core",
+				Message:   "failed to bind",
+			},
+		},
+		{
+			name: "pipe and colon separators",
+			line: "2024-05-06 12:34:56 | WARNING | [Routing] : path not found",
+			want: LogEntry{
+				Timestamp: time.Date(2024, 5, 6, 12, 34, 56, 0, time.UTC),
+				Level:     "WARNING",
+				Subsystem: "Routing",
+				Message:   "path not found",
+			},
+		},
+		{
+			name: "transaction in the message",
+			line: "INFO [Storage] transaction 0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0 serialized",
+			want: LogEntry{
+				Level:           "INFO",
+				Subsystem:       "Storage",
+				TransactionUUID: "0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0",
+				Message:         "transaction 0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0 serialized",
+			},
+		},
+		{
+			name: "unformatted line",
+			line: "  vtcpd started  ",
+			want: LogEntry{Message: "vtcpd started"},
+		},
+		{
+			name: "lowercase word is not a level",
+			line: "info about nothing",
+			want: LogEntry{Message: "info about nothing"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := ParseLogLine("node1", test.line)
+			test.want.NodeAlias = "node1"
+			test.want.Raw = test.line
+			if !got.Timestamp.Equal(test.want.Timestamp) {
+				t.Errorf("Timestamp mismatch. Expected: %v, got: %v", test.want.Timestamp, got.Timestamp)
+			}
+			got.Timestamp, test.want.Timestamp = time.Time{}, time.Time{}
+			if got != test.want {
+				t.Errorf("Entry mismatch.\nExpected: %+v\nGot:      %+v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestLogRecovering(t *testing.T) {
+	matcher := LogRecovering("0F1E2D3C-4B5A-6978-8796-A5B4C3D2E1F0")
+	recovering := ParseLogLine("node1", "INFO [ReceiverPaymentTA: 0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0] runVotesRecoveryParentStage")
+	if !matcher(recovering) {
+		t.Errorf("expected the recovery line of the transaction to match")
+	}
+	otherTransaction := ParseLogLine("node1", "INFO [ReceiverPaymentTA: 11111111-2222-3333-4444-555555555555] runVotesRecoveryParentStage")
+	if matcher(otherTransaction) {
+		t.Errorf("expected the recovery line of another transaction not to match")
+	}
+}
@@ -1,14 +1,15 @@
 package testsuite
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
-	"os/exec"
 	"strconv"
-	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -93,6 +94,10 @@ const (
 	NoMaxAllowablePaymentAmount = ""
 )
 
+// apiClient sends the requests to the node API, the deadline of every request is set by its context
+// (see doAPIRequest). Its transport records the calls into the timeline and the trace of the node's test.
+var apiClient = &http.Client{Transport: &recordingTransport{base: http.DefaultTransport}}
+
 // Testing flags based on Python test suite debug flags
 const (
 	// From test_transaction_direct_payment_two_nodes.py and their Go equivalents
@@ -125,6 +130,37 @@ const (
 	FlagSleepOnVoteConsistencyStage                    uint64 = 68719476736 // Python: flag_sleep_on_vote_consistency_stage
 )
 
+// TestingFlagsByName maps the names of the testing flags above to their values.
+var TestingFlagsByName = map[string]uint64{
+	"FlagForbidSendInitMessage":                          FlagForbidSendInitMessage,
+	"FlagForbidSendMessageToCoordinatorReservation":      FlagForbidSendMessageToCoordinatorReservation,
+	"FlagForbidSendRequestToIntermediateReservation":     FlagForbidSendRequestToIntermediateReservation,
+	"FlagForbidSendResponseToIntemediateOnReservation":   FlagForbidSendResponseToIntemediateOnReservation,
+	"FlagForbidSendMessageFinalPathConfig":               FlagForbidSendMessageFinalPathConfig,
+	"FlagForbidSendMessageFinalAmountClarification":      FlagForbidSendMessageFinalAmountClarification,
+	"FlagForbidSendMessageVoteStage":                     FlagForbidSendMessageVoteStage,
+	"FlagForbidSendMessageVoteConsistency":               FlagForbidSendMessageVoteConsistency,
+	"FlagForbidSendMessageRecoveryStage":                 FlagForbidSendMessageRecoveryStage,
+	"FlagThrowExceptionPreviousNeighborRequest":          FlagThrowExceptionPreviousNeighborRequest,
+	"FlagThrowExceptionCoordinatorRequest":               FlagThrowExceptionCoordinatorRequest,
+	"FlagThrowExceptionNextNeighborResponse":             FlagThrowExceptionNextNeighborResponse,
+	"FlagThrowExceptionVote":                             FlagThrowExceptionVote,
+	"FlagThrowExceptionVoteConsistency":                  FlagThrowExceptionVoteConsistency,
+	"FlagThrowExceptionCoordinatorAfterApprove":          FlagThrowExceptionCoordinatorAfterApprove,
+	"FlagThrowExceptionOnObservingSubmitClaimStage":      FlagThrowExceptionOnObservingSubmitClaimStage,
+	"FlagThrowExceptionOnObservingCheckClaimStatusStage": FlagThrowExceptionOnObservingCheckClaimStatusStage,
+	"FlagTerminateProcessPreviousNeighborRequest":        FlagTerminateProcessPreviousNeighborRequest,
+	"FlagTerminateProcessCoordinatorRequest":             FlagTerminateProcessCoordinatorRequest,
+	"FlagTerminateProcessNextNeighborResponse":           FlagTerminateProcessNextNeighborResponse,
+	"FlagTerminateProcessVote":                           FlagTerminateProcessVote,
+	"FlagTerminateProcessVoteConsistency":                FlagTerminateProcessVoteConsistency,
+	"FlagTerminateProcessCoordinatorAfterApprove":        FlagTerminateProcessCoordinatorAfterApprove,
+	"FlagTerminateProcessOnObservingSubmitClaimStage":    FlagTerminateProcessOnObservingSubmitClaimStage,
+	"FlagSleepOnNextNeighborResponseProcessing":          FlagSleepOnNextNeighborResponseProcessing,
+	"FlagSleepOnFinalAmountClarification":                FlagSleepOnFinalAmountClarification,
+	"FlagSleepOnVoteConsistencyStage":                    FlagSleepOnVoteConsistencyStage,
+}
+
 type Node struct {
 	ID          string
 	Host        string
@@ -135,6 +171,32 @@ type Node struct {
 	ContainerID string
 	Alias       string
 	Env         []string
+	// Storage gives read access to the node's database, it is attached when the node is started.
+	Storage StorageInspector
+	// Testing manages the testing flags of the node.
+	Testing *TestingController
+	// ConfigOptions are applied to conf.json before vtcpd first starts.
+	ConfigOptions []NodeOption
+	// Image overrides the cluster's node image, e.g. to run a previous release next to the current one.
+	Image string
+
+	// ctx is the context the node was run with and stepTimeout the deadline of its API calls and docker commands,
+	// see bindNode.
+	ctx         context.Context
+	stepTimeout time.Duration
+	// testingFlags keeps every testing flag applied to the node, for reporting.
+	testingFlags []TestingFlagRecord
+	// artifacts are the files collected from the node's container, relative to the report directory.
+	artifacts []string
+	// valgrind is set when the node's container runs vtcpd under valgrind.
+	valgrind bool
+	// databaseConfig is VTCPD_DATABASE_CONFIG the node's container was created with, empty for the image default.
+	databaseConfig string
+	// commissions are the commissions of the node's config by equivalent, as last written to the container.
+	commissions map[string]int
+	// initiatedTransactions are the UUIDs of the payments the node has initiated, see LogTimelineOnFailure.
+	initiatedMu           sync.Mutex
+	initiatedTransactions []string
 }
 
 type ChannelInitResponseData struct {
@@ -159,9 +221,9 @@ type SettlementLineInfo struct {
 	OwnKeysPresent        string `json:"own_keys_present"`
 	ContractorKeysPresent string `json:"contractor_keys_present"`
 	AuditNumber           string `json:"audit_number"`
-	MaxNegativeBalance    string `json:"max_negative_balance"`
-	MaxPositiveBalance    string `json:"max_positive_balance"`
-	Balance               string `json:"balance"`
+	MaxNegativeBalance    Amount `json:"max_negative_balance"`
+	MaxPositiveBalance    Amount `json:"max_positive_balance"`
+	Balance               Amount `json:"balance"`
 }
 
 type SettlementLineInfoList struct {
@@ -172,7 +234,7 @@ type SettlementLineInfoList struct {
 type MaxFlowItemInfo struct {
 	AddressType       string `json:"address_type"`
 	ContractorAddress string `json:"contractor_address"`
-	MaxAmount         string `json:"max_amount"`
+	MaxAmount         Amount `json:"max_amount"`
 }
 
 type MaxFlowInfo struct {
@@ -183,25 +245,28 @@ type MaxFlowInfo struct {
 // MaxFlowBatchResult holds the contractor address and its corresponding max flow amount.
 type MaxFlowBatchResult struct {
 	ContractorAddress string
-	MaxAmount         string
+	MaxAmount         Amount
 }
 
 // MaxFlowBatchCheck is a helper struct for CheckMaxFlowBatch
 type MaxFlowBatchCheck struct {
 	Node            *Node
-	ExpectedMaxFlow string
+	ExpectedMaxFlow Amount
 }
 
 // Exchange rates related types
+
+// RateItem is an exchange rate as returned by the rates API. Value and RealRate are the rate itself
+// (value·10^-shift and a decimal), not amounts. The limits are nil if the rate has none.
 type RateItem struct {
-	EquivalentFrom            string `json:"equivalent_from"`
-	EquivalentTo              string `json:"equivalent_to"`
-	Value                     string `json:"value"`
-	Shift                     int16  `json:"shift"`
-	RealRate                  string `json:"real_rate"`
-	MinExchangeAmount         string `json:"min_exchange_amount"`
-	MaxExchangeAmount         string `json:"max_exchange_amount"`
-	ExpiresAtUnixMicroseconds string `json:"expires_at_unix_microseconds"`
+	EquivalentFrom            string  `json:"equivalent_from"`
+	EquivalentTo              string  `json:"equivalent_to"`
+	Value                     string  `json:"value"`
+	Shift                     int16   `json:"shift"`
+	RealRate                  string  `json:"real_rate"`
+	MinExchangeAmount         *Amount `json:"min_exchange_amount"`
+	MaxExchangeAmount         *Amount `json:"max_exchange_amount"`
+	ExpiresAtUnixMicroseconds string  `json:"expires_at_unix_microseconds"`
 }
 
 type RatesListResponse struct {
@@ -209,8 +274,10 @@ type RatesListResponse struct {
 	Rates []RateItem `json:"rates"`
 }
 
-func NewNode(t *testing.T, ipAddress string, alias string) *Node {
-	return &Node{
+// NewNode describes a node, it is started by the cluster. Options are applied to the node's config
+// before vtcpd first starts, e.g. NewNode(t, ip, "hub", WithHub(equivalent)).
+func NewNode(t *testing.T, ipAddress string, alias string, options ...NodeOption) *Node {
+	node := &Node{
 		ID:          uuid.New().String(),
 		Host:        "0.0.0.0",
 		NodePort:    DefaultNodePort,
@@ -228,7 +295,10 @@ func NewNode(t *testing.T, ipAddress string, alias string) *Node {
 			fmt.Sprintf("CLI_LISTEN_PORT=%d", DefaultCLIPort),
 			fmt.Sprintf("CLI_LISTEN_PORT_TESTING=%d", DefaultCLIPortTest),
 		},
+		ConfigOptions: options,
 	}
+	node.Testing = newTestingController(t, node)
+	return node
 }
 
 func (n *Node) GetIpAndPort() string {
@@ -248,7 +318,7 @@ func (n *Node) InitChannelCheckStatusCode(t *testing.T, targetNode *Node, crypto
 	}
 
 	// Send the request to initialize the channel
-	resp, err := http.Post(initURL, "application/json", nil)
+	resp, err := n.doAPIRequest(n.runContext(), http.MethodPost, initURL)
 	if err != nil {
 		t.Fatalf("failed to send init-channel request: %v", err)
 	}
@@ -263,7 +333,7 @@ func (n *Node) GetChannelInfo(t *testing.T, channelID string) *ChannelInfo {
 	url := fmt.Sprintf("http://%s:%d/api/v1/node/channels/%s/",
 		n.IPAddress, n.CLIPort, channelID)
 
-	resp, err := http.Get(url)
+	resp, err := n.doAPIRequest(n.runContext(), http.MethodGet, url)
 	if err != nil {
 		t.Fatalf("failed to send get channel request: %v", err)
 	}
@@ -284,8 +354,15 @@ func (n *Node) GetChannelInfo(t *testing.T, channelID string) *ChannelInfo {
 
 // OpenChannel opens a channel between this node and the target node.
 // It uses the init-channel functionality to establish the connection.
-// Returns an error if the channel initialization fails, otherwise returns nil.
 func (n *Node) OpenChannel(t *testing.T, targetNode *Node) {
+	if err := n.InitChannel(targetNode); err != nil {
+		t.Fatalf("%v", err)
+	}
+}
+
+// InitChannel opens a channel between this node and the target node.
+// Returns an error if the channel initialization fails, otherwise returns nil.
+func (n *Node) InitChannel(targetNode *Node) error {
 	// Step 1: This node initiates the channel
 	// Prepare the request body with the target node's address
 	// Using IPV4 type code 12
@@ -293,14 +370,14 @@ func (n *Node) OpenChannel(t *testing.T, targetNode *Node) {
 		n.IPAddress, n.CLIPort, targetNode.GetIPAddressForRequests())
 
 	// Send the request to initialize the channel
-	resp, err := http.Post(initURL, "application/json", nil)
+	resp, err := n.doAPIRequest(n.runContext(), http.MethodPost, initURL)
 	if err != nil {
-		t.Fatalf("failed to send init-channel request: %v", err)
+		return fmt.Errorf("failed to send init-channel request: %v", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != StatusOK {
-		t.Fatalf("init-channel request failed with status: %d", resp.StatusCode)
+		return fmt.Errorf("init-channel request failed with status: %d", resp.StatusCode)
 	}
 
 	// Parse the response to get channel_id and crypto_key
@@ -309,7 +386,7 @@ func (n *Node) OpenChannel(t *testing.T, targetNode *Node) {
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&initResponse); err != nil {
-		t.Fatalf("failed to decode init-channel response: %v", err)
+		return fmt.Errorf("failed to decode init-channel response: %v", err)
 	}
 
 	// Step 2: Target node completes the channel initialization
@@ -318,15 +395,16 @@ func (n *Node) OpenChannel(t *testing.T, targetNode *Node) {
 		targetNode.IPAddress, targetNode.CLIPort, n.GetIPAddressForRequests(), initResponse.Data.ChannelID, initResponse.Data.CryptoKey)
 
 	// Send the request to complete channel initialization
-	targetResp, err := http.Post(targetURL, "application/json", nil)
+	targetResp, err := targetNode.doAPIRequest(targetNode.runContext(), http.MethodPost, targetURL)
 	if err != nil {
-		t.Fatalf("failed to send target init-channel request: %v", err)
+		return fmt.Errorf("failed to send target init-channel request: %v", err)
 	}
 	defer targetResp.Body.Close()
 
 	if targetResp.StatusCode != http.StatusOK {
-		t.Fatalf("target init-channel request failed with status: %d", targetResp.StatusCode)
+		return fmt.Errorf("target init-channel request failed with status: %d", targetResp.StatusCode)
 	}
+	return nil
 }
 
 // getChannelInfo queries the channel-by-address endpoint to get channel info with another node.
@@ -335,7 +413,7 @@ func (n *Node) GetChannelInfoByAddress(targetNode *Node) (*ChannelInfo, error) {
 	url := fmt.Sprintf("http://%s:%d/api/v1/node/channel-by-address/?contractor_address=%s",
 		n.IPAddress, n.CLIPort, targetNode.GetIPAddressForRequests())
 
-	resp, err := http.Get(url)
+	resp, err := n.doAPIRequest(n.runContext(), http.MethodGet, url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send channel-by-address request: %w", err)
 	}
@@ -354,9 +432,15 @@ func (n *Node) GetChannelInfoByAddress(targetNode *Node) (*ChannelInfo, error) {
 	return &result.Data, nil
 }
 
-// WaitForReady waits for the node to be ready to accept API requests
+// WaitForReady is WaitForReadyCtx under the context the node was run with.
 func (n *Node) WaitForReady(t *testing.T, timeout time.Duration) error {
-	t.Logf("Waiting for node %s (%s) to be ready...", n.Alias, n.IPAddress)
+	return n.WaitForReadyCtx(n.runContext(), t, timeout)
+}
+
+// WaitForReadyCtx waits for the node to be ready to accept API requests, until ctx is done.
+// t may be nil when the node is used outside of a test.
+func (n *Node) WaitForReadyCtx(ctx context.Context, t *testing.T, timeout time.Duration) error {
+	logf(t, "Waiting for node %s (%s) to be ready...", n.Alias, n.IPAddress)
 
 	healthCheckURL := fmt.Sprintf("http://%s:%d/api/v1/node/contractors/", n.IPAddress, n.CLIPort)
 
@@ -366,18 +450,20 @@ func (n *Node) WaitForReady(t *testing.T, timeout time.Duration) error {
 
 	for {
 		select {
+		case <-ctx.Done():
+			return fmt.Errorf("node %s (%s) stopped waiting to become ready: %v", n.Alias, n.IPAddress, ctx.Err())
 		case <-ticker.C:
-			resp, err := http.Get(healthCheckURL)
+			resp, err := n.doAPIRequest(ctx, http.MethodGet, healthCheckURL)
 			if err == nil {
 				resp.Body.Close()
 				// Any response (even error codes) means the server is responding
 				elapsed := time.Since(start)
-				t.Logf("Node %s (%s) is ready after %v", n.Alias, n.IPAddress, elapsed)
+				logf(t, "Node %s (%s) is ready after %v", n.Alias, n.IPAddress, elapsed)
 
 				// Additional wait for PostgreSQL database to be fully ready
 				// Check if we're using PostgreSQL by looking for connection string
-				if dbConfig := os.Getenv("VTCPD_DATABASE_CONFIG"); strings.Contains(dbConfig, "postgresql") {
-					t.Logf("PostgreSQL detected (pre-initialized), waiting additional 2 seconds for startup...")
+				if n.storage().Dialect() == StorageDialectPostgreSQL {
+					logf(t, "PostgreSQL detected (pre-initialized), waiting additional 2 seconds for startup...")
 					time.Sleep(2 * time.Second)
 				}
 				return nil
@@ -391,6 +477,15 @@ func (n *Node) WaitForReady(t *testing.T, timeout time.Duration) error {
 	}
 }
 
+// logf logs through t when running inside a test, and through the standard logger otherwise.
+func logf(t *testing.T, format string, args ...any) {
+	if t != nil {
+		t.Logf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
 func (n *Node) OpenChannelAndCheck(t *testing.T, targetNode *Node) {
 	n.OpenChannel(t, targetNode)
 	channelInfo, err := n.GetChannelInfoByAddress(targetNode)
@@ -419,12 +514,19 @@ func (n *Node) CreateAndSetSettlementLine(t *testing.T, targetNode *Node, equiva
 
 // CreateAndSetSettlementLine creates a settlement line with another node.
 // It first gets the contractor_id using getChannelInfo
-// Returns error if any step fails.
 func (n *Node) CreateSettlementLine(t *testing.T, targetNode *Node, equivalent string) {
+	if err := n.InitSettlementLine(targetNode, equivalent); err != nil {
+		t.Fatalf("%v", err)
+	}
+}
+
+// InitSettlementLine creates a settlement line with another node.
+// Returns error if any step fails.
+func (n *Node) InitSettlementLine(targetNode *Node, equivalent string) error {
 	// Step 1: Get contractor_id (channel_id) for the target node
 	channelInfo, err := n.GetChannelInfoByAddress(targetNode)
 	if err != nil {
-		t.Fatalf("failed to get channel info: %v", err)
+		return fmt.Errorf("failed to get channel info: %v", err)
 	}
 	contractorID := channelInfo.ChannelID
 
@@ -432,41 +534,46 @@ func (n *Node) CreateSettlementLine(t *testing.T, targetNode *Node, equivalent s
 	initURL := fmt.Sprintf("http://%s:%d/api/v1/node/contractors/%s/init-settlement-line/%s/",
 		n.IPAddress, n.CLIPort, contractorID, equivalent)
 
-	initResp, err := http.Post(initURL, "application/json", nil)
+	initResp, err := n.doAPIRequest(n.runContext(), http.MethodPost, initURL)
 	if err != nil {
-		t.Fatalf("failed to send init-settlement-line request: %v", err)
+		return fmt.Errorf("failed to send init-settlement-line request: %v", err)
 	}
 	defer initResp.Body.Close()
 	if initResp.StatusCode != http.StatusOK {
-		t.Fatalf("init-settlement-line request failed with status: %d", initResp.StatusCode)
+		return fmt.Errorf("init-settlement-line request failed with status: %d", initResp.StatusCode)
 	}
+	return nil
 }
 
 func (n *Node) SetSettlementLine(t *testing.T, targetNode *Node, equivalent string, amount string, expectedStatusCode int) {
+	statusCode, err := n.UpdateSettlementLine(targetNode, equivalent, amount)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if statusCode != expectedStatusCode {
+		t.Fatalf("set-settlement-line request failed with status: %d", statusCode)
+	}
+}
+
+// UpdateSettlementLine sets the max positive balance of the settlement line with another node.
+// Returns the response status code; error is returned only if the request could not be performed.
+func (n *Node) UpdateSettlementLine(targetNode *Node, equivalent string, amount string) (int, error) {
 	// Step 1: Get contractor_id (channel_id) for the target node
 	channelInfo, err := n.GetChannelInfoByAddress(targetNode)
 	if err != nil {
-		t.Fatalf("failed to get channel info: %v", err)
+		return 0, fmt.Errorf("failed to get channel info: %v", err)
 	}
 	contractorID := channelInfo.ChannelID
 
 	// Step 2: Set max positive balance (PUT)
 	setURL := fmt.Sprintf("http://%s:%d/api/v1/node/contractors/%s/settlement-lines/%s/?amount=%s",
 		n.IPAddress, n.CLIPort, contractorID, equivalent, amount)
-	request, err := http.NewRequest(http.MethodPut, setURL, nil)
-	if err != nil {
-		t.Fatalf("failed to create set-settlement-line request: %v", err)
-	}
-	request.Header.Set("Content-Type", "application/json")
-	client := &http.Client{}
-	setResp, err := client.Do(request)
+	setResp, err := n.doAPIRequest(n.runContext(), http.MethodPut, setURL)
 	if err != nil {
-		t.Fatalf("failed to send set-settlement-line request: %v", err)
+		return 0, fmt.Errorf("failed to send set-settlement-line request: %v", err)
 	}
 	defer setResp.Body.Close()
-	if setResp.StatusCode != expectedStatusCode {
-		t.Fatalf("set-settlement-line request failed with status: %d", setResp.StatusCode)
-	}
+	return setResp.StatusCode, nil
 }
 
 // GetSettlementsLineInfoByAddress fetches settlement line information using the target node's address.
@@ -474,7 +581,7 @@ func (n *Node) GetSettlementsLineInfoByAddress(targetNode *Node, equivalent stri
 	url := fmt.Sprintf("http://%s:%d/api/v1/node/contractors/settlement-line-by-address/%s/?contractor_address=%s",
 		n.IPAddress, n.CLIPort, equivalent, targetNode.GetIPAddressForRequests())
 
-	resp, err := http.Get(url)
+	resp, err := n.doAPIRequest(n.runContext(), http.MethodGet, url)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to send settlement-line-by-address request: %w", err)
 	}
@@ -528,13 +635,13 @@ func (n *Node) CheckSettlementLine(t *testing.T, targetNode *Node, equivalent, e
 	if settlementLineInfo.State != expectedState {
 		t.Fatalf("Node %s to %s for equivalent %s: settlement line state mismatch. Expected: %s, Got: %s", n.Alias, targetNode.Alias, equivalent, expectedState, settlementLineInfo.State)
 	}
-	if settlementLineInfo.MaxPositiveBalance != expectedMaxPositiveBalance {
+	if !amountsEqual(expectedMaxPositiveBalance, settlementLineInfo.MaxPositiveBalance) {
 		t.Fatalf("Node %s to %s for equivalent %s: max positive balance mismatch. Expected: %s, Got: %s", n.Alias, targetNode.Alias, equivalent, expectedMaxPositiveBalance, settlementLineInfo.MaxPositiveBalance)
 	}
-	if settlementLineInfo.MaxNegativeBalance != expectedMaxNegativeBalance {
+	if !amountsEqual(expectedMaxNegativeBalance, settlementLineInfo.MaxNegativeBalance) {
 		t.Fatalf("Node %s to %s for equivalent %s: max negative balance mismatch. Expected: %s, Got: %s", n.Alias, targetNode.Alias, equivalent, expectedMaxNegativeBalance, settlementLineInfo.MaxNegativeBalance)
 	}
-	if settlementLineInfo.Balance != expectedBalance {
+	if !amountsEqual(expectedBalance, settlementLineInfo.Balance) {
 		t.Fatalf("Node %s to %s for equivalent %s: balance mismatch. Expected: %s, Got: %s", n.Alias, targetNode.Alias, equivalent, expectedBalance, settlementLineInfo.Balance)
 	}
 	if settlementLineInfo.OwnKeysPresent != expectedOwnKeysPresent {
@@ -569,8 +676,8 @@ func (n *Node) SetSettlementLineAndCheck(t *testing.T, targetNode *Node, equival
 	}
 	n.SetSettlementLine(t, targetNode, equivalent, amount, StatusOK)
 	time.Sleep(500 * time.Millisecond)
-	n.CheckActiveSettlementLine(t, targetNode, equivalent, amount, settlementLineInfo.MaxNegativeBalance, settlementLineInfo.Balance)
-	targetNode.CheckActiveSettlementLine(t, n, equivalent, settlementLineInfo.MaxNegativeBalance, amount, settlementLineInfo.Balance)
+	n.CheckActiveSettlementLine(t, targetNode, equivalent, amount, settlementLineInfo.MaxNegativeBalance.String(), settlementLineInfo.Balance.String())
+	targetNode.CheckActiveSettlementLine(t, n, equivalent, settlementLineInfo.MaxNegativeBalance.String(), amount, settlementLineInfo.Balance.String())
 }
 
 func (n *Node) CreateChannelAndSettlementLineAndCheck(t *testing.T, targetNode *Node, equivalent string, amount string) {
@@ -581,7 +688,7 @@ func (n *Node) CreateChannelAndSettlementLineAndCheck(t *testing.T, targetNode *
 func (n *Node) GetSettlementLines(equivalent string) ([]SettlementLineInfo, error) {
 	url := fmt.Sprintf("http://%s:%d/api/v1/node/contractors/settlement-lines/%s/", n.IPAddress, n.CLIPort, equivalent)
 
-	resp, err := http.Get(url)
+	resp, err := n.doAPIRequest(n.runContext(), http.MethodGet, url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send get settlement-lines request: %w", err)
 	}
@@ -616,13 +723,7 @@ func (n *Node) CloseMaxNegativeBalance(t *testing.T, targetNode *Node, equivalen
 	// Step 2: Set max negative balance into zero (DELETE)
 	setURL := fmt.Sprintf("http://%s:%d/api/v1/node/contractors/%s/close-incoming-settlement-line/%s/",
 		n.IPAddress, n.CLIPort, contractorID, equivalent)
-	request, err := http.NewRequest(http.MethodDelete, setURL, nil)
-	if err != nil {
-		t.Fatalf("failed to create close-incoming-settlement-line request: %v", err)
-	}
-	request.Header.Set("Content-Type", "application/json")
-	client := &http.Client{}
-	setResp, err := client.Do(request)
+	setResp, err := n.doAPIRequest(n.runContext(), http.MethodDelete, setURL)
 	if err != nil {
 		t.Fatalf("failed to send close-incoming-settlement-line request: %v", err)
 	}
@@ -634,43 +735,57 @@ func (n *Node) CloseMaxNegativeBalance(t *testing.T, targetNode *Node, equivalen
 }
 
 func (n *Node) CheckSettlementLineForSync(t *testing.T, targetNode *Node, equivalent string) {
+	mismatches, err := n.settlementLineSyncMismatches(targetNode, equivalent)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	reportMismatches(t, fmt.Sprintf("settlement line %s - %s is not synced", n.Alias, targetNode.Alias), mismatches)
+}
+
+// settlementLineSyncMismatches compares the node's side of the settlement line with targetNode with the mirrored
+// side of targetNode: the expected values are the ones of targetNode. The storage diff of the line is attached
+// to the first mismatch.
+func (n *Node) settlementLineSyncMismatches(targetNode *Node, equivalent string) ([]AssertionMismatch, error) {
 	settlementLineInfo, _, err := n.GetSettlementsLineInfoByAddress(targetNode, equivalent)
 	if err != nil {
-		t.Fatalf("failed to get settlement line info: %v", err)
+		return nil, fmt.Errorf("failed to get settlement line info: %v", err)
 	}
 
 	targetNodeSettlementLineInfo, _, err := targetNode.GetSettlementsLineInfoByAddress(n, equivalent)
 	if err != nil {
-		t.Fatalf("failed to get settlement line info: %v", err)
+		return nil, fmt.Errorf("failed to get settlement line info: %v", err)
 	}
 
-	if settlementLineInfo.State != targetNodeSettlementLineInfo.State {
-		t.Fatalf("settlement line state is not synced")
+	var mismatches []AssertionMismatch
+	mismatch := func(field string, expected, actual any) {
+		mismatches = append(mismatches, newMismatch("settlement line sync", n, targetNode, field, expected, actual))
 	}
-
-	if settlementLineInfo.MaxPositiveBalance != targetNodeSettlementLineInfo.MaxNegativeBalance {
-		t.Fatalf("settlement line max positive balance is not synced")
+	if settlementLineInfo.State != targetNodeSettlementLineInfo.State {
+		mismatch("state", targetNodeSettlementLineInfo.State, settlementLineInfo.State)
 	}
-
-	if settlementLineInfo.MaxNegativeBalance != targetNodeSettlementLineInfo.MaxPositiveBalance {
-		t.Fatalf("settlement line max negative balance is not synced")
+	if !settlementLineInfo.MaxPositiveBalance.Equal(targetNodeSettlementLineInfo.MaxNegativeBalance) {
+		mismatch("max positive balance", targetNodeSettlementLineInfo.MaxNegativeBalance, settlementLineInfo.MaxPositiveBalance)
 	}
-
-	settlementLineBalance, err := strconv.Atoi(settlementLineInfo.Balance)
-	if err != nil {
-		t.Fatalf("failed to convert settlement line balance to int: %v", err)
+	if !settlementLineInfo.MaxNegativeBalance.Equal(targetNodeSettlementLineInfo.MaxPositiveBalance) {
+		mismatch("max negative balance", targetNodeSettlementLineInfo.MaxPositiveBalance, settlementLineInfo.MaxNegativeBalance)
 	}
-	targetNodeSettlementLineBalance, err := strconv.Atoi(targetNodeSettlementLineInfo.Balance)
-	if err != nil {
-		t.Fatalf("failed to convert settlement line balance to int: %v", err)
+	if !settlementLineInfo.Balance.Equal(targetNodeSettlementLineInfo.Balance.Neg()) {
+		mismatch("balance", targetNodeSettlementLineInfo.Balance.Neg(), settlementLineInfo.Balance)
 	}
-	if settlementLineBalance != -targetNodeSettlementLineBalance {
-		t.Fatalf("settlement line balance is not synced")
+
+	if len(mismatches) > 0 {
+		mismatches[0].Details = n.settlementLineDiffReport(targetNode, equivalent)
 	}
+	return mismatches, nil
 }
 
+// CheckSettlementLineForSyncBatch checks that every settlement line of the nodes is synced with the contractor's
+// side, and reports all the lines that are not synced together. Every line is checked once, from the side of
+// the node listed first.
 func CheckSettlementLineForSyncBatch(t *testing.T, nodes []*Node, equivalent string, timeToSleepSeconds int) {
 	time.Sleep(time.Duration(timeToSleepSeconds) * time.Second)
+	var mismatches []AssertionMismatch
+	checked := make(map[[2]*Node]bool)
 	for _, node := range nodes {
 		settlementLines, err := node.GetSettlementLines(equivalent)
 		if err != nil {
@@ -685,11 +800,23 @@ func CheckSettlementLineForSyncBatch(t *testing.T, nodes []*Node, equivalent str
 				}
 			}
 			if targetNode == nil {
-				t.Fatalf("target node not found for settlement line: %+v", settlementLine)
+				mismatches = append(mismatches, newMismatch("settlement line sync", node, nil, "contractor",
+					"a node of the batch", settlementLine.ContractorAddress))
+				continue
+			}
+			if checked[[2]*Node{targetNode, node}] {
+				continue
+			}
+			checked[[2]*Node{node, targetNode}] = true
+
+			lineMismatches, err := node.settlementLineSyncMismatches(targetNode, equivalent)
+			if err != nil {
+				t.Fatalf("%v", err)
 			}
-			node.CheckSettlementLineForSync(t, targetNode, equivalent)
+			mismatches = append(mismatches, lineMismatches...)
 		}
 	}
+	reportMismatches(t, "settlement lines are not synced", mismatches)
 }
 
 func (n *Node) SettlementLineKeysSharing(t *testing.T, targetNode *Node, equivalent string) {
@@ -703,13 +830,7 @@ func (n *Node) SettlementLineKeysSharing(t *testing.T, targetNode *Node, equival
 	// Step 2: Set keys sharing (PUT)
 	setURL := fmt.Sprintf("http://%s:%d/api/v1/node/contractors/%s/keys-sharing/%s/",
 		n.IPAddress, n.CLIPort, contractorID, equivalent)
-	request, err := http.NewRequest(http.MethodPut, setURL, nil)
-	if err != nil {
-		t.Fatalf("failed to create keys-sharing request: %v", err)
-	}
-	request.Header.Set("Content-Type", "application/json")
-	client := &http.Client{}
-	setResp, err := client.Do(request)
+	setResp, err := n.doAPIRequest(n.runContext(), http.MethodPut, setURL)
 	if err != nil {
 		t.Fatalf("failed to send keys-sharing request: %v", err)
 	}
@@ -722,19 +843,35 @@ func (n *Node) SettlementLineKeysSharing(t *testing.T, targetNode *Node, equival
 // CreateTransaction initiates a transaction to the target node.
 // It first gets the contractor_id using getChannelInfo.
 func (n *Node) CreateTransactionCheckStatus(t *testing.T, targetNode *Node, equivalent string, amount string, expectedStatus int) (string, error) {
+	transactionUUID, statusCode, body, err := n.CreateTransaction(targetNode, equivalent, amount)
+	if statusCode != 0 && statusCode != expectedStatus { // Documentation example implies 200 OK
+		t.Fatalf("create transaction request failed with status: %d, body: %s", statusCode, body)
+	}
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	t.Logf("transaction_uuid: %s", transactionUUID)
+
+	return transactionUUID, nil
+}
+
+// CreateTransaction initiates a payment to the target node and returns the transaction UUID (if the node returned one),
+// the response status code and body.
+func (n *Node) CreateTransaction(targetNode *Node, equivalent string, amount string) (string, int, string, error) {
 	url := fmt.Sprintf("http://%s:%d/api/v1/node/contractors/transactions/%s/?contractor_address=%s&amount=%s",
 		n.IPAddress, n.CLIPort, equivalent, targetNode.GetIPAddressForRequests(), amount)
 
 	// No request body needed, parameters are in the URL query
-	resp, err := http.Post(url, "application/json", nil) // Body is nil
+	resp, err := n.doAPIRequest(n.runContext(), http.MethodPost, url) // Body is nil
 	if err != nil {
-		t.Fatalf("failed to send create transaction request: %v", err)
+		return "", 0, "", fmt.Errorf("failed to send create transaction request: %v", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != expectedStatus { // Documentation example implies 200 OK
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		t.Fatalf("create transaction request failed with status: %d, body: %s", resp.StatusCode, string(bodyBytes))
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", resp.StatusCode, "", fmt.Errorf("failed to read create transaction response: %v", err)
 	}
 
 	// Decode response to get transaction_uuid if needed
@@ -743,13 +880,33 @@ func (n *Node) CreateTransactionCheckStatus(t *testing.T, targetNode *Node, equi
 			TransactionUUID string `json:"transaction_uuid"`
 		} `json:"data"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		t.Fatalf("failed to decode create transaction response: %v", err)
+	if err := json.Unmarshal(bodyBytes, &result); err != nil {
+		return "", resp.StatusCode, string(bodyBytes), fmt.Errorf("failed to decode create transaction response: %v", err)
 	}
 
-	t.Logf("transaction_uuid: %s", result.Data.TransactionUUID)
+	n.recordInitiatedTransaction(result.Data.TransactionUUID)
+	return result.Data.TransactionUUID, resp.StatusCode, string(bodyBytes), nil
+}
 
-	return result.Data.TransactionUUID, nil
+// recordInitiatedTransaction remembers the UUID of a payment the node has initiated.
+func (n *Node) recordInitiatedTransaction(transactionUUID string) {
+	if transactionUUID == "" {
+		return
+	}
+	n.initiatedMu.Lock()
+	defer n.initiatedMu.Unlock()
+	n.initiatedTransactions = append(n.initiatedTransactions, transactionUUID)
+}
+
+// initiatedTransactionsSince returns the UUIDs of the payments initiated after the first `since` ones,
+// and the number of the payments initiated so far.
+func (n *Node) initiatedTransactionsSince(since int) ([]string, int) {
+	n.initiatedMu.Lock()
+	defer n.initiatedMu.Unlock()
+	if since > len(n.initiatedTransactions) {
+		since = len(n.initiatedTransactions)
+	}
+	return append([]string(nil), n.initiatedTransactions[since:]...), len(n.initiatedTransactions)
 }
 
 // CreateExchangeTransactionCheckStatus initiates an exchange transaction to the target node.
@@ -764,25 +921,31 @@ func (n *Node) CreateExchangeTransactionCheckStatus(t *testing.T, targetNode *No
 	}
 
 	// No request body needed, parameters are in the URL query
-	resp, err := http.Post(url, "application/json", nil) // Body is nil
+	resp, err := n.doAPIRequest(n.runContext(), http.MethodPost, url) // Body is nil
 	if err != nil {
 		t.Fatalf("failed to send create exchange transaction request: %v", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != expectedStatus {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		t.Fatalf("create exchange transaction request failed with status: %d, body: %s", resp.StatusCode, string(bodyBytes))
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read create exchange transaction response: %v", err)
 	}
 
-	// Decode response to get transaction_uuid if needed
+	// Decode response to get transaction_uuid, error responses carry it as well
 	var result struct {
 		Data struct {
 			TransactionUUID string `json:"transaction_uuid"`
 		} `json:"data"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		t.Fatalf("failed to decode create exchange transaction response: %v", err)
+	decodeErr := json.Unmarshal(bodyBytes, &result)
+	n.recordInitiatedTransaction(result.Data.TransactionUUID)
+
+	if resp.StatusCode != expectedStatus {
+		t.Fatalf("create exchange transaction request failed with status: %d, body: %s", resp.StatusCode, string(bodyBytes))
+	}
+	if decodeErr != nil {
+		t.Fatalf("failed to decode create exchange transaction response: %v", decodeErr)
 	}
 
 	t.Logf("exchange transaction_uuid: %s", result.Data.TransactionUUID)
@@ -790,30 +953,30 @@ func (n *Node) CreateExchangeTransactionCheckStatus(t *testing.T, targetNode *No
 	return result.Data.TransactionUUID, nil
 }
 
-func (n *Node) GetMaxFlow(t *testing.T, targetNode *Node, equivalent string) (string, error) {
+func (n *Node) GetMaxFlow(t *testing.T, targetNode *Node, equivalent string) (Amount, error) {
 	url := fmt.Sprintf("http://%s:%d/api/v1/node/contractors/transactions/max/%s/?contractor_address=%s",
 		n.IPAddress, n.CLIPort, equivalent, targetNode.GetIPAddressForRequests())
 
-	resp, err := http.Get(url)
+	resp, err := n.doAPIRequest(n.runContext(), http.MethodGet, url)
 	if err != nil {
-		return "", fmt.Errorf("failed to send max-flow request: %w", err)
+		return Amount{}, fmt.Errorf("failed to send max-flow request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("max-flow request failed with status: %d", resp.StatusCode)
+		return Amount{}, fmt.Errorf("max-flow request failed with status: %d", resp.StatusCode)
 	}
 
 	var result struct {
 		Data MaxFlowInfo `json:"data"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("failed to decode max-flow response: %w", err)
+		return Amount{}, fmt.Errorf("failed to decode max-flow response: %w", err)
 	}
 	println(fmt.Sprintf("max-flow response: %+v", result.Data))
 
 	if result.Data.Count != 1 {
-		return "", fmt.Errorf("max-flow response has wrong count. expected: 1, got: %d", result.Data.Count)
+		return Amount{}, fmt.Errorf("max-flow response has wrong count. expected: 1, got: %d", result.Data.Count)
 	}
 
 	return result.Data.Records[0].MaxAmount, nil
@@ -824,7 +987,7 @@ func (n *Node) CheckMaxFlow(t *testing.T, targetNode *Node, equivalent string, e
 	if err != nil {
 		t.Fatalf("failed to get max-flow: %v", err)
 	}
-	if maxFlow != expectedMaxFlow {
+	if !amountsEqual(expectedMaxFlow, maxFlow) {
 		t.Fatalf("max-flow is wrong. expected: %s, got: %s", expectedMaxFlow, maxFlow)
 	}
 }
@@ -848,7 +1011,7 @@ func (n *Node) GetMaxFlowBatch(t *testing.T, targetNodes []*Node, equivalent str
 		}
 	}
 
-	resp, err := http.Get(url)
+	resp, err := n.doAPIRequest(n.runContext(), http.MethodGet, url)
 	if err != nil {
 		t.Fatalf("failed to send max-flow batch request: %v", err)
 	}
@@ -904,25 +1067,26 @@ func (n *Node) CheckMaxFlowBatch(t *testing.T, checks []MaxFlowBatchCheck, equiv
 		t.Fatalf("number of max flows received (%d) does not match number of checks (%d)", len(maxFlowResults), len(checks))
 	}
 
-	resultsMap := make(map[string]string)
+	resultsMap := make(map[string]Amount)
 	for _, res := range maxFlowResults {
 		resultsMap[res.ContractorAddress] = res.MaxAmount
 	}
 
+	var mismatches []AssertionMismatch
 	for _, check := range checks {
-		nodeAddress := check.Node.GetIpAndPort()
-		actualMaxAmount, found := resultsMap[nodeAddress]
+		actualMaxAmount, found := resultsMap[check.Node.GetIpAndPort()]
 		if !found {
-			t.Fatalf("max-flow result not found for contractor address: %s. Available results: %+v", nodeAddress, resultsMap)
+			mismatches = append(mismatches, newMismatch("max flow", n, check.Node, "max amount", check.ExpectedMaxFlow, "no result"))
+			continue
 		}
-		if actualMaxAmount != check.ExpectedMaxFlow {
-			t.Fatalf("max-flow for node %s (address %s) is wrong. expected: %s, got: %s",
-				check.Node.Alias, nodeAddress, check.ExpectedMaxFlow, actualMaxAmount)
+		if !check.ExpectedMaxFlow.Equal(actualMaxAmount) {
+			mismatches = append(mismatches, newMismatch("max flow", n, check.Node, "max amount", check.ExpectedMaxFlow, actualMaxAmount))
 		}
 	}
+	reportMismatches(t, fmt.Sprintf("max flows of node %s in equivalent %s are wrong", n.Alias, equivalent), mismatches)
 }
 
-func (n *Node) GetExchangeMaxFlow(t *testing.T, targetNode *Node, equivalent string, exchangeEquivalents []string) (string, error) {
+func (n *Node) GetExchangeMaxFlow(t *testing.T, targetNode *Node, equivalent string, exchangeEquivalents []string) (Amount, error) {
 	baseURL := fmt.Sprintf("http://%s:%d/api/v1/node/contractors/transactions/exchange/max/%s/",
 		n.IPAddress, n.CLIPort, equivalent)
 
@@ -940,26 +1104,26 @@ func (n *Node) GetExchangeMaxFlow(t *testing.T, targetNode *Node, equivalent str
 		}
 	}
 
-	resp, err := http.Get(url)
+	resp, err := n.doAPIRequest(n.runContext(), http.MethodGet, url)
 	if err != nil {
-		return "", fmt.Errorf("failed to send exchange max-flow request: %w", err)
+		return Amount{}, fmt.Errorf("failed to send exchange max-flow request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("exchange max-flow request failed with status: %d", resp.StatusCode)
+		return Amount{}, fmt.Errorf("exchange max-flow request failed with status: %d", resp.StatusCode)
 	}
 
 	var result struct {
 		Data MaxFlowInfo `json:"data"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("failed to decode exchange max-flow response: %w", err)
+		return Amount{}, fmt.Errorf("failed to decode exchange max-flow response: %w", err)
 	}
 	println(fmt.Sprintf("exchange max-flow response: %+v", result.Data))
 
 	if result.Data.Count != 1 {
-		return "", fmt.Errorf("exchange max-flow response has wrong count. expected: 1, got: %d", result.Data.Count)
+		return Amount{}, fmt.Errorf("exchange max-flow response has wrong count. expected: 1, got: %d", result.Data.Count)
 	}
 
 	return result.Data.Records[0].MaxAmount, nil
@@ -970,7 +1134,7 @@ func (n *Node) CheckExchangeMaxFlow(t *testing.T, targetNode *Node, equivalent s
 	if err != nil {
 		t.Fatalf("failed to get exchange max-flow: %v", err)
 	}
-	if maxFlow != expectedMaxFlow {
+	if !amountsEqual(expectedMaxFlow, maxFlow) {
 		t.Fatalf("exchange max-flow is wrong. expected: %s, got: %s", expectedMaxFlow, maxFlow)
 	}
 }
@@ -999,7 +1163,7 @@ func (n *Node) GetExchangeMaxFlowBatch(t *testing.T, targetNodes []*Node, equiva
 		}
 	}
 
-	resp, err := http.Get(url)
+	resp, err := n.doAPIRequest(n.runContext(), http.MethodGet, url)
 	if err != nil {
 		t.Fatalf("failed to send exchange max-flow batch request: %v", err)
 	}
@@ -1055,55 +1219,60 @@ func (n *Node) CheckExchangeMaxFlowBatch(t *testing.T, checks []MaxFlowBatchChec
 		t.Fatalf("number of max flows received (%d) does not match number of checks (%d)", len(maxFlowResults), len(checks))
 	}
 
-	resultsMap := make(map[string]string)
+	resultsMap := make(map[string]Amount)
 	for _, res := range maxFlowResults {
 		resultsMap[res.ContractorAddress] = res.MaxAmount
 	}
 
+	var mismatches []AssertionMismatch
 	for _, check := range checks {
-		nodeAddress := check.Node.GetIpAndPort()
-		actualMaxAmount, found := resultsMap[nodeAddress]
+		actualMaxAmount, found := resultsMap[check.Node.GetIpAndPort()]
 		if !found {
-			t.Fatalf("exchange max-flow result not found for contractor address: %s. Available results: %+v", nodeAddress, resultsMap)
+			mismatches = append(mismatches, newMismatch("exchange max flow", n, check.Node, "max amount", check.ExpectedMaxFlow, "no result"))
+			continue
 		}
-		if actualMaxAmount != check.ExpectedMaxFlow {
-			t.Fatalf("exchange max-flow for node %s (address %s) is wrong. expected: %s, got: %s",
-				check.Node.Alias, nodeAddress, check.ExpectedMaxFlow, actualMaxAmount)
+		if !check.ExpectedMaxFlow.Equal(actualMaxAmount) {
+			mismatches = append(mismatches, newMismatch("exchange max flow", n, check.Node, "max amount", check.ExpectedMaxFlow, actualMaxAmount))
 		}
 	}
+	reportMismatches(t, fmt.Sprintf("exchange max flows of node %s in equivalent %s are wrong", n.Alias, equivalent), mismatches)
 }
 
 func (n *Node) SetTestingFlag(t *testing.T, flag uint64, appliableNodeAddress string, appliableAmount string) {
+	if err := n.SendTestingFlag(flag, appliableNodeAddress, appliableAmount); err != nil {
+		t.Fatalf("%v", err)
+	}
+}
+
+// SendTestingFlag sets the debug flag on the node's testing port.
+// appliableNodeAddress and appliableAmount optionally narrow the flag down to a specific contractor/amount.
+func (n *Node) SendTestingFlag(flag uint64, appliableNodeAddress string, appliableAmount string) error {
+	n.recordTestingFlag(TestingFlagKindPayment, flag, appliableNodeAddress, appliableAmount)
+
 	url := fmt.Sprintf("http://%s:%d/api/v1/node/subsystems-controller/%d/?forbidden_address=%s&forbidden_amount=%s",
 		n.IPAddress, n.CLIPortTest, flag, appliableNodeAddress, appliableAmount)
 
-	request, err := http.NewRequest(http.MethodPut, url, nil)
-	if err != nil {
-		t.Fatalf("failed to send set testing flag request: %v", err)
-	}
-	request.Header.Set("Content-Type", "application/json")
-	client := &http.Client{}
-	setResp, err := client.Do(request)
+	setResp, err := n.doAPIRequest(n.runContext(), http.MethodPut, url)
 	if err != nil {
-		t.Fatalf("failed to send subsystems-controller request: %v", err)
+		return fmt.Errorf("failed to send subsystems-controller request: %v", err)
 	}
 	defer setResp.Body.Close()
 	if setResp.StatusCode != http.StatusOK {
-		t.Fatalf("subsystems-controller request failed with status: %d", setResp.StatusCode)
+		return fmt.Errorf("subsystems-controller request failed with status: %d", setResp.StatusCode)
 	}
+	if n.Testing != nil {
+		n.Testing.setCurrent(TestingFlagsState{Flags: TestingFlags(flag), Address: appliableNodeAddress, Amount: appliableAmount})
+	}
+	return nil
 }
 
 func (n *Node) SetTestingSLFlag(flag uint64, firstParam, secondParam, thirdParam string) error {
+	n.recordTestingFlag(TestingFlagKindSettlementLine, flag, firstParam, secondParam, thirdParam)
+
 	url := fmt.Sprintf("http://%s:%d/api/v1/node/settlement-lines-influence/%d/?first_parameter=%s&second_parameter=%s&third_parameter=%s",
 		n.IPAddress, n.CLIPortTest, flag, firstParam, secondParam, thirdParam)
 
-	request, err := http.NewRequest(http.MethodPut, url, nil)
-	if err != nil {
-		return fmt.Errorf("failed to send set testing SL flag request: %w", err)
-	}
-	request.Header.Set("Content-Type", "application/json")
-	client := &http.Client{}
-	setResp, err := client.Do(request)
+	setResp, err := n.doAPIRequest(n.runContext(), http.MethodPut, url)
 	if err != nil {
 		return fmt.Errorf("failed to send settlement-lines-influence request: %w", err)
 	}
@@ -1115,14 +1284,43 @@ func (n *Node) SetTestingSLFlag(flag uint64, firstParam, secondParam, thirdParam
 	return nil
 }
 
-// CheckPaymentTransaction queries the node's SQLite database within its Docker container
+// storage returns the node's storage inspector. Nodes started by a cluster get it at start-up,
+// nodes restored from outside of a cluster get it on first use.
+func (n *Node) storage() StorageInspector {
+	if n.Storage == nil {
+		n.Storage = NewStorageInspector(n, os.Getenv("VTCPD_DATABASE_CONFIG"))
+	}
+	return n.Storage
+}
+
+// QueryStorage runs an arbitrary query against the node's storage, for assertions not covered by the Check* methods.
+// Placeholders are written as ?, see StorageInspector.
+func (n *Node) QueryStorage(t *testing.T, query string, args ...any) []StorageRow {
+	rows, err := n.storage().Query(query, args...)
+	if err != nil {
+		t.Fatalf("Node %s: Error executing storage query. Query: '%s'. Error: %v", n.Alias, query, err)
+	}
+	return rows
+}
+
+// CheckStorageQueryRowsCount checks the number of rows returned by an arbitrary storage query.
+func (n *Node) CheckStorageQueryRowsCount(t *testing.T, expectedCount int, query string, args ...any) {
+	rows := n.QueryStorage(t, query, args...)
+	if len(rows) != expectedCount {
+		reportMismatches(t, fmt.Sprintf("Node %s: storage check failed", n.Alias), []AssertionMismatch{
+			newMismatch("storage", n, nil, fmt.Sprintf("rows of '%s' %v", query, args), expectedCount, len(rows)),
+		})
+	}
+}
+
+// CheckPaymentTransaction queries the node's database within its Docker container
 // to verify various aspects of payment transactions.
 // - transactionState: Optional. If provided, checks the 'observing_state' of the latest payment transaction.
 // - paymentTransactionsCount: Expected count of records in 'payment_transactions' table.
 // - participantsVotesCount: Expected count of records in 'payment_participants_votes' table.
 // - incomingReceiptsCount: Expected count of records in 'incoming_receipt' table.
 // - outgoingReceiptsCount: Expected count of records in 'outgoing_receipt' table.
-func (n *Node) CheckPaymentTransactionSQLite(
+func (n *Node) CheckPaymentTransaction(
 	t *testing.T,
 	transactionState string,
 	paymentTransactionsCount int,
@@ -1130,80 +1328,52 @@ func (n *Node) CheckPaymentTransactionSQLite(
 	incomingReceiptsCount int,
 	outgoingReceiptsCount int,
 ) {
-	if n.ContainerID == "" {
-		t.Fatalf("Node %s: ContainerID is not set, cannot execute database checks.", n.Alias)
-	}
-
-	dbPath := "/vtcp/vtcpd/io/storagedb" // As specified by the user
-
-	executeQuery := func(query string) (string, error) {
-		cmdArgs := []string{"exec", n.ContainerID, "sqlite3", dbPath, query}
-		cmd := exec.Command("docker", cmdArgs...)
-
-		output, err := cmd.CombinedOutput()
-		trimmedOutput := strings.TrimSpace(string(output))
-
-		if err != nil {
-			return trimmedOutput, fmt.Errorf("docker exec command failed for query ['%s'] on node %s (container: %s): %v. Output: %s", query, n.Alias, n.ContainerID, err, trimmedOutput)
-		}
-		return trimmedOutput, nil
-	}
+	storage := n.storage()
+	var mismatches []AssertionMismatch
 
 	// 1. Check transaction_state (if provided)
 	if transactionState != "" {
-		query := "SELECT observing_state FROM payment_transactions ORDER BY recording_time DESC LIMIT 1"
-		actualState, err := executeQuery(query)
+		actualState, err := storage.LatestPaymentTransactionState()
 		if err != nil {
-			// If executeQuery returns an error, it means the command failed (e.g. docker exec error, sqlite error like no such table)
-			// If the query executes but returns no rows (e.g. table is empty), sqlite3 CLI typically outputs nothing and exits 0.
-			// In that case, actualState would be an empty string.
-			if actualState == "" && strings.Contains(err.Error(), "Output: ") { // Check if error message already contains output
-				t.Fatalf("Node %s: Error querying transaction state. Query: '%s'. Error: %v", n.Alias, query, err)
-			} else {
-				t.Fatalf("Node %s: Error querying transaction state. Query: '%s'. Error: %v. Output: %s", n.Alias, query, err, actualState)
-			}
+			t.Fatalf("Node %s: Error querying transaction state. Error: %v", n.Alias, err)
 		}
-		// If query was successful but returned no rows (e.g. payment_transactions table is empty), actualState will be ""
 		if actualState != transactionState {
-			t.Fatalf("Node %s: Transaction state mismatch. Expected: '%s', Got: '%s'. Query: '%s'", n.Alias, transactionState, actualState, query)
+			mismatches = append(mismatches, newMismatch("storage", n, nil, "transaction state", transactionState, actualState))
 		}
 	}
 
 	// Helper for checking counts
-	checkCount := func(tableName string, expectedCount int) {
-		query := fmt.Sprintf("SELECT count(*) FROM %s", tableName)
-		countStr, err := executeQuery(query)
+	checkCount := func(tableName string, expectedCount int, count func() (int, error)) {
+		actualCount, err := count()
 		if err != nil {
-			t.Fatalf("Node %s: Error querying count for table '%s'. Query: '%s'. Error: %v. Output: %s", n.Alias, tableName, query, err, countStr)
-		}
-		actualCount, convErr := strconv.Atoi(countStr)
-		if convErr != nil {
-			t.Fatalf("Node %s: Error converting count '%s' to int for table '%s'. Query: '%s'. Error: %v", n.Alias, countStr, tableName, query, convErr)
+			t.Fatalf("Node %s: Error querying count for table '%s'. Error: %v", n.Alias, tableName, err)
 		}
 		if actualCount != expectedCount {
-			t.Fatalf("Node %s: Count mismatch for table '%s'. Expected: %d, Got: %d. Query: '%s'", n.Alias, tableName, expectedCount, actualCount, query)
+			mismatches = append(mismatches, newMismatch("storage", n, nil, tableName+" count", expectedCount, actualCount))
 		}
 	}
 
 	// 2. Check payment_transactions count
-	checkCount("payment_transactions", paymentTransactionsCount)
+	checkCount("payment_transactions", paymentTransactionsCount, storage.PaymentTransactionsCount)
 
 	// 3. Check payment_participants_votes count
-	checkCount("payment_participants_votes", participantsVotesCount)
+	checkCount("payment_participants_votes", participantsVotesCount, storage.ParticipantsVotesCount)
 
 	// 4. Check incoming_receipt count
-	checkCount("incoming_receipt", incomingReceiptsCount)
+	checkCount("incoming_receipt", incomingReceiptsCount, storage.IncomingReceiptsCount)
 
 	// 5. Check outgoing_receipt count
-	checkCount("outgoing_receipt", outgoingReceiptsCount)
+	checkCount("outgoing_receipt", outgoingReceiptsCount, storage.OutgoingReceiptsCount)
+
+	reportMismatches(t, fmt.Sprintf("Node %s: payment transaction check failed", n.Alias), mismatches)
 }
 
-// CheckSerializedTransaction queries the node's SQLite database within its Docker container
+// CheckSerializedTransaction queries the node's database within its Docker container
 // to check for the presence and count of records in the 'transactions' table.
 // - t: The testing.T instance for logging and failing tests.
 // - isTransactionShouldBePresent: A boolean indicating if a transaction record is expected (true means 1, false means 0).
 // - timeToSleepSeconds: The number of seconds to sleep before performing the check.
-func (n *Node) CheckSerializedTransactionSQLite(
+func (n *Node) CheckSerializedTransaction(
 	t *testing.T,
 	isTransactionShouldBePresent bool,
 	timeToSleepSeconds int,
@@ -1212,309 +1382,148 @@ func (n *Node) CheckSerializedTransactionSQLite(
 		time.Sleep(time.Duration(timeToSleepSeconds) * time.Second)
 	}
 
-	if n.ContainerID == "" {
-		t.Fatalf("Node %s: ContainerID is not set, cannot execute database checks.", n.Alias)
-	}
-
-	dbPath := "/vtcp/vtcpd/io/storagedb" // As specified by the user
-
-	executeQuery := func(query string) (string, error) {
-		cmdArgs := []string{"exec", n.ContainerID, "sqlite3", dbPath, query}
-		cmd := exec.Command("docker", cmdArgs...)
-
-		output, err := cmd.CombinedOutput()
-		trimmedOutput := strings.TrimSpace(string(output))
-
-		if err != nil {
-			return trimmedOutput, fmt.Errorf("docker exec command failed for query ['%s'] on node %s (container: %s): %v. Output: %s", query, n.Alias, n.ContainerID, err, trimmedOutput)
-		}
-		return trimmedOutput, nil
-	}
-
-	query := "SELECT count(*) FROM transactions"
-	countStr, err := executeQuery(query)
+	actualCount, err := n.storage().SerializedTransactionsCount()
 	if err != nil {
-		// If executeQuery returns an error, it implies command failure or sqlite error (e.g., table doesn't exist)
-		// countStr might contain stderr output in such cases.
-		t.Fatalf("Node %s: Error querying count from 'transactions' table. Query: '%s'. Error: %v. Output: %s", n.Alias, query, err, countStr)
-	}
-
-	actualCount, convErr := strconv.Atoi(countStr)
-	if convErr != nil {
-		t.Fatalf("Node %s: Error converting count '%s' to int for 'transactions' table. Query: '%s'. SQLite output: '%s'. Conversion error: %v", n.Alias, countStr, query, countStr, convErr)
+		t.Fatalf("Node %s: Error querying count from 'transactions' table. Error: %v", n.Alias, err)
 	}
 
 	expectedCount := 0
 	if isTransactionShouldBePresent {
 		expectedCount = 1
 	}
-
 	if actualCount != expectedCount {
-		if isTransactionShouldBePresent {
-			t.Fatalf("Node %s: Expected 1 serialized transaction in DB, but found %d. Query: '%s'", n.Alias, actualCount, query)
-		} else {
-			t.Fatalf("Node %s: Expected 0 serialized transactions in DB, but found %d. Query: '%s'", n.Alias, actualCount, query)
-		}
+		reportMismatches(t, fmt.Sprintf("Node %s: serialized transaction check failed", n.Alias), []AssertionMismatch{
+			newMismatch("storage", n, nil, "serialized transactions count", expectedCount, actualCount),
+		})
 	}
 }
 
-// CheckValidKeys queries the node's SQLite database to check the count of valid own and contractor keys.
-func (n *Node) CheckValidKeysSQLite(t *testing.T, expectedOwnValidKeysCount, expectedContractorValidKeysCount int) {
-	if n.ContainerID == "" {
-		t.Fatalf("Node %s: ContainerID is not set, cannot execute database checks.", n.Alias)
-	}
-
-	dbPath := "/vtcp/vtcpd/io/storagedb"
-
-	executeQuery := func(query string) (string, error) {
-		cmdArgs := []string{"exec", n.ContainerID, "sqlite3", dbPath, query}
-		cmd := exec.Command("docker", cmdArgs...)
-		output, err := cmd.CombinedOutput()
-		trimmedOutput := strings.TrimSpace(string(output))
-		if err != nil {
-			return trimmedOutput, fmt.Errorf("docker exec command failed for query ['%s'] on node %s (container: %s): %v. Output: %s", query, n.Alias, n.ContainerID, err, trimmedOutput)
-		}
-		return trimmedOutput, nil
-	}
-
-	// Check own_keys
-	queryOwnKeys := "SELECT COUNT(*) FROM own_keys WHERE is_valid = 1"
-	ownKeysCountStr, err := executeQuery(queryOwnKeys)
+// CheckValidKeys queries the node's database to check the count of valid own and contractor keys.
+func (n *Node) CheckValidKeys(t *testing.T, expectedOwnValidKeysCount, expectedContractorValidKeysCount int) {
+	var mismatches []AssertionMismatch
+	ownKeysCount, err := n.storage().ValidOwnKeysCount("")
 	if err != nil {
-		t.Fatalf("Node %s: Error querying own_keys count. Query: '%s'. Error: %v. Output: %s", n.Alias, queryOwnKeys, err, ownKeysCountStr)
-	}
-	ownKeysCount, convErr := strconv.Atoi(ownKeysCountStr)
-	if convErr != nil {
-		t.Fatalf("Node %s: Error converting own_keys count '%s' to int. Query: '%s'. Error: %v", n.Alias, ownKeysCountStr, queryOwnKeys, convErr)
+		t.Fatalf("Node %s: Error querying own_keys count. Error: %v", n.Alias, err)
 	}
 	if ownKeysCount != expectedOwnValidKeysCount {
-		t.Fatalf("Node %s: Own valid keys count mismatch. Expected: %d, Got: %d. Query: '%s'", n.Alias, expectedOwnValidKeysCount, ownKeysCount, queryOwnKeys)
+		mismatches = append(mismatches, newMismatch("storage", n, nil, "own valid keys count", expectedOwnValidKeysCount, ownKeysCount))
 	}
 
-	// Check contractor_keys
-	queryContractorKeys := "SELECT COUNT(*) FROM contractor_keys WHERE is_valid = 1"
-	contractorKeysCountStr, err := executeQuery(queryContractorKeys)
+	contractorKeysCount, err := n.storage().ValidContractorKeysCount("")
 	if err != nil {
-		t.Fatalf("Node %s: Error querying contractor_keys count. Query: '%s'. Error: %v. Output: %s", n.Alias, queryContractorKeys, err, contractorKeysCountStr)
-	}
-	contractorKeysCount, convErr := strconv.Atoi(contractorKeysCountStr)
-	if convErr != nil {
-		t.Fatalf("Node %s: Error converting contractor_keys count '%s' to int. Query: '%s'. Error: %v", n.Alias, contractorKeysCountStr, queryContractorKeys, convErr)
+		t.Fatalf("Node %s: Error querying contractor_keys count. Error: %v", n.Alias, err)
 	}
 	if contractorKeysCount != expectedContractorValidKeysCount {
-		t.Fatalf("Node %s: Contractor valid keys count mismatch. Expected: %d, Got: %d. Query: '%s'", n.Alias, expectedContractorValidKeysCount, contractorKeysCount, queryContractorKeys)
+		mismatches = append(mismatches, newMismatch("storage", n, nil, "contractor valid keys count", expectedContractorValidKeysCount, contractorKeysCount))
 	}
+	reportMismatches(t, fmt.Sprintf("Node %s: valid keys check failed", n.Alias), mismatches)
 }
 
-// CheckSettlementLineState queries the node's SQLite database to check the state of a trust line.
-// equivalent is typically "1" or another string.
-func (n *Node) CheckSettlementLineStateSQLite(t *testing.T, targetNode *Node, equivalent string, expectedState string) {
-	if n.ContainerID == "" {
-		t.Fatalf("Node %s: ContainerID is not set, cannot execute database checks.", n.Alias)
-	}
-
-	// First, get contractor_id (channel_id) for the target node, as this is used in the trust_lines table.
-	// This assumes that a channel must exist for a trust line to exist, which is typical.
-	// If trust lines can exist without a channel, this part needs adjustment or contractor_id must be passed directly.
+// storedSettlementLine returns the trust_lines record of the settlement line with targetNode.
+// The contractor_id of the record is the channel ID, so a channel must exist.
+func (n *Node) storedSettlementLine(t *testing.T, targetNode *Node, equivalent string) *SettlementLineRow {
 	channelInfo, err := n.GetChannelInfoByAddress(targetNode)
 	if err != nil {
 		t.Fatalf("Node %s: Failed to get channel info for target %s to find contractor_id: %v", n.Alias, targetNode.Alias, err)
 	}
-	contractorID := channelInfo.ChannelID // This is the contractor_id in the context of trust_lines table
-
-	dbPath := "/vtcp/vtcpd/io/storagedb"
-	query := fmt.Sprintf("SELECT state FROM trust_lines WHERE contractor_id = '%s' AND equivalent = %s", contractorID, equivalent)
-
-	executeQuery := func(q string) (string, error) {
-		cmdArgs := []string{"exec", n.ContainerID, "sqlite3", dbPath, q}
-		cmd := exec.Command("docker", cmdArgs...)
-		output, errCmd := cmd.CombinedOutput()
-		trimmedOutput := strings.TrimSpace(string(output))
-		if errCmd != nil {
-			return trimmedOutput, fmt.Errorf("docker exec command failed for query ['%s'] on node %s: %v. Output: %s", q, n.Alias, errCmd, trimmedOutput)
-		}
-		return trimmedOutput, nil
-	}
 
-	actualState, err := executeQuery(query)
+	settlementLine, err := n.storage().SettlementLine(channelInfo.ChannelID, equivalent, "id", "contractor_id", "state")
 	if err != nil {
-		t.Fatalf("Node %s: Error querying trust_line state. Query: '%s'. Error: %v", n.Alias, query, err)
+		t.Fatalf("Node %s: Error querying trust_line. Error: %v", n.Alias, err)
 	}
-	if actualState == "" {
-		t.Fatalf("Node %s: No trust_line state returned for query: '%s'. The trust line might not exist for contractor_id '%s' and equivalent '%s'.", n.Alias, query, contractorID, equivalent)
+	if settlementLine == nil {
+		t.Fatalf("Node %s: No trust_line found. The trust line might not exist for contractor_id '%s' and equivalent '%s'.", n.Alias, channelInfo.ChannelID, equivalent)
 	}
+	return settlementLine
+}
 
-	if actualState != expectedState {
-		t.Fatalf("Node %s: TrustLine state mismatch for contractor %s (ID: %s), equivalent %s. Expected: %s, Got: %s. Query: '%s'",
-			n.Alias, targetNode.Alias, contractorID, equivalent, expectedState, actualState, query)
+// CheckSettlementLineState queries the node's database to check the state of a trust line.
+// equivalent is typically "1" or another string.
+func (n *Node) CheckSettlementLineState(t *testing.T, targetNode *Node, equivalent string, expectedState string) {
+	settlementLine := n.storedSettlementLine(t, targetNode, equivalent)
+	if settlementLine.State != expectedState {
+		reportMismatches(t, fmt.Sprintf("Node %s: TrustLine state check failed for contractor %s (ID: %s), equivalent %s",
+			n.Alias, targetNode.Alias, settlementLine.ContractorID, equivalent), []AssertionMismatch{
+			newMismatch("storage", n, targetNode, "trust line state", expectedState, settlementLine.State),
+		})
 	}
 }
 
-// CheckPaymentRecordWithCommandUUID queries the node's SQLite database
+// CheckPaymentRecordWithCommandUUID queries the node's database
 // to check for the presence of a payment record with a specific command_uuid.
-func (n *Node) CheckPaymentRecordWithCommandUUIDSQLite(t *testing.T, commandUUID string, shouldBePresent bool) {
-	if n.ContainerID == "" {
-		t.Fatalf("Node %s: ContainerID is not set, cannot execute database checks.", n.Alias)
-	}
-
-	dbPath := "/vtcp/vtcpd/io/storagedb"
-	// The command_uuid in the database is stored as a blob without dashes.
-	formattedCommandUUID := strings.ReplaceAll(commandUUID, "-", "")
-	query := fmt.Sprintf("SELECT COUNT(*) FROM history WHERE command_uuid = x'%s'", formattedCommandUUID)
-
-	executeQuery := func(q string) (string, error) {
-		cmdArgs := []string{"exec", n.ContainerID, "sqlite3", dbPath, q}
-		cmd := exec.Command("docker", cmdArgs...)
-		output, errCmd := cmd.CombinedOutput()
-		trimmedOutput := strings.TrimSpace(string(output))
-		if errCmd != nil {
-			return trimmedOutput, fmt.Errorf("docker exec command failed for query ['%s'] on node %s: %v. Output: %s", q, n.Alias, errCmd, trimmedOutput)
-		}
-		return trimmedOutput, nil
-	}
-
-	countStr, err := executeQuery(query)
+func (n *Node) CheckPaymentRecordWithCommandUUID(t *testing.T, commandUUID string, shouldBePresent bool) {
+	recordsCount, err := n.storage().HistoryRecordsCountByCommandUUID(commandUUID)
 	if err != nil {
-		t.Fatalf("Node %s: Error querying history for command_uuid '%s'. Query: '%s'. Error: %v", n.Alias, commandUUID, query, err)
-	}
-
-	actualCount, convErr := strconv.Atoi(countStr)
-	if convErr != nil {
-		t.Fatalf("Node %s: Error converting count '%s' to int for history query. Command UUID: '%s'. Query: '%s'. Error: %v", n.Alias, countStr, commandUUID, query, convErr)
+		t.Fatalf("Node %s: Error querying history for command_uuid '%s'. Error: %v", n.Alias, commandUUID, err)
 	}
 
+	field := fmt.Sprintf("payment records with command_uuid %s", commandUUID)
 	if shouldBePresent {
-		if actualCount == 0 {
-			t.Fatalf("Node %s: Expected payment record with command_uuid '%s' to be present, but found 0. Query: '%s'", n.Alias, commandUUID, query)
-		}
-		if actualCount > 1 {
-			// This might be a valid scenario depending on the system, but the Python test implies checking for existence (not necessarily count=1)
-			// For now, just log or consider if this should be a t.Fatalf
-			t.Logf("Node %s: Warning - found %d records for command_uuid '%s'. Expected at least 1. Query: '%s'", n.Alias, actualCount, commandUUID, query)
+		if recordsCount == 0 {
+			reportMismatches(t, fmt.Sprintf("Node %s: payment record check failed", n.Alias), []AssertionMismatch{
+				newMismatch("storage", n, nil, field, "at least 1", 0),
+			})
 		}
-	} else {
-		if actualCount > 0 {
-			t.Fatalf("Node %s: Expected no payment record with command_uuid '%s', but found %d. Query: '%s'", n.Alias, commandUUID, actualCount, query)
+		if recordsCount > 1 {
+			t.Logf("Node %s: Warning - found %d records for command_uuid '%s'. Expected at least 1", n.Alias, recordsCount, commandUUID)
 		}
+	} else if recordsCount > 0 {
+		reportMismatches(t, fmt.Sprintf("Node %s: payment record check failed", n.Alias), []AssertionMismatch{
+			newMismatch("storage", n, nil, field, 0, recordsCount),
+		})
 	}
 }
 
-// CheckCurrentAudit queries the node's SQLite database to check the current audit number for a trust line.
+// CheckCurrentAudit queries the node's database to check the current audit number for a trust line.
 // equivalent is typically "1" or another integer string.
-func (n *Node) CheckCurrentAuditSQLite(t *testing.T, targetNode *Node, equivalent string, expectedAuditNumber int) {
-	if n.ContainerID == "" {
-		t.Fatalf("Node %s: ContainerID is not set, cannot execute database checks.", n.Alias)
-	}
+func (n *Node) CheckCurrentAudit(t *testing.T, targetNode *Node, equivalent string, expectedAuditNumber int) {
+	settlementLine := n.storedSettlementLine(t, targetNode, equivalent)
 
-	channelInfo, err := n.GetChannelInfoByAddress(targetNode)
+	audit, err := n.storage().LatestAudit(settlementLine.ID, "number")
 	if err != nil {
-		t.Fatalf("Node %s: Failed to get channel info for target %s to find contractor_id for audit check: %v", n.Alias, targetNode.Alias, err)
+		t.Fatalf("Node %s: Error querying audit number. Error: %v", n.Alias, err)
 	}
-	contractorID := channelInfo.ChannelID
-
-	dbPath := "/vtcp/vtcpd/io/storagedb"
-	// First, get the trust_line_id
-	queryTrustLineID := fmt.Sprintf("SELECT id FROM trust_lines WHERE contractor_id = '%s' AND equivalent = %s", contractorID, equivalent)
-
-	executeQuery := func(q string) (string, error) {
-		cmdArgs := []string{"exec", n.ContainerID, "sqlite3", dbPath, q}
-		cmd := exec.Command("docker", cmdArgs...)
-		output, errCmd := cmd.CombinedOutput()
-		trimmedOutput := strings.TrimSpace(string(output))
-		if errCmd != nil {
-			return trimmedOutput, fmt.Errorf("docker exec command failed for query ['%s'] on node %s: %v. Output: %s", q, n.Alias, errCmd, trimmedOutput)
+	actual := "none"
+	if audit != nil {
+		if audit.Number == int64(expectedAuditNumber) {
+			return
 		}
-		return trimmedOutput, nil
-	}
-
-	trustLineIDStr, err := executeQuery(queryTrustLineID)
-	if err != nil {
-		t.Fatalf("Node %s: Error querying trust_line id. Query: '%s'. Error: %v", n.Alias, queryTrustLineID, err)
-	}
-	if trustLineIDStr == "" {
-		t.Fatalf("Node %s: No trust_line id returned for query: '%s'. The trust line might not exist for contractor_id '%s' and equivalent '%s'.", n.Alias, queryTrustLineID, contractorID, equivalent)
-	}
-	// trust_line_id is an integer, but we use it in the next query as a string.
-	// No conversion to int is strictly needed for the SQL query itself.
-
-	queryAudit := fmt.Sprintf("SELECT number FROM audit WHERE trust_line_id = %s ORDER BY number DESC LIMIT 1", trustLineIDStr)
-	actualAuditStr, err := executeQuery(queryAudit)
-	if err != nil {
-		t.Fatalf("Node %s: Error querying audit number. Query: '%s'. Error: %v", n.Alias, queryAudit, err)
-	}
-	if actualAuditStr == "" {
-		// This could mean no audit entries exist yet for this trust line.
-		// Depending on expectations, this might be a valid state (e.g., for a new trust line).
-		// The Python code assumes an audit entry will be found.
-		// If expectedAuditNumber is 0 and actualAuditStr is "", this might be a pass condition.
-		// For now, strict check:
-		t.Fatalf("Node %s: No audit number returned for trust_line_id '%s'. Query: '%s'", n.Alias, trustLineIDStr, queryAudit)
-	}
-
-	actualAuditNumber, convErr := strconv.Atoi(actualAuditStr)
-	if convErr != nil {
-		t.Fatalf("Node %s: Error converting audit number '%s' to int. Query: '%s'. Error: %v", n.Alias, actualAuditStr, queryAudit, convErr)
-	}
-
-	if actualAuditNumber != expectedAuditNumber {
-		t.Fatalf("Node %s: Current audit number mismatch for contractor %s (TrustLineID: %s), equivalent %s. Expected: %d, Got: %d. Query: '%s'", n.Alias, targetNode.Alias, trustLineIDStr, equivalent, expectedAuditNumber, actualAuditNumber, queryAudit)
+		actual = strconv.FormatInt(audit.Number, 10)
 	}
+	reportMismatches(t, fmt.Sprintf("Node %s: Current audit check failed for contractor %s (TrustLineID: %s), equivalent %s",
+		n.Alias, targetNode.Alias, settlementLine.ID, equivalent), []AssertionMismatch{
+		newMismatch("storage", n, targetNode, "current audit number", expectedAuditNumber, actual),
+	})
 }
 
 // CheckNodeForLogMessage checks if a specific message (optionally for a specific transaction UUID) exists in the node's operations.log.
 // - transactionUUID: Optional. If provided, the log line must contain this UUID.
 // - message: The specific string to search for in the log line.
 // - expectedToFind: Boolean indicating whether the message is expected to be found.
+// The log is read once; use LogWatcher to wait for a message to show up.
 func (n *Node) CheckNodeForLogMessage(t *testing.T, transactionUUID string, message string, expectedToFind bool) {
-	if n.ContainerID == "" {
-		t.Fatalf("Node %s: ContainerID is not set, cannot execute log checks.", n.Alias)
+	entries, err := n.ReadLogs()
+	if err != nil {
+		t.Fatalf("%v", err)
 	}
 
-	// Construct the grep command. -q for quiet (no output), exit status indicates match.
-	// We will use a more verbose grep and check the output directly to handle cases where the log might be empty
-	// or the file doesn't exist, which `grep -q` might not distinguish well for our needs.
-	var grepCmd string
+	matcher := LogContains(message)
 	if transactionUUID != "" {
-		// Search for lines containing both the transactionUUID and the message.
-		// Using .* to allow any characters between UUID and message in any order on the line.
-		// This is a simplified approach. For more robust matching, more complex awk/grep could be used.
-		// Escaping for grep can be tricky; assuming simple strings for now.
-		grepCmd = fmt.Sprintf("grep '%s' %s | grep '%s'", transactionUUID, DefaultOperationsLogPath, message)
-	} else {
-		grepCmd = fmt.Sprintf("grep '%s' %s", message, DefaultOperationsLogPath)
-	}
-
-	fullCmd := []string{"exec", n.ContainerID, "sh", "-c", grepCmd}
-	cmd := exec.Command("docker", fullCmd...)
-
-	output, err := cmd.CombinedOutput()
-	trimmedOutput := strings.TrimSpace(string(output))
-
-	found := false
-	if err == nil && trimmedOutput != "" {
-		// `grep` found matches and output them.
-		found = true
-	} else if exitError, ok := err.(*exec.ExitError); ok {
-		// `grep` exited with non-zero status.
-		// Exit status 1 means no lines were selected.
-		// Other exit statuses (e.g., 2) indicate an error with grep itself (e.g., file not found).
-		if exitError.ExitCode() == 1 {
-			found = false // No match
-		} else {
-			// Grep error (e.g. file not found by grep within container)
-			t.Fatalf("Node %s: Error executing grep command for log check. Command: '%s'. Error: %v. Output: %s", n.Alias, strings.Join(fullCmd, " "), err, trimmedOutput)
-			return
+		matcher = LogAll(LogTransaction(transactionUUID), matcher)
+	}
+
+	var found *LogEntry
+	for i := range entries {
+		if matcher(entries[i]) {
+			found = &entries[i]
+			break
 		}
-	} else if err != nil {
-		// Other execution error (e.g., docker command itself failed to start)
-		t.Fatalf("Node %s: Failed to execute docker command for log check. Command: '%s'. Error: %v. Output: %s", n.Alias, strings.Join(fullCmd, " "), err, trimmedOutput)
-		return
 	}
 
-	if expectedToFind && !found {
-		t.Fatalf("Node %s: Expected to find log message '%s' (UUID: '%s'), but did not. Grep output: %s", n.Alias, message, transactionUUID, trimmedOutput)
-	} else if !expectedToFind && found {
-		t.Fatalf("Node %s: Expected NOT to find log message '%s' (UUID: '%s'), but did. Grep output: %s", n.Alias, message, transactionUUID, trimmedOutput)
+	if expectedToFind && found == nil {
+		t.Fatalf("Node %s: Expected to find log message '%s' (UUID: '%s'), but did not.", n.Alias, message, transactionUUID)
+	} else if !expectedToFind && found != nil {
+		t.Fatalf("Node %s: Expected NOT to find log message '%s' (UUID: '%s'), but did. Log line: %s", n.Alias, message, transactionUUID, found.Raw)
 	}
 }
 
@@ -1550,902 +1559,109 @@ func (n *Node) CheckSettlementLineKeysPresence(t *testing.T, targetNode *Node, i
 	}
 }
 
+// MakeHub makes the node a hub (gateway) in the equivalent and restarts it
 func (n *Node) MakeHub(equivalent string) error {
-	if n.ContainerID == "" {
-		return fmt.Errorf("Node %s: ContainerID is not set, cannot execute commands", n.Alias)
-	}
-
-	configFilePath := "/vtcp/vtcpd/conf.json"
-
-	// First, check if the config file exists and create it if it doesn't
-	checkCmd := []string{"exec", n.ContainerID, "sh", "-c",
-		fmt.Sprintf("[ -f %s ] && echo 'exists' || echo 'not_exists'", configFilePath)}
-	checkCmdExec := exec.Command("docker", checkCmd...)
-	checkOutput, err := checkCmdExec.CombinedOutput()
+	equivalentNum, err := strconv.Atoi(equivalent)
 	if err != nil {
-		return fmt.Errorf("Node %s: failed to check config file existence: %v. Output: %s", n.Alias, err, string(checkOutput))
+		return fmt.Errorf("Node %s: failed to convert equivalent to integer for gateway config: %v", n.Alias, err)
 	}
+	return n.UpdateConfig(func(config *NodeConfig) {
+		config.Gateway = []int{equivalentNum}
+	})
+}
 
-	var config map[string]interface{}
+// SetHopsCount sets or updates the max_hops_count field in the node's configuration file
+func (n *Node) SetHopsCount(hopsCount int) error {
+	return n.UpdateConfig(func(config *NodeConfig) {
+		config.SetMaxHopsCount(hopsCount)
+	})
+}
 
-	if strings.TrimSpace(string(checkOutput)) == "exists" {
-		// Read the existing conf.json file from the container
-		readCmd := []string{"exec", n.ContainerID, "cat", configFilePath}
-		readCmdExec := exec.Command("docker", readCmd...)
-		output, err := readCmdExec.CombinedOutput()
-		if err != nil {
-			return fmt.Errorf("Node %s: failed to read config file: %v. Output: %s", n.Alias, err, string(output))
+// SetCommissions updates commissions configuration in /vtcp/vtcpd/conf.json inside the node's container
+// Accepts an array of pairs (equivalent, amount) and writes them in the following format:
+//
+//	"commissions": {
+//	    "byEquivalent": {
+//	        "1": {"amount": 100},
+//	        "2": {"amount": 250}
+//	    }
+//	}
+//
+// After updating the configuration, the node is restarted (similar to MakeHub and SetHopsCount).
+func (n *Node) SetCommissions(pairs []CommissionPair) error {
+	return n.UpdateConfig(func(config *NodeConfig) {
+		for _, pair := range pairs {
+			config.SetCommission(pair)
 		}
+	})
+}
 
-		// Parse the existing JSON
-		if err := json.Unmarshal(output, &config); err != nil {
-			return fmt.Errorf("Node %s: failed to parse existing config JSON: %v", n.Alias, err)
-		}
-	} else {
-		// Create a minimal config structure
-		config = make(map[string]interface{})
-	}
+// Exchange rates methods
 
-	// Add the gateway configuration
-	equivalentNum, err := strconv.Atoi(equivalent)
-	if err != nil {
-		return fmt.Errorf("Node %s: failed to convert equivalent to integer for gateway config: %v", n.Alias, err)
-	}
-	config["gateway"] = []int{equivalentNum}
+// SetExchangeRate sets an exchange rate using real decimal format
+func (n *Node) SetExchangeRate(t *testing.T, equivalentFrom, equivalentTo, realRate string, minAmount, maxAmount *string, expectedStatusCode int) {
+	url := fmt.Sprintf("http://%s:%d/api/v1/node/rates/%s/%s/?real_rate=%s",
+		n.IPAddress, n.CLIPort, equivalentFrom, equivalentTo, realRate)
 
-	// Convert back to JSON with proper formatting
-	updatedJSON, err := json.MarshalIndent(config, "", "  ")
-	if err != nil {
-		return fmt.Errorf("Node %s: failed to marshal updated config: %v", n.Alias, err)
+	// Add optional parameters
+	if minAmount != nil {
+		url += "&min_exchange_amount=" + *minAmount
 	}
-
-	// Create directory if needed and write the updated JSON using a safer method
-	createDirCmd := []string{"exec", n.ContainerID, "mkdir", "-p", "/vtcp/vtcpd"}
-	createDirExec := exec.Command("docker", createDirCmd...)
-	createDirExec.CombinedOutput() // Ignore errors - directory might already exist
-
-	// Use printf instead of echo to handle special characters better
-	// Escape the JSON content to avoid shell interpretation issues
-	escapedJSON := strings.ReplaceAll(string(updatedJSON), "'", "'\"'\"'")
-	shellCommand := fmt.Sprintf("printf '%%s' '%s' > %s", escapedJSON, configFilePath)
-	writeCmd := []string{"exec", n.ContainerID, "sh", "-c", shellCommand}
-	writeCmdExec := exec.Command("docker", writeCmd...)
-	writeOutput, err := writeCmdExec.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("Node %s: failed to write updated config file: %v. Output: %s", n.Alias, err, string(writeOutput))
+	if maxAmount != nil {
+		url += "&max_exchange_amount=" + *maxAmount
 	}
 
-	// Verify the file was written correctly
-	verifyCmd := []string{"exec", n.ContainerID, "cat", configFilePath}
-	verifyCmdExec := exec.Command("docker", verifyCmd...)
-	verifyOutput, err := verifyCmdExec.CombinedOutput()
+	resp, err := n.doAPIRequest(n.runContext(), http.MethodPost, url)
 	if err != nil {
-		return fmt.Errorf("Node %s: failed to verify config file: %v. Output: %s", n.Alias, err, string(verifyOutput))
+		t.Fatalf("failed to send set-exchange-rate request: %v", err)
 	}
+	defer resp.Body.Close()
 
-	err = n.RestartNode()
-	if err != nil {
-		return fmt.Errorf("Node %s: failed to restart node: %v", n.Alias, err)
+	if resp.StatusCode != expectedStatusCode {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		t.Fatalf("set-exchange-rate request returned unexpected status: expected %d, got %d, body: %s", expectedStatusCode, resp.StatusCode, string(bodyBytes))
 	}
-
-	return nil
 }
 
-// RestartNode stops the vtcpd process, allowing CLI to restart it with new configuration
-func (n *Node) RestartNode() error {
-	if n.ContainerID == "" {
-		return fmt.Errorf("Node %s: ContainerID is not set, cannot execute commands", n.Alias)
-	}
-
-	println("=== RestartNode: Starting vtcpd restart process ===")
-
-	// Step 1: Get full process list to understand what's running
-	allProcessesCmd := []string{"exec", n.ContainerID, "ps", "aux"}
-	allProcessesExec := exec.Command("docker", allProcessesCmd...)
-	_, _ = allProcessesExec.CombinedOutput()
+// SetExchangeRateNative sets an exchange rate using native value+shift format
+func (n *Node) SetExchangeRateNative(t *testing.T, equivalentFrom, equivalentTo, value string, shift int16, minAmount, maxAmount *string, expectedStatusCode int) {
+	url := fmt.Sprintf("http://%s:%d/api/v1/node/rates/%s/%s/?value=%s&shift=%d",
+		n.IPAddress, n.CLIPort, equivalentFrom, equivalentTo, value, shift)
 
-	// Step 2: Find vtcpd processes using pgrep
-	findCmd := []string{"exec", n.ContainerID, "pgrep", "vtcpd"}
-	findExec := exec.Command("docker", findCmd...)
-	findOutput, err := findExec.CombinedOutput()
+	// Add optional parameters
+	if minAmount != nil {
+		url += "&min_exchange_amount=" + *minAmount
+	}
+	if maxAmount != nil {
+		url += "&max_exchange_amount=" + *maxAmount
+	}
 
+	resp, err := n.doAPIRequest(n.runContext(), http.MethodPost, url)
 	if err != nil {
-		println("No vtcpd processes found to stop")
-		return fmt.Errorf("Node %s: No vtcpd processes found", n.Alias)
+		t.Fatalf("failed to send set-exchange-rate-native request: %v", err)
 	}
+	defer resp.Body.Close()
 
-	vtcpdPids := strings.Fields(strings.TrimSpace(string(findOutput)))
-	if len(vtcpdPids) == 0 {
-		println("No vtcpd processes found to stop")
-		return fmt.Errorf("Node %s: No vtcpd processes found", n.Alias)
+	if resp.StatusCode != expectedStatusCode {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		t.Fatalf("set-exchange-rate-native request returned unexpected status: expected %d, got %d, body: %s", expectedStatusCode, resp.StatusCode, string(bodyBytes))
 	}
+}
 
-	// Step 3: Stop vtcpd processes gracefully
-	for _, pid := range vtcpdPids {
-		killCmd := []string{"exec", n.ContainerID, "kill", "-TERM", pid}
-		killExec := exec.Command("docker", killCmd...)
-		_, _ = killExec.CombinedOutput()
+// SetExchangeRateFromModel sets the rate of the exchange rate model in native format, with its limits.
+// The expiry of the rate is not set.
+func (n *Node) SetExchangeRateFromModel(t *testing.T, rate ExchangeRate, expectedStatusCode int) {
+	var minAmount, maxAmount *string
+	if rate.Min != nil {
+		min := rate.Min.String()
+		minAmount = &min
 	}
-
-	// Wait for graceful shutdown
-	time.Sleep(3 * time.Second)
-
-	// Check if processes are still running
-	checkCmd := []string{"exec", n.ContainerID, "pgrep", "vtcpd"}
-	checkExec := exec.Command("docker", checkCmd...)
-	checkOutput, checkErr := checkExec.CombinedOutput()
-
-	if checkErr == nil && strings.TrimSpace(string(checkOutput)) != "" {
-		println("Some vtcpd processes still running, using SIGKILL...")
-
-		// Force kill remaining processes
-		for _, pid := range vtcpdPids {
-			forceKillCmd := []string{"exec", n.ContainerID, "kill", "-KILL", pid}
-			forceKillExec := exec.Command("docker", forceKillCmd...)
-			_, _ = forceKillExec.CombinedOutput()
-		}
-
-		time.Sleep(1 * time.Second)
-	}
-
-	// Verify all vtcpd processes are stopped
-	verifyStoppedCmd := []string{"exec", n.ContainerID, "pgrep", "vtcpd"}
-	verifyStoppedExec := exec.Command("docker", verifyStoppedCmd...)
-	_, verifyErr := verifyStoppedExec.CombinedOutput()
-
-	if verifyErr == nil {
-		println("Warning: Some vtcpd processes may still be running")
-	} else {
-		println("All vtcpd processes stopped successfully")
-	}
-
-	// Wait for CLI to restart vtcpd
-	println("Waiting for CLI to restart vtcpd with new configuration...")
-	for i := 0; i < 15; i++ {
-		time.Sleep(1 * time.Second)
-
-		checkRestartCmd := []string{"exec", n.ContainerID, "pgrep", "vtcpd"}
-		checkRestartExec := exec.Command("docker", checkRestartCmd...)
-		_, restartErr := checkRestartExec.CombinedOutput()
-
-		if restartErr == nil {
-			println(fmt.Sprintf("vtcpd restarted successfully after %d seconds", i+1))
-			println("=== RestartNode: vtcpd restart completed successfully ===")
-			return nil
-		}
-
-		println(fmt.Sprintf("Waiting for vtcpd restart... (%d/15)", i+1))
-	}
-
-	return fmt.Errorf("Node %s: vtcpd did not restart automatically within 15 seconds", n.Alias)
-}
-
-// SetHopsCount sets or updates the max_hops_count field in the node's configuration file
-func (n *Node) SetHopsCount(hopsCount int) error {
-	if n.ContainerID == "" {
-		return fmt.Errorf("Node %s: ContainerID is not set, cannot execute commands", n.Alias)
-	}
-
-	configFilePath := "/vtcp/vtcpd/conf.json"
-
-	// First, check if the config file exists and create it if it doesn't
-	checkCmd := []string{"exec", n.ContainerID, "sh", "-c",
-		fmt.Sprintf("[ -f %s ] && echo 'exists' || echo 'not_exists'", configFilePath)}
-	checkCmdExec := exec.Command("docker", checkCmd...)
-	checkOutput, err := checkCmdExec.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("Node %s: failed to check config file existence: %v. Output: %s", n.Alias, err, string(checkOutput))
-	}
-
-	var config map[string]interface{}
-
-	if strings.TrimSpace(string(checkOutput)) == "exists" {
-		// Read the existing conf.json file from the container
-		readCmd := []string{"exec", n.ContainerID, "cat", configFilePath}
-		readCmdExec := exec.Command("docker", readCmd...)
-		output, err := readCmdExec.CombinedOutput()
-		if err != nil {
-			return fmt.Errorf("Node %s: failed to read config file: %v. Output: %s", n.Alias, err, string(output))
-		}
-
-		// Parse the existing JSON
-		if err := json.Unmarshal(output, &config); err != nil {
-			return fmt.Errorf("Node %s: failed to parse existing config JSON: %v", n.Alias, err)
-		}
-	} else {
-		// Create a minimal config structure
-		config = make(map[string]interface{})
-	}
-
-	// Set or update the max_hops_count configuration
-	config["max_hops_count"] = hopsCount
-
-	// Convert back to JSON with proper formatting
-	updatedJSON, err := json.MarshalIndent(config, "", "  ")
-	if err != nil {
-		return fmt.Errorf("Node %s: failed to marshal updated config: %v", n.Alias, err)
-	}
-
-	// Create directory if needed and write the updated JSON using a safer method
-	createDirCmd := []string{"exec", n.ContainerID, "mkdir", "-p", "/vtcp/vtcpd"}
-	createDirExec := exec.Command("docker", createDirCmd...)
-	createDirExec.CombinedOutput() // Ignore errors - directory might already exist
-
-	// Use printf instead of echo to handle special characters better
-	// Escape the JSON content to avoid shell interpretation issues
-	escapedJSON := strings.ReplaceAll(string(updatedJSON), "'", "'\"'\"'")
-	shellCommand := fmt.Sprintf("printf '%%s' '%s' > %s", escapedJSON, configFilePath)
-	writeCmd := []string{"exec", n.ContainerID, "sh", "-c", shellCommand}
-	writeCmdExec := exec.Command("docker", writeCmd...)
-	writeOutput, err := writeCmdExec.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("Node %s: failed to write updated config file: %v. Output: %s", n.Alias, err, string(writeOutput))
-	}
-
-	// Verify the file was written correctly
-	verifyCmd := []string{"exec", n.ContainerID, "cat", configFilePath}
-	verifyCmdExec := exec.Command("docker", verifyCmd...)
-	verifyOutput, err := verifyCmdExec.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("Node %s: failed to verify config file: %v. Output: %s", n.Alias, err, string(verifyOutput))
-	}
-
-	err = n.RestartNode()
-	if err != nil {
-		return fmt.Errorf("Node %s: failed to restart node: %v", n.Alias, err)
-	}
-
-	return nil
-}
-
-// CommissionPair represents a pair of equivalent and commission amount to be set in config
-type CommissionPair struct {
-	Equivalent string
-	Amount     int
-}
-
-// SetCommissions updates commissions configuration in /vtcp/vtcpd/conf.json inside the node's container
-// Accepts an array of pairs (equivalent, amount) and writes them in the following format:
-//
-//	"commissions": {
-//	    "byEquivalent": {
-//	        "1": {"amount": 100},
-//	        "2": {"amount": 250}
-//	    }
-//	}
-//
-// After updating the configuration, the node is restarted (similar to MakeHub and SetHopsCount).
-func (n *Node) SetCommissions(pairs []CommissionPair) error {
-	if n.ContainerID == "" {
-		return fmt.Errorf("Node %s: ContainerID is not set, cannot execute commands", n.Alias)
-	}
-
-	configFilePath := "/vtcp/vtcpd/conf.json"
-
-	// Check if the config file exists
-	checkCmd := []string{"exec", n.ContainerID, "sh", "-c",
-		fmt.Sprintf("[ -f %s ] && echo 'exists' || echo 'not_exists'", configFilePath)}
-	checkCmdExec := exec.Command("docker", checkCmd...)
-	checkOutput, err := checkCmdExec.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("Node %s: failed to check config file existence: %v. Output: %s", n.Alias, err, string(checkOutput))
-	}
-
-	var config map[string]interface{}
-
-	if strings.TrimSpace(string(checkOutput)) == "exists" {
-		// Read the existing conf.json file from the container
-		readCmd := []string{"exec", n.ContainerID, "cat", configFilePath}
-		readCmdExec := exec.Command("docker", readCmd...)
-		output, err := readCmdExec.CombinedOutput()
-		if err != nil {
-			return fmt.Errorf("Node %s: failed to read config file: %v. Output: %s", n.Alias, err, string(output))
-		}
-
-		// Parse the existing JSON
-		if err := json.Unmarshal(output, &config); err != nil {
-			return fmt.Errorf("Node %s: failed to parse existing config JSON: %v", n.Alias, err)
-		}
-	} else {
-		// Create a minimal config structure
-		config = make(map[string]interface{})
-	}
-
-	// Prepare or merge commissions.byEquivalent
-	var commissionsObj map[string]interface{}
-	if v, ok := config["commissions"].(map[string]interface{}); ok {
-		commissionsObj = v
-	} else {
-		commissionsObj = make(map[string]interface{})
-	}
-
-	var byEq map[string]interface{}
-	if v, ok := commissionsObj["byEquivalent"].(map[string]interface{}); ok {
-		byEq = v
-	} else {
-		byEq = make(map[string]interface{})
-	}
-
-	for _, p := range pairs {
-		byEq[p.Equivalent] = map[string]interface{}{
-			"amount": p.Amount,
-		}
-	}
-
-	commissionsObj["byEquivalent"] = byEq
-	config["commissions"] = commissionsObj
-
-	// Convert back to JSON with proper formatting
-	updatedJSON, err := json.MarshalIndent(config, "", "  ")
-	if err != nil {
-		return fmt.Errorf("Node %s: failed to marshal updated config: %v", n.Alias, err)
-	}
-
-	// Ensure directory exists and write the file safely
-	createDirCmd := []string{"exec", n.ContainerID, "mkdir", "-p", "/vtcp/vtcpd"}
-	createDirExec := exec.Command("docker", createDirCmd...)
-	createDirExec.CombinedOutput()
-
-	escapedJSON := strings.ReplaceAll(string(updatedJSON), "'", "'\"'\"'")
-	shellCommand := fmt.Sprintf("printf '%%s' '%s' > %s", escapedJSON, configFilePath)
-	writeCmd := []string{"exec", n.ContainerID, "sh", "-c", shellCommand}
-	writeCmdExec := exec.Command("docker", writeCmd...)
-	writeOutput, err := writeCmdExec.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("Node %s: failed to write updated config file: %v. Output: %s", n.Alias, err, string(writeOutput))
-	}
-
-	// Verify the file was written (best-effort)
-	verifyCmd := []string{"exec", n.ContainerID, "cat", configFilePath}
-	verifyCmdExec := exec.Command("docker", verifyCmd...)
-	verifyOutput, err := verifyCmdExec.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("Node %s: failed to verify config file: %v. Output: %s", n.Alias, err, string(verifyOutput))
-	}
-
-	// Restart node to apply configuration
-	err = n.RestartNode()
-	if err != nil {
-		return fmt.Errorf("Node %s: failed to restart node: %v", n.Alias, err)
-	}
-
-	return nil
-}
-
-// HistoryAdditionalPayments retrieves additional payment history for the node
-func (n *Node) HistoryAdditionalPayments(t *testing.T) map[string]interface{} {
-	url := fmt.Sprintf("http://%s:%d/api/v1/node/history/transactions/payments/additional/0/10/1/",
-		n.IPAddress, n.CLIPort)
-
-	resp, err := http.Get(url)
-	if err != nil {
-		t.Fatalf("failed to send history additional payments request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		t.Fatalf("history additional payments request failed with status: %d, body: %s", resp.StatusCode, string(bodyBytes))
-	}
-
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		t.Fatalf("failed to decode history additional payments response: %v", err)
-	}
-
-	return result
-}
-
-// HistoryPayments retrieves payment history for the node
-func (n *Node) HistoryPayments(t *testing.T) map[string]interface{} {
-	url := fmt.Sprintf("http://%s:%d/api/v1/node/history/transactions/payments/0/10/1/",
-		n.IPAddress, n.CLIPort)
-
-	resp, err := http.Get(url)
-	if err != nil {
-		t.Fatalf("failed to send history payments request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		t.Fatalf("history payments request failed with status: %d, body: %s", resp.StatusCode, string(bodyBytes))
-	}
-
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		t.Fatalf("failed to decode history payments response: %v", err)
-	}
-
-	return result
-}
-
-// HistoryPaymentsAllEquivalents retrieves payment history for all equivalents
-func (n *Node) HistoryPaymentsAllEquivalents(t *testing.T) map[string]interface{} {
-	url := fmt.Sprintf("http://%s:%d/api/v1/node/history/transactions/payments-all/0/10/",
-		n.IPAddress, n.CLIPort)
-
-	resp, err := http.Get(url)
-	if err != nil {
-		t.Fatalf("failed to send history payments all equivalents request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		t.Fatalf("history payments all equivalents request failed with status: %d, body: %s", resp.StatusCode, string(bodyBytes))
-	}
-
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		t.Fatalf("failed to decode history payments all equivalents response: %v", err)
-	}
-
-	return result
-}
-
-// CheckPaymentTransactionPostgreSQL queries the node's PostgreSQL database within its Docker container
-// to verify various aspects of payment transactions.
-func (n *Node) CheckPaymentTransactionPostgreSQL(
-	t *testing.T,
-	transactionState string,
-	paymentTransactionsCount int,
-	participantsVotesCount int,
-	incomingReceiptsCount int,
-	outgoingReceiptsCount int,
-) {
-	if n.ContainerID == "" {
-		t.Fatalf("Node %s: ContainerID is not set, cannot execute database checks.", n.Alias)
-	}
-
-	executeQuery := func(query string) (string, error) {
-		psqlCmd := fmt.Sprintf("PGPASSWORD=vtcpd_pass psql -h 127.0.0.1 -U vtcpd_user -d storagedb -t -A -c \"%s\"", query)
-		cmdArgs := []string{"exec", n.ContainerID, "sh", "-c", psqlCmd}
-		cmd := exec.Command("docker", cmdArgs...)
-
-		output, err := cmd.CombinedOutput()
-		trimmedOutput := strings.TrimSpace(string(output))
-
-		if err != nil {
-			return trimmedOutput, fmt.Errorf("docker exec psql command failed for query ['%s'] on node %s (container: %s): %v. Output: %s", query, n.Alias, n.ContainerID, err, trimmedOutput)
-		}
-		return trimmedOutput, nil
-	}
-
-	// 1. Check transaction_state (if provided)
-	if transactionState != "" {
-		query := "SELECT observing_state FROM payment_transactions ORDER BY recording_time DESC LIMIT 1"
-		actualState, err := executeQuery(query)
-		if err != nil {
-			t.Fatalf("Node %s: Error querying transaction state. Query: '%s'. Error: %v", n.Alias, query, err)
-		}
-		if actualState != transactionState {
-			t.Fatalf("Node %s: Transaction state mismatch. Expected: '%s', Got: '%s'. Query: '%s'", n.Alias, transactionState, actualState, query)
-		}
-	}
-
-	// Helper for checking counts
-	checkCount := func(tableName string, expectedCount int) {
-		query := fmt.Sprintf("SELECT count(*) FROM %s", tableName)
-		countStr, err := executeQuery(query)
-		if err != nil {
-			t.Fatalf("Node %s: Error querying count for table '%s'. Query: '%s'. Error: %v. Output: %s", n.Alias, tableName, query, err, countStr)
-		}
-		actualCount, convErr := strconv.Atoi(countStr)
-		if convErr != nil {
-			t.Fatalf("Node %s: Error converting count '%s' to int for table '%s'. Query: '%s'. Error: %v", n.Alias, countStr, tableName, query, convErr)
-		}
-		if actualCount != expectedCount {
-			t.Fatalf("Node %s: Count mismatch for table '%s'. Expected: %d, Got: %d. Query: '%s'", n.Alias, tableName, expectedCount, actualCount, query)
-		}
-	}
-
-	// 2. Check payment_transactions count
-	checkCount("payment_transactions", paymentTransactionsCount)
-
-	// 3. Check payment_participants_votes count
-	checkCount("payment_participants_votes", participantsVotesCount)
-
-	// 4. Check incoming_receipt count
-	checkCount("incoming_receipt", incomingReceiptsCount)
-
-	// 5. Check outgoing_receipt count
-	checkCount("outgoing_receipt", outgoingReceiptsCount)
-}
-
-// CheckSerializedTransactionPostgreSQL queries the node's PostgreSQL database within its Docker container
-// to check for the presence and count of records in the 'transactions' table.
-func (n *Node) CheckSerializedTransactionPostgreSQL(
-	t *testing.T,
-	isTransactionShouldBePresent bool,
-	timeToSleepSeconds int,
-) {
-	if timeToSleepSeconds > 0 {
-		time.Sleep(time.Duration(timeToSleepSeconds) * time.Second)
-	}
-
-	if n.ContainerID == "" {
-		t.Fatalf("Node %s: ContainerID is not set, cannot execute database checks.", n.Alias)
-	}
-
-	executeQuery := func(query string) (string, error) {
-		psqlCmd := fmt.Sprintf("PGPASSWORD=vtcpd_pass psql -h 127.0.0.1 -U vtcpd_user -d storagedb -t -A -c \"%s\"", query)
-		cmdArgs := []string{"exec", n.ContainerID, "sh", "-c", psqlCmd}
-		cmd := exec.Command("docker", cmdArgs...)
-
-		output, err := cmd.CombinedOutput()
-		trimmedOutput := strings.TrimSpace(string(output))
-
-		if err != nil {
-			return trimmedOutput, fmt.Errorf("docker exec psql command failed for query ['%s'] on node %s (container: %s): %v. Output: %s", query, n.Alias, n.ContainerID, err, trimmedOutput)
-		}
-		return trimmedOutput, nil
-	}
-
-	query := "SELECT count(*) FROM transactions"
-	countStr, err := executeQuery(query)
-	if err != nil {
-		t.Fatalf("Node %s: Error querying count from 'transactions' table. Query: '%s'. Error: %v. Output: %s", n.Alias, query, err, countStr)
-	}
-
-	actualCount, convErr := strconv.Atoi(countStr)
-	if convErr != nil {
-		t.Fatalf("Node %s: Error converting count '%s' to int for 'transactions' table. Query: '%s'. PostgreSQL output: '%s'. Conversion error: %v", n.Alias, countStr, query, countStr, convErr)
-	}
-
-	expectedCount := 0
-	if isTransactionShouldBePresent {
-		expectedCount = 1
-	}
-
-	if actualCount != expectedCount {
-		if isTransactionShouldBePresent {
-			t.Fatalf("Node %s: Expected 1 serialized transaction in DB, but found %d. Query: '%s'", n.Alias, actualCount, query)
-		} else {
-			t.Fatalf("Node %s: Expected 0 serialized transactions in DB, but found %d. Query: '%s'", n.Alias, actualCount, query)
-		}
-	}
-}
-
-// CheckValidKeysPostgreSQL queries the node's PostgreSQL database to check the count of valid own and contractor keys.
-func (n *Node) CheckValidKeysPostgreSQL(t *testing.T, expectedOwnValidKeysCount, expectedContractorValidKeysCount int) {
-	if n.ContainerID == "" {
-		t.Fatalf("Node %s: ContainerID is not set, cannot execute database checks.", n.Alias)
-	}
-
-	executeQuery := func(query string) (string, error) {
-		psqlCmd := fmt.Sprintf("PGPASSWORD=vtcpd_pass psql -h 127.0.0.1 -U vtcpd_user -d storagedb -t -A -c \"%s\"", query)
-		cmdArgs := []string{"exec", n.ContainerID, "sh", "-c", psqlCmd}
-		cmd := exec.Command("docker", cmdArgs...)
-		output, err := cmd.CombinedOutput()
-		trimmedOutput := strings.TrimSpace(string(output))
-		if err != nil {
-			return trimmedOutput, fmt.Errorf("docker exec psql command failed for query ['%s'] on node %s (container: %s): %v. Output: %s", query, n.Alias, n.ContainerID, err, trimmedOutput)
-		}
-		return trimmedOutput, nil
-	}
-
-	// Check own_keys
-	queryOwnKeys := "SELECT COUNT(*) FROM own_keys WHERE is_valid = true"
-	ownKeysCountStr, err := executeQuery(queryOwnKeys)
-	if err != nil {
-		t.Fatalf("Node %s: Error querying own_keys count. Query: '%s'. Error: %v. Output: %s", n.Alias, queryOwnKeys, err, ownKeysCountStr)
-	}
-	ownKeysCount, convErr := strconv.Atoi(ownKeysCountStr)
-	if convErr != nil {
-		t.Fatalf("Node %s: Error converting own_keys count '%s' to int. Query: '%s'. Error: %v", n.Alias, ownKeysCountStr, queryOwnKeys, convErr)
-	}
-	if ownKeysCount != expectedOwnValidKeysCount {
-		t.Fatalf("Node %s: Own valid keys count mismatch. Expected: %d, Got: %d. Query: '%s'", n.Alias, expectedOwnValidKeysCount, ownKeysCount, queryOwnKeys)
-	}
-
-	// Check contractor_keys
-	queryContractorKeys := "SELECT COUNT(*) FROM contractor_keys WHERE is_valid = true"
-	contractorKeysCountStr, err := executeQuery(queryContractorKeys)
-	if err != nil {
-		t.Fatalf("Node %s: Error querying contractor_keys count. Query: '%s'. Error: %v. Output: %s", n.Alias, queryContractorKeys, err, contractorKeysCountStr)
-	}
-	contractorKeysCount, convErr := strconv.Atoi(contractorKeysCountStr)
-	if convErr != nil {
-		t.Fatalf("Node %s: Error converting contractor_keys count '%s' to int. Query: '%s'. Error: %v", n.Alias, contractorKeysCountStr, queryContractorKeys, convErr)
-	}
-	if contractorKeysCount != expectedContractorValidKeysCount {
-		t.Fatalf("Node %s: Contractor valid keys count mismatch. Expected: %d, Got: %d. Query: '%s'", n.Alias, expectedContractorValidKeysCount, contractorKeysCount, queryContractorKeys)
-	}
-}
-
-// CheckSettlementLineStatePostgreSQL queries the node's PostgreSQL database to check the state of a trust line.
-func (n *Node) CheckSettlementLineStatePostgreSQL(t *testing.T, targetNode *Node, equivalent string, expectedState string) {
-	if n.ContainerID == "" {
-		t.Fatalf("Node %s: ContainerID is not set, cannot execute database checks.", n.Alias)
-	}
-
-	// First, get contractor_id (channel_id) for the target node
-	channelInfo, err := n.GetChannelInfoByAddress(targetNode)
-	if err != nil {
-		t.Fatalf("Node %s: Failed to get channel info for target %s to find contractor_id: %v", n.Alias, targetNode.Alias, err)
-	}
-	contractorID := channelInfo.ChannelID
-
-	query := fmt.Sprintf("SELECT state FROM trust_lines WHERE contractor_id = '%s' AND equivalent = %s", contractorID, equivalent)
-
-	executeQuery := func(q string) (string, error) {
-		psqlCmd := fmt.Sprintf("PGPASSWORD=vtcpd_pass psql -h 127.0.0.1 -U vtcpd_user -d storagedb -t -A -c \"%s\"", q)
-		cmdArgs := []string{"exec", n.ContainerID, "sh", "-c", psqlCmd}
-		cmd := exec.Command("docker", cmdArgs...)
-		output, errCmd := cmd.CombinedOutput()
-		trimmedOutput := strings.TrimSpace(string(output))
-		if errCmd != nil {
-			return trimmedOutput, fmt.Errorf("docker exec psql command failed for query ['%s'] on node %s: %v. Output: %s", q, n.Alias, errCmd, trimmedOutput)
-		}
-		return trimmedOutput, nil
-	}
-
-	actualState, err := executeQuery(query)
-	if err != nil {
-		t.Fatalf("Node %s: Error querying trust_line state. Query: '%s'. Error: %v", n.Alias, query, err)
-	}
-	if actualState == "" {
-		t.Fatalf("Node %s: No trust_line state returned for query: '%s'. The trust line might not exist for contractor_id '%s' and equivalent '%s'.", n.Alias, query, contractorID, equivalent)
-	}
-
-	if actualState != expectedState {
-		t.Fatalf("Node %s: TrustLine state mismatch for contractor %s (ID: %s), equivalent %s. Expected: %s, Got: %s. Query: '%s'",
-			n.Alias, targetNode.Alias, contractorID, equivalent, expectedState, actualState, query)
-	}
-}
-
-// CheckPaymentRecordWithCommandUUIDPostgreSQL queries the node's PostgreSQL database
-// to check for the presence of a payment record with a specific command_uuid.
-func (n *Node) CheckPaymentRecordWithCommandUUIDPostgreSQL(t *testing.T, commandUUID string, shouldBePresent bool) {
-	if n.ContainerID == "" {
-		t.Fatalf("Node %s: ContainerID is not set, cannot execute database checks.", n.Alias)
-	}
-
-	// The command_uuid in PostgreSQL is stored as a binary UUID, adapted for PostgreSQL format
-	formattedCommandUUID := strings.ReplaceAll(commandUUID, "-", "")
-	query := fmt.Sprintf("SELECT COUNT(*) FROM history WHERE command_uuid = E'\\\\x%s'", formattedCommandUUID)
-
-	executeQuery := func(q string) (string, error) {
-		psqlCmd := fmt.Sprintf("PGPASSWORD=vtcpd_pass psql -h 127.0.0.1 -U vtcpd_user -d storagedb -t -A -c \"%s\"", q)
-		cmdArgs := []string{"exec", n.ContainerID, "sh", "-c", psqlCmd}
-		cmd := exec.Command("docker", cmdArgs...)
-		output, errCmd := cmd.CombinedOutput()
-		trimmedOutput := strings.TrimSpace(string(output))
-		if errCmd != nil {
-			return trimmedOutput, fmt.Errorf("docker exec psql command failed for query ['%s'] on node %s: %v. Output: %s", q, n.Alias, errCmd, trimmedOutput)
-		}
-		return trimmedOutput, nil
-	}
-
-	countStr, err := executeQuery(query)
-	if err != nil {
-		t.Fatalf("Node %s: Error querying history for command_uuid '%s'. Query: '%s'. Error: %v", n.Alias, commandUUID, query, err)
-	}
-
-	actualCount, convErr := strconv.Atoi(countStr)
-	if convErr != nil {
-		t.Fatalf("Node %s: Error converting count '%s' to int for history query. Command UUID: '%s'. Query: '%s'. Error: %v", n.Alias, countStr, commandUUID, query, convErr)
-	}
-
-	if shouldBePresent {
-		if actualCount == 0 {
-			t.Fatalf("Node %s: Expected payment record with command_uuid '%s' to be present, but found 0. Query: '%s'", n.Alias, commandUUID, query)
-		}
-		if actualCount > 1 {
-			t.Logf("Node %s: Warning - found %d records for command_uuid '%s'. Expected at least 1. Query: '%s'", n.Alias, actualCount, commandUUID, query)
-		}
-	} else {
-		if actualCount > 0 {
-			t.Fatalf("Node %s: Expected no payment record with command_uuid '%s', but found %d. Query: '%s'", n.Alias, commandUUID, actualCount, query)
-		}
-	}
-}
-
-// CheckCurrentAuditPostgreSQL queries the node's PostgreSQL database to check the current audit number for a trust line.
-func (n *Node) CheckCurrentAuditPostgreSQL(t *testing.T, targetNode *Node, equivalent string, expectedAuditNumber int) {
-	if n.ContainerID == "" {
-		t.Fatalf("Node %s: ContainerID is not set, cannot execute database checks.", n.Alias)
-	}
-
-	channelInfo, err := n.GetChannelInfoByAddress(targetNode)
-	if err != nil {
-		t.Fatalf("Node %s: Failed to get channel info for target %s to find contractor_id for audit check: %v", n.Alias, targetNode.Alias, err)
-	}
-	contractorID := channelInfo.ChannelID
-
-	// First, get the trust_line_id
-	queryTrustLineID := fmt.Sprintf("SELECT id FROM trust_lines WHERE contractor_id = '%s' AND equivalent = %s", contractorID, equivalent)
-
-	executeQuery := func(q string) (string, error) {
-		psqlCmd := fmt.Sprintf("PGPASSWORD=vtcpd_pass psql -h 127.0.0.1 -U vtcpd_user -d storagedb -t -A -c \"%s\"", q)
-		cmdArgs := []string{"exec", n.ContainerID, "sh", "-c", psqlCmd}
-		cmd := exec.Command("docker", cmdArgs...)
-		output, errCmd := cmd.CombinedOutput()
-		trimmedOutput := strings.TrimSpace(string(output))
-		if errCmd != nil {
-			return trimmedOutput, fmt.Errorf("docker exec psql command failed for query ['%s'] on node %s: %v. Output: %s", q, n.Alias, errCmd, trimmedOutput)
-		}
-		return trimmedOutput, nil
-	}
-
-	trustLineIDStr, err := executeQuery(queryTrustLineID)
-	if err != nil {
-		t.Fatalf("Node %s: Error querying trust_line id. Query: '%s'. Error: %v", n.Alias, queryTrustLineID, err)
-	}
-	if trustLineIDStr == "" {
-		t.Fatalf("Node %s: No trust_line id returned for query: '%s'. The trust line might not exist for contractor_id '%s' and equivalent '%s'.", n.Alias, queryTrustLineID, contractorID, equivalent)
-	}
-
-	queryAudit := fmt.Sprintf("SELECT number FROM audit WHERE trust_line_id = %s ORDER BY number DESC LIMIT 1", trustLineIDStr)
-	actualAuditStr, err := executeQuery(queryAudit)
-	if err != nil {
-		t.Fatalf("Node %s: Error querying audit number. Query: '%s'. Error: %v", n.Alias, queryAudit, err)
-	}
-	if actualAuditStr == "" {
-		t.Fatalf("Node %s: No audit number returned for trust_line_id '%s'. Query: '%s'", n.Alias, trustLineIDStr, queryAudit)
-	}
-
-	actualAuditNumber, convErr := strconv.Atoi(actualAuditStr)
-	if convErr != nil {
-		t.Fatalf("Node %s: Error converting audit number '%s' to int. Query: '%s'. Error: %v", n.Alias, actualAuditStr, queryAudit, convErr)
-	}
-
-	if actualAuditNumber != expectedAuditNumber {
-		t.Fatalf("Node %s: Current audit number mismatch for contractor %s (TrustLineID: %s), equivalent %s. Expected: %d, Got: %d. Query: '%s'", n.Alias, targetNode.Alias, trustLineIDStr, equivalent, expectedAuditNumber, actualAuditNumber, queryAudit)
-	}
-}
-
-// Wrapper methods that dispatch to the appropriate database implementation based on VTCPD_DATABASE_CONFIG
-
-// CheckPaymentTransaction dispatches to the appropriate database implementation
-func (n *Node) CheckPaymentTransaction(
-	t *testing.T,
-	transactionState string,
-	paymentTransactionsCount int,
-	participantsVotesCount int,
-	incomingReceiptsCount int,
-	outgoingReceiptsCount int,
-) {
-	dbConfig := os.Getenv("VTCPD_DATABASE_CONFIG")
-	if strings.Contains(dbConfig, "sqlite") {
-		n.CheckPaymentTransactionSQLite(t, transactionState, paymentTransactionsCount, participantsVotesCount, incomingReceiptsCount, outgoingReceiptsCount)
-	} else if strings.Contains(dbConfig, "postgresql") {
-		n.CheckPaymentTransactionPostgreSQL(t, transactionState, paymentTransactionsCount, participantsVotesCount, incomingReceiptsCount, outgoingReceiptsCount)
-	} else {
-		n.CheckPaymentTransactionSQLite(t, transactionState, paymentTransactionsCount, participantsVotesCount, incomingReceiptsCount, outgoingReceiptsCount)
-	}
-}
-
-// CheckSerializedTransaction dispatches to the appropriate database implementation
-func (n *Node) CheckSerializedTransaction(
-	t *testing.T,
-	isTransactionShouldBePresent bool,
-	timeToSleepSeconds int,
-) {
-	dbConfig := os.Getenv("VTCPD_DATABASE_CONFIG")
-	if strings.Contains(dbConfig, "sqlite") {
-		n.CheckSerializedTransactionSQLite(t, isTransactionShouldBePresent, timeToSleepSeconds)
-	} else if strings.Contains(dbConfig, "postgresql") {
-		n.CheckSerializedTransactionPostgreSQL(t, isTransactionShouldBePresent, timeToSleepSeconds)
-	} else {
-		n.CheckSerializedTransactionSQLite(t, isTransactionShouldBePresent, timeToSleepSeconds)
-	}
-}
-
-// CheckValidKeys dispatches to the appropriate database implementation
-func (n *Node) CheckValidKeys(t *testing.T, expectedOwnValidKeysCount, expectedContractorValidKeysCount int) {
-	dbConfig := os.Getenv("VTCPD_DATABASE_CONFIG")
-	if strings.Contains(dbConfig, "sqlite") {
-		n.CheckValidKeysSQLite(t, expectedOwnValidKeysCount, expectedContractorValidKeysCount)
-	} else if strings.Contains(dbConfig, "postgresql") {
-		n.CheckValidKeysPostgreSQL(t, expectedOwnValidKeysCount, expectedContractorValidKeysCount)
-	} else {
-		n.CheckValidKeysSQLite(t, expectedOwnValidKeysCount, expectedContractorValidKeysCount)
-	}
-}
-
-// CheckSettlementLineState dispatches to the appropriate database implementation
-func (n *Node) CheckSettlementLineState(t *testing.T, targetNode *Node, equivalent string, expectedState string) {
-	dbConfig := os.Getenv("VTCPD_DATABASE_CONFIG")
-	if strings.Contains(dbConfig, "sqlite") {
-		n.CheckSettlementLineStateSQLite(t, targetNode, equivalent, expectedState)
-	} else if strings.Contains(dbConfig, "postgresql") {
-		n.CheckSettlementLineStatePostgreSQL(t, targetNode, equivalent, expectedState)
-	} else {
-		n.CheckSettlementLineStateSQLite(t, targetNode, equivalent, expectedState)
-	}
-}
-
-// CheckPaymentRecordWithCommandUUID dispatches to the appropriate database implementation
-func (n *Node) CheckPaymentRecordWithCommandUUID(t *testing.T, commandUUID string, shouldBePresent bool) {
-	dbConfig := os.Getenv("VTCPD_DATABASE_CONFIG")
-	if strings.Contains(dbConfig, "sqlite") {
-		n.CheckPaymentRecordWithCommandUUIDSQLite(t, commandUUID, shouldBePresent)
-	} else if strings.Contains(dbConfig, "postgresql") {
-		n.CheckPaymentRecordWithCommandUUIDPostgreSQL(t, commandUUID, shouldBePresent)
-	} else {
-		n.CheckPaymentRecordWithCommandUUIDSQLite(t, commandUUID, shouldBePresent)
-	}
-}
-
-// CheckCurrentAudit dispatches to the appropriate database implementation
-func (n *Node) CheckCurrentAudit(t *testing.T, targetNode *Node, equivalent string, expectedAuditNumber int) {
-	dbConfig := os.Getenv("VTCPD_DATABASE_CONFIG")
-	if strings.Contains(dbConfig, "sqlite") {
-		n.CheckCurrentAuditSQLite(t, targetNode, equivalent, expectedAuditNumber)
-	} else if strings.Contains(dbConfig, "postgresql") {
-		n.CheckCurrentAuditPostgreSQL(t, targetNode, equivalent, expectedAuditNumber)
-	} else {
-		n.CheckCurrentAuditSQLite(t, targetNode, equivalent, expectedAuditNumber)
-	}
-}
-
-// Exchange rates methods
-
-// SetExchangeRate sets an exchange rate using real decimal format
-func (n *Node) SetExchangeRate(t *testing.T, equivalentFrom, equivalentTo, realRate string, minAmount, maxAmount *string, expectedStatusCode int) {
-	url := fmt.Sprintf("http://%s:%d/api/v1/node/rates/%s/%s/?real_rate=%s",
-		n.IPAddress, n.CLIPort, equivalentFrom, equivalentTo, realRate)
-
-	// Add optional parameters
-	if minAmount != nil {
-		url += "&min_exchange_amount=" + *minAmount
-	}
-	if maxAmount != nil {
-		url += "&max_exchange_amount=" + *maxAmount
-	}
-
-	request, err := http.NewRequest(http.MethodPost, url, nil)
-	if err != nil {
-		t.Fatalf("failed to create set-exchange-rate request: %v", err)
-	}
-	request.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(request)
-	if err != nil {
-		t.Fatalf("failed to send set-exchange-rate request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != expectedStatusCode {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		t.Fatalf("set-exchange-rate request returned unexpected status: expected %d, got %d, body: %s", expectedStatusCode, resp.StatusCode, string(bodyBytes))
-	}
-}
-
-// SetExchangeRateNative sets an exchange rate using native value+shift format
-func (n *Node) SetExchangeRateNative(t *testing.T, equivalentFrom, equivalentTo, value string, shift int16, minAmount, maxAmount *string, expectedStatusCode int) {
-	url := fmt.Sprintf("http://%s:%d/api/v1/node/rates/%s/%s/?value=%s&shift=%d",
-		n.IPAddress, n.CLIPort, equivalentFrom, equivalentTo, value, shift)
-
-	// Add optional parameters
-	if minAmount != nil {
-		url += "&min_exchange_amount=" + *minAmount
-	}
-	if maxAmount != nil {
-		url += "&max_exchange_amount=" + *maxAmount
-	}
-
-	request, err := http.NewRequest(http.MethodPost, url, nil)
-	if err != nil {
-		t.Fatalf("failed to create set-exchange-rate-native request: %v", err)
-	}
-	request.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(request)
-	if err != nil {
-		t.Fatalf("failed to send set-exchange-rate-native request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != expectedStatusCode {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		t.Fatalf("set-exchange-rate-native request returned unexpected status: expected %d, got %d, body: %s", expectedStatusCode, resp.StatusCode, string(bodyBytes))
-	}
-}
+	if rate.Max != nil {
+		max := rate.Max.String()
+		maxAmount = &max
+	}
+	n.SetExchangeRateNative(t, rate.From, rate.To, rate.Value.String(), rate.Shift, minAmount, maxAmount, expectedStatusCode)
+}
 
 // SetExchangeRateWithConflictingParameters sets exchange rate with both real_rate and native parameters to test validation
 func (n *Node) SetExchangeRateWithConflictingParameters(t *testing.T, equivalentFrom, equivalentTo, realRate, value string, shift int16, minAmount, maxAmount *string, expectedStatusCode int) {
@@ -2460,14 +1676,7 @@ func (n *Node) SetExchangeRateWithConflictingParameters(t *testing.T, equivalent
 		url += "&max_exchange_amount=" + *maxAmount
 	}
 
-	request, err := http.NewRequest(http.MethodPost, url, nil)
-	if err != nil {
-		t.Fatalf("failed to create set-exchange-rate-conflicting request: %v", err)
-	}
-	request.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(request)
+	resp, err := n.doAPIRequest(n.runContext(), http.MethodPost, url)
 	if err != nil {
 		t.Fatalf("failed to send set-exchange-rate-conflicting request: %v", err)
 	}
@@ -2484,7 +1693,7 @@ func (n *Node) GetExchangeRate(t *testing.T, equivalentFrom, equivalentTo string
 	url := fmt.Sprintf("http://%s:%d/api/v1/node/rates/%s/%s/",
 		n.IPAddress, n.CLIPort, equivalentFrom, equivalentTo)
 
-	resp, err := http.Get(url)
+	resp, err := n.doAPIRequest(n.runContext(), http.MethodGet, url)
 	if err != nil {
 		t.Fatalf("failed to send get-exchange-rate request: %v", err)
 	}
@@ -2516,7 +1725,7 @@ func (n *Node) GetExchangeRate(t *testing.T, equivalentFrom, equivalentTo string
 func (n *Node) ListExchangeRates(t *testing.T) *RatesListResponse {
 	url := fmt.Sprintf("http://%s:%d/api/v1/node/rates/", n.IPAddress, n.CLIPort)
 
-	resp, err := http.Get(url)
+	resp, err := n.doAPIRequest(n.runContext(), http.MethodGet, url)
 	if err != nil {
 		t.Fatalf("failed to send list-exchange-rates request: %v", err)
 	}
@@ -2542,14 +1751,7 @@ func (n *Node) DeleteExchangeRate(t *testing.T, equivalentFrom, equivalentTo str
 	url := fmt.Sprintf("http://%s:%d/api/v1/node/rates/%s/%s/",
 		n.IPAddress, n.CLIPort, equivalentFrom, equivalentTo)
 
-	request, err := http.NewRequest(http.MethodDelete, url, nil)
-	if err != nil {
-		t.Fatalf("failed to create delete-exchange-rate request: %v", err)
-	}
-	request.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(request)
+	resp, err := n.doAPIRequest(n.runContext(), http.MethodDelete, url)
 	if err != nil {
 		t.Fatalf("failed to send delete-exchange-rate request: %v", err)
 	}
@@ -2565,14 +1767,7 @@ func (n *Node) DeleteExchangeRate(t *testing.T, equivalentFrom, equivalentTo str
 func (n *Node) ClearExchangeRates(t *testing.T) {
 	url := fmt.Sprintf("http://%s:%d/api/v1/node/rates/", n.IPAddress, n.CLIPort)
 
-	request, err := http.NewRequest(http.MethodDelete, url, nil)
-	if err != nil {
-		t.Fatalf("failed to create clear-exchange-rates request: %v", err)
-	}
-	request.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(request)
+	resp, err := n.doAPIRequest(n.runContext(), http.MethodDelete, url)
 	if err != nil {
 		t.Fatalf("failed to send clear-exchange-rates request: %v", err)
 	}
@@ -2590,7 +1785,7 @@ func (n *Node) ClearExchangeRates(t *testing.T) {
 // - receiveAmount: Desired amount to be received in receiver equivalent
 // - senderEquivalent: Equivalent in which the payer spends
 // - receiverEquivalent: Equivalent in which the contractor receives
-// - expectedEstimatedPayment: Expected estimated payment amount (string)
+// - expectedEstimatedPayment: Expected estimated payment amount, not checked unless the status is 200
 // - expectedStatusCode: Expected HTTP status code
 func (n *Node) CheckEstimatePaymentForReceiveAmount(
 	t *testing.T,
@@ -2598,7 +1793,7 @@ func (n *Node) CheckEstimatePaymentForReceiveAmount(
 	receiveAmount string,
 	senderEquivalent string,
 	receiverEquivalent string,
-	expectedEstimatedPayment string,
+	expectedEstimatedPayment Amount,
 	expectedStatusCode int,
 ) {
 	url := fmt.Sprintf(
@@ -2611,7 +1806,7 @@ func (n *Node) CheckEstimatePaymentForReceiveAmount(
 		receiveAmount,
 	)
 
-	resp, err := http.Get(url)
+	resp, err := n.doAPIRequest(n.runContext(), http.MethodGet, url)
 	if err != nil {
 		t.Fatalf("failed to send estimate payment request: %v", err)
 	}
@@ -2630,14 +1825,14 @@ func (n *Node) CheckEstimatePaymentForReceiveAmount(
 
 	var result struct {
 		Data struct {
-			EstimatedPaymentAmount string `json:"estimated_payment_amount"`
+			EstimatedPaymentAmount Amount `json:"estimated_payment_amount"`
 		} `json:"data"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		t.Fatalf("failed to decode estimate payment response: %v", err)
 	}
 
-	if result.Data.EstimatedPaymentAmount != expectedEstimatedPayment {
+	if !result.Data.EstimatedPaymentAmount.Equal(expectedEstimatedPayment) {
 		t.Fatalf("estimated payment amount mismatch: expected %s, got %s",
 			expectedEstimatedPayment, result.Data.EstimatedPaymentAmount)
 	}
@@ -2649,7 +1844,7 @@ func (n *Node) CheckEstimatePaymentForReceiveAmount(
 // - paymentAmount: Amount to be paid in sender equivalent
 // - senderEquivalent: Equivalent in which the payer spends
 // - receiverEquivalent: Equivalent in which the contractor receives
-// - expectedEstimatedReceive: Expected estimated receive amount (string)
+// - expectedEstimatedReceive: Expected estimated receive amount, not checked unless the status is 200
 // - expectedStatusCode: Expected HTTP status code
 func (n *Node) CheckEstimateReceiveForPaymentAmount(
 	t *testing.T,
@@ -2657,7 +1852,7 @@ func (n *Node) CheckEstimateReceiveForPaymentAmount(
 	paymentAmount string,
 	senderEquivalent string,
 	receiverEquivalent string,
-	expectedEstimatedReceive string,
+	expectedEstimatedReceive Amount,
 	expectedStatusCode int,
 ) {
 	url := fmt.Sprintf(
@@ -2670,7 +1865,7 @@ func (n *Node) CheckEstimateReceiveForPaymentAmount(
 		paymentAmount,
 	)
 
-	resp, err := http.Get(url)
+	resp, err := n.doAPIRequest(n.runContext(), http.MethodGet, url)
 	if err != nil {
 		t.Fatalf("failed to send estimate receive request: %v", err)
 	}
@@ -2689,14 +1884,14 @@ func (n *Node) CheckEstimateReceiveForPaymentAmount(
 
 	var result struct {
 		Data struct {
-			EstimatedReceiveAmount string `json:"estimated_receive_amount"`
+			EstimatedReceiveAmount Amount `json:"estimated_receive_amount"`
 		} `json:"data"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		t.Fatalf("failed to decode estimate receive response: %v", err)
 	}
 
-	if result.Data.EstimatedReceiveAmount != expectedEstimatedReceive {
+	if !result.Data.EstimatedReceiveAmount.Equal(expectedEstimatedReceive) {
 		t.Fatalf("estimated receive amount mismatch: expected %s, got %s",
 			expectedEstimatedReceive, result.Data.EstimatedReceiveAmount)
 	}
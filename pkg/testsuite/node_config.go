@@ -0,0 +1,373 @@
+package testsuite
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// NodeConfigPath is the vtcpd configuration file inside the node's container.
+const NodeConfigPath = "/vtcp/vtcpd/conf.json"
+
+// NodeConfigAddress is an address of the node or of an observer.
+type NodeConfigAddress struct {
+	Type    string `json:"type"`
+	Address string `json:"address"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// NodeCommission is the commission an intermediate node charges in an equivalent.
+type NodeCommission struct {
+	Amount int `json:"amount"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// NodeCommissionsConfig is the "commissions" section of the config.
+type NodeCommissionsConfig struct {
+	ByEquivalent map[string]NodeCommission `json:"byEquivalent,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// NodeConfig is the vtcpd configuration (conf.json).
+// Fields the suite doesn't know about are kept in the Extra of their section (the config itself, an address,
+// the commissions), so they survive an update.
+type NodeConfig struct {
+	Addresses                  []NodeConfigAddress    `json:"addresses,omitempty"`
+	DatabaseConfig             string                 `json:"database_config,omitempty"`
+	EquivalentsRegistryAddress string                 `json:"equivalents_registry_address,omitempty"`
+	MaxHopsCount               *int                   `json:"max_hops_count,omitempty"` // nil keeps the vtcpd default
+	Observers                  []NodeConfigAddress    `json:"observers,omitempty"`
+	Gateway                    []int                  `json:"gateway,omitempty"` // equivalents the node is a hub in
+	Commissions                *NodeCommissionsConfig `json:"commissions,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// CommissionPair represents a pair of equivalent and commission amount to be set in config
+type CommissionPair struct {
+	Equivalent string
+	Amount     int
+}
+
+// The *Fields types are needed to (un)marshal the config sections without recursing into their own methods.
+type (
+	nodeConfigFields            NodeConfig
+	nodeConfigAddressFields     NodeConfigAddress
+	nodeCommissionFields        NodeCommission
+	nodeCommissionsConfigFields NodeCommissionsConfig
+)
+
+func (c *NodeConfig) UnmarshalJSON(data []byte) error {
+	return unmarshalSection(data, (*nodeConfigFields)(c), &c.Extra)
+}
+
+func (c NodeConfig) MarshalJSON() ([]byte, error) {
+	return marshalSection(nodeConfigFields(c), c.Extra)
+}
+
+func (a *NodeConfigAddress) UnmarshalJSON(data []byte) error {
+	return unmarshalSection(data, (*nodeConfigAddressFields)(a), &a.Extra)
+}
+
+func (a NodeConfigAddress) MarshalJSON() ([]byte, error) {
+	return marshalSection(nodeConfigAddressFields(a), a.Extra)
+}
+
+func (c *NodeCommission) UnmarshalJSON(data []byte) error {
+	return unmarshalSection(data, (*nodeCommissionFields)(c), &c.Extra)
+}
+
+func (c NodeCommission) MarshalJSON() ([]byte, error) {
+	return marshalSection(nodeCommissionFields(c), c.Extra)
+}
+
+func (c *NodeCommissionsConfig) UnmarshalJSON(data []byte) error {
+	return unmarshalSection(data, (*nodeCommissionsConfigFields)(c), &c.Extra)
+}
+
+func (c NodeCommissionsConfig) MarshalJSON() ([]byte, error) {
+	return marshalSection(nodeCommissionsConfigFields(c), c.Extra)
+}
+
+// sectionKeys returns the JSON keys of the typed fields of a section.
+func sectionKeys(fields any) map[string]bool {
+	keys := make(map[string]bool)
+	sectionType := reflect.TypeOf(fields)
+	if sectionType.Kind() == reflect.Pointer {
+		sectionType = sectionType.Elem()
+	}
+	for i := 0; i < sectionType.NumField(); i++ {
+		name, _, _ := strings.Cut(sectionType.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			keys[name] = true
+		}
+	}
+	return keys
+}
+
+// unmarshalSection decodes the typed fields of a section and keeps the other keys in extra.
+func unmarshalSection(data []byte, fields any, extra *map[string]json.RawMessage) error {
+	if err := json.Unmarshal(data, fields); err != nil {
+		return err
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	keys := sectionKeys(fields)
+	*extra = nil
+	for key, value := range values {
+		if keys[key] {
+			continue
+		}
+		if *extra == nil {
+			*extra = make(map[string]json.RawMessage)
+		}
+		(*extra)[key] = value
+	}
+	return nil
+}
+
+// marshalSection encodes the typed fields of a section together with the extra keys,
+// the typed fields win over extra keys of the same name.
+func marshalSection(fields any, extra map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(fields)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	keys := sectionKeys(fields)
+	for key, value := range extra {
+		if !keys[key] {
+			values[key] = value
+		}
+	}
+	return json.Marshal(values)
+}
+
+// SetMaxHopsCount sets max_hops_count.
+func (c *NodeConfig) SetMaxHopsCount(hopsCount int) {
+	c.MaxHopsCount = &hopsCount
+}
+
+// SetCommission sets the commission charged in the equivalent, other equivalents and fields are kept.
+func (c *NodeConfig) SetCommission(pair CommissionPair) {
+	if c.Commissions == nil {
+		c.Commissions = &NodeCommissionsConfig{}
+	}
+	if c.Commissions.ByEquivalent == nil {
+		c.Commissions.ByEquivalent = make(map[string]NodeCommission)
+	}
+	commission := c.Commissions.ByEquivalent[pair.Equivalent]
+	commission.Amount = pair.Amount
+	c.Commissions.ByEquivalent[pair.Equivalent] = commission
+}
+
+// Validate checks the values vtcpd would reject or misinterpret.
+func (c *NodeConfig) Validate() error {
+	var problems []string
+	if c.MaxHopsCount != nil && *c.MaxHopsCount < 0 {
+		problems = append(problems, fmt.Sprintf("negative max_hops_count %d", *c.MaxHopsCount))
+	}
+	gateways := make(map[int]bool, len(c.Gateway))
+	for _, equivalent := range c.Gateway {
+		if gateways[equivalent] {
+			problems = append(problems, fmt.Sprintf("duplicated gateway equivalent %d", equivalent))
+		}
+		gateways[equivalent] = true
+	}
+	if c.Commissions != nil {
+		for equivalent, commission := range c.Commissions.ByEquivalent {
+			if _, err := strconv.Atoi(equivalent); err != nil {
+				problems = append(problems, fmt.Sprintf("commission equivalent %q is not a number", equivalent))
+			}
+			if commission.Amount < 0 {
+				problems = append(problems, fmt.Sprintf("negative commission %d in equivalent %s", commission.Amount, equivalent))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid node config: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// ReadConfig is ReadConfigCtx under the context the node was run with.
+func (n *Node) ReadConfig() (*NodeConfig, error) {
+	return n.ReadConfigCtx(n.runContext())
+}
+
+// ReadConfigCtx reads the node's config from the container. A missing file is an empty config.
+func (n *Node) ReadConfigCtx(ctx context.Context) (*NodeConfig, error) {
+	if n.ContainerID == "" {
+		return nil, fmt.Errorf("Node %s: ContainerID is not set, cannot execute commands", n.Alias)
+	}
+
+	ctx, cancel := n.stepContext(ctx)
+	defer cancel()
+	shellCommand := fmt.Sprintf("if [ -f %[1]s ]; then cat %[1]s; fi", NodeConfigPath)
+	output, err := n.dockerCombinedOutput(ctx, exec.CommandContext(ctx, "docker", "exec", n.ContainerID, "sh", "-c", shellCommand))
+	if err != nil {
+		return nil, fmt.Errorf("Node %s: failed to read config file: %v. Output: %s", n.Alias, err, string(output))
+	}
+
+	config := &NodeConfig{}
+	if len(bytes.TrimSpace(output)) == 0 {
+		return config, nil
+	}
+	if err := json.Unmarshal(output, config); err != nil {
+		return nil, fmt.Errorf("Node %s: failed to parse config JSON: %v", n.Alias, err)
+	}
+	return config, nil
+}
+
+// WriteConfig is WriteConfigCtx under the context the node was run with.
+func (n *Node) WriteConfig(config *NodeConfig) error {
+	return n.WriteConfigCtx(n.runContext(), config)
+}
+
+// WriteConfigCtx validates the config, writes it to the container and checks that the file reads back the same.
+// The node must be restarted to pick the changes up.
+func (n *Node) WriteConfigCtx(ctx context.Context, config *NodeConfig) error {
+	if n.ContainerID == "" {
+		return fmt.Errorf("Node %s: ContainerID is not set, cannot execute commands", n.Alias)
+	}
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("Node %s: %v", n.Alias, err)
+	}
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Node %s: failed to marshal config: %v", n.Alias, err)
+	}
+
+	stepCtx, cancel := n.stepContext(ctx)
+	defer cancel()
+	// The config is passed through stdin, so it doesn't need any shell escaping
+	shellCommand := fmt.Sprintf("mkdir -p $(dirname %[1]s) && cat > %[1]s", NodeConfigPath)
+	cmd := exec.CommandContext(stepCtx, "docker", "exec", "-i", n.ContainerID, "sh", "-c", shellCommand)
+	cmd.Stdin = bytes.NewReader(data)
+	if output, err := n.dockerCombinedOutput(stepCtx, cmd); err != nil {
+		return fmt.Errorf("Node %s: failed to write config file: %v. Output: %s", n.Alias, err, string(output))
+	}
+
+	written, err := n.ReadConfigCtx(ctx)
+	if err != nil {
+		return err
+	}
+	expected, _ := json.Marshal(config)
+	actual, err := json.Marshal(written)
+	if err != nil {
+		return fmt.Errorf("Node %s: failed to marshal written config: %v", n.Alias, err)
+	}
+	if !bytes.Equal(expected, actual) {
+		return fmt.Errorf("Node %s: config read back differs from the written one.\nExpected: %s\nGot: %s",
+			n.Alias, string(expected), string(actual))
+	}
+	n.rememberCommissions(config)
+	return nil
+}
+
+// rememberCommissions keeps the commissions of the config written to the node, for the commission checks.
+func (n *Node) rememberCommissions(config *NodeConfig) {
+	n.commissions = make(map[string]int)
+	if config.Commissions == nil {
+		return
+	}
+	for equivalent, commission := range config.Commissions.ByEquivalent {
+		n.commissions[equivalent] = commission.Amount
+	}
+}
+
+// Commission returns the commission the node charges in the equivalent as an intermediate node,
+// according to the config written by the suite.
+func (n *Node) Commission(equivalent string) Amount {
+	return NewAmount(int64(n.commissions[equivalent]))
+}
+
+// UpdateConfig is UpdateConfigCtx under the context the node was run with.
+func (n *Node) UpdateConfig(update func(config *NodeConfig)) error {
+	return n.UpdateConfigCtx(n.runContext(), update)
+}
+
+// UpdateConfigCtx reads the node's config, applies the changes, writes it back and restarts the node once,
+// however many options are changed.
+func (n *Node) UpdateConfigCtx(ctx context.Context, update func(config *NodeConfig)) error {
+	config, err := n.ReadConfigCtx(ctx)
+	if err != nil {
+		return err
+	}
+	update(config)
+	if err := n.WriteConfigCtx(ctx, config); err != nil {
+		return err
+	}
+
+	if _, err := n.RestartCtx(ctx, RestartOptions{}); err != nil {
+		return fmt.Errorf("Node %s: failed to restart node: %v", n.Alias, err)
+	}
+	return nil
+}
+
+// Boot configuration.
+//
+// The image entrypoint generates conf.json from the container environment unless the file is already there.
+// The suite takes the config the node's image generates (see Cluster.imageConfig), applies the options passed
+// to NewNode and copies the file into the container before vtcpd first starts, with no restart needed.
+
+// NodeOption changes the node's config before vtcpd first starts.
+type NodeOption func(config *NodeConfig) error
+
+// WithHub makes the node a hub (gateway) in the equivalent.
+func WithHub(equivalent string) NodeOption {
+	return func(config *NodeConfig) error {
+		equivalentNum, err := strconv.Atoi(equivalent)
+		if err != nil {
+			return fmt.Errorf("failed to convert equivalent to integer for gateway config: %v", err)
+		}
+		config.Gateway = append(config.Gateway, equivalentNum)
+		return nil
+	}
+}
+
+// WithCommissions sets the commissions the node charges as an intermediate node.
+func WithCommissions(pairs ...CommissionPair) NodeOption {
+	return func(config *NodeConfig) error {
+		for _, pair := range pairs {
+			config.SetCommission(pair)
+		}
+		return nil
+	}
+}
+
+// WithMaxHops sets max_hops_count.
+func WithMaxHops(hopsCount int) NodeOption {
+	return func(config *NodeConfig) error {
+		config.SetMaxHopsCount(hopsCount)
+		return nil
+	}
+}
+
+// applyConfigOptions applies the node's options to the config generated by its image.
+func (n *Node) applyConfigOptions(config *NodeConfig) error {
+	for _, option := range n.ConfigOptions {
+		if err := option(config); err != nil {
+			return fmt.Errorf("Node %s: %v", n.Alias, err)
+		}
+	}
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("Node %s: %v", n.Alias, err)
+	}
+	n.rememberCommissions(config)
+	return nil
+}
@@ -0,0 +1,70 @@
+package testsuite
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestNodeConfigRoundTripKeepsUnknownFields(t *testing.T) {
+	original := `{
+		"addresses": [{"type": "ipv4", "address": "10.0.0.2:2000", "interface": "eth0"}],
+		"database_config": "sqlite3:///io",
+		"max_hops_count": 5,
+		"commissions": {
+			"byEquivalent": {"1": {"amount": 10, "mode": "fixed"}},
+			"defaultAmount": 3
+		},
+		"logging": {"level": "debug", "rotation": {"size": 1024}}
+	}`
+
+	config := &NodeConfig{}
+	if err := json.Unmarshal([]byte(original), config); err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	config.SetMaxHopsCount(3)
+	config.SetCommission(CommissionPair{Equivalent: "1", Amount: 20})
+	config.SetCommission(CommissionPair{Equivalent: "2", Amount: 30})
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("failed to marshal config: %v", err)
+	}
+
+	expected := `{
+		"addresses": [{"type": "ipv4", "address": "10.0.0.2:2000", "interface": "eth0"}],
+		"database_config": "sqlite3:///io",
+		"max_hops_count": 3,
+		"commissions": {
+			"byEquivalent": {"1": {"amount": 20, "mode": "fixed"}, "2": {"amount": 30}},
+			"defaultAmount": 3
+		},
+		"logging": {"level": "debug", "rotation": {"size": 1024}}
+	}`
+	var want, got any
+	if err := json.Unmarshal([]byte(expected), &want); err != nil {
+		t.Fatalf("failed to parse expected config: %v", err)
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("failed to parse marshalled config: %v", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("config mismatch.\nExpected: %s\nGot:      %s", expected, data)
+	}
+}
+
+func TestNodeConfigTypedFieldsWinOverExtra(t *testing.T) {
+	address := NodeConfigAddress{
+		Type:    "ipv4",
+		Address: "10.0.0.2:2000",
+		Extra:   map[string]json.RawMessage{"address": json.RawMessage(`"stale"`), "interface": json.RawMessage(`"eth0"`)},
+	}
+	data, err := json.Marshal(address)
+	if err != nil {
+		t.Fatalf("failed to marshal address: %v", err)
+	}
+	want := `{"address":"10.0.0.2:2000","interface":"eth0","type":"ipv4"}`
+	if string(data) != want {
+		t.Errorf("address mismatch.\nExpected: %s\nGot:      %s", want, data)
+	}
+}
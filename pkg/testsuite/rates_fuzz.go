@@ -0,0 +1,607 @@
+package testsuite
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"text/tabwriter"
+)
+
+// Exchange rates fuzzing.
+//
+// Cases drive POST /api/v1/node/rates/{from}/{to}/ with boundary and random parameters. Every response must be
+// a defined status (no 5xx), the node must stay alive, and whatever is accepted must read back the same through
+// GET of the pair and the rates list. A rejected request must leave the stored rate as it was.
+
+// RateFuzzExpectation is what the node is expected to do with a case.
+type RateFuzzExpectation int
+
+const (
+	// RateFuzzAnyStatus only requires a defined (non 5xx) status, the outcome is not specified.
+	RateFuzzAnyStatus RateFuzzExpectation = iota
+	RateFuzzAccepted
+	RateFuzzRejected
+)
+
+func (e RateFuzzExpectation) String() string {
+	switch e {
+	case RateFuzzAccepted:
+		return "accepted"
+	case RateFuzzRejected:
+		return "rejected"
+	default:
+		return "any"
+	}
+}
+
+// RateFuzzCase is a single set-rate request.
+type RateFuzzCase struct {
+	Name   string
+	From   string
+	To     string
+	Params url.Values // real_rate, value, shift, min_exchange_amount, max_exchange_amount
+	Expect RateFuzzExpectation
+}
+
+// NewRateFuzzCase builds a case with the expectation derived from the parameters, see ClassifyRateParams.
+func NewRateFuzzCase(name, from, to string, params url.Values) RateFuzzCase {
+	return RateFuzzCase{Name: name, From: from, To: to, Params: params, Expect: ClassifyRateParams(from, to, params)}
+}
+
+func (c RateFuzzCase) request() string {
+	return fmt.Sprintf("POST /api/v1/node/rates/%s/%s/?%s", c.From, c.To, c.Params.Encode())
+}
+
+var (
+	integerLiteral = regexp.MustCompile(`^-?[0-9]+$`)
+	decimalLiteral = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+)
+
+// numberRunes are the characters a number may be written with in any of the forms parsers accept.
+const numberRunes = "0123456789.+-eExX "
+
+// malformedNumber reports whether s can't be read as a number by any parser: it has no digits, several decimal
+// points or characters that don't appear in numbers. Lenient forms like "1.", "+1" or "1e5" are not malformed.
+func malformedNumber(s string) bool {
+	if !strings.ContainsAny(s, "0123456789") || strings.Count(s, ".") > 1 {
+		return true
+	}
+	return strings.ContainsFunc(s, func(r rune) bool { return !strings.ContainsRune(numberRunes, r) })
+}
+
+// ClassifyRateParams tells what the rates API must do with the parameters. Malformed numbers, both or neither
+// rate forms and real rates more precise than MaxRealRateDecimals are rejected. Positive rates between different
+// known equivalents, with int16 shifts and ordered non-negative limits that fit into uint64, are accepted.
+// Anything else (zero or negative rates, huge values, lenient number forms, unknown equivalents) only has to get
+// a defined status.
+func ClassifyRateParams(from, to string, params url.Values) RateFuzzExpectation {
+	realRate, hasReal := params["real_rate"]
+	value, hasValue := params["value"]
+	shift, hasShift := params["shift"]
+	if hasReal == (hasValue || hasShift) {
+		return RateFuzzRejected
+	}
+	for _, name := range []string{"real_rate", "value", "shift", "min_exchange_amount", "max_exchange_amount"} {
+		if values, ok := params[name]; ok && malformedNumber(values[0]) {
+			return RateFuzzRejected
+		}
+	}
+
+	accepted := true
+	var limits []*big.Int
+	for _, name := range []string{"min_exchange_amount", "max_exchange_amount"} {
+		limit, ok := params[name]
+		if !ok {
+			limits = append(limits, nil)
+			continue
+		}
+		if !integerLiteral.MatchString(limit[0]) {
+			return RateFuzzAnyStatus
+		}
+		amount, _ := new(big.Int).SetString(limit[0], 10)
+		if amount.Sign() < 0 || !amount.IsUint64() {
+			accepted = false
+		}
+		limits = append(limits, amount)
+	}
+	if limits[0] != nil && limits[1] != nil && limits[0].Cmp(limits[1]) > 0 {
+		accepted = false
+	}
+
+	_, knownFrom := EquivalentDecimals[from]
+	_, knownTo := EquivalentDecimals[to]
+	if !knownFrom || !knownTo || from == to {
+		accepted = false
+	}
+
+	if hasReal {
+		if !decimalLiteral.MatchString(realRate[0]) {
+			return RateFuzzAnyStatus
+		}
+		_, decimals, _ := strings.Cut(realRate[0], ".")
+		if len(decimals) > MaxRealRateDecimals {
+			return RateFuzzRejected
+		}
+		rate, _ := new(big.Rat).SetString(realRate[0])
+		if rate.Sign() <= 0 || !accepted {
+			return RateFuzzAnyStatus
+		}
+		nativeValue, _, err := ParseRealRate(realRate[0], EquivalentDecimals[from], EquivalentDecimals[to])
+		if err != nil {
+			return RateFuzzAnyStatus
+		}
+		if parsed, _ := new(big.Int).SetString(nativeValue, 10); !parsed.IsUint64() {
+			return RateFuzzAnyStatus
+		}
+		return RateFuzzAccepted
+	}
+
+	if !hasValue || !hasShift {
+		return RateFuzzRejected
+	}
+	if !integerLiteral.MatchString(value[0]) || !integerLiteral.MatchString(shift[0]) {
+		return RateFuzzAnyStatus
+	}
+	nativeValue, _ := new(big.Int).SetString(value[0], 10)
+	shiftValue, err := strconv.ParseInt(shift[0], 10, 64)
+	if err != nil || shiftValue < math.MinInt16 || shiftValue > math.MaxInt16 {
+		return RateFuzzAnyStatus
+	}
+	if nativeValue.Sign() <= 0 || !nativeValue.IsUint64() || !accepted {
+		return RateFuzzAnyStatus
+	}
+	return RateFuzzAccepted
+}
+
+func nativeParams(value, shift string) url.Values {
+	return url.Values{"value": {value}, "shift": {shift}}
+}
+
+func realParams(realRate string) url.Values {
+	return url.Values{"real_rate": {realRate}}
+}
+
+func withLimits(params url.Values, min, max string) url.Values {
+	if min != "" {
+		params.Set("min_exchange_amount", min)
+	}
+	if max != "" {
+		params.Set("max_exchange_amount", max)
+	}
+	return params
+}
+
+// BoundaryRateFuzzCases are the edge values of every parameter for the pair.
+func BoundaryRateFuzzCases(from, to string) []RateFuzzCase {
+	huge := "1" + strings.Repeat("0", 40)
+	var cases []RateFuzzCase
+	add := func(name string, params url.Values) {
+		cases = append(cases, NewRateFuzzCase(name, from, to, params))
+	}
+
+	for _, shift := range []int{0, 1, -1, 16, -16, math.MaxInt16, math.MinInt16, math.MaxInt16 + 1, math.MinInt16 - 1, math.MaxInt32} {
+		add(fmt.Sprintf("native shift %d", shift), nativeParams("1", strconv.Itoa(shift)))
+	}
+	for _, value := range []string{"0", "-1", strconv.FormatUint(math.MaxUint64, 10), huge, "1.5", "abc", "", " 1", "0x10", "1e5"} {
+		add(fmt.Sprintf("native value %q", value), nativeParams(value, "0"))
+	}
+	add("native shift \"x\"", nativeParams("1", "x"))
+	add("native without shift", url.Values{"value": {"1"}})
+	add("shift without value", url.Values{"shift": {"1"}})
+
+	for _, realRate := range []string{"1", "0.5", "112071.54", "0." + strings.Repeat("0", 15) + "1", "0." + strings.Repeat("0", 16) + "1",
+		"0", "-1", huge, "1.", ".5", "1..2", "1e5", "NaN", "", " 1", "1,5", "+1"} {
+		add(fmt.Sprintf("real rate %q", realRate), realParams(realRate))
+	}
+
+	add("conflicting forms", url.Values{"real_rate": {"1"}, "value": {"1"}, "shift": {"0"}})
+	add("no rate", url.Values{})
+
+	for _, limits := range [][2]string{{"0", ""}, {"", "0"}, {"0", "0"}, {"1", strconv.FormatUint(math.MaxUint64, 10)},
+		{huge, ""}, {"", huge}, {huge, huge}, {"-1", ""}, {"", "-1"}, {"100", "10"}, {"abc", ""}, {"", "1.5"}} {
+		add(fmt.Sprintf("limits min=%q max=%q", limits[0], limits[1]), withLimits(nativeParams("15", "-1"), limits[0], limits[1]))
+	}
+	return cases
+}
+
+// RandomRateFuzzCases are count random cases between the equivalents. Values are drawn mostly near the boundaries.
+func RandomRateFuzzCases(rng *rand.Rand, equivalents []string, count int) []RateFuzzCase {
+	cases := make([]RateFuzzCase, 0, count)
+	for i := 0; i < count; i++ {
+		from, to := equivalents[rng.Intn(len(equivalents))], equivalents[rng.Intn(len(equivalents))]
+		var params url.Values
+		switch rng.Intn(10) {
+		case 0:
+			params = url.Values{"real_rate": {randomDecimal(rng)}, "value": {randomInteger(rng)}, "shift": {randomShift(rng)}}
+		case 1, 2, 3, 4:
+			params = realParams(randomDecimal(rng))
+		default:
+			params = nativeParams(randomInteger(rng), randomShift(rng))
+		}
+		if rng.Intn(3) == 0 {
+			params = withLimits(params, randomInteger(rng), randomInteger(rng))
+		}
+		cases = append(cases, NewRateFuzzCase(fmt.Sprintf("random #%d", i+1), from, to, params))
+	}
+	return cases
+}
+
+// RepeatedPairRateFuzzCases set the rate of the same pair count times, accepted and rejected requests mixed,
+// the pair must keep the last accepted rate only.
+func RepeatedPairRateFuzzCases(rng *rand.Rand, from, to string, count int) []RateFuzzCase {
+	cases := make([]RateFuzzCase, 0, count)
+	for i := 0; i < count; i++ {
+		params := nativeParams(strconv.Itoa(1+rng.Intn(1000000)), strconv.Itoa(rng.Intn(33)-16))
+		if rng.Intn(4) == 0 {
+			params = realParams(randomDecimal(rng))
+		}
+		cases = append(cases, NewRateFuzzCase(fmt.Sprintf("repeated #%d", i+1), from, to, params))
+	}
+	return cases
+}
+
+func randomInteger(rng *rand.Rand) string {
+	switch rng.Intn(8) {
+	case 0:
+		return "0"
+	case 1:
+		return "-" + strconv.Itoa(1+rng.Intn(1000))
+	case 2:
+		return strconv.FormatUint(math.MaxUint64-uint64(rng.Intn(2)), 10) + strings.Repeat("0", rng.Intn(2))
+	case 3:
+		return strconv.Itoa(rng.Intn(100)) + string("x.,e "[rng.Intn(5)])
+	default:
+		return strconv.FormatInt(1+rng.Int63n(1<<uint(1+rng.Intn(62))), 10)
+	}
+}
+
+func randomShift(rng *rand.Rand) string {
+	switch rng.Intn(6) {
+	case 0:
+		return strconv.Itoa([]int{math.MaxInt16, math.MinInt16, math.MaxInt16 + 1, math.MinInt16 - 1}[rng.Intn(4)])
+	case 1:
+		return strconv.Itoa(rng.Intn(200000) - 100000)
+	default:
+		return strconv.Itoa(rng.Intn(41) - 20)
+	}
+}
+
+func randomDecimal(rng *rand.Rand) string {
+	whole := strconv.FormatInt(rng.Int63n(1000000), 10)
+	switch rng.Intn(8) {
+	case 0:
+		return whole
+	case 1:
+		return "-" + whole + ".5"
+	case 2:
+		return whole + "." + strings.Repeat("1", MaxRealRateDecimals+1)
+	case 3:
+		return whole + ".." + whole
+	case 4:
+		return whole + "e" + strconv.Itoa(rng.Intn(10))
+	default:
+		decimals := rng.Intn(MaxRealRateDecimals) + 1
+		fraction := make([]byte, decimals)
+		for i := range fraction {
+			fraction[i] = byte('0' + rng.Intn(10))
+		}
+		return whole + "." + string(fraction)
+	}
+}
+
+// RateFuzzFailure is a request the node handled wrong.
+type RateFuzzFailure struct {
+	Case    RateFuzzCase
+	Status  int
+	Body    string
+	Problem string
+}
+
+// RateFuzzReport sums up a fuzzing run.
+type RateFuzzReport struct {
+	Cases    int
+	Accepted int
+	Rejected int
+	Failures []RateFuzzFailure
+	// Crashed is set when the node stopped responding, the run stops at the case that caused it.
+	Crashed bool
+}
+
+func (r *RateFuzzReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d cases: %d accepted, %d rejected, %d failures", r.Cases, r.Accepted, r.Rejected, len(r.Failures))
+	if r.Crashed {
+		sb.WriteString(", the node crashed")
+	}
+	sb.WriteString("\n")
+	if len(r.Failures) == 0 {
+		return sb.String()
+	}
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CASE\tEXPECTED\tSTATUS\tPROBLEM\tREQUEST\t")
+	for _, failure := range r.Failures {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t\n", failure.Case.Name, failure.Case.Expect, failure.Status,
+			failure.Problem, failure.Case.request())
+	}
+	w.Flush()
+	return sb.String()
+}
+
+type ratePair struct {
+	from, to string
+}
+
+// FuzzExchangeRates runs the cases against the node, in order. The node's rates are cleared first.
+func (n *Node) FuzzExchangeRates(cases []RateFuzzCase) (*RateFuzzReport, error) {
+	if status, body, err := n.apiRequest(http.MethodDelete, "/api/v1/node/rates/", nil); err != nil || status != http.StatusOK {
+		return nil, fmt.Errorf("Node %s: failed to clear exchange rates: status %d, %v %s", n.Alias, status, err, body)
+	}
+
+	report := &RateFuzzReport{}
+	stored := make(map[ratePair]*RateItem)
+	for _, c := range cases {
+		report.Cases++
+		fail := func(status int, body, problem string, args ...any) {
+			report.Failures = append(report.Failures, RateFuzzFailure{Case: c, Status: status, Body: body, Problem: fmt.Sprintf(problem, args...)})
+		}
+
+		status, body, err := n.apiRequest(http.MethodPost, fmt.Sprintf("/api/v1/node/rates/%s/%s/", c.From, c.To), c.Params)
+		if err != nil {
+			fail(0, "", "request failed: %v", err)
+		}
+		if aliveErr := n.CheckAlive(); aliveErr != nil {
+			fail(status, body, "node is down after the request: %v", aliveErr)
+			report.Crashed = true
+			return report, nil
+		}
+		if err != nil {
+			continue
+		}
+
+		switch {
+		case status >= 500:
+			fail(status, body, "server error")
+		case c.Expect == RateFuzzAccepted && status != http.StatusOK:
+			fail(status, body, "valid rate rejected")
+		case c.Expect == RateFuzzRejected && status == http.StatusOK:
+			fail(status, body, "invalid rate accepted")
+		}
+
+		pair := ratePair{c.From, c.To}
+		if status == http.StatusOK {
+			report.Accepted++
+			expected, problem := expectedRateItem(c)
+			if problem != "" {
+				fail(status, body, "%s", problem)
+			}
+			item, getStatus, getBody, err := n.fetchExchangeRate(c.From, c.To)
+			switch {
+			case err != nil:
+				fail(getStatus, getBody, "failed to read the rate back: %v", err)
+			case item == nil:
+				fail(getStatus, getBody, "accepted rate is not stored")
+			default:
+				if problem := compareRateItems(expected, item); problem != "" {
+					fail(getStatus, getBody, "read back differs: %s", problem)
+				}
+				stored[pair] = item
+			}
+			continue
+		}
+
+		report.Rejected++
+		item, getStatus, getBody, err := n.fetchExchangeRate(c.From, c.To)
+		switch {
+		case err != nil:
+			fail(getStatus, getBody, "failed to read the rate back: %v", err)
+		case stored[pair] == nil && item != nil:
+			fail(getStatus, getBody, "rejected request stored a rate: %+v", *item)
+		case stored[pair] != nil && item == nil:
+			fail(getStatus, getBody, "rejected request removed the stored rate")
+		case stored[pair] != nil:
+			if problem := compareRateItems(stored[pair], item); problem != "" {
+				fail(getStatus, getBody, "rejected request changed the stored rate: %s", problem)
+			}
+		}
+	}
+
+	problems, err := n.checkRatesList(stored)
+	if err != nil {
+		return report, err
+	}
+	for _, problem := range problems {
+		report.Failures = append(report.Failures, RateFuzzFailure{Case: RateFuzzCase{Name: "rates list"}, Status: http.StatusOK, Problem: problem})
+	}
+	return report, nil
+}
+
+// FuzzExchangeRatesAndCheck runs the cases and fails the test on any failure.
+func (n *Node) FuzzExchangeRatesAndCheck(t *testing.T, cases []RateFuzzCase) *RateFuzzReport {
+	report, err := n.FuzzExchangeRates(cases)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(report.Failures) > 0 {
+		t.Fatalf("Node %s: exchange rates fuzzing failed: %s", n.Alias, report)
+	}
+	t.Logf("Node %s: exchange rates fuzzing: %s", n.Alias, report)
+	return report
+}
+
+// expectedRateItem is the rate the accepted case must read back as. Values that can't be predicted stay empty.
+func expectedRateItem(c RateFuzzCase) (*RateItem, string) {
+	item := &RateItem{EquivalentFrom: c.From, EquivalentTo: c.To}
+	// A limit that is not an integer (e.g. "1e5") can't be predicted
+	item.MinExchangeAmount, _ = parseOptionalAmount(c.Params.Get("min_exchange_amount"))
+	item.MaxExchangeAmount, _ = parseOptionalAmount(c.Params.Get("max_exchange_amount"))
+	decimalsFrom, knownFrom := EquivalentDecimals[c.From]
+	decimalsTo, knownTo := EquivalentDecimals[c.To]
+
+	if realRate := c.Params.Get("real_rate"); c.Params.Has("real_rate") {
+		item.RealRate = realRate
+		if knownFrom && knownTo {
+			// A rate the model can't convert (e.g. a negative one) is only compared as real_rate
+			if value, shift, err := ParseRealRate(realRate, decimalsFrom, decimalsTo); err == nil {
+				item.Value, item.Shift = value, shift
+			}
+		}
+		return item, ""
+	}
+
+	shift, err := strconv.ParseInt(c.Params.Get("shift"), 10, 16)
+	if err != nil {
+		return item, fmt.Sprintf("accepted shift %q out of int16", c.Params.Get("shift"))
+	}
+	item.Value, item.Shift = c.Params.Get("value"), int16(shift)
+	if value, ok := new(big.Int).SetString(item.Value, 10); ok && knownFrom && knownTo {
+		rate := ExchangeRate{Value: value, Shift: item.Shift}
+		item.RealRate = rate.RealRate(decimalsFrom, decimalsTo)
+	}
+	return item, ""
+}
+
+// compareRateItems compares the rates by value: value and shift as value·10^-shift, real rates as decimals.
+// Fields left empty in expected are not compared.
+func compareRateItems(expected, actual *RateItem) string {
+	var problems []string
+	if expected.EquivalentFrom != actual.EquivalentFrom || expected.EquivalentTo != actual.EquivalentTo {
+		problems = append(problems, fmt.Sprintf("pair %s -> %s, expected %s -> %s",
+			actual.EquivalentFrom, actual.EquivalentTo, expected.EquivalentFrom, expected.EquivalentTo))
+	}
+	if expected.Value != "" && !sameNativeRate(expected.Value, expected.Shift, actual.Value, actual.Shift) {
+		problems = append(problems, fmt.Sprintf("value=%s shift=%d, expected value=%s shift=%d",
+			actual.Value, actual.Shift, expected.Value, expected.Shift))
+	}
+	if expected.RealRate != "" && !sameDecimal(expected.RealRate, actual.RealRate) {
+		problems = append(problems, fmt.Sprintf("real_rate=%s, expected %s", actual.RealRate, expected.RealRate))
+	}
+	if expected.MinExchangeAmount != nil && !sameLimit(*expected.MinExchangeAmount, actual.MinExchangeAmount) {
+		problems = append(problems, fmt.Sprintf("min_exchange_amount=%s, expected %s", formatLimit(actual.MinExchangeAmount), expected.MinExchangeAmount))
+	}
+	if expected.MaxExchangeAmount != nil && !sameLimit(*expected.MaxExchangeAmount, actual.MaxExchangeAmount) {
+		problems = append(problems, fmt.Sprintf("max_exchange_amount=%s, expected %s", formatLimit(actual.MaxExchangeAmount), expected.MaxExchangeAmount))
+	}
+	return strings.Join(problems, "; ")
+}
+
+func sameNativeRate(value string, shift int16, otherValue string, otherShift int16) bool {
+	first, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		return false
+	}
+	second, ok := new(big.Int).SetString(otherValue, 10)
+	if !ok {
+		return false
+	}
+	// value·10^-shift == otherValue·10^-otherShift
+	exponent := int64(otherShift) - int64(shift)
+	power := new(big.Int).Exp(big.NewInt(10), big.NewInt(absInt64(exponent)), nil)
+	if exponent >= 0 {
+		first.Mul(first, power)
+	} else {
+		second.Mul(second, power)
+	}
+	return first.Cmp(second) == 0
+}
+
+func absInt64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func sameLimit(expected Amount, actual *Amount) bool {
+	return actual != nil && expected.Equal(*actual)
+}
+
+func formatLimit(limit *Amount) string {
+	if limit == nil {
+		return "none"
+	}
+	return limit.String()
+}
+
+func sameDecimal(expected, actual string) bool {
+	first, ok := new(big.Rat).SetString(expected)
+	if !ok {
+		return expected == actual
+	}
+	second, ok := new(big.Rat).SetString(actual)
+	return ok && first.Cmp(second) == 0
+}
+
+// checkRatesList checks that the rates list holds exactly the stored rates, each pair once.
+func (n *Node) checkRatesList(stored map[ratePair]*RateItem) ([]string, error) {
+	status, body, err := n.apiRequest(http.MethodGet, "/api/v1/node/rates/", nil)
+	if err != nil || status != http.StatusOK {
+		return nil, fmt.Errorf("Node %s: failed to list exchange rates: status %d, %v %s", n.Alias, status, err, body)
+	}
+	var result struct {
+		Data RatesListResponse `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(body), &result); err != nil {
+		return nil, fmt.Errorf("Node %s: failed to decode rates list: %v", n.Alias, err)
+	}
+
+	var problems []string
+	if result.Data.Count != len(result.Data.Rates) {
+		problems = append(problems, fmt.Sprintf("count %d, but %d rates listed", result.Data.Count, len(result.Data.Rates)))
+	}
+	listed := make(map[ratePair]bool)
+	for i := range result.Data.Rates {
+		item := &result.Data.Rates[i]
+		pair := ratePair{item.EquivalentFrom, item.EquivalentTo}
+		if listed[pair] {
+			problems = append(problems, fmt.Sprintf("pair %s -> %s is listed more than once", pair.from, pair.to))
+		}
+		listed[pair] = true
+		expected, ok := stored[pair]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unexpected rate %s -> %s", pair.from, pair.to))
+			continue
+		}
+		if problem := compareRateItems(expected, item); problem != "" {
+			problems = append(problems, fmt.Sprintf("rate %s -> %s differs from GET: %s", pair.from, pair.to, problem))
+		}
+	}
+	for pair := range stored {
+		if !listed[pair] {
+			problems = append(problems, fmt.Sprintf("rate %s -> %s is missing", pair.from, pair.to))
+		}
+	}
+	sort.Strings(problems)
+	return problems, nil
+}
+
+// fetchExchangeRate reads the rate of the pair, nil if the node has no such rate.
+func (n *Node) fetchExchangeRate(from, to string) (*RateItem, int, string, error) {
+	status, body, err := n.apiRequest(http.MethodGet, fmt.Sprintf("/api/v1/node/rates/%s/%s/", from, to), nil)
+	if err != nil {
+		return nil, status, body, err
+	}
+	if status != http.StatusOK {
+		if status >= 500 {
+			return nil, status, body, fmt.Errorf("server error %d", status)
+		}
+		return nil, status, body, nil
+	}
+	var result struct {
+		Data struct {
+			Rate RateItem `json:"rate"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(body), &result); err != nil {
+		return nil, status, body, fmt.Errorf("failed to decode rate: %v", err)
+	}
+	return &result.Data.Rate, status, body, nil
+}
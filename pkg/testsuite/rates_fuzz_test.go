@@ -0,0 +1,177 @@
+package testsuite
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestMalformedNumber(t *testing.T) {
+	tests := []struct {
+		input     string
+		malformed bool
+	}{
+		{input: "1", malformed: false},
+		{input: "-1.5", malformed: false},
+		// Lenient forms are left to the parsers
+		{input: "1.", malformed: false},
+		{input: "+1", malformed: false},
+		{input: "1e5", malformed: false},
+		{input: "0x10", malformed: false},
+		{input: " 1", malformed: false},
+		{input: "", malformed: true},
+		{input: "-", malformed: true},
+		{input: "abc", malformed: true},
+		{input: "1.2.3", malformed: true},
+		{input: "1,5", malformed: true},
+		{input: "1;DROP", malformed: true},
+	}
+
+	for _, test := range tests {
+		if malformed := malformedNumber(test.input); malformed != test.malformed {
+			t.Errorf("%q: expected malformed=%v, got %v", test.input, test.malformed, malformed)
+		}
+	}
+}
+
+func TestClassifyRateParams(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     string
+		to       string
+		params   url.Values
+		expected RateFuzzExpectation
+	}{
+		// Valid
+		{name: "native", from: "1001", to: "2002", params: nativeParams("15", "1"), expected: RateFuzzAccepted},
+		{name: "negative shift", from: "1001", to: "2002", params: nativeParams("15", "-32768"), expected: RateFuzzAccepted},
+		{name: "real", from: "1001", to: "2002", params: realParams("112071.54"), expected: RateFuzzAccepted},
+		{name: "limits", from: "1001", to: "2002", params: withLimits(nativeParams("1", "0"), "10", "10"), expected: RateFuzzAccepted},
+
+		// Out of range
+		{name: "zero value", from: "1001", to: "2002", params: nativeParams("0", "0"), expected: RateFuzzAnyStatus},
+		{name: "negative value", from: "1001", to: "2002", params: nativeParams("-1", "0"), expected: RateFuzzAnyStatus},
+		{name: "value above uint64", from: "1001", to: "2002", params: nativeParams("18446744073709551616", "0"), expected: RateFuzzAnyStatus},
+		{name: "shift above int16", from: "1001", to: "2002", params: nativeParams("1", "32768"), expected: RateFuzzAnyStatus},
+		{name: "negative limit", from: "1001", to: "2002", params: withLimits(nativeParams("1", "0"), "-1", ""), expected: RateFuzzAnyStatus},
+		{name: "min above max", from: "1001", to: "2002", params: withLimits(nativeParams("1", "0"), "11", "10"), expected: RateFuzzAnyStatus},
+		{name: "zero real rate", from: "1001", to: "2002", params: realParams("0"), expected: RateFuzzAnyStatus},
+		{name: "real rate too precise", from: "1001", to: "2002", params: realParams("0.12345678901234567"), expected: RateFuzzRejected},
+
+		// Malformed
+		{name: "malformed value", from: "1001", to: "2002", params: nativeParams("abc", "0"), expected: RateFuzzRejected},
+		{name: "malformed shift", from: "1001", to: "2002", params: nativeParams("1", "x"), expected: RateFuzzRejected},
+		{name: "malformed limit", from: "1001", to: "2002", params: withLimits(nativeParams("1", "0"), "1.2.3", ""), expected: RateFuzzRejected},
+		{name: "lenient value", from: "1001", to: "2002", params: nativeParams("1e5", "0"), expected: RateFuzzAnyStatus},
+
+		// Native pair: value and shift come together, instead of real_rate
+		{name: "value without shift", from: "1001", to: "2002", params: url.Values{"value": {"1"}}, expected: RateFuzzRejected},
+		{name: "shift without value", from: "1001", to: "2002", params: url.Values{"shift": {"1"}}, expected: RateFuzzRejected},
+		{name: "both forms", from: "1001", to: "2002", params: url.Values{"real_rate": {"1"}, "value": {"1"}, "shift": {"0"}}, expected: RateFuzzRejected},
+		{name: "no rate", from: "1001", to: "2002", params: url.Values{}, expected: RateFuzzRejected},
+
+		// Equivalents: one into itself, or one the suite doesn't know
+		{name: "same equivalent", from: "1001", to: "1001", params: nativeParams("1", "0"), expected: RateFuzzAnyStatus},
+		{name: "unknown equivalent", from: "1001", to: "9999", params: realParams("1.5"), expected: RateFuzzAnyStatus},
+	}
+
+	for _, test := range tests {
+		if expectation := ClassifyRateParams(test.from, test.to, test.params); expectation != test.expected {
+			t.Errorf("%s (%s): expected %s, got %s", test.name, test.params.Encode(), test.expected, expectation)
+		}
+	}
+}
+
+func TestExpectedRateItem(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   url.Values
+		expected RateItem
+		// Limits as formatted by formatLimit
+		min, max string
+		problem  bool
+	}{
+		{
+			name:     "native",
+			params:   nativeParams("11207154", "4"),
+			expected: RateItem{Value: "11207154", Shift: 4, RealRate: "112071.54"},
+			min:      "none",
+			max:      "none",
+		},
+		{
+			name:     "real",
+			params:   realParams("112071.54"),
+			expected: RateItem{Value: "11207154", Shift: 4, RealRate: "112071.54"},
+			min:      "none",
+			max:      "none",
+		},
+		{
+			// "1e5" can't be predicted and is not compared
+			name:     "limits",
+			params:   withLimits(nativeParams("1", "0"), "10", "1e5"),
+			expected: RateItem{Value: "1", RealRate: "100"},
+			min:      "10",
+			max:      "none",
+		},
+		{
+			name:     "negative real rate",
+			params:   realParams("-1"),
+			expected: RateItem{RealRate: "-1"},
+			min:      "none",
+			max:      "none",
+		},
+		{name: "shift out of int16", params: nativeParams("1", "40000"), problem: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			item, problem := expectedRateItem(NewRateFuzzCase(test.name, "1001", "2002", test.params))
+			if test.problem {
+				if problem == "" {
+					t.Fatalf("expected a problem, got %+v", item)
+				}
+				return
+			}
+			if problem != "" {
+				t.Fatalf("unexpected problem: %s", problem)
+			}
+			test.expected.EquivalentFrom, test.expected.EquivalentTo = "1001", "2002"
+			if mismatch := compareRateItems(&test.expected, item); mismatch != "" {
+				t.Errorf("expected rate mismatch: %s", mismatch)
+			}
+			if min, max := formatLimit(item.MinExchangeAmount), formatLimit(item.MaxExchangeAmount); min != test.min || max != test.max {
+				t.Errorf("limits mismatch.\nExpected: %s, %s\nGot:      %s, %s", test.min, test.max, min, max)
+			}
+			if item.Value != test.expected.Value || item.RealRate != test.expected.RealRate {
+				t.Errorf("rate mismatch.\nExpected: value=%s real_rate=%s\nGot:      value=%s real_rate=%s",
+					test.expected.Value, test.expected.RealRate, item.Value, item.RealRate)
+			}
+		})
+	}
+}
+
+func TestSameNativeRate(t *testing.T) {
+	tests := []struct {
+		value      string
+		shift      int16
+		otherValue string
+		otherShift int16
+		same       bool
+	}{
+		{value: "15", shift: 1, otherValue: "15", otherShift: 1, same: true},
+		{value: "15", shift: 1, otherValue: "150", otherShift: 2, same: true},
+		{value: "1500", shift: 3, otherValue: "15", otherShift: 1, same: true},
+		{value: "2", shift: -1, otherValue: "20", otherShift: 0, same: true},
+		{value: "0", shift: 5, otherValue: "0", otherShift: -3, same: true},
+		{value: "15", shift: 1, otherValue: "15", otherShift: 2, same: false},
+		{value: "15", shift: 1, otherValue: "16", otherShift: 1, same: false},
+		{value: "abc", shift: 0, otherValue: "abc", otherShift: 0, same: false},
+		{value: "1", shift: 0, otherValue: "", otherShift: 0, same: false},
+	}
+
+	for _, test := range tests {
+		if same := sameNativeRate(test.value, test.shift, test.otherValue, test.otherShift); same != test.same {
+			t.Errorf("%s,%d vs %s,%d: expected %v, got %v",
+				test.value, test.shift, test.otherValue, test.otherShift, test.same, same)
+		}
+	}
+}
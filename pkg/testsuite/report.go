@@ -0,0 +1,443 @@
+package testsuite
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Test reporting.
+//
+// When ClusterSettings.ReportDir is set, every cluster keeps track of what happened during the test
+// (nodes, network conditions, testing flags, collected artifacts). When the test finishes, an entry is
+// appended to <ReportDir>/<subsystem>.json and <ReportDir>/<subsystem>.junit.xml, where subsystem is the
+// name of the tests/ sub-directory the test belongs to (payment, settlement_lines, max_flow, ...).
+
+const (
+	TestStatusPassed  = "passed"
+	TestStatusFailed  = "failed"
+	TestStatusSkipped = "skipped"
+
+	TestingFlagKindPayment        = "payment"         // Set via SetTestingFlag
+	TestingFlagKindSettlementLine = "settlement_line" // Set via SetTestingSLFlag
+
+	reportArtifactsDirName = "artifacts"
+	// maxFailureOutputLines is the number of the last output lines of a failed test kept in its report entry.
+	maxFailureOutputLines = 200
+)
+
+// TestingFlagRecord describes a testing flag applied to a node.
+type TestingFlagRecord struct {
+	Kind      string    `json:"kind"`
+	Flag      uint64    `json:"flag"`
+	Params    []string  `json:"params,omitempty"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// NetworkConditionsRecord describes network conditions applied to (or removed from) a node.
+type NetworkConditionsRecord struct {
+	NodeAlias  string             `json:"node_alias"`
+	Conditions *NetworkConditions `json:"conditions"` // nil means the conditions were removed
+	AppliedAt  time.Time          `json:"applied_at"`
+}
+
+// NodeReport holds per-node data of a test report entry.
+type NodeReport struct {
+	Alias             string                    `json:"alias"`
+	IPAddress         string                    `json:"ip_address"`
+	ContainerID       string                    `json:"container_id"`
+	Image             string                    `json:"image"`
+	NetworkConditions []NetworkConditionsRecord `json:"network_conditions,omitempty"`
+	TestingFlags      []TestingFlagRecord       `json:"testing_flags,omitempty"`
+	// Artifacts are paths relative to the report directory.
+	Artifacts []string `json:"artifacts,omitempty"`
+}
+
+// TestReportEntry is the report of a single test.
+type TestReportEntry struct {
+	Name        string       `json:"name"`
+	Subsystem   string       `json:"subsystem"`
+	Status      string       `json:"status"`
+	StartedAt   time.Time    `json:"started_at"`
+	DurationSec float64      `json:"duration_sec"`
+	NetworkName string       `json:"network_name"`
+	Nodes       []NodeReport `json:"nodes"`
+	// Timeline is the path of the HTML timeline of the test, relative to the report directory.
+	Timeline string `json:"timeline,omitempty"`
+	// FailureOutput is the output of the failed test (its t.Log/t.Error lines), set by vtcp-suite from
+	// the go test output, see AddFailureOutputToReport.
+	FailureOutput []string `json:"failure_output,omitempty"`
+	// Flakiness is set by the rerun mode of vtcp-suite, see AddFlakinessToReport.
+	Flakiness *TestFlakiness `json:"flakiness,omitempty"`
+}
+
+// TestReport is the content of <ReportDir>/<subsystem>.json.
+type TestReport struct {
+	Subsystem string            `json:"subsystem"`
+	Tests     []TestReportEntry `json:"tests"`
+}
+
+var (
+	// Each test package runs in its own process and has its own subsystem,
+	// so the reports are only shared between tests of the same package.
+	reportsMu sync.Mutex
+	reports   = make(map[string]*TestReport)
+)
+
+func newTestReportEntry(t *testing.T) *TestReportEntry {
+	return &TestReportEntry{
+		Name:      t.Name(),
+		Subsystem: testSubsystem(),
+		StartedAt: time.Now(),
+	}
+}
+
+// testSubsystem returns the directory name of the first *_test.go file found in the call stack.
+func testSubsystem() string {
+	pcs := make([]uintptr, 32)
+	count := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:count])
+	for {
+		frame, more := frames.Next()
+		if strings.HasSuffix(frame.File, "_test.go") {
+			return filepath.Base(filepath.Dir(frame.File))
+		}
+		if !more {
+			break
+		}
+	}
+	return "unknown"
+}
+
+// sanitizeFileName makes a test name usable as a path element.
+func sanitizeFileName(name string) string {
+	return strings.NewReplacer("/", "_", " ", "_", ":", "_").Replace(name)
+}
+
+func (n *Node) recordTestingFlag(kind string, flag uint64, params ...string) {
+	n.testingFlags = append(n.testingFlags, TestingFlagRecord{
+		Kind:      kind,
+		Flag:      flag,
+		Params:    params,
+		AppliedAt: time.Now(),
+	})
+}
+
+func (c *Cluster) recordNetworkConditions(node *Node, conditions *NetworkConditions) {
+	var conditionsCopy *NetworkConditions
+	if conditions != nil {
+		copied := *conditions
+		conditionsCopy = &copied
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.networkConditions = append(c.networkConditions, NetworkConditionsRecord{
+		NodeAlias:  node.Alias,
+		Conditions: conditionsCopy,
+		AppliedAt:  time.Now(),
+	})
+}
+
+// collectNodeArtifacts copies the node's logs and configuration into the report directory.
+// Errors are logged only: missing artifacts must not fail the test. Every copy has the step deadline.
+func (c *Cluster) collectNodeArtifacts(ctx context.Context, t *testing.T, node *Node, valgrind bool) {
+	if c.settings.ReportDir == "" || node.ContainerID == "" {
+		return
+	}
+
+	relativeDir := filepath.Join(reportArtifactsDirName, sanitizeFileName(t.Name()), node.Alias)
+	targetDir := filepath.Join(c.settings.ReportDir, relativeDir)
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		t.Logf("Node %s: failed to create artifacts directory %s: %v", node.Alias, targetDir, err)
+		return
+	}
+
+	sources := []string{DefaultOperationsLogPath, "/vtcp/vtcpd/conf.json"}
+	if valgrind {
+		sources = append(sources, "/vtcp/valgrind.log")
+	}
+
+	for _, source := range sources {
+		fileName := filepath.Base(source)
+		stepCtx, cancel := c.stepContext(ctx)
+		cmd := exec.CommandContext(stepCtx, "docker", "cp", fmt.Sprintf("%s:%s", node.ContainerID, source), filepath.Join(targetDir, fileName))
+		output, err := cmd.CombinedOutput()
+		err = c.stepTimeoutError(stepCtx, node, "cp "+source, err)
+		cancel()
+		if err != nil {
+			t.Logf("Node %s: failed to collect artifact %s: %v. Output: %s", node.Alias, source, err, strings.TrimSpace(string(output)))
+			continue
+		}
+		node.artifacts = append(node.artifacts, filepath.Join(relativeDir, fileName))
+	}
+}
+
+// writeReport finalizes the test entry and rewrites the subsystem report files.
+func (c *Cluster) writeReport(t *testing.T) {
+	if c.settings.ReportDir == "" {
+		return
+	}
+
+	entry := *c.report
+	entry.DurationSec = time.Since(entry.StartedAt).Seconds()
+	entry.NetworkName = c.settings.NetworkName
+	switch {
+	case t.Skipped():
+		entry.Status = TestStatusSkipped
+	case t.Failed():
+		entry.Status = TestStatusFailed
+	default:
+		entry.Status = TestStatusPassed
+	}
+	defer c.endTestSpan(t, entry.Status)
+	entry.Timeline = c.writeTestTimeline(t, entry.Status)
+
+	c.mu.Lock()
+	for _, node := range c.nodes {
+		nodeReport := NodeReport{
+			Alias:        node.Alias,
+			IPAddress:    node.IPAddress,
+			ContainerID:  node.ContainerID,
+			Image:        c.nodeImage(node),
+			TestingFlags: node.testingFlags,
+			Artifacts:    node.artifacts,
+		}
+		for _, record := range c.networkConditions {
+			if record.NodeAlias == node.Alias {
+				nodeReport.NetworkConditions = append(nodeReport.NetworkConditions, record)
+			}
+		}
+		entry.Nodes = append(entry.Nodes, nodeReport)
+	}
+	c.mu.Unlock()
+
+	reportsMu.Lock()
+	defer reportsMu.Unlock()
+
+	report, ok := reports[entry.Subsystem]
+	if !ok {
+		report = &TestReport{Subsystem: entry.Subsystem}
+		reports[entry.Subsystem] = report
+	}
+	report.Tests = append(report.Tests, entry)
+
+	if err := writeTestReportFiles(c.settings.ReportDir, report); err != nil {
+		t.Logf("failed to write test report: %v", err)
+	}
+}
+
+// readTestReport reads the subsystem report of reportDir, a missing report is an empty one.
+func readTestReport(reportDir, subsystem string) (*TestReport, error) {
+	report := &TestReport{Subsystem: subsystem}
+	jsonPath := filepath.Join(reportDir, subsystem+".json")
+	data, err := os.ReadFile(jsonPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read report %s: %w", jsonPath, err)
+	default:
+		if err := json.Unmarshal(data, report); err != nil {
+			return nil, fmt.Errorf("failed to parse report %s: %w", jsonPath, err)
+		}
+	}
+	return report, nil
+}
+
+// AddFailureOutputToReport sets the output of the failed tests, by name, in the subsystem report of reportDir and
+// rewrites its JSON and JUnit files. The go test output is only available outside of the test process,
+// the report entries are written before it is. Top-level tests that have no entry in the report (e.g. they failed
+// before their cluster started) get one.
+func AddFailureOutputToReport(reportDir, subsystem string, output map[string][]string) error {
+	report, err := readTestReport(reportDir, subsystem)
+	if err != nil {
+		return err
+	}
+
+	found := make(map[string]bool)
+	for i := range report.Tests {
+		entry := &report.Tests[i]
+		if lines, ok := output[entry.Name]; ok {
+			entry.Status = TestStatusFailed
+			entry.FailureOutput = lastLines(lines, maxFailureOutputLines)
+			found[entry.Name] = true
+		}
+	}
+	names := make([]string, 0, len(output))
+	for name := range output {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if found[name] || strings.Contains(name, "/") {
+			continue
+		}
+		report.Tests = append(report.Tests, TestReportEntry{
+			Name:          name,
+			Subsystem:     subsystem,
+			Status:        TestStatusFailed,
+			FailureOutput: lastLines(output[name], maxFailureOutputLines),
+		})
+	}
+
+	return writeTestReportFiles(reportDir, report)
+}
+
+func lastLines(lines []string, count int) []string {
+	if len(lines) > count {
+		return lines[len(lines)-count:]
+	}
+	return lines
+}
+
+func writeTestReportFiles(reportDir string, report *TestReport) error {
+	if err := os.MkdirAll(reportDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create report directory %s: %w", reportDir, err)
+	}
+
+	jsonData, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON report: %w", err)
+	}
+	jsonPath := filepath.Join(reportDir, report.Subsystem+".json")
+	if err := os.WriteFile(jsonPath, jsonData, 0o644); err != nil {
+		return fmt.Errorf("failed to write JSON report %s: %w", jsonPath, err)
+	}
+
+	xmlData, err := xml.MarshalIndent(newJUnitTestSuites(report), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JUnit report: %w", err)
+	}
+	xmlPath := filepath.Join(reportDir, report.Subsystem+".junit.xml")
+	if err := os.WriteFile(xmlPath, append([]byte(xml.Header), xmlData...), 0o644); err != nil {
+		return fmt.Errorf("failed to write JUnit report %s: %w", xmlPath, err)
+	}
+	return nil
+}
+
+// JUnit XML representation. Per-node data is exposed as test case properties,
+// so that it can be ingested by tools that do not read the JSON report.
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name       string          `xml:"name,attr"`
+	Classname  string          `xml:"classname,attr"`
+	Time       string          `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Failure    *junitMessage   `xml:"failure,omitempty"`
+	Skipped    *junitMessage   `xml:"skipped,omitempty"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+func newJUnitTestSuites(report *TestReport) junitTestSuites {
+	suite := junitTestSuite{Name: report.Subsystem}
+	totalTime := 0.0
+	for _, entry := range report.Tests {
+		testCase := junitTestCase{
+			Name:       entry.Name,
+			Classname:  report.Subsystem,
+			Time:       fmt.Sprintf("%.3f", entry.DurationSec),
+			Properties: junitPropertiesFor(entry),
+		}
+		switch entry.Status {
+		case TestStatusFailed:
+			suite.Failures++
+			message := "test failed, see node artifacts"
+			if count := len(entry.FailureOutput); count > 0 {
+				// The failure that stopped the test is usually the last line it logged.
+				message = entry.FailureOutput[count-1]
+			}
+			if entry.Flakiness != nil {
+				message = fmt.Sprintf("test failed, %s over %d attempts: %s",
+					entry.Flakiness.Classification, len(entry.Flakiness.Attempts), message)
+			}
+			testCase.Failure = &junitMessage{Message: message, Body: strings.Join(entry.FailureOutput, "\n")}
+		case TestStatusSkipped:
+			suite.Skipped++
+			testCase.Skipped = &junitMessage{Message: "test skipped"}
+		}
+		suite.Tests++
+		totalTime += entry.DurationSec
+		suite.Cases = append(suite.Cases, testCase)
+	}
+	suite.Time = fmt.Sprintf("%.3f", totalTime)
+	return junitTestSuites{Suites: []junitTestSuite{suite}}
+}
+
+func junitPropertiesFor(entry TestReportEntry) []junitProperty {
+	properties := []junitProperty{
+		{Name: "subsystem", Value: entry.Subsystem},
+		{Name: "network", Value: entry.NetworkName},
+	}
+	if entry.Timeline != "" {
+		properties = append(properties, junitProperty{Name: "timeline", Value: entry.Timeline})
+	}
+	if entry.Flakiness != nil {
+		properties = append(properties, junitProperty{Name: "flakiness", Value: entry.Flakiness.Classification})
+		for i, attempt := range entry.Flakiness.Attempts {
+			value := attempt.Status
+			if attempt.ReportDir != "" {
+				value += " " + attempt.ReportDir
+			}
+			properties = append(properties, junitProperty{Name: fmt.Sprintf("flakiness.attempt.%d", i), Value: value})
+		}
+	}
+	for _, node := range entry.Nodes {
+		prefix := "node." + node.Alias
+		properties = append(properties,
+			junitProperty{Name: prefix + ".ip_address", Value: node.IPAddress},
+			junitProperty{Name: prefix + ".container_id", Value: node.ContainerID},
+			junitProperty{Name: prefix + ".image", Value: node.Image},
+		)
+		for i, record := range node.NetworkConditions {
+			value := "removed"
+			if record.Conditions != nil {
+				value = fmt.Sprintf("%+v", *record.Conditions)
+			}
+			properties = append(properties, junitProperty{Name: fmt.Sprintf("%s.network_conditions.%d", prefix, i), Value: value})
+		}
+		for i, flag := range node.TestingFlags {
+			properties = append(properties, junitProperty{
+				Name:  fmt.Sprintf("%s.testing_flag.%d", prefix, i),
+				Value: fmt.Sprintf("%s:%d %s", flag.Kind, flag.Flag, strings.Join(flag.Params, ",")),
+			})
+		}
+		for i, artifact := range node.Artifacts {
+			properties = append(properties, junitProperty{Name: fmt.Sprintf("%s.artifact.%d", prefix, i), Value: artifact})
+		}
+	}
+	return properties
+}
@@ -0,0 +1,342 @@
+package testsuite
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// vtcpd restart.
+//
+// vtcpd runs under vtcpd-cli, which respawns it when the process exits. A restart stops the process,
+// waits for the CLI to start a new one and for the new process to serve API requests.
+
+const (
+	DefaultRestartShutdownTimeout = 10 * time.Second
+	DefaultRestartReadyTimeout    = 30 * time.Second
+	DefaultRestartLogLines        = 50
+)
+
+// ShutdownMode is how the vtcpd process is stopped.
+type ShutdownMode int
+
+const (
+	// ShutdownGraceful sends SIGTERM and falls back to SIGKILL when the process outlives the shutdown timeout.
+	ShutdownGraceful ShutdownMode = iota
+	// ShutdownForced sends SIGKILL.
+	ShutdownForced
+)
+
+// RestartOptions configure Node.Restart. Zero values mean defaults.
+type RestartOptions struct {
+	Shutdown        ShutdownMode
+	ShutdownTimeout time.Duration
+	ReadyTimeout    time.Duration
+	LogLines        int // last lines of operations.log kept from the old process
+}
+
+func (o RestartOptions) withDefaults() RestartOptions {
+	if o.ShutdownTimeout <= 0 {
+		o.ShutdownTimeout = DefaultRestartShutdownTimeout
+	}
+	if o.ReadyTimeout <= 0 {
+		o.ReadyTimeout = DefaultRestartReadyTimeout
+	}
+	if o.LogLines <= 0 {
+		o.LogLines = DefaultRestartLogLines
+	}
+	return o
+}
+
+// RestartResult describes what happened to the old process and how long the restart took.
+type RestartResult struct {
+	WasRunning bool     // false if no vtcpd process was found, the restart then only waits for readiness
+	OldPIDs    []string // processes that were stopped
+	NewPIDs    []string
+	Signal     string // last signal sent to the old process: TERM or KILL
+	Escalated  bool   // the graceful shutdown timed out and the process was killed
+
+	// ExitCode of the old process as reported by vtcpd-cli in the container output, -1 if it wasn't reported.
+	ExitCode int
+	// ExitStatus is the container output line the exit code was taken from.
+	ExitStatus string
+	// LastLogLines are the last lines of operations.log written by the old process.
+	LastLogLines []string
+
+	StoppedAfter time.Duration
+	ReadyAfter   time.Duration
+}
+
+func (r *RestartResult) String() string {
+	if !r.WasRunning {
+		return fmt.Sprintf("no vtcpd process was running, ready after %v", r.ReadyAfter)
+	}
+	exitCode := "unknown"
+	if r.ExitCode >= 0 {
+		exitCode = strconv.Itoa(r.ExitCode)
+	}
+	return fmt.Sprintf("pids %v stopped by SIG%s after %v (exit code %s), new pids %v ready after %v",
+		r.OldPIDs, r.Signal, r.StoppedAfter, exitCode, r.NewPIDs, r.ReadyAfter)
+}
+
+// RestartNode stops the vtcpd process, allowing CLI to restart it with new configuration,
+// and waits for the new process to be ready.
+func (n *Node) RestartNode() error {
+	_, err := n.Restart(RestartOptions{})
+	return err
+}
+
+// RestartAndCheck restarts vtcpd, logs what happened to the old process and fails the test if the node
+// doesn't become ready.
+func (n *Node) RestartAndCheck(t *testing.T, options RestartOptions) *RestartResult {
+	result, err := n.Restart(options)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	t.Logf("Node %s: vtcpd restarted: %s", n.Alias, result)
+	return result
+}
+
+// Restart is RestartCtx under the context the node was run with.
+func (n *Node) Restart(options RestartOptions) (*RestartResult, error) {
+	return n.RestartCtx(n.runContext(), options)
+}
+
+// RestartCtx stops the vtcpd process and waits until the respawned one answers API requests, until ctx is done.
+// It doesn't fail when no process is running, the node is then only waited for.
+func (n *Node) RestartCtx(ctx context.Context, options RestartOptions) (*RestartResult, error) {
+	if n.ContainerID == "" {
+		return nil, fmt.Errorf("Node %s: ContainerID is not set, cannot execute commands", n.Alias)
+	}
+	options = options.withDefaults()
+
+	result := &RestartResult{ExitCode: -1}
+	started := time.Now()
+
+	oldPIDs, err := n.vtcpdPIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result.OldPIDs = oldPIDs
+	result.WasRunning = len(oldPIDs) > 0
+
+	if result.WasRunning {
+		if err := n.stopVtcpd(ctx, options, result); err != nil {
+			return result, err
+		}
+		result.StoppedAfter = time.Since(started)
+		result.LastLogLines = n.lastLogLines(ctx, options.LogLines)
+		result.ExitStatus, result.ExitCode = n.vtcpdExitStatus(ctx, started)
+	}
+
+	if err := n.waitForRespawn(ctx, oldPIDs, options.ReadyTimeout, result); err != nil {
+		return result, err
+	}
+	result.ReadyAfter = time.Since(started)
+
+	// Testing flags live in the process memory only
+	if n.Testing != nil {
+		n.Testing.setCurrent(TestingFlagsState{})
+	}
+	return result, nil
+}
+
+// vtcpdPIDs returns the pids of the running vtcpd processes.
+func (n *Node) vtcpdPIDs(ctx context.Context) ([]string, error) {
+	ctx, cancel := n.stepContext(ctx)
+	defer cancel()
+	output, err := n.dockerCombinedOutput(ctx, exec.CommandContext(ctx, "docker", "exec", n.ContainerID, "pgrep", "vtcpd"))
+	if err != nil {
+		// pgrep exits with 1 when nothing matches
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("Node %s: failed to list vtcpd processes: %v. Output: %s", n.Alias, err, string(output))
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// runningPIDs returns the pids that are still running.
+func (n *Node) runningPIDs(ctx context.Context, pids []string) []string {
+	current, err := n.vtcpdPIDs(ctx)
+	if err != nil {
+		return pids
+	}
+	var running []string
+	for _, pid := range pids {
+		if slices.Contains(current, pid) {
+			running = append(running, pid)
+		}
+	}
+	return running
+}
+
+func (n *Node) signalVtcpd(ctx context.Context, signal string, pids []string) error {
+	stepCtx, cancel := n.stepContext(ctx)
+	defer cancel()
+	args := append([]string{"exec", n.ContainerID, "kill", "-" + signal}, pids...)
+	output, err := n.dockerCombinedOutput(stepCtx, exec.CommandContext(stepCtx, "docker", args...))
+	// The process may exit between listing and signaling it, which is not an error
+	if err != nil && len(n.runningPIDs(ctx, pids)) > 0 {
+		return fmt.Errorf("Node %s: failed to send SIG%s to vtcpd %v: %v. Output: %s", n.Alias, signal, pids, err, string(output))
+	}
+	return nil
+}
+
+// waitForExit polls until none of the pids is running.
+func (n *Node) waitForExit(ctx context.Context, pids []string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if len(n.runningPIDs(ctx, pids)) == 0 {
+			return true
+		}
+		if time.Now().After(deadline) || ctx.Err() != nil {
+			return false
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+func (n *Node) stopVtcpd(ctx context.Context, options RestartOptions, result *RestartResult) error {
+	result.Signal = "KILL"
+	if options.Shutdown == ShutdownGraceful {
+		result.Signal = "TERM"
+	}
+	if err := n.signalVtcpd(ctx, result.Signal, result.OldPIDs); err != nil {
+		return err
+	}
+	if n.waitForExit(ctx, result.OldPIDs, options.ShutdownTimeout) {
+		return nil
+	}
+	if options.Shutdown == ShutdownForced {
+		return fmt.Errorf("Node %s: vtcpd %v is still running %v after SIGKILL", n.Alias, result.OldPIDs, options.ShutdownTimeout)
+	}
+
+	result.Signal, result.Escalated = "KILL", true
+	running := n.runningPIDs(ctx, result.OldPIDs)
+	if err := n.signalVtcpd(ctx, result.Signal, running); err != nil {
+		return err
+	}
+	if !n.waitForExit(ctx, running, options.ShutdownTimeout) {
+		return fmt.Errorf("Node %s: vtcpd %v is still running %v after SIGKILL", n.Alias, running, options.ShutdownTimeout)
+	}
+	return nil
+}
+
+// lastLogLines returns the tail of operations.log. It is read right after the old process has exited,
+// before the respawned one gets to write much.
+func (n *Node) lastLogLines(ctx context.Context, count int) []string {
+	ctx, cancel := n.stepContext(ctx)
+	defer cancel()
+	output, err := n.dockerOutput(ctx, exec.CommandContext(ctx, "docker", "exec", n.ContainerID, "tail", "-n", strconv.Itoa(count), DefaultOperationsLogPath))
+	if err != nil {
+		return nil
+	}
+	return strings.Split(strings.TrimRight(string(output), "\n"), "\n")
+}
+
+// vtcpdExitStatusPattern matches the way a Go process reports the exit of a child ("exit status 1", "signal: killed").
+var vtcpdExitStatusPattern = regexp.MustCompile(`exit status (\d+)|signal: (\w+)`)
+
+// signalExitCodes are the shell-style exit codes of processes terminated by a signal.
+var signalExitCodes = map[string]int{"killed": 137, "terminated": 143, "interrupt": 130, "aborted": 134}
+
+// vtcpdExitStatus looks for the exit of vtcpd reported by vtcpd-cli in the container output since the moment given.
+func (n *Node) vtcpdExitStatus(ctx context.Context, since time.Time) (string, int) {
+	ctx, cancel := n.stepContext(ctx)
+	defer cancel()
+	output, err := n.dockerCombinedOutput(ctx, exec.CommandContext(ctx, "docker", "logs", "--since", since.Format(time.RFC3339Nano), n.ContainerID))
+	if err != nil {
+		return "", -1
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		match := vtcpdExitStatusPattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		if match[1] != "" {
+			code, _ := strconv.Atoi(match[1])
+			return strings.TrimSpace(line), code
+		}
+		if code, ok := signalExitCodes[match[2]]; ok {
+			return strings.TrimSpace(line), code
+		}
+		return strings.TrimSpace(line), -1
+	}
+	return "", -1
+}
+
+// CheckAlive is CheckAliveCtx under the context the node was run with.
+func (n *Node) CheckAlive() error {
+	return n.CheckAliveCtx(n.runContext())
+}
+
+// CheckAliveCtx checks that vtcpd is running and answers API requests.
+func (n *Node) CheckAliveCtx(ctx context.Context) error {
+	pids, err := n.vtcpdPIDs(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pids) == 0 {
+		return fmt.Errorf("Node %s: no vtcpd process", n.Alias)
+	}
+	resp, err := n.doAPIRequest(ctx, http.MethodGet, fmt.Sprintf("http://%s:%d/api/v1/node/contractors/", n.IPAddress, n.CLIPort))
+	if err != nil {
+		return fmt.Errorf("Node %s: API request failed: %v", n.Alias, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Node %s: API responded with status %d", n.Alias, resp.StatusCode)
+	}
+	return nil
+}
+
+// waitForRespawn waits for a vtcpd process other than the old ones and for the API to answer with 200.
+// Any response is not enough: vtcpd-cli keeps serving while vtcpd is down, and the contractors list
+// needs both vtcpd and its storage to be up. Waiting stops when ctx is done.
+func (n *Node) waitForRespawn(ctx context.Context, oldPIDs []string, timeout time.Duration, result *RestartResult) error {
+	readinessURL := fmt.Sprintf("http://%s:%d/api/v1/node/contractors/", n.IPAddress, n.CLIPort)
+	readinessRequest, err := http.NewRequestWithContext(ctx, http.MethodGet, readinessURL, nil)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Transport: apiClient.Transport, Timeout: 2 * time.Second}
+	deadline := time.Now().Add(timeout)
+
+	lastProblem := "no vtcpd process"
+	for {
+		pids, err := n.vtcpdPIDs(ctx)
+		if err != nil {
+			return err
+		}
+		result.NewPIDs = slices.DeleteFunc(pids, func(pid string) bool { return slices.Contains(oldPIDs, pid) })
+
+		if len(result.NewPIDs) > 0 {
+			resp, err := client.Do(readinessRequest)
+			if err == nil {
+				resp.Body.Close()
+				if resp.StatusCode == http.StatusOK {
+					return nil
+				}
+				lastProblem = fmt.Sprintf("API responded with status %d", resp.StatusCode)
+			} else {
+				lastProblem = fmt.Sprintf("API request failed: %v", err)
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Node %s: vtcpd was not ready within %v after restart: %s", n.Alias, timeout, lastProblem)
+		}
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("Node %s: stopped waiting for vtcpd to get ready: %w (%s)", n.Alias, err, lastProblem)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
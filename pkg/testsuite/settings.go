@@ -0,0 +1,86 @@
+package testsuite
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/docker/docker/client"
+)
+
+// networkNamePattern is what docker accepts as a network name.
+var networkNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Validate checks the settings up front, so that a typo is reported before any container is created:
+// the node images exist, the network name is valid and the database config is well-formed.
+func (s *ClusterSettings) Validate(ctx context.Context) error {
+	var problems []string
+	if s.NodeImageName == "" {
+		problems = append(problems, "node image name is not set")
+	}
+	if !networkNamePattern.MatchString(s.NetworkName) {
+		problems = append(problems, fmt.Sprintf("invalid network name %q", s.NetworkName))
+	}
+	if err := ValidateDatabaseConfig(s.DatabaseConfig); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if s.StepTimeout < 0 {
+		problems = append(problems, fmt.Sprintf("negative step timeout %v", s.StepTimeout))
+	}
+
+	if s.NodeImageName != "" {
+		if err := CheckImageExists(ctx, s.NodeImageName); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid cluster settings: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// ValidateDatabaseConfig checks a VTCPD_DATABASE_CONFIG value: "sqlite3:///<dir>" or a "postgresql://..." URL.
+// Empty is valid and means the image default.
+func ValidateDatabaseConfig(databaseConfig string) error {
+	if databaseConfig == "" {
+		return nil
+	}
+	parsed, err := url.Parse(databaseConfig)
+	if err != nil {
+		return fmt.Errorf("malformed database config %q: %v", databaseConfig, err)
+	}
+
+	switch parsed.Scheme {
+	case "sqlite3":
+		if parsed.Host != "" || !strings.HasPrefix(parsed.Path, "/") {
+			return fmt.Errorf("malformed database config %q: expected sqlite3:///<directory>", databaseConfig)
+		}
+	case "postgresql":
+		if parsed.Host == "" && strings.Trim(parsed.Path, "/") == "" {
+			return fmt.Errorf("malformed database config %q: expected postgresql://<connection parameters>", databaseConfig)
+		}
+	default:
+		return fmt.Errorf("malformed database config %q: unsupported database %q, expected sqlite3 or postgresql", databaseConfig, parsed.Scheme)
+	}
+	return nil
+}
+
+// CheckImageExists reports an error if the image is not available locally.
+func CheckImageExists(ctx context.Context, image string) error {
+	cli, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		return fmt.Errorf("failed to create docker client: %v", err)
+	}
+	defer cli.Close()
+
+	if _, _, err := cli.ImageInspectWithRaw(ctx, image); err != nil {
+		if client.IsErrNotFound(err) {
+			return fmt.Errorf("image %s not found, build it first (make docker-build-test-<distro>)", image)
+		}
+		return fmt.Errorf("failed to inspect image %s: %v", image, err)
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package testsuite
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"text/tabwriter"
+)
+
+// Soft assertions.
+//
+// The batch checks (CheckMaxFlowBatch, CheckExchangeMaxFlowBatch, CheckSettlementLineForSyncBatch) and the storage
+// checks run all of their comparisons and report every mismatch together, as a table. Within a step opened with
+// SoftAssert, they don't fail the test at all: the mismatches are collected and the test fails when the step is done,
+// with the mismatches of all the checks of the step. Errors that prevent a check from running (a failed API call,
+// a failed query) still fail the test right away. The checks of subtests (t.Run) are collected into the step
+// of the closest test up the tree that has one open, as the step log routes them (see recordTestAssertion).
+//
+//	step := vtcp.SoftAssert(t, "after the payment")
+//	nodes[0].CheckMaxFlowBatch(t, checks, equivalent)
+//	vtcp.CheckSettlementLineForSyncBatch(t, nodes, equivalent, vtcp.WaitingParticipantsVotesSec)
+//	step.Done()
+
+// AssertionMismatch is a value that differs from the expected one.
+type AssertionMismatch struct {
+	Check    string // e.g. "max flow"
+	Node     string
+	Target   string // alias of the other node of the pair, empty for single-node checks
+	Field    string
+	Expected string
+	Actual   string
+	// Details are printed below the table, e.g. the storage diff of a settlement line.
+	Details string
+}
+
+func newMismatch(check string, node, target *Node, field string, expected, actual any) AssertionMismatch {
+	mismatch := AssertionMismatch{Check: check, Node: node.Alias, Field: field,
+		Expected: fmt.Sprint(expected), Actual: fmt.Sprint(actual)}
+	if target != nil {
+		mismatch.Target = target.Alias
+	}
+	return mismatch
+}
+
+// FormatMismatches renders the mismatches as a table, followed by their details.
+func FormatMismatches(mismatches []AssertionMismatch) string {
+	var builder strings.Builder
+	writer := tabwriter.NewWriter(&builder, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "CHECK\tNODE\tTARGET\tFIELD\tEXPECTED\tACTUAL\t")
+	for _, mismatch := range mismatches {
+		target := mismatch.Target
+		if target == "" {
+			target = "-"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t\n", mismatch.Check, mismatch.Node, target, mismatch.Field,
+			mismatch.Expected, mismatch.Actual)
+	}
+	writer.Flush()
+
+	for _, mismatch := range mismatches {
+		if mismatch.Details == "" {
+			continue
+		}
+		fmt.Fprintf(&builder, "\n%s %s", mismatch.Check, mismatch.Node)
+		if mismatch.Target != "" {
+			fmt.Fprintf(&builder, " -> %s", mismatch.Target)
+		}
+		fmt.Fprintf(&builder, ":\n%s", mismatch.Details)
+	}
+	return builder.String()
+}
+
+// SoftAssertions collects the mismatches of the checks of a step, see SoftAssert.
+type SoftAssertions struct {
+	t    *testing.T
+	test string // name of the test the step is open in
+	step string
+
+	mu         sync.Mutex
+	mismatches []AssertionMismatch
+	done       bool
+}
+
+var (
+	softAssertionsMu sync.Mutex
+	softAssertions   = make(map[string]*SoftAssertions) // the open step of a test, by test name
+)
+
+// SoftAssert opens a step of the test whose checks collect their mismatches instead of failing the test.
+// The test fails with all of them on Done, or when the test ends if the step is not done by then.
+func SoftAssert(t *testing.T, step string) *SoftAssertions {
+	t.Helper()
+	softAssertionsMu.Lock()
+	open, ok := softAssertions[t.Name()]
+	assertions := &SoftAssertions{t: t, test: t.Name(), step: step}
+	if !ok {
+		softAssertions[t.Name()] = assertions
+	}
+	softAssertionsMu.Unlock()
+	if ok {
+		t.Fatalf("step %q is opened while step %q is not done", step, open.step)
+	}
+
+	t.Cleanup(func() {
+		if mismatches, ok := assertions.finish(); ok && len(mismatches) > 0 {
+			t.Errorf("step %q (not done): %d mismatch(es)\n%s", step, len(mismatches), FormatMismatches(mismatches))
+		}
+	})
+	return assertions
+}
+
+// Add records mismatches found by the test itself.
+func (a *SoftAssertions) Add(mismatches ...AssertionMismatch) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.mismatches = append(a.mismatches, mismatches...)
+}
+
+// Mismatches returns the mismatches collected so far.
+func (a *SoftAssertions) Mismatches() []AssertionMismatch {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]AssertionMismatch(nil), a.mismatches...)
+}
+
+// Done closes the step and fails the test if any check of the step found a mismatch.
+func (a *SoftAssertions) Done() {
+	a.t.Helper()
+	mismatches, ok := a.finish()
+	if !ok {
+		a.t.Fatalf("step %q is already done", a.step)
+	}
+	if len(mismatches) > 0 {
+		recordTestAssertion(a.t, "step "+a.step, fmt.Errorf("%d mismatch(es)", len(mismatches)))
+		a.t.Fatalf("step %q: %d mismatch(es)\n%s", a.step, len(mismatches), FormatMismatches(mismatches))
+	}
+	recordTestAssertion(a.t, "step "+a.step, nil)
+}
+
+// finish closes the step and returns its mismatches, ok is false if the step was already closed.
+func (a *SoftAssertions) finish() ([]AssertionMismatch, bool) {
+	softAssertionsMu.Lock()
+	if softAssertions[a.test] == a {
+		delete(softAssertions, a.test)
+	}
+	softAssertionsMu.Unlock()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.done {
+		return nil, false
+	}
+	a.done = true
+	return a.mismatches, true
+}
+
+// reportMismatches fails the test with the mismatches of the check, or records them in the open step of the test.
+func reportMismatches(t *testing.T, check string, mismatches []AssertionMismatch) {
+	t.Helper()
+	if len(mismatches) == 0 {
+		return
+	}
+	recordTestAssertion(t, check, fmt.Errorf("%d mismatch(es)\n%s", len(mismatches), FormatMismatches(mismatches)))
+	if step := openStep(t); step != nil {
+		step.Add(mismatches...)
+		return
+	}
+	t.Fatalf("%s: %d mismatch(es)\n%s", check, len(mismatches), FormatMismatches(mismatches))
+}
+
+// openStep returns the open step of the test, or of its closest parent test that has one.
+func openStep(t *testing.T) *SoftAssertions {
+	softAssertionsMu.Lock()
+	defer softAssertionsMu.Unlock()
+	for name := t.Name(); ; {
+		if step, ok := softAssertions[name]; ok {
+			return step
+		}
+		parent := strings.LastIndex(name, "/")
+		if parent < 0 {
+			return nil
+		}
+		name = name[:parent]
+	}
+}
@@ -0,0 +1,47 @@
+package testsuite
+
+import "testing"
+
+func TestSoftAssertCollectsSubtestMismatches(t *testing.T) {
+	step := SoftAssert(t, "batch")
+	t.Run("first", func(t *testing.T) {
+		reportMismatches(t, "max flow", []AssertionMismatch{{Check: "max flow", Node: "node1", Expected: "1000", Actual: "800"}})
+		t.Run("nested", func(t *testing.T) {
+			reportMismatches(t, "max flow", []AssertionMismatch{{Check: "max flow", Node: "node2", Expected: "700", Actual: "500"}})
+		})
+	})
+	t.Run("second", func(t *testing.T) {
+		// A step of the subtest itself takes its checks instead of the parent's one
+		own := SoftAssert(t, "own")
+		reportMismatches(t, "sync", []AssertionMismatch{{Check: "sync", Node: "node3"}})
+		if mismatches, _ := own.finish(); len(mismatches) != 1 || mismatches[0].Node != "node3" {
+			t.Errorf("expected the subtest's step to collect its mismatch, got %v", mismatches)
+		}
+	})
+
+	mismatches, ok := step.finish()
+	if !ok {
+		t.Fatalf("expected the step to be open")
+	}
+	if len(mismatches) != 2 || mismatches[0].Node != "node1" || mismatches[1].Node != "node2" {
+		t.Errorf("expected the mismatches of both subtests, got %v", mismatches)
+	}
+	if openStep(t) != nil {
+		t.Errorf("expected no open step after the step is done")
+	}
+}
+
+func TestSoftAssertStepsOfSiblingTests(t *testing.T) {
+	for _, name := range []string{"a", "ab"} {
+		t.Run(name, func(t *testing.T) {
+			step := SoftAssert(t, name)
+			if openStep(t) != step {
+				t.Errorf("expected the step of %s", t.Name())
+			}
+			step.finish()
+		})
+	}
+	if openStep(t) != nil {
+		t.Errorf("expected no step of the parent test")
+	}
+}
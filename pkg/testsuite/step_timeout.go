@@ -0,0 +1,178 @@
+package testsuite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"slices"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Step deadlines.
+//
+// A hung vtcpd must fail the step that waits for it, not freeze the test until go test times out. Every node API
+// call, every docker command into a node's container (docker exec, docker cp) and every docker operation of
+// the cluster runs with a deadline of the cluster's step timeout (ClusterSettings.StepTimeout, DefaultStepTimeout
+// if not set, SetStepTimeout per test). A call that runs out of time fails with a StepTimeoutError naming the node
+// and the endpoint or the command.
+//
+// The cluster operations take a context. The node methods that run docker commands or wait for the node have
+// ...Ctx variants taking the context explicitly (RestartCtx, WaitForReadyCtx, ReadConfigCtx, QueryCtx of the
+// storage, ...). The other node methods, and the plain variants, run under the context the node was run with
+// (Cluster.RunNodes), so cancelling that context aborts them. The unexported operations they are built from
+// take the context explicitly.
+
+// DefaultStepTimeout is the step timeout of the clusters whose settings don't set one.
+const DefaultStepTimeout = 60 * time.Second
+
+// StepTimeoutError is returned when a node doesn't respond within the step timeout.
+type StepTimeoutError struct {
+	NodeAlias string
+	Method    string
+	Endpoint  string
+	Timeout   time.Duration
+}
+
+func (e *StepTimeoutError) Error() string {
+	return fmt.Sprintf("Node %s: %s %s hung: no response within the step timeout of %v", e.NodeAlias, e.Method, e.Endpoint, e.Timeout)
+}
+
+// stepTimeout returns the configured step timeout, or DefaultStepTimeout.
+func (s *ClusterSettings) stepTimeout() time.Duration {
+	if s.StepTimeout > 0 {
+		return s.StepTimeout
+	}
+	return DefaultStepTimeout
+}
+
+// SetStepTimeout overrides the step timeout of the test, for the nodes already running as well.
+func (c *Cluster) SetStepTimeout(timeout time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stepTimeout = timeout
+	for _, node := range c.nodes {
+		node.stepTimeout = timeout
+	}
+}
+
+// stepContext returns ctx with the step deadline, for the docker operations of the cluster.
+func (c *Cluster) stepContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	c.mu.Lock()
+	timeout := c.stepTimeout
+	c.mu.Unlock()
+	return context.WithTimeout(ctx, timeout)
+}
+
+// stepTimeoutError turns err of the docker operation on the node into a StepTimeoutError if the step deadline
+// of ctx cut the operation.
+func (c *Cluster) stepTimeoutError(ctx context.Context, node *Node, operation string, err error) error {
+	if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	c.mu.Lock()
+	timeout := c.stepTimeout
+	c.mu.Unlock()
+	return &StepTimeoutError{NodeAlias: node.Alias, Method: "docker", Endpoint: operation, Timeout: timeout}
+}
+
+// bindNode makes the node's operations run under ctx with the step timeout of the cluster.
+// The API calls of the node belong to the test, not to the setup phase that started the node.
+func (c *Cluster) bindNode(ctx context.Context, node *Node) {
+	if c.testSpan != nil {
+		ctx = trace.ContextWithSpan(ctx, c.testSpan)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	node.ctx, node.stepTimeout = ctx, c.stepTimeout
+}
+
+// runContext returns the context the node was run with, the background context if it's not running in a cluster.
+func (n *Node) runContext() context.Context {
+	if n.ctx == nil {
+		return context.Background()
+	}
+	return n.ctx
+}
+
+func (n *Node) stepDeadline() time.Duration {
+	if n.stepTimeout > 0 {
+		return n.stepTimeout
+	}
+	return DefaultStepTimeout
+}
+
+// stepContext returns ctx with the node's step deadline, for a docker command on the node.
+func (n *Node) stepContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, n.stepDeadline())
+}
+
+// dockerTimeoutError turns err of the docker command on the node into a StepTimeoutError if the step deadline
+// of ctx cut the command.
+func (n *Node) dockerTimeoutError(ctx context.Context, cmd *exec.Cmd, err error) error {
+	if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return &StepTimeoutError{NodeAlias: n.Alias, Method: "docker", Endpoint: dockerOperation(n, cmd), Timeout: n.stepDeadline()}
+}
+
+// dockerOperation names the docker command on the node for errors, e.g. "exec pgrep".
+func dockerOperation(n *Node, cmd *exec.Cmd) string {
+	if len(cmd.Args) < 2 {
+		return cmd.Path
+	}
+	operation := cmd.Args[1]
+	if i := slices.Index(cmd.Args, n.ContainerID); i > 1 && i+1 < len(cmd.Args) {
+		operation += " " + cmd.Args[i+1]
+	}
+	return operation
+}
+
+// doAPIRequest sends a request without a body to the node's API under ctx with the step deadline. The deadline
+// covers reading the response body too, the body must be closed.
+func (n *Node) doAPIRequest(ctx context.Context, method, url string) (*http.Response, error) {
+	timeout := n.stepDeadline()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	request, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	timeoutErr := &StepTimeoutError{NodeAlias: n.Alias, Method: method, Endpoint: request.URL.Path, Timeout: timeout}
+	resp, err := apiClient.Do(request)
+	if err != nil {
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, timeoutErr
+		}
+		return nil, err
+	}
+	resp.Body = &deadlineBody{ReadCloser: resp.Body, cancel: cancel, timeoutErr: timeoutErr}
+	return resp, nil
+}
+
+// deadlineBody releases the request's deadline on Close and reports a body cut by the deadline as a timeout.
+type deadlineBody struct {
+	io.ReadCloser
+	cancel     context.CancelFunc
+	timeoutErr *StepTimeoutError
+}
+
+func (b *deadlineBody) Read(p []byte) (int, error) {
+	count, err := b.ReadCloser.Read(p)
+	if err != nil && errors.Is(err, context.DeadlineExceeded) {
+		err = b.timeoutErr
+	}
+	return count, err
+}
+
+func (b *deadlineBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
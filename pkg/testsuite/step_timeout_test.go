@@ -0,0 +1,75 @@
+package testsuite
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hangingNode returns a node whose API is a local server that doesn't respond until the test ends,
+// and the URL of the contractors list of the node.
+func hangingNode(t *testing.T) (*Node, string) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(done) })
+
+	address, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("failed to parse server URL: %v", err)
+	}
+	port, err := strconv.ParseUint(address.Port(), 10, 16)
+	if err != nil {
+		t.Fatalf("failed to parse server port: %v", err)
+	}
+	node := NewNode(t, address.Hostname(), "node1")
+	node.CLIPort = uint16(port)
+	return node, server.URL + "/api/v1/node/contractors/"
+}
+
+func TestDoAPIRequestStepTimeout(t *testing.T) {
+	node, contractorsURL := hangingNode(t)
+	node.stepTimeout = 100 * time.Millisecond
+
+	_, err := node.doAPIRequest(context.Background(), http.MethodGet, contractorsURL)
+	var timeoutErr *StepTimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("expected a StepTimeoutError, got %v", err)
+	}
+	if timeoutErr.Endpoint != "/api/v1/node/contractors/" || timeoutErr.Timeout != node.stepTimeout {
+		t.Errorf("unexpected timeout error: %v", timeoutErr)
+	}
+}
+
+func TestDoAPIRequestCancelled(t *testing.T) {
+	node, contractorsURL := hangingNode(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	_, err := node.doAPIRequest(ctx, http.MethodGet, contractorsURL)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the request to be cancelled, got %v", err)
+	}
+}
+
+func TestWaitForReadyCtxCancelled(t *testing.T) {
+	node, _ := hangingNode(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := node.WaitForReadyCtx(ctx, t, time.Minute)
+	if err == nil || !strings.Contains(err.Error(), "stopped waiting to become ready") {
+		t.Fatalf("expected waiting to stop with the context, got %v", err)
+	}
+}
@@ -0,0 +1,532 @@
+package testsuite
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const (
+	StorageDialectSQLite     = "sqlite"
+	StorageDialectPostgreSQL = "postgresql"
+
+	SQLiteStoragePath = "/vtcp/vtcpd/io/storagedb"
+
+	PostgreSQLHost     = "127.0.0.1"
+	PostgreSQLUser     = "vtcpd_user"
+	PostgreSQLPassword = "vtcpd_pass"
+	PostgreSQLDatabase = "storagedb"
+
+	// Unit and record separators: never occur in vtcpd storage values, unlike '|', ',' or a newline.
+	postgreSQLFieldSeparator  = "\x1f"
+	postgreSQLRecordSeparator = "\x1e"
+)
+
+// StorageInspector reads the vtcpd storage of a node from inside its container.
+// Queries use ? placeholders, arguments are bound as parameters by the database client
+// (supported argument types: string, int, int64, uint64, bool and []byte).
+//
+// Blob (bytea) values are returned as lowercase hex strings, booleans as "1"/"0", NULL as an empty string,
+// so that rows of both implementations can be compared directly.
+type StorageInspector interface {
+	Dialect() string
+	// Query is QueryCtx under the context the node was run with, as are the other queries.
+	Query(query string, args ...any) ([]StorageRow, error)
+	QueryCtx(ctx context.Context, query string, args ...any) ([]StorageRow, error)
+
+	PaymentTransactionsCount() (int, error)
+	// LatestPaymentTransactionState returns the observing state of the latest payment transaction,
+	// an empty string if there is none.
+	LatestPaymentTransactionState() (string, error)
+	ParticipantsVotesCount() (int, error)
+	IncomingReceiptsCount() (int, error)
+	OutgoingReceiptsCount() (int, error)
+	SerializedTransactionsCount() (int, error)
+	// ValidOwnKeysCount and ValidContractorKeysCount count the valid keys of the settlement line,
+	// of every settlement line if settlementLineID is empty.
+	ValidOwnKeysCount(settlementLineID string) (int, error)
+	ValidContractorKeysCount(settlementLineID string) (int, error)
+	HistoryRecordsCountByCommandUUID(commandUUID string) (int, error)
+
+	// SettlementLine and LatestAudit read the listed columns of the record, all of them if none are listed.
+	SettlementLine(contractorID string, equivalent string, columns ...string) (*SettlementLineRow, error)
+	LatestAudit(settlementLineID string, columns ...string) (*AuditRow, error)
+}
+
+// StorageRow maps column names to values.
+type StorageRow map[string]string
+
+// Int returns the column value as an integer.
+func (r StorageRow) Int(column string) (int64, error) {
+	value, ok := r[column]
+	if !ok {
+		return 0, fmt.Errorf("column %s is not present in row %v", column, r)
+	}
+	result, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("column %s value '%s' is not an integer: %w", column, value, err)
+	}
+	return result, nil
+}
+
+// SettlementLineRow is a row of the trust_lines table, the fields of the columns that were not read are empty.
+type SettlementLineRow struct {
+	ID           string
+	ContractorID string
+	Equivalent   string
+	State        string
+	Columns      StorageRow
+}
+
+// AuditRow is a row of the audit table, the fields of the columns that were not read are empty.
+type AuditRow struct {
+	Number           int64
+	SettlementLineID string
+	Columns          StorageRow
+}
+
+// NewStorageInspector returns the inspector matching the node's database configuration
+// (the value of VTCPD_DATABASE_CONFIG). SQLite is used by default.
+func NewStorageInspector(node *Node, databaseConfig string) StorageInspector {
+	if strings.Contains(databaseConfig, "postgresql") {
+		return NewPostgreSQLInspector(node)
+	}
+	return NewSQLiteInspector(node)
+}
+
+// queryRunner executes a single query with bound arguments.
+type queryRunner interface {
+	Query(query string, args ...any) ([]StorageRow, error)
+}
+
+// storageQueries implements the typed part of StorageInspector on top of a queryRunner,
+// so every query is written once for both databases.
+type storageQueries struct {
+	runner queryRunner
+}
+
+// count runs a SELECT COUNT(*) AS count query.
+func (q storageQueries) count(query string, args ...any) (int, error) {
+	rows, err := q.runner.Query(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) != 1 {
+		return 0, fmt.Errorf("count query ['%s'] returned %d rows", query, len(rows))
+	}
+	count, err := rows[0].Int("count")
+	if err != nil {
+		return 0, err
+	}
+	return int(count), nil
+}
+
+func (q storageQueries) PaymentTransactionsCount() (int, error) {
+	return q.count("SELECT COUNT(*) AS count FROM payment_transactions")
+}
+
+func (q storageQueries) LatestPaymentTransactionState() (string, error) {
+	rows, err := q.runner.Query("SELECT observing_state FROM payment_transactions ORDER BY recording_time DESC LIMIT 1")
+	if err != nil || len(rows) == 0 {
+		return "", err
+	}
+	return rows[0]["observing_state"], nil
+}
+
+func (q storageQueries) ParticipantsVotesCount() (int, error) {
+	return q.count("SELECT COUNT(*) AS count FROM payment_participants_votes")
+}
+
+func (q storageQueries) IncomingReceiptsCount() (int, error) {
+	return q.count("SELECT COUNT(*) AS count FROM incoming_receipt")
+}
+
+func (q storageQueries) OutgoingReceiptsCount() (int, error) {
+	return q.count("SELECT COUNT(*) AS count FROM outgoing_receipt")
+}
+
+func (q storageQueries) SerializedTransactionsCount() (int, error) {
+	return q.count("SELECT COUNT(*) AS count FROM transactions")
+}
+
+func (q storageQueries) ValidOwnKeysCount(settlementLineID string) (int, error) {
+	return q.validKeysCount("own_keys", settlementLineID)
+}
+
+func (q storageQueries) ValidContractorKeysCount(settlementLineID string) (int, error) {
+	return q.validKeysCount("contractor_keys", settlementLineID)
+}
+
+func (q storageQueries) validKeysCount(tableName string, settlementLineID string) (int, error) {
+	query := "SELECT COUNT(*) AS count FROM " + tableName + " WHERE is_valid = ?"
+	if settlementLineID == "" {
+		return q.count(query, true)
+	}
+	settlementLineIDNum, err := strconv.Atoi(settlementLineID)
+	if err != nil {
+		return 0, fmt.Errorf("settlement line id '%s' is not an integer: %w", settlementLineID, err)
+	}
+	return q.count(query+" AND trust_line_id = ?", true, settlementLineIDNum)
+}
+
+// HistoryRecordsCountByCommandUUID counts history records of the command (stored as a blob without dashes).
+func (q storageQueries) HistoryRecordsCountByCommandUUID(commandUUID string) (int, error) {
+	uuidBytes, err := hex.DecodeString(strings.ReplaceAll(commandUUID, "-", ""))
+	if err != nil {
+		return 0, fmt.Errorf("command uuid '%s' is not valid: %w", commandUUID, err)
+	}
+	return q.count("SELECT COUNT(*) AS count FROM history WHERE command_uuid = ?", uuidBytes)
+}
+
+// SettlementLine returns nil if there is no settlement line with the contractor in the equivalent.
+func (q storageQueries) SettlementLine(contractorID string, equivalent string, columns ...string) (*SettlementLineRow, error) {
+	equivalentNum, err := strconv.Atoi(equivalent)
+	if err != nil {
+		return nil, fmt.Errorf("equivalent '%s' is not an integer: %w", equivalent, err)
+	}
+	contractorIDNum, err := strconv.Atoi(contractorID)
+	if err != nil {
+		return nil, fmt.Errorf("contractor id '%s' is not an integer: %w", contractorID, err)
+	}
+
+	rows, err := q.runner.Query("SELECT "+selectedColumns(columns)+" FROM trust_lines WHERE contractor_id = ? AND equivalent = ?",
+		contractorIDNum, equivalentNum)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	row := rows[0]
+	return &SettlementLineRow{
+		ID:           row["id"],
+		ContractorID: row["contractor_id"],
+		Equivalent:   row["equivalent"],
+		State:        row["state"],
+		Columns:      row,
+	}, nil
+}
+
+// LatestAudit returns the audit of the settlement line with the highest number, nil if there is no audit yet.
+func (q storageQueries) LatestAudit(settlementLineID string, columns ...string) (*AuditRow, error) {
+	settlementLineIDNum, err := strconv.Atoi(settlementLineID)
+	if err != nil {
+		return nil, fmt.Errorf("settlement line id '%s' is not an integer: %w", settlementLineID, err)
+	}
+
+	rows, err := q.runner.Query("SELECT "+selectedColumns(columns)+" FROM audit WHERE trust_line_id = ? ORDER BY number DESC LIMIT 1",
+		settlementLineIDNum)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	row := rows[0]
+	audit := &AuditRow{SettlementLineID: row["trust_line_id"], Columns: row}
+	if _, ok := row["number"]; ok {
+		if audit.Number, err = row.Int("number"); err != nil {
+			return nil, err
+		}
+	}
+	return audit, nil
+}
+
+func selectedColumns(columns []string) string {
+	if len(columns) == 0 {
+		return "*"
+	}
+	return strings.Join(columns, ", ")
+}
+
+// SQLiteInspector runs queries with the sqlite3 CLI inside the node's container.
+type SQLiteInspector struct {
+	storageQueries
+	node   *Node
+	DBPath string
+}
+
+func NewSQLiteInspector(node *Node) *SQLiteInspector {
+	inspector := &SQLiteInspector{node: node, DBPath: SQLiteStoragePath}
+	inspector.storageQueries = storageQueries{runner: inspector}
+	return inspector
+}
+
+func (s *SQLiteInspector) Dialect() string {
+	return StorageDialectSQLite
+}
+
+func (s *SQLiteInspector) Query(query string, args ...any) ([]StorageRow, error) {
+	return s.QueryCtx(s.node.runContext(), query, args...)
+}
+
+// QueryCtx binds the arguments with the sqlite3 ".parameter" command and reads the result in "quote" mode,
+// which renders every value as an SQL literal and thus survives blobs and separators in text.
+func (s *SQLiteInspector) QueryCtx(ctx context.Context, query string, args ...any) ([]StorageRow, error) {
+	if s.node.ContainerID == "" {
+		return nil, fmt.Errorf("node %s: ContainerID is not set, cannot execute database checks", s.node.Alias)
+	}
+
+	var script strings.Builder
+	script.WriteString(".mode quote\n.headers on\n.parameter init\n")
+	for i, arg := range args {
+		literal, err := sqliteLiteral(arg)
+		if err != nil {
+			return nil, err
+		}
+		// Double quotes keep the literal's single quotes through the dot-command argument parser.
+		fmt.Fprintf(&script, ".parameter set :p%d \"%s\"\n", i+1, strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(literal))
+	}
+	script.WriteString(replacePlaceholders(query, func(i int) string { return fmt.Sprintf(":p%d", i) }))
+	script.WriteString(";\n")
+
+	ctx, cancel := s.node.stepContext(ctx)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "docker", "exec", "-i", s.node.ContainerID, "sqlite3", "-bail", s.DBPath)
+	cmd.Stdin = strings.NewReader(script.String())
+	output, err := s.node.dockerCombinedOutput(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("docker exec command failed for query ['%s'] on node %s (container: %s): %v. Output: %s",
+			query, s.node.Alias, s.node.ContainerID, err, strings.TrimSpace(string(output)))
+	}
+
+	lines := strings.Split(strings.TrimRight(string(output), "\n"), "\n")
+	if len(lines) == 0 || lines[0] == "" {
+		return nil, nil
+	}
+
+	header, err := parseSQLiteQuotedLine(lines[0])
+	if err != nil {
+		return nil, fmt.Errorf("node %s: failed to parse sqlite3 header for query ['%s']: %w", s.node.Alias, query, err)
+	}
+	rows := make([]StorageRow, 0, len(lines)-1)
+	for _, line := range lines[1:] {
+		values, err := parseSQLiteQuotedLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("node %s: failed to parse sqlite3 output for query ['%s']: %w", s.node.Alias, query, err)
+		}
+		if len(values) != len(header) {
+			return nil, fmt.Errorf("node %s: sqlite3 row %q does not match header %q", s.node.Alias, line, lines[0])
+		}
+		row := make(StorageRow, len(header))
+		for i, column := range header {
+			row[column] = values[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func sqliteLiteral(arg any) (string, error) {
+	switch value := arg.(type) {
+	case string:
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'", nil
+	case int, int64, uint64:
+		return fmt.Sprintf("%d", value), nil
+	case bool:
+		if value {
+			return "1", nil
+		}
+		return "0", nil
+	case []byte:
+		return "x'" + hex.EncodeToString(value) + "'", nil
+	default:
+		return "", fmt.Errorf("unsupported query argument type %T", arg)
+	}
+}
+
+// parseSQLiteQuotedLine splits a line produced by sqlite3 in "quote" mode into values.
+func parseSQLiteQuotedLine(line string) ([]string, error) {
+	var values []string
+	for pos := 0; pos <= len(line); {
+		var value string
+		switch {
+		case strings.HasPrefix(line[pos:], "'"):
+			var buffer strings.Builder
+			i := pos + 1
+			for ; i < len(line); i++ {
+				if line[i] == '\'' {
+					if i+1 < len(line) && line[i+1] == '\'' {
+						buffer.WriteByte('\'')
+						i++
+						continue
+					}
+					break
+				}
+				buffer.WriteByte(line[i])
+			}
+			if i >= len(line) {
+				return nil, fmt.Errorf("unterminated string in %q", line)
+			}
+			value = buffer.String()
+			pos = i + 1
+		case strings.HasPrefix(line[pos:], "X'"):
+			end := strings.IndexByte(line[pos+2:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated blob in %q", line)
+			}
+			value = strings.ToLower(line[pos+2 : pos+2+end])
+			pos = pos + 2 + end + 1
+		default:
+			end := strings.IndexByte(line[pos:], ',')
+			if end < 0 {
+				end = len(line) - pos
+			}
+			value = line[pos : pos+end]
+			if value == "NULL" {
+				value = ""
+			}
+			pos += end
+		}
+		values = append(values, value)
+
+		if pos >= len(line) {
+			break
+		}
+		if line[pos] != ',' {
+			return nil, fmt.Errorf("unexpected character at %d in %q", pos, line)
+		}
+		pos++
+	}
+	return values, nil
+}
+
+// PostgreSQLInspector runs queries with psql inside the node's container.
+type PostgreSQLInspector struct {
+	storageQueries
+	node *Node
+}
+
+func NewPostgreSQLInspector(node *Node) *PostgreSQLInspector {
+	inspector := &PostgreSQLInspector{node: node}
+	inspector.storageQueries = storageQueries{runner: inspector}
+	return inspector
+}
+
+func (p *PostgreSQLInspector) Dialect() string {
+	return StorageDialectPostgreSQL
+}
+
+func (p *PostgreSQLInspector) Query(query string, args ...any) ([]StorageRow, error) {
+	return p.QueryCtx(p.node.runContext(), query, args...)
+}
+
+// QueryCtx binds the arguments as psql variables (interpolated as quoted literals by psql itself).
+// The query is passed through stdin, so no shell quoting is involved.
+func (p *PostgreSQLInspector) QueryCtx(ctx context.Context, query string, args ...any) ([]StorageRow, error) {
+	if p.node.ContainerID == "" {
+		return nil, fmt.Errorf("node %s: ContainerID is not set, cannot execute database checks", p.node.Alias)
+	}
+
+	cmdArgs := []string{
+		"exec", "-i", "-e", "PGPASSWORD=" + PostgreSQLPassword, p.node.ContainerID,
+		"psql", "-h", PostgreSQLHost, "-U", PostgreSQLUser, "-d", PostgreSQLDatabase,
+		"-X", "-q", "-A", "-F", postgreSQLFieldSeparator, "-R", postgreSQLRecordSeparator, "-P", "footer=off", "-v", "ON_ERROR_STOP=1",
+	}
+	for i, arg := range args {
+		value, err := postgreSQLValue(arg)
+		if err != nil {
+			return nil, err
+		}
+		cmdArgs = append(cmdArgs, "-v", fmt.Sprintf("p%d=%s", i+1, value))
+	}
+	cmdArgs = append(cmdArgs, "-f", "-")
+
+	ctx, cancel := p.node.stepContext(ctx)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "docker", cmdArgs...)
+	cmd.Stdin = strings.NewReader(replacePlaceholders(query, func(i int) string { return fmt.Sprintf(":'p%d'", i) }) + ";\n")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := p.node.dockerOutput(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("docker exec psql command failed for query ['%s'] on node %s (container: %s): %v. Output: %s",
+			query, p.node.Alias, p.node.ContainerID, err, strings.TrimSpace(stderr.String()+string(output)))
+	}
+
+	rows, err := parsePostgreSQLOutput(string(output))
+	if err != nil {
+		return nil, fmt.Errorf("node %s: failed to parse psql output for query ['%s']: %w", p.node.Alias, query, err)
+	}
+	return rows, nil
+}
+
+// parsePostgreSQLOutput reads the unaligned psql output: the header and the rows separated by the record
+// separator, the fields by the field separator. psql ends the last record with a newline.
+func parsePostgreSQLOutput(output string) ([]StorageRow, error) {
+	output = strings.TrimSuffix(output, "\n")
+	if output == "" {
+		return nil, nil
+	}
+	records := strings.Split(output, postgreSQLRecordSeparator)
+	header := strings.Split(records[0], postgreSQLFieldSeparator)
+	rows := make([]StorageRow, 0, len(records)-1)
+	for _, record := range records[1:] {
+		values := strings.Split(record, postgreSQLFieldSeparator)
+		if len(values) != len(header) {
+			return nil, fmt.Errorf("row %q does not match header %q", record, records[0])
+		}
+		row := make(StorageRow, len(header))
+		for i, column := range header {
+			row[column] = normalizePostgreSQLValue(values[i])
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func postgreSQLValue(arg any) (string, error) {
+	switch value := arg.(type) {
+	case string:
+		return value, nil
+	case int, int64, uint64:
+		return fmt.Sprintf("%d", value), nil
+	case bool:
+		if value {
+			return "true", nil
+		}
+		return "false", nil
+	case []byte:
+		// bytea hex input format
+		return `\x` + hex.EncodeToString(value), nil
+	default:
+		return "", fmt.Errorf("unsupported query argument type %T", arg)
+	}
+}
+
+// normalizePostgreSQLValue converts psql output to the representation used by SQLiteInspector.
+func normalizePostgreSQLValue(value string) string {
+	switch {
+	case value == "t":
+		return "1"
+	case value == "f":
+		return "0"
+	case strings.HasPrefix(value, `\x`):
+		return value[2:]
+	}
+	return value
+}
+
+// replacePlaceholders replaces ? placeholders outside of quoted strings with the dialect-specific form.
+func replacePlaceholders(query string, placeholder func(i int) string) string {
+	var result strings.Builder
+	inString := false
+	index := 0
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			inString = !inString
+			result.WriteRune(r)
+		case r == '?' && !inString:
+			index++
+			result.WriteString(placeholder(index))
+		default:
+			result.WriteRune(r)
+		}
+	}
+	return result.String()
+}
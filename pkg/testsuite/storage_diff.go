@@ -0,0 +1,248 @@
+package testsuite
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Cross-node comparison of a settlement line.
+//
+// Both sides of a settlement line store mirrored data: one node's max positive balance is the other's max negative
+// balance, balances have opposite signs, own keys of one node are contractor keys of the other and so on.
+// The diff pairs every field with its counterpart on the other node and marks the pairs that disagree.
+
+// settlementLineLocalColumns are storage columns that are meaningful only for the node that stores them
+// (row ids, contractor id of the channel) or can't be compared as stored (balance blob, compared through the API instead).
+var settlementLineLocalColumns = map[string]bool{
+	"id":            true,
+	"trust_line_id": true,
+	"contractor_id": true,
+	"balance":       true,
+}
+
+// SettlementLineStorageDump is what a node knows about one of its settlement lines.
+type SettlementLineStorageDump struct {
+	Info           *SettlementLineInfo
+	SettlementLine *SettlementLineRow
+	LatestAudit    *AuditRow // nil if there is no audit yet
+	// ValidOwnKeys and ValidContractorKeys are the numbers of the valid keys of the settlement line.
+	ValidOwnKeys        int
+	ValidContractorKeys int
+}
+
+// DumpSettlementLineStorage collects the settlement line with targetNode from the node's API and storage.
+func (n *Node) DumpSettlementLineStorage(targetNode *Node, equivalent string) (*SettlementLineStorageDump, error) {
+	dump := &SettlementLineStorageDump{}
+
+	info, _, err := n.GetSettlementsLineInfoByAddress(targetNode, equivalent)
+	if err != nil {
+		return nil, fmt.Errorf("node %s: %w", n.Alias, err)
+	}
+	dump.Info = info
+
+	channelInfo, err := n.GetChannelInfoByAddress(targetNode)
+	if err != nil {
+		return nil, fmt.Errorf("node %s: failed to get channel info for target %s: %w", n.Alias, targetNode.Alias, err)
+	}
+	storage := n.storage()
+	dump.SettlementLine, err = storage.SettlementLine(channelInfo.ChannelID, equivalent)
+	if err != nil {
+		return nil, fmt.Errorf("node %s: %w", n.Alias, err)
+	}
+	if dump.SettlementLine == nil {
+		return dump, nil
+	}
+
+	dump.LatestAudit, err = storage.LatestAudit(dump.SettlementLine.ID)
+	if err != nil {
+		return nil, fmt.Errorf("node %s: %w", n.Alias, err)
+	}
+
+	dump.ValidOwnKeys, err = storage.ValidOwnKeysCount(dump.SettlementLine.ID)
+	if err != nil {
+		return nil, fmt.Errorf("node %s: %w", n.Alias, err)
+	}
+	dump.ValidContractorKeys, err = storage.ValidContractorKeysCount(dump.SettlementLine.ID)
+	if err != nil {
+		return nil, fmt.Errorf("node %s: %w", n.Alias, err)
+	}
+
+	return dump, nil
+}
+
+// StorageDiffField is a pair of mirrored values of a settlement line on both nodes.
+type StorageDiffField struct {
+	Name        string
+	Value       string
+	TargetName  string // the field on the target node that must correspond to Name
+	TargetValue string
+	Compared    bool // false for fields shown for information only
+	Mismatch    bool
+}
+
+// SettlementLineStorageDiff is a field-by-field comparison of a settlement line as seen by both of its nodes.
+type SettlementLineStorageDiff struct {
+	Node       *Node
+	TargetNode *Node
+	Equivalent string
+	Fields     []StorageDiffField
+}
+
+// DiffSettlementLineStorage dumps the settlement line from both nodes and pairs the fields.
+func DiffSettlementLineStorage(node, targetNode *Node, equivalent string) (*SettlementLineStorageDiff, error) {
+	dump, err := node.DumpSettlementLineStorage(targetNode, equivalent)
+	if err != nil {
+		return nil, err
+	}
+	targetDump, err := targetNode.DumpSettlementLineStorage(node, equivalent)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &SettlementLineStorageDiff{Node: node, TargetNode: targetNode, Equivalent: equivalent}
+
+	diff.addEqual("api.state", dump.Info.State, targetDump.Info.State)
+	diff.addEqual("api.audit_number", dump.Info.AuditNumber, targetDump.Info.AuditNumber)
+	diff.add("api.max_positive_balance", dump.Info.MaxPositiveBalance.String(), "api.max_negative_balance", targetDump.Info.MaxNegativeBalance.String(), true)
+	diff.add("api.max_negative_balance", dump.Info.MaxNegativeBalance.String(), "api.max_positive_balance", targetDump.Info.MaxPositiveBalance.String(), true)
+	diff.Fields = append(diff.Fields, StorageDiffField{
+		Name:        "api.balance",
+		Value:       dump.Info.Balance.String(),
+		TargetName:  "-api.balance",
+		TargetValue: targetDump.Info.Balance.String(),
+		Compared:    true,
+		Mismatch:    !dump.Info.Balance.Equal(targetDump.Info.Balance.Neg()),
+	})
+	diff.add("api.own_keys_present", dump.Info.OwnKeysPresent, "api.contractor_keys_present", targetDump.Info.ContractorKeysPresent, true)
+	diff.add("api.contractor_keys_present", dump.Info.ContractorKeysPresent, "api.own_keys_present", targetDump.Info.OwnKeysPresent, true)
+
+	var lineColumns, targetLineColumns StorageRow
+	if dump.SettlementLine != nil {
+		lineColumns = dump.SettlementLine.Columns
+	}
+	if targetDump.SettlementLine != nil {
+		targetLineColumns = targetDump.SettlementLine.Columns
+	}
+	diff.addColumns("trust_lines", lineColumns, targetLineColumns)
+
+	var auditColumns, targetAuditColumns StorageRow
+	if dump.LatestAudit != nil {
+		auditColumns = dump.LatestAudit.Columns
+	}
+	if targetDump.LatestAudit != nil {
+		targetAuditColumns = targetDump.LatestAudit.Columns
+	}
+	diff.addColumns("audit", auditColumns, targetAuditColumns)
+
+	diff.add("own_keys.valid", fmt.Sprint(dump.ValidOwnKeys), "contractor_keys.valid", fmt.Sprint(targetDump.ValidContractorKeys), true)
+	diff.add("contractor_keys.valid", fmt.Sprint(dump.ValidContractorKeys), "own_keys.valid", fmt.Sprint(targetDump.ValidOwnKeys), true)
+
+	return diff, nil
+}
+
+func (d *SettlementLineStorageDiff) add(name, value, targetName, targetValue string, compared bool) {
+	d.Fields = append(d.Fields, StorageDiffField{
+		Name:        name,
+		Value:       value,
+		TargetName:  targetName,
+		TargetValue: targetValue,
+		Compared:    compared,
+		Mismatch:    compared && value != targetValue,
+	})
+}
+
+func (d *SettlementLineStorageDiff) addEqual(name, value, targetValue string) {
+	d.add(name, value, name, targetValue, true)
+}
+
+// addColumns pairs the columns of a storage row with their counterparts (own_* with contractor_*,
+// incoming_* with outgoing_*) in the target node's row. A missing row is shown as "<none>".
+func (d *SettlementLineStorageDiff) addColumns(table string, row, targetRow StorageRow) {
+	if row == nil && targetRow == nil {
+		return
+	}
+	columns := make(map[string]bool)
+	for column := range row {
+		columns[column] = true
+	}
+	for column := range targetRow {
+		columns[counterpartColumn(column)] = true
+	}
+	names := make([]string, 0, len(columns))
+	for column := range columns {
+		names = append(names, column)
+	}
+	sort.Strings(names)
+
+	rowValue := func(row StorageRow, column string) string {
+		if row == nil {
+			return "<none>"
+		}
+		return row[column]
+	}
+	for _, column := range names {
+		targetColumn := counterpartColumn(column)
+		d.add(table+"."+column, rowValue(row, column), table+"."+targetColumn, rowValue(targetRow, targetColumn),
+			!settlementLineLocalColumns[column])
+	}
+}
+
+// counterpartColumn returns the column that holds the same data on the other side of a settlement line.
+func counterpartColumn(column string) string {
+	if settlementLineLocalColumns[column] {
+		return column
+	}
+	replacements := [][2]string{
+		{"own_", "contractor_"},
+		{"contractor_", "own_"},
+		{"incoming_", "outgoing_"},
+		{"outgoing_", "incoming_"},
+	}
+	for _, replacement := range replacements {
+		if strings.HasPrefix(column, replacement[0]) {
+			return replacement[1] + strings.TrimPrefix(column, replacement[0])
+		}
+	}
+	return column
+}
+
+// HasMismatches reports whether any compared field differs between the nodes.
+func (d *SettlementLineStorageDiff) HasMismatches() bool {
+	for _, field := range d.Fields {
+		if field.Mismatch {
+			return true
+		}
+	}
+	return false
+}
+
+// String renders the diff as a table, mismatching fields are marked with "!=".
+func (d *SettlementLineStorageDiff) String() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "Settlement line %s <-> %s, equivalent %s:\n", d.Node.Alias, d.TargetNode.Alias, d.Equivalent)
+	writer := tabwriter.NewWriter(&builder, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(writer, "\tFIELD (%s)\tVALUE\tFIELD (%s)\tVALUE\n", d.Node.Alias, d.TargetNode.Alias)
+	for _, field := range d.Fields {
+		marker := ""
+		switch {
+		case field.Mismatch:
+			marker = "!="
+		case !field.Compared:
+			marker = "~"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", marker, field.Name, field.Value, field.TargetName, field.TargetValue)
+	}
+	writer.Flush()
+	return builder.String()
+}
+
+// settlementLineDiffReport is appended to settlement line sync failures.
+func (n *Node) settlementLineDiffReport(targetNode *Node, equivalent string) string {
+	diff, err := DiffSettlementLineStorage(n, targetNode, equivalent)
+	if err != nil {
+		return fmt.Sprintf("(failed to diff settlement line storage: %v)", err)
+	}
+	return diff.String()
+}
@@ -0,0 +1,132 @@
+package testsuite
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestParseSQLiteQuotedLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "doubled quotes", line: "'it''s','''quoted'''", want: []string{"it's", "'quoted'"}},
+		{name: "blobs", line: "X'0A0B',X''", want: []string{"0a0b", ""}},
+		{name: "null and empty values", line: "NULL,'',7", want: []string{"", "", "7"}},
+		{name: "commas inside strings", line: "'a, b',',','c'", want: []string{"a, b", ",", "c"}},
+		{name: "numbers", line: "1,-2,3.5", want: []string{"1", "-2", "3.5"}},
+		{name: "unterminated string", line: "'abc", wantErr: true},
+		{name: "unterminated blob", line: "X'0a", wantErr: true},
+		{name: "text after a string", line: "'a'b", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseSQLiteQuotedLine(test.line)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("values mismatch.\nExpected: %q\nGot:      %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestSQLiteLiteral(t *testing.T) {
+	tests := []struct {
+		arg     any
+		want    string
+		wantErr bool
+	}{
+		{arg: "it's", want: "'it''s'"},
+		{arg: "", want: "''"},
+		{arg: []byte{0xab, 0x01}, want: "x'ab01'"},
+		{arg: true, want: "1"},
+		{arg: false, want: "0"},
+		{arg: int64(-42), want: "-42"},
+		{arg: 1.5, wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := sqliteLiteral(test.arg)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%#v: expected an error, got %q", test.arg, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("%#v: expected %q, got %q (error: %v)", test.arg, test.want, got, err)
+		}
+	}
+}
+
+func TestReplacePlaceholders(t *testing.T) {
+	placeholder := func(i int) string { return fmt.Sprintf(":p%d", i) }
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "SELECT * FROM t WHERE a = ? AND b = ?", want: "SELECT * FROM t WHERE a = :p1 AND b = :p2"},
+		{query: "SELECT '?' FROM t WHERE a = ?", want: "SELECT '?' FROM t WHERE a = :p1"},
+		{query: "SELECT 'it''s ?' FROM t WHERE a = ?", want: "SELECT 'it''s ?' FROM t WHERE a = :p1"},
+		{query: "SELECT 1", want: "SELECT 1"},
+	}
+
+	for _, test := range tests {
+		if got := replacePlaceholders(test.query, placeholder); got != test.want {
+			t.Errorf("%q: expected %q, got %q", test.query, test.want, got)
+		}
+	}
+}
+
+func TestParsePostgreSQLOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    []StorageRow
+		wantErr bool
+	}{
+		{name: "no output", output: ""},
+		{name: "header only", output: "a\x1fb\n", want: []StorageRow{}},
+		{
+			name:   "newlines inside values",
+			output: "a\x1fb\x1e1\x1fline1\nline2\x1e2\x1f\n",
+			want:   []StorageRow{{"a": "1", "b": "line1\nline2"}, {"a": "2", "b": ""}},
+		},
+		{
+			name:   "booleans and bytea",
+			output: "flag\x1fkey\x1et\x1f\\xab01\x1ef\x1f\n",
+			want:   []StorageRow{{"flag": "1", "key": "ab01"}, {"flag": "0", "key": ""}},
+		},
+		{name: "row does not match the header", output: "a\x1fb\x1e1\n", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parsePostgreSQLOutput(test.output)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("rows mismatch.\nExpected: %q\nGot:      %q", test.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,348 @@
+package testsuite
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Test timeline: everything that happened during a test on one time axis, with a column for the test itself
+// and a column per node.
+//
+// When reporting is enabled, the cluster records the test's step log: the API calls to its nodes, the steps and
+// the assertions of the test. When the test finishes, the step log is merged with the operations.log of every node
+// (as collected with the artifacts), the network-condition changes and the testing-flag changes into
+// <ReportDir>/artifacts/<test>/timeline.html. Log timestamps are compared with the host clock, see Timeline.
+
+const (
+	TestEventStep              = "step"
+	TestEventAPICall           = "api"
+	TestEventAssertion         = "assertion"
+	TestEventNetworkConditions = "network"
+	TestEventTestingFlag       = "flag"
+	TestEventLog               = "log"
+
+	testTimelineFileName = "timeline.html"
+	// testTimelineColumn is the column of the events that don't belong to a node.
+	testTimelineColumn = "test"
+)
+
+// TestEvent is an entry of the test timeline.
+type TestEvent struct {
+	Kind      string
+	NodeAlias string // empty for events of the test itself
+	At        time.Time
+	Duration  time.Duration
+	Text      string
+	Failed    bool
+	Level     string // log entries only
+}
+
+// Step records a step of the test in the step log, e.g. "payment with the receiver offline".
+func (c *Cluster) Step(name string) {
+	c.recordEvent(TestEvent{Kind: TestEventStep, At: time.Now(), Text: name})
+}
+
+// RecordAssertion records the outcome of a check in the step log, err is the mismatch found by the check.
+func (c *Cluster) RecordAssertion(name string, err error) {
+	event := TestEvent{Kind: TestEventAssertion, At: time.Now(), Text: name + ": ok"}
+	if err != nil {
+		event.Text = fmt.Sprintf("%s: %v", name, err)
+		event.Failed = true
+	}
+	c.recordEvent(event)
+}
+
+// Assertions of the shared checks (see reportMismatches) are routed to the cluster of their test by the test name,
+// so that the checks of subtests are recorded as well.
+
+var (
+	recordedTestsMu sync.Mutex
+	recordedTests   = make(map[string]*Cluster) // by test name
+)
+
+// recordAssertions routes the assertions of the test and its subtests into the step log until the test finishes.
+func (c *Cluster) recordAssertions(t *testing.T) {
+	if c.report == nil || c.settings.ReportDir == "" {
+		return
+	}
+	name := t.Name()
+	recordedTestsMu.Lock()
+	recordedTests[name] = c
+	recordedTestsMu.Unlock()
+	t.Cleanup(func() {
+		recordedTestsMu.Lock()
+		defer recordedTestsMu.Unlock()
+		if recordedTests[name] == c {
+			delete(recordedTests, name)
+		}
+	})
+}
+
+// recordTestAssertion records the outcome of a check in the step log of the cluster of the test, if it is recorded.
+func recordTestAssertion(t *testing.T, name string, err error) {
+	recordedTestsMu.Lock()
+	var cluster *Cluster
+	for testName := t.Name(); cluster == nil; {
+		cluster = recordedTests[testName]
+		parent := strings.LastIndex(testName, "/")
+		if parent < 0 {
+			break
+		}
+		testName = testName[:parent]
+	}
+	recordedTestsMu.Unlock()
+	if cluster != nil {
+		cluster.RecordAssertion(name, err)
+	}
+}
+
+func (c *Cluster) recordEvent(event TestEvent) {
+	if c.report == nil || c.settings.ReportDir == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.steps = append(c.steps, event)
+}
+
+// API calls are recorded by the transport of apiClient, which sends every request of the suite to the nodes, routing
+// the requests to the cluster of the node they are sent to. The same registry lets node methods, which don't know
+// their cluster, trace into the cluster's test (see traceDockerCommand).
+
+type recordedNode struct {
+	cluster *Cluster
+	alias   string
+}
+
+var (
+	recordedNodesMu sync.Mutex
+	recordedNodes   = make(map[string]recordedNode) // by node IP address
+)
+
+// recordedNodeByIP returns the cluster and the alias of the node with the IP address, if its test is recorded.
+func recordedNodeByIP(ipAddress string) (recordedNode, bool) {
+	recordedNodesMu.Lock()
+	defer recordedNodesMu.Unlock()
+	target, ok := recordedNodes[ipAddress]
+	return target, ok
+}
+
+type recordingTransport struct {
+	base http.RoundTripper
+}
+
+func (rt *recordingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	target, ok := recordedNodeByIP(request.URL.Hostname())
+	if !ok {
+		return rt.base.RoundTrip(request)
+	}
+
+	startedAt := time.Now()
+	span := target.cluster.startAPISpan(request, target.alias)
+	resp, err := rt.base.RoundTrip(request)
+	endAPISpan(span, resp, err)
+
+	event := TestEvent{
+		Kind:      TestEventAPICall,
+		NodeAlias: target.alias,
+		At:        startedAt,
+		Duration:  time.Since(startedAt),
+		Text:      fmt.Sprintf("%s %s", request.Method, request.URL.RequestURI()),
+	}
+	if err != nil {
+		event.Text += fmt.Sprintf(" failed: %v", err)
+		event.Failed = true
+	} else {
+		event.Text += fmt.Sprintf(" -> %d", resp.StatusCode)
+		event.Failed = resp.StatusCode >= http.StatusInternalServerError
+	}
+	target.cluster.recordEvent(event)
+	return resp, err
+}
+
+// recordAPICalls routes the API calls to the node into the step log and the test's trace until the test finishes.
+func (c *Cluster) recordAPICalls(t *testing.T, node *Node) {
+	if c.report == nil || c.settings.ReportDir == "" {
+		return
+	}
+	recordedNodesMu.Lock()
+	recordedNodes[node.IPAddress] = recordedNode{cluster: c, alias: node.Alias}
+	recordedNodesMu.Unlock()
+	t.Cleanup(func() {
+		recordedNodesMu.Lock()
+		defer recordedNodesMu.Unlock()
+		if recordedNodes[node.IPAddress].cluster == c {
+			delete(recordedNodes, node.IPAddress)
+		}
+	})
+}
+
+// TestTimeline is the merged timeline of a test.
+type TestTimeline struct {
+	TestName string
+	Status   string
+	Columns  []string // testTimelineColumn, then the node aliases
+	Events   []TestEvent
+}
+
+// testTimeline merges the step log, the network conditions and the testing flags recorded during the test and
+// the node logs collected into artifactsDir.
+func (c *Cluster) testTimeline(name, status, artifactsDir string) *TestTimeline {
+	c.mu.Lock()
+	nodes := append([]*Node(nil), c.nodes...)
+	events := append([]TestEvent(nil), c.steps...)
+	networkConditions := append([]NetworkConditionsRecord(nil), c.networkConditions...)
+	c.mu.Unlock()
+
+	timeline := &TestTimeline{TestName: name, Status: status, Columns: []string{testTimelineColumn}}
+	for _, record := range networkConditions {
+		text := "network conditions removed"
+		if record.Conditions != nil {
+			text = fmt.Sprintf("network conditions: %+v", *record.Conditions)
+		}
+		events = append(events, TestEvent{Kind: TestEventNetworkConditions, NodeAlias: record.NodeAlias, At: record.AppliedAt, Text: text})
+	}
+	for _, node := range nodes {
+		timeline.Columns = append(timeline.Columns, node.Alias)
+		for _, flag := range node.testingFlags {
+			events = append(events, TestEvent{Kind: TestEventTestingFlag, NodeAlias: node.Alias, At: flag.AppliedAt,
+				Text: fmt.Sprintf("%s testing flag %d %s", flag.Kind, flag.Flag, strings.Join(flag.Params, " "))})
+		}
+		events = append(events, collectedLogEvents(node, artifactsDir)...)
+	}
+
+	// Stable: log lines without a timestamp keep their place after the previous line of the node.
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At.Before(events[j].At) })
+	timeline.Events = events
+	return timeline
+}
+
+// collectedLogEvents reads the operations.log of the node collected into artifactsDir, if any.
+// Lines without a timestamp take the timestamp of the previous line.
+func collectedLogEvents(node *Node, artifactsDir string) []TestEvent {
+	data, err := os.ReadFile(filepath.Join(artifactsDir, node.Alias, filepath.Base(DefaultOperationsLogPath)))
+	if err != nil {
+		return nil
+	}
+	var events []TestEvent
+	var last time.Time
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		entry := ParseLogLine(node.Alias, line)
+		if !entry.Timestamp.IsZero() {
+			last = entry.Timestamp
+		}
+		if last.IsZero() {
+			continue
+		}
+		text := entry.Message
+		if entry.Subsystem != "" {
+			text = fmt.Sprintf("[%s] %s", entry.Subsystem, entry.Message)
+		}
+		events = append(events, TestEvent{Kind: TestEventLog, NodeAlias: node.Alias, At: last, Text: text, Level: entry.Level,
+			Failed: entry.Level == "ERROR"})
+	}
+	return events
+}
+
+var testTimelineHTMLTemplate = template.Must(template.New("test-timeline").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.TestName}}</title>
+<style>
+body { font-family: monospace; font-size: 12px; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 2px 6px; vertical-align: top; }
+th { background: #eee; position: sticky; top: 0; }
+.kind { color: #888; }
+.step { background: #e8f0fe; font-weight: bold; }
+.api { background: #f4f4f4; }
+.assertion { background: #e6f4ea; }
+.network { background: #fef7e0; }
+.flag { background: #fce8e6; }
+.failed { color: #c33; font-weight: bold; }
+</style>
+</head>
+<body>
+<h3>{{.TestName}}: {{.Status}}</h3>
+<table>
+<tr><th>Time (UTC)</th>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr><td>{{.Time}}</td>{{range .Cells}}{{if .}}<td class="{{.Kind}}{{if .Failed}} failed{{end}}"><span class="kind">{{.Kind}}{{if .Level}} {{.Level}}{{end}}</span> {{.Text}}{{if .Duration}} <span class="kind">({{.Duration}})</span>{{end}}</td>{{else}}<td></td>{{end}}{{end}}</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+type testTimelineHTMLRow struct {
+	Time  string
+	Cells []*TestEvent
+}
+
+// HTML renders the timeline as a page with a column for the test and a column per node.
+func (tl *TestTimeline) HTML() (string, error) {
+	columns := make(map[string]int, len(tl.Columns))
+	for i, column := range tl.Columns {
+		columns[column] = i
+	}
+
+	rows := make([]testTimelineHTMLRow, 0, len(tl.Events))
+	for i := range tl.Events {
+		event := &tl.Events[i]
+		column, ok := columns[event.NodeAlias]
+		if !ok {
+			column = columns[testTimelineColumn]
+		}
+		row := testTimelineHTMLRow{
+			Time:  event.At.UTC().Format("15:04:05.000000"),
+			Cells: make([]*TestEvent, len(tl.Columns)),
+		}
+		row.Cells[column] = event
+		rows = append(rows, row)
+	}
+
+	var builder strings.Builder
+	err := testTimelineHTMLTemplate.Execute(&builder, struct {
+		TestName string
+		Status   string
+		Columns  []string
+		Rows     []testTimelineHTMLRow
+	}{tl.TestName, tl.Status, tl.Columns, rows})
+	if err != nil {
+		return "", fmt.Errorf("failed to render test timeline: %w", err)
+	}
+	return builder.String(), nil
+}
+
+// writeTestTimeline writes the timeline of the test next to its node artifacts and returns its path relative to
+// the report directory, or "" if it could not be written.
+func (c *Cluster) writeTestTimeline(t *testing.T, status string) string {
+	relativeDir := filepath.Join(reportArtifactsDirName, sanitizeFileName(t.Name()))
+	targetDir := filepath.Join(c.settings.ReportDir, relativeDir)
+
+	c.recordEvent(TestEvent{Kind: TestEventAssertion, At: time.Now(), Text: "test " + status, Failed: status == TestStatusFailed})
+	page, err := c.testTimeline(t.Name(), status, targetDir).HTML()
+	if err != nil {
+		t.Logf("%v", err)
+		return ""
+	}
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		t.Logf("failed to create artifacts directory %s: %v", targetDir, err)
+		return ""
+	}
+	if err := os.WriteFile(filepath.Join(targetDir, testTimelineFileName), []byte(page), 0o644); err != nil {
+		t.Logf("failed to write test timeline: %v", err)
+		return ""
+	}
+	return filepath.Join(relativeDir, testTimelineFileName)
+}
@@ -0,0 +1,24 @@
+package testsuite
+
+import "testing"
+
+func TestMismatchesAreRecordedInTheTimeline(t *testing.T) {
+	cluster := &Cluster{settings: &ClusterSettings{ReportDir: t.TempDir()}, report: &TestReportEntry{}}
+	cluster.recordAssertions(t)
+
+	t.Run("subtest", func(t *testing.T) {
+		// The step keeps the mismatches from failing the test.
+		step := SoftAssert(t, "after the payment")
+		reportMismatches(t, "max flows are wrong", []AssertionMismatch{{Check: "max flow", Node: "node1",
+			Field: "amount", Expected: "10", Actual: "5"}})
+		step.finish()
+	})
+
+	if len(cluster.steps) != 1 {
+		t.Fatalf("expected 1 event, got %d: %+v", len(cluster.steps), cluster.steps)
+	}
+	event := cluster.steps[0]
+	if event.Kind != TestEventAssertion || !event.Failed {
+		t.Errorf("expected a failed assertion, got %+v", event)
+	}
+}
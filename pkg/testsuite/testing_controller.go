@@ -0,0 +1,235 @@
+package testsuite
+
+import (
+	"fmt"
+	"log"
+	"math/bits"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestingFlags is a bitmask of the payment testing flags (Flag* constants),
+// sent to the subsystems controller of the node's testing port.
+type TestingFlags uint64
+
+// NewTestingFlags combines the flags into a bitmask.
+func NewTestingFlags(flags ...uint64) TestingFlags {
+	var result TestingFlags
+	for _, flag := range flags {
+		result = result.With(flag)
+	}
+	return result
+}
+
+// With returns the bitmask with the flag added.
+func (f TestingFlags) With(flag uint64) TestingFlags {
+	return f | TestingFlags(flag)
+}
+
+// Without returns the bitmask with the flag removed.
+func (f TestingFlags) Without(flag uint64) TestingFlags {
+	return f &^ TestingFlags(flag)
+}
+
+// Has reports whether the flag is set.
+func (f TestingFlags) Has(flag uint64) bool {
+	return uint64(f)&flag == flag
+}
+
+// Names returns the names of the set flags, unknown bits are returned as numbers.
+func (f TestingFlags) Names() []string {
+	var names []string
+	for bit := uint64(f); bit != 0; bit &= bit - 1 {
+		flag := uint64(1) << bits.TrailingZeros64(bit)
+		names = append(names, testingFlagName(flag))
+	}
+	return names
+}
+
+func (f TestingFlags) String() string {
+	if f == 0 {
+		return "none"
+	}
+	return strings.Join(f.Names(), "|")
+}
+
+func testingFlagName(flag uint64) string {
+	for name, value := range TestingFlagsByName {
+		if value == flag {
+			return name
+		}
+	}
+	return fmt.Sprint(flag)
+}
+
+// Failure injection flags are named <kind><stage>, e.g. FlagThrowExceptionVote and FlagTerminateProcessVote.
+const (
+	testingFlagThrowExceptionPrefix = "FlagThrowException"
+	testingFlagTerminatePrefix      = "FlagTerminateProcess"
+)
+
+// Validate checks that the combination makes sense:
+//   - every bit is a known flag;
+//   - at most one process termination is requested, the process never gets to the later ones;
+//   - an exception and a process termination are not requested for the same stage, only one of them can happen.
+func (f TestingFlags) Validate() error {
+	var unknown, terminations []string
+	stages := make(map[string]string)
+	var conflicts []string
+	for _, name := range f.Names() {
+		if _, ok := TestingFlagsByName[name]; !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+
+		var stage string
+		switch {
+		case strings.HasPrefix(name, testingFlagTerminatePrefix):
+			terminations = append(terminations, name)
+			stage = strings.TrimPrefix(name, testingFlagTerminatePrefix)
+		case strings.HasPrefix(name, testingFlagThrowExceptionPrefix):
+			stage = strings.TrimPrefix(name, testingFlagThrowExceptionPrefix)
+		default:
+			continue
+		}
+		if other, ok := stages[stage]; ok {
+			conflicts = append(conflicts, other+" and "+name)
+		}
+		stages[stage] = name
+	}
+
+	var problems []string
+	if len(unknown) > 0 {
+		problems = append(problems, "unknown flags: "+strings.Join(unknown, ", "))
+	}
+	if len(terminations) > 1 {
+		problems = append(problems, "more than one process termination: "+strings.Join(terminations, ", "))
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		problems = append(problems, "exception and termination on the same stage: "+strings.Join(conflicts, "; "))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid testing flags %d: %s", uint64(f), strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// TestingFlagsState is the set of payment testing flags active on a node.
+type TestingFlagsState struct {
+	Flags   TestingFlags
+	Address string
+	Amount  string
+}
+
+// TestingController manages the testing flags of a node.
+//
+// The testing port has no endpoint to read the flags back, so the controller caches the state that was
+// last successfully sent to the node by this process (by the controller, SetTestingFlag or SendTestingFlag).
+// A vtcpd restart through the suite resets the cache.
+//
+// Nodes created without a test (t is nil, e.g. by cmd/vtcp-suite) can use the controller as well:
+// failures panic instead of failing the test, and the flags are not reset automatically.
+type TestingController struct {
+	node *Node
+	t    *testing.T
+
+	mu      sync.Mutex
+	current TestingFlagsState
+}
+
+func newTestingController(t *testing.T, node *Node) *TestingController {
+	return &TestingController{node: node, t: t}
+}
+
+// LastApplied returns the payment testing flags last sent to the node by this process, from the local cache.
+// It is not a readback of the flags active on the node (the testing port has no endpoint for that): flags set
+// from elsewhere (another process, vtcp-suite flag) are not seen, and neither is a reset by a vtcpd crash.
+func (c *TestingController) LastApplied() TestingFlagsState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.current
+}
+
+func (c *TestingController) setCurrent(state TestingFlagsState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.current = state
+}
+
+// fatalf fails the test, or panics for a node created without one.
+func (c *TestingController) fatalf(format string, args ...any) {
+	if c.t == nil {
+		panic(fmt.Sprintf(format, args...))
+	}
+	c.t.Helper()
+	c.t.Fatalf(format, args...)
+}
+
+func (c *TestingController) logf(format string, args ...any) {
+	if c.t == nil {
+		log.Printf(format, args...)
+		return
+	}
+	c.t.Logf(format, args...)
+}
+
+// cleanup registers f to run when the test finishes, nodes created without a test have to call it themselves.
+func (c *TestingController) cleanup(f func()) {
+	if c.t != nil {
+		c.t.Cleanup(f)
+	}
+}
+
+// Apply validates the flags and sets them on the node (replacing the active ones) for the given contractor address
+// and amount (both optional). The returned func restores the previous flags, it is also registered in t.Cleanup,
+// so the flags never outlive the test. Calling it more than once is safe.
+// Resetting fails when the flags have terminated the node's process (which resets them anyway), so it only logs errors.
+func (c *TestingController) Apply(flags TestingFlags, address string, amount string) func() {
+	if c.t != nil {
+		c.t.Helper()
+	}
+	if err := flags.Validate(); err != nil {
+		c.fatalf("Node %s: %v", c.node.Alias, err)
+	}
+
+	previous := c.LastApplied()
+	if err := c.node.SendTestingFlag(uint64(flags), address, amount); err != nil {
+		c.fatalf("Node %s: failed to apply testing flags %s: %v", c.node.Alias, flags, err)
+	}
+
+	var once sync.Once
+	reset := func() {
+		once.Do(func() {
+			if err := c.node.SendTestingFlag(uint64(previous.Flags), previous.Address, previous.Amount); err != nil {
+				c.logf("Node %s: failed to reset testing flags to %s: %v", c.node.Alias, previous.Flags, err)
+			}
+		})
+	}
+	c.cleanup(reset)
+	return reset
+}
+
+// ApplySettlementLine sets a settlement line testing flag (see SetTestingSLFlag).
+// The returned func clears the flag, it is also registered in t.Cleanup.
+func (c *TestingController) ApplySettlementLine(flag uint64, firstParam, secondParam, thirdParam string) func() {
+	if c.t != nil {
+		c.t.Helper()
+	}
+	if err := c.node.SetTestingSLFlag(flag, firstParam, secondParam, thirdParam); err != nil {
+		c.fatalf("Node %s: failed to apply settlement line testing flag %d: %v", c.node.Alias, flag, err)
+	}
+
+	var once sync.Once
+	reset := func() {
+		once.Do(func() {
+			if err := c.node.SetTestingSLFlag(0, "", "", ""); err != nil {
+				c.logf("Node %s: failed to reset settlement line testing flag: %v", c.node.Alias, err)
+			}
+		})
+	}
+	c.cleanup(reset)
+	return reset
+}
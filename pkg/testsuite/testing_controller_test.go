@@ -0,0 +1,140 @@
+package testsuite
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTestingControllerWithoutTest(t *testing.T) {
+	node := NewNode(nil, "10.0.0.2", "node1")
+
+	defer func() {
+		recovered := recover()
+		message, ok := recovered.(string)
+		if !ok || !strings.Contains(message, "Node node1: invalid testing flags") {
+			t.Fatalf("expected a panic with the validation error, got %v", recovered)
+		}
+		if flags := node.Testing.LastApplied().Flags; flags != 0 {
+			t.Errorf("expected no flags to be applied, got %s", flags)
+		}
+	}()
+	node.Testing.Apply(NewTestingFlags(1<<63), "", "")
+}
+
+func TestTestingFlagsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   TestingFlags
+		wantErr string
+	}{
+		{name: "no flags", flags: 0},
+		{name: "independent flags", flags: NewTestingFlags(FlagForbidSendInitMessage, FlagThrowExceptionVote)},
+		{name: "single termination", flags: NewTestingFlags(FlagTerminateProcessVote, FlagForbidSendInitMessage)},
+		{
+			name:    "exception and termination on the same stage",
+			flags:   NewTestingFlags(FlagThrowExceptionVote, FlagTerminateProcessVote),
+			wantErr: "exception and termination on the same stage: FlagThrowExceptionVote and FlagTerminateProcessVote",
+		},
+		{
+			name:    "more than one termination",
+			flags:   NewTestingFlags(FlagTerminateProcessCoordinatorRequest, FlagTerminateProcessVote),
+			wantErr: "more than one process termination: FlagTerminateProcessCoordinatorRequest, FlagTerminateProcessVote",
+		},
+		{name: "unknown bit", flags: NewTestingFlags(1 << 62), wantErr: "unknown flags: 4611686018427387904"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.flags.Validate()
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("expected an error containing %q, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestTestingFlagsNames(t *testing.T) {
+	tests := []struct {
+		flags      TestingFlags
+		wantNames  []string
+		wantString string
+	}{
+		{flags: 0, wantString: "none"},
+		{flags: NewTestingFlags(FlagForbidSendInitMessage), wantNames: []string{"FlagForbidSendInitMessage"},
+			wantString: "FlagForbidSendInitMessage"},
+		{flags: NewTestingFlags(FlagThrowExceptionVote, FlagForbidSendInitMessage),
+			wantNames:  []string{"FlagForbidSendInitMessage", "FlagThrowExceptionVote"},
+			wantString: "FlagForbidSendInitMessage|FlagThrowExceptionVote"},
+		{flags: NewTestingFlags(FlagForbidSendInitMessage, 1<<62), wantNames: []string{"FlagForbidSendInitMessage", "4611686018427387904"},
+			wantString: "FlagForbidSendInitMessage|4611686018427387904"},
+	}
+
+	for _, test := range tests {
+		if names := test.flags.Names(); !reflect.DeepEqual(names, test.wantNames) {
+			t.Errorf("%d: expected names %v, got %v", uint64(test.flags), test.wantNames, names)
+		}
+		if s := test.flags.String(); s != test.wantString {
+			t.Errorf("%d: expected %q, got %q", uint64(test.flags), test.wantString, s)
+		}
+	}
+}
+
+// testingPortNode returns a node whose testing port is a local server recording the flags sent to it.
+func testingPortNode(t *testing.T) (*Node, *[]string) {
+	var (
+		mu   sync.Mutex
+		sent []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		sent = append(sent, path.Base(r.URL.Path)+" "+r.URL.Query().Get("forbidden_address"))
+	}))
+	t.Cleanup(server.Close)
+
+	address, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("failed to parse server URL: %v", err)
+	}
+	port, err := strconv.ParseUint(address.Port(), 10, 16)
+	if err != nil {
+		t.Fatalf("failed to parse server port: %v", err)
+	}
+	node := NewNode(t, address.Hostname(), "node1")
+	node.CLIPortTest = uint16(port)
+	return node, &sent
+}
+
+func TestTestingControllerApplyRestoresPreviousFlags(t *testing.T) {
+	node, sent := testingPortNode(t)
+
+	node.Testing.Apply(NewTestingFlags(FlagForbidSendInitMessage), "10.0.0.3:2000", "")
+	reset := node.Testing.Apply(NewTestingFlags(FlagThrowExceptionVote), "", "")
+	if flags := node.Testing.LastApplied().Flags; flags != NewTestingFlags(FlagThrowExceptionVote) {
+		t.Fatalf("expected FlagThrowExceptionVote to be applied, got %s", flags)
+	}
+
+	reset()
+	reset()
+	state := node.Testing.LastApplied()
+	if state.Flags != NewTestingFlags(FlagForbidSendInitMessage) || state.Address != "10.0.0.3:2000" {
+		t.Errorf("expected the previous flags to be restored, got %+v", state)
+	}
+	want := []string{"4 10.0.0.3:2000", "16384 ", "4 10.0.0.3:2000"}
+	if !reflect.DeepEqual(*sent, want) {
+		t.Errorf("requests mismatch.\nExpected: %q\nGot:      %q", want, *sent)
+	}
+}
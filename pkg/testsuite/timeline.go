@@ -0,0 +1,245 @@
+package testsuite
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"text/tabwriter"
+	"time"
+)
+
+// Transaction timeline: log entries of one transaction from every node of the cluster, merged by time.
+// Containers share the host clock, so timestamps of different nodes are comparable.
+
+const (
+	TimelineStageReservation         = "reservation"
+	TimelineStageFinalPathConfig     = "final path config"
+	TimelineStageAmountClarification = "amount clarification"
+	TimelineStageVote                = "vote"
+	TimelineStageVoteConsistency     = "vote consistency"
+	TimelineStageRecovery            = "recovery"
+)
+
+// timelineStageKeywords are searched in the subsystem and message of a log entry, lowercased and without
+// spaces and underscores. The order matters: "runVotesRecoveryParentStage" is a recovery, not a vote.
+var timelineStageKeywords = []struct {
+	stage    string
+	keywords []string
+}{
+	{TimelineStageRecovery, []string{"recovery", "recovering"}},
+	{TimelineStageVoteConsistency, []string{"consistency"}},
+	{TimelineStageFinalPathConfig, []string{"finalpathconfig", "finalpathsconfig", "finalamountsconfig"}},
+	{TimelineStageAmountClarification, []string{"amountclarification", "amountsclarification"}},
+	{TimelineStageReservation, []string{"reservation", "reserve"}},
+	{TimelineStageVote, []string{"vote"}},
+}
+
+// timelineStage returns the stage the log entry belongs to, or "" if it can't be recognized.
+func timelineStage(entry LogEntry) string {
+	text := strings.NewReplacer(" ", "", "_", "").Replace(strings.ToLower(entry.Subsystem + " " + entry.Message))
+	for _, stage := range timelineStageKeywords {
+		for _, keyword := range stage.keywords {
+			if strings.Contains(text, keyword) {
+				return stage.stage
+			}
+		}
+	}
+	return ""
+}
+
+// TimelineEntry is a log entry of the transaction.
+type TimelineEntry struct {
+	LogEntry
+	Stage        string
+	StageChanged bool // the entry is the first one of its stage on the node
+}
+
+// Time is the moment used to order the entry.
+func (e TimelineEntry) Time() time.Time {
+	if e.Timestamp.IsZero() {
+		return e.ReceivedAt
+	}
+	return e.Timestamp
+}
+
+// Timeline is the merged log of a transaction.
+type Timeline struct {
+	TransactionUUID string
+	NodeAliases     []string
+	Entries         []TimelineEntry
+}
+
+// Timeline merges log entries of the transaction from every node of the cluster.
+func (c *Cluster) Timeline(transactionUUID string) (*Timeline, error) {
+	c.mu.Lock()
+	nodes := append([]*Node(nil), c.nodes...)
+	c.mu.Unlock()
+
+	timeline := &Timeline{TransactionUUID: transactionUUID}
+	matcher := LogTransaction(transactionUUID)
+	for _, node := range nodes {
+		entries, err := node.ReadLogs()
+		if err != nil {
+			return nil, err
+		}
+		timeline.NodeAliases = append(timeline.NodeAliases, node.Alias)
+
+		currentStage := ""
+		for _, entry := range entries {
+			if !matcher(entry) {
+				continue
+			}
+			timelineEntry := TimelineEntry{LogEntry: entry, Stage: timelineStage(entry)}
+			if timelineEntry.Stage != "" && timelineEntry.Stage != currentStage {
+				timelineEntry.StageChanged = true
+				currentStage = timelineEntry.Stage
+			}
+			timeline.Entries = append(timeline.Entries, timelineEntry)
+		}
+	}
+
+	// Stable: entries of a node without timestamps keep the log order.
+	sort.SliceStable(timeline.Entries, func(i, j int) bool {
+		return timeline.Entries[i].Time().Before(timeline.Entries[j].Time())
+	})
+	return timeline, nil
+}
+
+// Text renders the timeline as a table, stage transitions are marked with "==>".
+func (tl *Timeline) Text() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "Timeline of transaction %s (%d entries):\n", tl.TransactionUUID, len(tl.Entries))
+	writer := tabwriter.NewWriter(&builder, 0, 4, 2, ' ', 0)
+	for _, entry := range tl.Entries {
+		stage := ""
+		if entry.StageChanged {
+			stage = "==> " + entry.Stage
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n",
+			entry.Time().Format("15:04:05.000000"), entry.NodeAlias, stage, entry.Level, entry.Message)
+	}
+	writer.Flush()
+	return builder.String()
+}
+
+var timelineHTMLTemplate = template.Must(template.New("timeline").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Transaction {{.TransactionUUID}}</title>
+<style>
+body { font-family: monospace; font-size: 12px; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 2px 6px; vertical-align: top; }
+th { background: #eee; position: sticky; top: 0; }
+tr.stage td { border-top: 2px solid #36c; }
+.stage-name { color: #36c; font-weight: bold; }
+.level-ERROR, .level-WARNING { color: #c33; }
+</style>
+</head>
+<body>
+<h3>Transaction {{.TransactionUUID}}</h3>
+<table>
+<tr><th>Time</th>{{range .NodeAliases}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr{{if .StageChanged}} class="stage"{{end}}><td>{{.Time}}</td>{{range .Cells}}<td>{{if .}}{{if .StageChanged}}<div class="stage-name">{{.Stage}}</div>{{end}}<span class="level-{{.Level}}">{{.Level}}</span> {{.Message}}{{end}}</td>{{end}}</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+type timelineHTMLRow struct {
+	Time         string
+	StageChanged bool
+	Cells        []*TimelineEntry
+}
+
+// HTML renders the timeline as a page with a column per node.
+func (tl *Timeline) HTML() (string, error) {
+	columns := make(map[string]int, len(tl.NodeAliases))
+	for i, alias := range tl.NodeAliases {
+		columns[alias] = i
+	}
+
+	rows := make([]timelineHTMLRow, 0, len(tl.Entries))
+	for i := range tl.Entries {
+		entry := &tl.Entries[i]
+		row := timelineHTMLRow{
+			Time:         entry.Time().Format("15:04:05.000000"),
+			StageChanged: entry.StageChanged,
+			Cells:        make([]*TimelineEntry, len(tl.NodeAliases)),
+		}
+		row.Cells[columns[entry.NodeAlias]] = entry
+		rows = append(rows, row)
+	}
+
+	var builder strings.Builder
+	err := timelineHTMLTemplate.Execute(&builder, struct {
+		TransactionUUID string
+		NodeAliases     []string
+		Rows            []timelineHTMLRow
+	}{tl.TransactionUUID, tl.NodeAliases, rows})
+	if err != nil {
+		return "", fmt.Errorf("failed to render timeline: %w", err)
+	}
+	return builder.String(), nil
+}
+
+// LogTimeline logs the timeline of the transaction and, when reporting is enabled,
+// saves its HTML version next to the node artifacts of the test.
+func (c *Cluster) LogTimeline(t *testing.T, transactionUUID string) {
+	timeline, err := c.Timeline(transactionUUID)
+	if err != nil {
+		t.Logf("failed to build timeline of transaction %s: %v", transactionUUID, err)
+		return
+	}
+	t.Log(timeline.Text())
+
+	if c.settings.ReportDir == "" {
+		return
+	}
+	page, err := timeline.HTML()
+	if err != nil {
+		t.Logf("%v", err)
+		return
+	}
+	targetDir := filepath.Join(c.settings.ReportDir, reportArtifactsDirName, sanitizeFileName(t.Name()))
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		t.Logf("failed to create artifacts directory %s: %v", targetDir, err)
+		return
+	}
+	path := filepath.Join(targetDir, "timeline-"+transactionUUID+".html")
+	if err := os.WriteFile(path, []byte(page), 0o644); err != nil {
+		t.Logf("failed to write timeline %s: %v", path, err)
+	}
+}
+
+// LogTimelineOnFailure logs, when the test fails, the timeline of every payment the cluster's nodes initiated
+// after the call. Register it before the payments, so that a test failing in the payment call itself
+// (e.g. on an unexpected status) still gets the timeline.
+// The cleanup runs before the nodes are removed, so their logs are still available.
+func (c *Cluster) LogTimelineOnFailure(t *testing.T) {
+	c.mu.Lock()
+	nodes := append([]*Node(nil), c.nodes...)
+	c.mu.Unlock()
+
+	initiated := make([]int, len(nodes))
+	for i, node := range nodes {
+		_, initiated[i] = node.initiatedTransactionsSince(0)
+	}
+
+	t.Cleanup(func() {
+		if !t.Failed() {
+			return
+		}
+		for i, node := range nodes {
+			transactionUUIDs, _ := node.initiatedTransactionsSince(initiated[i])
+			for _, transactionUUID := range transactionUUIDs {
+				c.LogTimeline(t, transactionUUID)
+			}
+		}
+	})
+}
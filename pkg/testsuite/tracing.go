@@ -0,0 +1,231 @@
+package testsuite
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Tracing.
+//
+// When reporting is enabled, every test is traced with OpenTelemetry: the test is the root span, the cluster setup
+// phases, the API calls to the nodes and the docker execs into their containers are its descendants, with the node
+// alias, the endpoint or command and the status as attributes. Spans are appended to <ReportDir>/<subsystem>.traces.jsonl
+// by the stdout exporter, one JSON span per line. If OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or OTEL_EXPORTER_OTLP_ENDPOINT
+// is set, they are also sent there by the OTLP/HTTP exporter, which takes the rest of its settings from the standard
+// OTEL_EXPORTER_OTLP_* variables. Spans are exported in batches, which are flushed when a test ends; export errors
+// are logged.
+
+const (
+	tracerName = "github.com/vTCP-Foundation/vtcpd-test-suite"
+
+	AttributeTest       = attribute.Key("vtcp.test")
+	AttributeSubsystem  = attribute.Key("vtcp.subsystem")
+	AttributeNodeAlias  = attribute.Key("vtcp.node.alias")
+	AttributeNodeCount  = attribute.Key("vtcp.node.count")
+	AttributeEndpoint   = attribute.Key("vtcp.endpoint")
+	AttributeMethod     = attribute.Key("http.request.method")
+	AttributeStatusCode = attribute.Key("http.response.status_code")
+	AttributeCommand    = attribute.Key("vtcp.docker.command")
+	AttributeExitCode   = attribute.Key("process.exit.code")
+	AttributeStatus     = attribute.Key("vtcp.status")
+
+	// flushTimeout bounds the export of the spans of a test when it ends.
+	flushTimeout = 10 * time.Second
+	// maxCommandAttributeLength cuts the commands in the span attributes, shell scripts with configs can be long.
+	maxCommandAttributeLength = 256
+)
+
+var (
+	tracingOnce sync.Once
+	// testTracer is the tracer of the process. Each test package runs in its own process, so the spans of
+	// a process belong to a single subsystem.
+	testTracer trace.Tracer = noop.NewTracerProvider().Tracer(tracerName)
+	// tracerProvider is nil until tracing is set up, or if the exporters could not be created.
+	tracerProvider *sdktrace.TracerProvider
+)
+
+// initTracing sets up the tracer of the process on the first test that has reporting enabled.
+func initTracing(reportDir, subsystem string) {
+	tracingOnce.Do(func() {
+		otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+			log.Printf("tracing: %v", err)
+		}))
+
+		options, err := traceExporters(filepath.Join(reportDir, subsystem+".traces.jsonl"))
+		if err != nil {
+			log.Printf("tracing is disabled: %v", err)
+			return
+		}
+		resource := sdkresource.NewSchemaless(
+			attribute.String("service.name", "vtcpd-test-suite"),
+			AttributeSubsystem.String(subsystem),
+		)
+		tracerProvider = sdktrace.NewTracerProvider(append(options, sdktrace.WithResource(resource))...)
+		testTracer = tracerProvider.Tracer(tracerName)
+	})
+}
+
+// traceExporters returns the batch span processors of the trace file and, if an endpoint is configured,
+// of the OTLP collector.
+func traceExporters(path string) ([]sdktrace.TracerProviderOption, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trace file: %w", err)
+	}
+	fileExporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to create trace file exporter: %w", err)
+	}
+	options := []sdktrace.TracerProviderOption{sdktrace.WithBatcher(fileExporter)}
+
+	if os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" {
+		collectorExporter, err := otlptracehttp.New(context.Background())
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+		options = append(options, sdktrace.WithBatcher(collectorExporter))
+	}
+	return options, nil
+}
+
+// startTestSpan starts the root span of the test, it is ended by endTestSpan when the report is written.
+func (c *Cluster) startTestSpan(t *testing.T) {
+	if c.settings.ReportDir == "" {
+		return
+	}
+	initTracing(c.settings.ReportDir, c.report.Subsystem)
+	c.traceCtx, c.testSpan = testTracer.Start(context.Background(), t.Name(),
+		trace.WithAttributes(AttributeTest.String(t.Name()), AttributeSubsystem.String(c.report.Subsystem)))
+}
+
+// endTestSpan ends the root span of the test and exports the spans of the test.
+func (c *Cluster) endTestSpan(t *testing.T, status string) {
+	if c.testSpan == nil {
+		return
+	}
+	c.testSpan.SetAttributes(AttributeStatus.String(status))
+	if status == TestStatusFailed {
+		c.testSpan.SetStatus(codes.Error, "test failed")
+	}
+	c.testSpan.End()
+
+	if tracerProvider == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+	if err := tracerProvider.ForceFlush(ctx); err != nil {
+		t.Logf("failed to export the spans of the test: %v", err)
+	}
+}
+
+// traceContext returns ctx if it carries a span, the context of the test span otherwise.
+func (c *Cluster) traceContext(ctx context.Context) context.Context {
+	if ctx != nil && trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	if c.traceCtx != nil {
+		return c.traceCtx
+	}
+	return context.Background()
+}
+
+// startSpan starts a span of a cluster phase, as a child of the span of ctx or of the test span.
+func (c *Cluster) startSpan(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return testTracer.Start(c.traceContext(ctx), name, trace.WithAttributes(attributes...))
+}
+
+// endSpan ends the span with the outcome of its phase.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (c *Cluster) startAPISpan(request *http.Request, alias string) trace.Span {
+	_, span := testTracer.Start(c.traceContext(request.Context()), request.Method+" "+request.URL.Path,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			AttributeNodeAlias.String(alias),
+			AttributeEndpoint.String(request.URL.Path),
+			AttributeMethod.String(request.Method),
+		))
+	return span
+}
+
+func endAPISpan(span trace.Span, resp *http.Response, err error) {
+	if err == nil {
+		span.SetAttributes(AttributeStatusCode.Int(resp.StatusCode))
+		if resp.StatusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, resp.Status)
+		}
+	}
+	endSpan(span, err)
+}
+
+// traceDockerCommand runs a docker command into the node's container (docker exec, docker cp) with run,
+// e.g. cmd.Output, as a span of the node's test. The command must be created with ctx (exec.CommandContext),
+// an error caused by its step deadline is returned as a StepTimeoutError.
+func traceDockerCommand[T any](ctx context.Context, n *Node, cmd *exec.Cmd, run func() (T, error)) (T, error) {
+	target, ok := recordedNodeByIP(n.IPAddress)
+	if !ok {
+		result, err := run()
+		return result, n.dockerTimeoutError(ctx, cmd, err)
+	}
+
+	command := strings.Join(cmd.Args, " ")
+	if len(command) > maxCommandAttributeLength {
+		command = command[:maxCommandAttributeLength] + "..."
+	}
+	name := "docker"
+	if len(cmd.Args) > 1 {
+		name += " " + cmd.Args[1]
+	}
+	_, span := target.cluster.startSpan(ctx, name, AttributeNodeAlias.String(n.Alias), AttributeCommand.String(command))
+	result, err := run()
+	err = n.dockerTimeoutError(ctx, cmd, err)
+	if cmd.ProcessState != nil {
+		span.SetAttributes(AttributeExitCode.Int(cmd.ProcessState.ExitCode()))
+	}
+	endSpan(span, err)
+	return result, err
+}
+
+// dockerOutput runs cmd.Output as a span, see traceDockerCommand.
+func (n *Node) dockerOutput(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	return traceDockerCommand(ctx, n, cmd, cmd.Output)
+}
+
+// dockerCombinedOutput runs cmd.CombinedOutput as a span, see traceDockerCommand.
+func (n *Node) dockerCombinedOutput(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	return traceDockerCommand(ctx, n, cmd, cmd.CombinedOutput)
+}
+
+// dockerRun runs cmd.Run as a span, see traceDockerCommand.
+func (n *Node) dockerRun(ctx context.Context, cmd *exec.Cmd) error {
+	_, err := traceDockerCommand(ctx, n, cmd, func() (struct{}, error) { return struct{}{}, cmd.Run() })
+	return err
+}
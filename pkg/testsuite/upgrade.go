@@ -0,0 +1,143 @@
+package testsuite
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// nodePersistentPaths are carried over to the new container on upgrade: the storage (with the node's keys),
+// the config and the log. The PostgreSQL data directory is only present in PostgreSQL setups.
+var nodePersistentPaths = []string{
+	"/vtcp/vtcpd/io",
+	NodeConfigPath,
+	DefaultOperationsLogPath,
+	"/var/lib/postgresql/data",
+}
+
+// UpgradeNode swaps the node's container for one created from newImage. The node keeps its identity
+// (address, ports, environment) and its storage, config and log, so the new build has to load a database
+// written by the previous one. The node is ready to accept API requests when UpgradeNode returns.
+// The old container is only stopped until the new one is up: if the new build doesn't start or doesn't
+// become ready, the new container is removed and the old one is started again.
+// Every docker operation of the upgrade has the step deadline.
+func (c *Cluster) UpgradeNode(ctx context.Context, node *Node, newImage string) error {
+	if node.ContainerID == "" {
+		return fmt.Errorf("Node %s: ContainerID is not set, cannot upgrade", node.Alias)
+	}
+	oldContainerID := node.ContainerID
+
+	// step runs a docker operation of the upgrade with the step deadline.
+	step := func(operation string, run func(ctx context.Context) error) error {
+		stepCtx, cancel := c.stepContext(ctx)
+		defer cancel()
+		return c.stepTimeoutError(stepCtx, node, operation, run(stepCtx))
+	}
+
+	// The old container holds the node's address while it runs, a stopped one releases it
+	secondsToWait := 10
+	err := step("container stop", func(ctx context.Context) error {
+		return c.cli.ContainerStop(ctx, oldContainerID, container.StopOptions{Timeout: &secondsToWait})
+	})
+	if err != nil {
+		return fmt.Errorf("Node %s: failed to stop container: %v", node.Alias, err)
+	}
+
+	var newContainerID string
+	err = step("container create", func(ctx context.Context) (err error) {
+		newContainerID, err = c.createNodeContainer(ctx, node, newImage, node.databaseConfig)
+		return err
+	})
+	if err != nil {
+		return c.rollbackUpgrade(node, oldContainerID, "", newImage, err)
+	}
+
+	for _, persistentPath := range nodePersistentPaths {
+		err := step("copy "+persistentPath, func(ctx context.Context) error {
+			return c.copyBetweenContainers(ctx, oldContainerID, newContainerID, persistentPath)
+		})
+		if err != nil {
+			return c.rollbackUpgrade(node, oldContainerID, newContainerID, newImage, err)
+		}
+	}
+
+	err = step("container start", func(ctx context.Context) error {
+		return c.cli.ContainerStart(ctx, newContainerID, container.StartOptions{})
+	})
+	if err != nil {
+		return c.rollbackUpgrade(node, oldContainerID, newContainerID, newImage, fmt.Errorf("failed to start upgraded container: %v", err))
+	}
+	node.ContainerID = newContainerID
+
+	// Testing flags live in the process memory only
+	if node.Testing != nil {
+		node.Testing.setCurrent(TestingFlagsState{})
+	}
+
+	if err := node.waitForRespawn(ctx, nil, DefaultRestartReadyTimeout, &RestartResult{}); err != nil {
+		return c.rollbackUpgrade(node, oldContainerID, newContainerID, newImage, err)
+	}
+	node.Image = newImage
+
+	err = step("container remove", func(ctx context.Context) error {
+		return c.cli.ContainerRemove(ctx, oldContainerID, container.RemoveOptions{Force: true})
+	})
+	if err != nil {
+		return fmt.Errorf("Node %s: upgraded to %s, but failed to remove the old container %s: %v",
+			node.Alias, newImage, oldContainerID, err)
+	}
+	return nil
+}
+
+// rollbackUpgrade removes the new container, if it was created, and starts the old one again.
+// It returns the upgrade error, together with the rollback error if the old container doesn't come back.
+func (c *Cluster) rollbackUpgrade(node *Node, oldContainerID, newContainerID, newImage string, upgradeErr error) error {
+	// The rollback runs even if the upgrade's context is cancelled.
+	rollbackCtx := context.WithoutCancel(node.runContext())
+	ctx, cancel := c.stepContext(rollbackCtx)
+	defer cancel()
+
+	var rollbackErr error
+	if newContainerID != "" {
+		rollbackErr = c.cli.ContainerRemove(ctx, newContainerID, container.RemoveOptions{Force: true})
+	}
+	node.ContainerID = oldContainerID
+	if rollbackErr == nil {
+		rollbackErr = c.cli.ContainerStart(ctx, oldContainerID, container.StartOptions{})
+	}
+	if node.Testing != nil {
+		node.Testing.setCurrent(TestingFlagsState{})
+	}
+	if rollbackErr == nil {
+		rollbackErr = node.waitForRespawn(rollbackCtx, nil, DefaultRestartReadyTimeout, &RestartResult{})
+	}
+
+	if rollbackErr != nil {
+		return fmt.Errorf("Node %s: failed to upgrade to %s: %v; the old container didn't come back: %v",
+			node.Alias, newImage, upgradeErr, rollbackErr)
+	}
+	return fmt.Errorf("Node %s: failed to upgrade to %s, the old container is running again: %v",
+		node.Alias, newImage, upgradeErr)
+}
+
+// copyBetweenContainers copies the file or directory at path from one container to the same place in another.
+// A path missing in the source container is skipped.
+func (c *Cluster) copyBetweenContainers(ctx context.Context, sourceID, targetID, sourcePath string) error {
+	content, _, err := c.cli.CopyFromContainer(ctx, sourceID, sourcePath)
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to copy %s from container: %v", sourcePath, err)
+	}
+	defer content.Close()
+
+	options := container.CopyToContainerOptions{CopyUIDGID: true}
+	if err := c.cli.CopyToContainer(ctx, targetID, path.Dir(sourcePath), content, options); err != nil {
+		return fmt.Errorf("failed to copy %s into container: %v", sourcePath, err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	vtcp "github.com/vTCP-Foundation/vtcpd-test-suite/pkg/testsuite"
+	"github.com/vTCP-Foundation/vtcpd-test-suite/tests/testconfig"
+)
+
+const (
+	// API_FUZZ_SEED_ENV reproduces a run with the seed it logged
+	API_FUZZ_SEED_ENV = "VTCP_API_FUZZ_SEED"
+	API_FUZZ_RANDOM   = 500
+)
+
+var (
+	apiFuzzingNextNodeIndex = 1
+)
+
+func getNextIPForAPIFuzzing() string {
+	ip := fmt.Sprintf("%s%d", testconfig.StaticContainerIPPartForAPIFuzzing, apiFuzzingNextNodeIndex)
+	apiFuzzingNextNodeIndex++
+	return ip
+}
+
+// setupNodesForAPIFuzzing runs two nodes with a channel and a settlement line, so that the valid values
+// the mutations replace refer to existing entities.
+func setupNodesForAPIFuzzing(t *testing.T) ([]*vtcp.Node, vtcp.APIFuzzValues) {
+	nodes := make([]*vtcp.Node, 2)
+	for i := range 2 {
+		nodes[i] = vtcp.NewNode(t, getNextIPForAPIFuzzing(), fmt.Sprintf("node%c", 'A'+i))
+	}
+
+	ctx := context.Background()
+	cluster, err := vtcp.NewCluster(ctx, t, &testconfig.GSettings)
+	if err != nil {
+		t.Fatalf("failed to create cluster: %v", err)
+	}
+
+	cluster.RunNodes(ctx, t, nodes, false)
+
+	nodes[0].OpenChannelAndCheck(t, nodes[1])
+	nodes[1].CreateAndSetSettlementLineAndCheck(t, nodes[0], testconfig.Equivalent, "1000")
+
+	channelInfo, err := nodes[0].GetChannelInfoByAddress(nodes[1])
+	if err != nil {
+		t.Fatalf("failed to get channel info: %v", err)
+	}
+	values := vtcp.APIFuzzValues{
+		vtcp.APIParamAddress:    nodes[1].GetIPAddressForRequests(),
+		vtcp.APIParamAmount:     "1",
+		vtcp.APIParamEquivalent: testconfig.Equivalent,
+		vtcp.APIParamChannelID:  channelInfo.ChannelID,
+		vtcp.APIParamCryptoKey:  channelInfo.ChannelCryptoKey,
+		vtcp.APIParamCount:      "10",
+	}
+	return nodes, values
+}
+
+// TestAPIFuzzingSingleMutations mutates every parameter and the path of every endpoint, one at a time.
+func TestAPIFuzzingSingleMutations(t *testing.T) {
+	nodes, values := setupNodesForAPIFuzzing(t)
+
+	nodes[0].FuzzAPIAndCheck(t, vtcp.APIFuzzRequests(vtcp.APIEndpoints, values))
+}
+
+// TestAPIFuzzingRandomMutations sends random endpoints with several parameters mutated at once.
+func TestAPIFuzzingRandomMutations(t *testing.T) {
+	nodes, values := setupNodesForAPIFuzzing(t)
+	rng := rand.New(rand.NewSource(vtcp.FuzzSeed(t, API_FUZZ_SEED_ENV)))
+
+	nodes[0].FuzzAPIAndCheck(t, vtcp.RandomAPIFuzzRequests(rng, vtcp.APIEndpoints, values, API_FUZZ_RANDOM))
+}
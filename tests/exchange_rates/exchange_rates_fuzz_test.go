@@ -0,0 +1,41 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+
+	vtcp "github.com/vTCP-Foundation/vtcpd-test-suite/pkg/testsuite"
+)
+
+const (
+	// RATES_FUZZ_SEED_ENV reproduces a run with the seed it logged
+	RATES_FUZZ_SEED_ENV    = "VTCP_RATES_FUZZ_SEED"
+	RATES_FUZZ_RANDOM      = 200
+	RATES_FUZZ_REPETITIONS = 50
+)
+
+// TestExchangeRatesBoundaryFuzzing drives the rates endpoints with the edge values of every parameter.
+func TestExchangeRatesBoundaryFuzzing(t *testing.T) {
+	node, _ := setupNodeForExchangeRatesTest(t)
+
+	var cases []vtcp.RateFuzzCase
+	cases = append(cases, vtcp.BoundaryRateFuzzCases(EQUIVALENT_1001, EQUIVALENT_2002)...)
+	cases = append(cases, vtcp.BoundaryRateFuzzCases(EQUIVALENT_2002, EQUIVALENT_101)...)
+	cases = append(cases, vtcp.BoundaryRateFuzzCases(EQUIVALENT_1001, EQUIVALENT_1001)...)
+	cases = append(cases, vtcp.BoundaryRateFuzzCases(UNKNOWN_EQUIVALENT_1, UNKNOWN_EQUIVALENT_2)...)
+
+	node.FuzzExchangeRatesAndCheck(t, cases)
+}
+
+// TestExchangeRatesRandomFuzzing drives the rates endpoints with random values and sets one pair many times.
+func TestExchangeRatesRandomFuzzing(t *testing.T) {
+	node, _ := setupNodeForExchangeRatesTest(t)
+	rng := rand.New(rand.NewSource(vtcp.FuzzSeed(t, RATES_FUZZ_SEED_ENV)))
+
+	equivalents := []string{EQUIVALENT_101, EQUIVALENT_1001, EQUIVALENT_1002, EQUIVALENT_2002, UNKNOWN_EQUIVALENT_1}
+	var cases []vtcp.RateFuzzCase
+	cases = append(cases, vtcp.RandomRateFuzzCases(rng, equivalents, RATES_FUZZ_RANDOM)...)
+	cases = append(cases, vtcp.RepeatedPairRateFuzzCases(rng, EQUIVALENT_1002, EQUIVALENT_2002, RATES_FUZZ_REPETITIONS)...)
+
+	node.FuzzExchangeRatesAndCheck(t, cases)
+}
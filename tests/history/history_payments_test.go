@@ -74,31 +74,31 @@ func TestHistoryAdditionalPayments(t *testing.T) {
 	node1.CheckMaxFlow(t, node5, testconfig.Equivalent, "300")
 
 	// Check history additional payments for node2
-	jsonRes := node2.HistoryAdditionalPayments(t)
-	if jsonRes["data"].(map[string]interface{})["count"].(float64) != 3 {
-		t.Errorf("Expected count 3, got %v", jsonRes["data"].(map[string]interface{})["count"])
+	history := node2.HistoryAdditionalPayments(t, vtcp.HistoryFilter{Equivalent: testconfig.Equivalent})
+	if history.Count != 3 {
+		t.Errorf("Expected count 3, got %v", history.Count)
 	}
 
-	records := jsonRes["data"].(map[string]interface{})["records"].([]interface{})
-	if int(records[0].(map[string]interface{})["amount"].(float64)) != 500 {
-		t.Errorf("Expected amount 500, got %v", records[0].(map[string]interface{})["amount"])
+	records := history.Records
+	if !records[0].Amount.Equal(vtcp.NewAmount(500)) {
+		t.Errorf("Expected amount 500, got %v", records[0].Amount)
 	}
-	if int(records[1].(map[string]interface{})["amount"].(float64)) != 500 {
-		t.Errorf("Expected amount 500, got %v", records[1].(map[string]interface{})["amount"])
+	if !records[1].Amount.Equal(vtcp.NewAmount(500)) {
+		t.Errorf("Expected amount 500, got %v", records[1].Amount)
 	}
-	if int(records[2].(map[string]interface{})["amount"].(float64)) != 500 {
-		t.Errorf("Expected amount 500, got %v", records[2].(map[string]interface{})["amount"])
+	if !records[2].Amount.Equal(vtcp.NewAmount(500)) {
+		t.Errorf("Expected amount 500, got %v", records[2].Amount)
 	}
 
 	// Check history additional payments for node3
-	jsonRes = node3.HistoryAdditionalPayments(t)
-	if int(jsonRes["data"].(map[string]interface{})["count"].(float64)) != 1 {
-		t.Errorf("Expected count 1, got %v", jsonRes["data"].(map[string]interface{})["count"])
+	history = node3.HistoryAdditionalPayments(t, vtcp.HistoryFilter{Equivalent: testconfig.Equivalent})
+	if history.Count != 1 {
+		t.Errorf("Expected count 1, got %v", history.Count)
 	}
 
-	records = jsonRes["data"].(map[string]interface{})["records"].([]interface{})
-	if int(records[0].(map[string]interface{})["amount"].(float64)) != 200 {
-		t.Errorf("Expected amount 200, got %v", records[0].(map[string]interface{})["amount"])
+	records = history.Records
+	if !records[0].Amount.Equal(vtcp.NewAmount(200)) {
+		t.Errorf("Expected amount 200, got %v", records[0].Amount)
 	}
 }
 
@@ -153,34 +153,40 @@ func TestHistoryPayments(t *testing.T) {
 	node1.CheckMaxFlow(t, node5, testconfig.Equivalent, "300")
 
 	// Check history payments
-	jsonRes := node1.HistoryPayments(t)
-	if jsonRes["data"].(map[string]interface{})["count"].(float64) != 3 {
-		t.Errorf("Expected count 3, got %v", jsonRes["data"].(map[string]interface{})["count"])
+	history := node1.HistoryPayments(t, vtcp.HistoryFilter{Equivalent: testconfig.Equivalent})
+	if history.Count != 3 {
+		t.Errorf("Expected count 3, got %v", history.Count)
 	}
 
-	records := jsonRes["data"].(map[string]interface{})["records"].([]interface{})
-	if int(records[2].(map[string]interface{})["amount"].(float64)) != 500 {
-		t.Errorf("Expected amount 500, got %v", records[2].(map[string]interface{})["amount"])
+	records := history.Records
+	if !records[2].Amount.Equal(vtcp.NewAmount(500)) {
+		t.Errorf("Expected amount 500, got %v", records[2].Amount)
 	}
-	if int(records[1].(map[string]interface{})["amount"].(float64)) != 500 {
-		t.Errorf("Expected amount 500, got %v", records[1].(map[string]interface{})["amount"])
+	if !records[1].Amount.Equal(vtcp.NewAmount(500)) {
+		t.Errorf("Expected amount 500, got %v", records[1].Amount)
 	}
-	if int(records[0].(map[string]interface{})["amount"].(float64)) != 700 {
-		t.Errorf("Expected amount 700, got %v", records[0].(map[string]interface{})["amount"])
+	if !records[0].Amount.Equal(vtcp.NewAmount(700)) {
+		t.Errorf("Expected amount 700, got %v", records[0].Amount)
 	}
 
 	// Check balance after operations
 	// pay 500 from node_1 to node_5
-	if int(records[2].(map[string]interface{})["balance_after_operation"].(float64)) != -500 {
-		t.Errorf("Expected balance_after_operation -500, got %v", records[2].(map[string]interface{})["balance_after_operation"])
+	if !records[2].BalanceAfterOperation.Equal(vtcp.NewAmount(-500)) {
+		t.Errorf("Expected balance_after_operation -500, got %v", records[2].BalanceAfterOperation)
 	}
 	// pay 500 from node_5 to node_1
-	if int(records[1].(map[string]interface{})["balance_after_operation"].(float64)) != 0 {
-		t.Errorf("Expected balance_after_operation 0, got %v", records[1].(map[string]interface{})["balance_after_operation"])
+	if !records[1].BalanceAfterOperation.Equal(vtcp.NewAmount(0)) {
+		t.Errorf("Expected balance_after_operation 0, got %v", records[1].BalanceAfterOperation)
 	}
 	// pay 700 from node_1 to node_5
-	if int(records[0].(map[string]interface{})["balance_after_operation"].(float64)) != -700 {
-		t.Errorf("Expected balance_after_operation -700, got %v", records[0].(map[string]interface{})["balance_after_operation"])
+	if !records[0].BalanceAfterOperation.Equal(vtcp.NewAmount(-700)) {
+		t.Errorf("Expected balance_after_operation -700, got %v", records[0].BalanceAfterOperation)
+	}
+
+	// The whole history, read page by page, must contain the same records
+	allRecords := node1.AllHistoryPayments(t, vtcp.HistoryFilter{Equivalent: testconfig.Equivalent, Count: 2})
+	if len(allRecords) != 3 {
+		t.Errorf("Expected 3 records in the whole history, got %v", len(allRecords))
 	}
 }
 
@@ -242,30 +248,71 @@ func TestHistoryPaymentsAllEquivalents(t *testing.T) {
 	time.Sleep(1 * time.Second)
 
 	// Check history payments all equivalents
-	jsonRes := node1.HistoryPaymentsAllEquivalents(t)
-	if jsonRes["data"].(map[string]interface{})["count"].(float64) != 3 {
-		t.Errorf("Expected count 3, got %v", jsonRes["data"].(map[string]interface{})["count"])
+	history := node1.HistoryPaymentsAllEquivalents(t, vtcp.HistoryFilter{})
+	if history.Count != 3 {
+		t.Errorf("Expected count 3, got %v", history.Count)
 	}
 
-	records := jsonRes["data"].(map[string]interface{})["records"].([]interface{})
-	if int(records[0].(map[string]interface{})["equivalent"].(float64)) != 2 {
-		t.Errorf("Expected equivalent 2, got %v", records[0].(map[string]interface{})["equivalent"])
+	records := history.Records
+	if records[0].Equivalent != "2" {
+		t.Errorf("Expected equivalent 2, got %v", records[0].Equivalent)
 	}
-	if int(records[0].(map[string]interface{})["balance_after_operation"].(float64)) != -300 {
-		t.Errorf("Expected balance_after_operation -300, got %v", records[0].(map[string]interface{})["balance_after_operation"])
+	if !records[0].BalanceAfterOperation.Equal(vtcp.NewAmount(-300)) {
+		t.Errorf("Expected balance_after_operation -300, got %v", records[0].BalanceAfterOperation)
 	}
 
-	if int(records[1].(map[string]interface{})["equivalent"].(float64)) != 2 {
-		t.Errorf("Expected equivalent 2, got %v", records[1].(map[string]interface{})["equivalent"])
+	if records[1].Equivalent != "2" {
+		t.Errorf("Expected equivalent 2, got %v", records[1].Equivalent)
+	}
+	if !records[1].BalanceAfterOperation.Equal(vtcp.NewAmount(-200)) {
+		t.Errorf("Expected balance_after_operation -200, got %v", records[1].BalanceAfterOperation)
+	}
+
+	if records[2].Equivalent.String() != testconfig.Equivalent {
+		t.Errorf("Expected equivalent %s, got %v", testconfig.Equivalent, records[2].Equivalent)
+	}
+	if !records[2].BalanceAfterOperation.Equal(vtcp.NewAmount(-500)) {
+		t.Errorf("Expected balance_after_operation -500, got %v", records[2].BalanceAfterOperation)
+	}
+}
+
+// TestHistoryPaymentsFilters checks that the node applies the filters of the history request.
+func TestHistoryPaymentsFilters(t *testing.T) {
+	node1 := vtcp.NewNode(t, getNextIPForHistoryPaymentsTest(), "node_1")
+	node2 := vtcp.NewNode(t, getNextIPForHistoryPaymentsTest(), "node_2")
+
+	ctx := context.Background()
+	cluster, err := vtcp.NewCluster(ctx, t, &testconfig.GSettings)
+	if err != nil {
+		t.Fatalf("failed to create cluster: %v", err)
 	}
-	if int(records[1].(map[string]interface{})["balance_after_operation"].(float64)) != -200 {
-		t.Errorf("Expected balance_after_operation -200, got %v", records[1].(map[string]interface{})["balance_after_operation"])
+
+	cluster.RunNodes(ctx, t, []*vtcp.Node{node1, node2}, false)
+
+	node1.OpenChannelAndCheck(t, node2)
+	time.Sleep(1 * time.Second)
+	node2.CreateAndSetSettlementLineAndCheck(t, node1, testconfig.Equivalent, "1000")
+	time.Sleep(2 * time.Second)
+
+	node1.CreateTransactionCheckStatus(t, node2, testconfig.Equivalent, "100", vtcp.StatusOK)
+	time.Sleep(1 * time.Second)
+	node1.CreateTransactionCheckStatus(t, node2, testconfig.Equivalent, "200", vtcp.StatusOK)
+	time.Sleep(1 * time.Second)
+	lastPaymentStarted := time.Now()
+	node1.CreateTransactionCheckStatus(t, node2, testconfig.Equivalent, "300", vtcp.StatusOK)
+
+	history := node1.HistoryPayments(t, vtcp.HistoryFilter{Equivalent: testconfig.Equivalent, AmountFrom: "150", AmountTo: "250"})
+	if len(history.Records) != 1 || !history.Records[0].Amount.Equal(vtcp.NewAmount(200)) {
+		t.Errorf("Expected the payment of 200 only with the amount filter, got %+v", history.Records)
 	}
 
-	if int(records[2].(map[string]interface{})["equivalent"].(float64)) != 1 {
-		t.Errorf("Expected equivalent 1, got %v", records[2].(map[string]interface{})["equivalent"])
+	history = node1.HistoryPayments(t, vtcp.HistoryFilter{Equivalent: testconfig.Equivalent, From: lastPaymentStarted})
+	if len(history.Records) != 1 || !history.Records[0].Amount.Equal(vtcp.NewAmount(300)) {
+		t.Errorf("Expected the payment of 300 only with the date filter, got %+v", history.Records)
 	}
-	if int(records[2].(map[string]interface{})["balance_after_operation"].(float64)) != -500 {
-		t.Errorf("Expected balance_after_operation -500, got %v", records[2].(map[string]interface{})["balance_after_operation"])
+
+	history = node1.HistoryPayments(t, vtcp.HistoryFilter{Equivalent: testconfig.Equivalent, Contractor: node2})
+	if len(history.Records) != 3 {
+		t.Errorf("Expected 3 payments with the contractor filter, got %+v", history.Records)
 	}
 }
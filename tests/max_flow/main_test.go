@@ -0,0 +1,11 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/vTCP-Foundation/vtcpd-test-suite/tests/testconfig"
+)
+
+func TestMain(m *testing.M) {
+	testconfig.Main(m)
+}
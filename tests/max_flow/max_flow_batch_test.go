@@ -24,7 +24,8 @@ func TestMaxBatchFlowThrough6Hops(t *testing.T) {
 		t.Fatalf("failed to create cluster: %v", err)
 	}
 
-	cluster.RunNodes(ctx, t, []*vtcp.Node{node1, node2, node3, node4, node5, node6, node7}, false)
+	nodes := []*vtcp.Node{node1, node2, node3, node4, node5, node6, node7}
+	cluster.RunNodes(ctx, t, nodes, false)
 
 	node1.OpenChannelAndCheck(t, node2)
 	node3.OpenChannelAndCheck(t, node4)
@@ -42,12 +43,12 @@ func TestMaxBatchFlowThrough6Hops(t *testing.T) {
 	node7.CreateAndSetSettlementLineAndCheck(t, node6, testconfig.Equivalent, "1000")
 
 	expectedMaxFlows := []vtcp.MaxFlowBatchCheck{
-		{Node: node2, ExpectedMaxFlow: "1000"},
-		{Node: node3, ExpectedMaxFlow: "800"},
-		{Node: node4, ExpectedMaxFlow: "800"},
-		{Node: node5, ExpectedMaxFlow: "700"},
-		{Node: node6, ExpectedMaxFlow: "700"},
-		{Node: node7, ExpectedMaxFlow: "700"},
+		{Node: node2, ExpectedMaxFlow: vtcp.NewAmount(1000)},
+		{Node: node3, ExpectedMaxFlow: vtcp.NewAmount(800)},
+		{Node: node4, ExpectedMaxFlow: vtcp.NewAmount(800)},
+		{Node: node5, ExpectedMaxFlow: vtcp.NewAmount(700)},
+		{Node: node6, ExpectedMaxFlow: vtcp.NewAmount(700)},
+		{Node: node7, ExpectedMaxFlow: vtcp.NewAmount(700)},
 	}
 	node1.CheckMaxFlowBatch(t, expectedMaxFlows, testconfig.Equivalent)
 
@@ -55,13 +56,16 @@ func TestMaxBatchFlowThrough6Hops(t *testing.T) {
 	time.Sleep(5 * time.Second)
 
 	expectedMaxFlows = []vtcp.MaxFlowBatchCheck{
-		{Node: node2, ExpectedMaxFlow: "800"},
-		{Node: node3, ExpectedMaxFlow: "600"},
-		{Node: node4, ExpectedMaxFlow: "600"},
-		{Node: node5, ExpectedMaxFlow: "500"},
-		{Node: node6, ExpectedMaxFlow: "500"},
-		{Node: node7, ExpectedMaxFlow: "500"},
+		{Node: node2, ExpectedMaxFlow: vtcp.NewAmount(800)},
+		{Node: node3, ExpectedMaxFlow: vtcp.NewAmount(600)},
+		{Node: node4, ExpectedMaxFlow: vtcp.NewAmount(600)},
+		{Node: node5, ExpectedMaxFlow: vtcp.NewAmount(500)},
+		{Node: node6, ExpectedMaxFlow: vtcp.NewAmount(500)},
+		{Node: node7, ExpectedMaxFlow: vtcp.NewAmount(500)},
 	}
+	// Max flows and settlement lines are reported together if the payment went wrong
+	step := vtcp.SoftAssert(t, "after the payment")
 	node1.CheckMaxFlowBatch(t, expectedMaxFlows, testconfig.Equivalent)
-
+	vtcp.CheckSettlementLineForSyncBatch(t, nodes, testconfig.Equivalent, vtcp.WaitingParticipantsVotesSec)
+	step.Done()
 }
@@ -519,6 +519,7 @@ func Test7dLostMsgWithSignatureFromCoordinatorToAllIntermediateNodes(t *testing.
 		t.Fatalf("failed to create cluster: %v", err)
 	}
 	cluster.RunNodes(ctx, t, nodes, false)
+	logs := cluster.WatchLogs(t)
 	createChannelsAndSettlementLinesSevenNodes(t, node1, node2, node3, node4, node5, node6, node7)
 
 	// self.flag_forbid_send_message_on_vote_consistency (Corresponds to flag 4)
@@ -528,12 +529,11 @@ func Test7dLostMsgWithSignatureFromCoordinatorToAllIntermediateNodes(t *testing.
 	if err != nil {
 		t.Fatalf("CreateTransactionCheckStatus failed for node1: %v", err)
 	}
-	time.Sleep(vtcp.WaitingParticipantsVotesSec * time.Second)
+	logs.WaitForLog(node2, vtcp.LogRecovering(uuid), vtcp.RecoveringTimeout)
 
 	vtcp.CheckSettlementLineForSyncBatch(t, nodes, testconfig.Equivalent, vtcp.WaitingParticipantsVotesSec)
 
 	node1.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, false)
-	node2.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, true)
 	node3.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, true)
 	node4.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, true)
 	node5.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, true)
@@ -569,6 +569,7 @@ func Test7eLostMsgWithSignatureFromCoordinatorToAllIntermediateNodesAlsoOnRecove
 		t.Fatalf("failed to create cluster: %v", err)
 	}
 	cluster.RunNodes(ctx, t, nodes, false)
+	logs := cluster.WatchLogs(t)
 	createChannelsAndSettlementLinesSevenNodes(t, node1, node2, node3, node4, node5, node6, node7)
 
 	// flag_forbid_send_message_on_recovery_stage = 103 (New)
@@ -578,10 +579,9 @@ func Test7eLostMsgWithSignatureFromCoordinatorToAllIntermediateNodesAlsoOnRecove
 	if err != nil {
 		t.Fatalf("CreateTransactionCheckStatus failed for node1: %v", err)
 	}
-	time.Sleep(vtcp.WaitingParticipantsVotesSec * time.Second)
+	logs.WaitForLog(node2, vtcp.LogRecovering(uuid), vtcp.RecoveringTimeout)
 
 	node1.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, false)
-	node2.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, true)
 	node3.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, true)
 	node4.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, true)
 	node5.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, true)
@@ -36,14 +36,14 @@ func Test1DirectPaymentNormalAmount(t *testing.T) {
 	nodeB.CreateChannelAndSettlementLineAndCheck(t, nodeA, testconfig.Equivalent, "1000")
 	nodeA.CheckMaxFlowBatch(t,
 		[]vtcp.MaxFlowBatchCheck{
-			{Node: nodeB, ExpectedMaxFlow: "1000"}},
+			{Node: nodeB, ExpectedMaxFlow: vtcp.NewAmount(1000)}},
 		testconfig.Equivalent)
 
 	nodeC.CreateChannelAndSettlementLineAndCheck(t, nodeA, testconfig.Equivalent, "1000")
 	nodeA.CheckMaxFlowBatch(t,
 		[]vtcp.MaxFlowBatchCheck{
-			{Node: nodeB, ExpectedMaxFlow: "1000"},
-			{Node: nodeC, ExpectedMaxFlow: "1000"}},
+			{Node: nodeB, ExpectedMaxFlow: vtcp.NewAmount(1000)},
+			{Node: nodeC, ExpectedMaxFlow: vtcp.NewAmount(1000)}},
 		testconfig.Equivalent)
 
 	nodeB.CreateChannelAndSettlementLineAndCheck(t, nodeD, testconfig.Equivalent, "500")
@@ -61,16 +61,16 @@ func Test1DirectPaymentNormalAmount(t *testing.T) {
 
 	nodeA.CheckMaxFlowBatch(t,
 		[]vtcp.MaxFlowBatchCheck{
-			{Node: nodeB, ExpectedMaxFlow: "400"},
-			{Node: nodeC, ExpectedMaxFlow: "1000"}},
+			{Node: nodeB, ExpectedMaxFlow: vtcp.NewAmount(400)},
+			{Node: nodeC, ExpectedMaxFlow: vtcp.NewAmount(1000)}},
 		testconfig.Equivalent)
 
 	nodeB.CreateTransactionCheckStatus(t, nodeA, testconfig.Equivalent, "600", vtcp.StatusOK)
 
 	nodeA.CheckMaxFlowBatch(t,
 		[]vtcp.MaxFlowBatchCheck{
-			{Node: nodeB, ExpectedMaxFlow: "1000"},
-			{Node: nodeC, ExpectedMaxFlow: "1000"}},
+			{Node: nodeB, ExpectedMaxFlow: vtcp.NewAmount(1000)},
+			{Node: nodeC, ExpectedMaxFlow: vtcp.NewAmount(1000)}},
 		testconfig.Equivalent)
 }
 
@@ -102,8 +102,8 @@ func Test2DirectPaymentOvertrustAmount(t *testing.T) {
 	nodeC.CreateChannelAndSettlementLineAndCheck(t, nodeA, testconfig.Equivalent, "1000")
 	nodeA.CheckMaxFlowBatch(t,
 		[]vtcp.MaxFlowBatchCheck{
-			{Node: nodeB, ExpectedMaxFlow: "1000"},
-			{Node: nodeC, ExpectedMaxFlow: "1000"}},
+			{Node: nodeB, ExpectedMaxFlow: vtcp.NewAmount(1000)},
+			{Node: nodeC, ExpectedMaxFlow: vtcp.NewAmount(1000)}},
 		testconfig.Equivalent)
 	nodeC.CreateChannelAndSettlementLineAndCheck(t, nodeD, testconfig.Equivalent, "500")
 	nodeD.CheckMaxFlow(t, nodeC, testconfig.Equivalent, "500")
@@ -111,22 +111,22 @@ func Test2DirectPaymentOvertrustAmount(t *testing.T) {
 	nodeA.CreateTransactionCheckStatus(t, nodeB, testconfig.Equivalent, "2500", vtcp.StatusInsufficientFunds)
 	nodeA.CheckMaxFlowBatch(t,
 		[]vtcp.MaxFlowBatchCheck{
-			{Node: nodeB, ExpectedMaxFlow: "1000"},
-			{Node: nodeC, ExpectedMaxFlow: "1000"}},
+			{Node: nodeB, ExpectedMaxFlow: vtcp.NewAmount(1000)},
+			{Node: nodeC, ExpectedMaxFlow: vtcp.NewAmount(1000)}},
 		testconfig.Equivalent)
 
 	nodeA.CreateTransactionCheckStatus(t, nodeB, testconfig.Equivalent, "2000", vtcp.StatusInsufficientFunds)
 	nodeA.CheckMaxFlowBatch(t,
 		[]vtcp.MaxFlowBatchCheck{
-			{Node: nodeB, ExpectedMaxFlow: "1000"},
-			{Node: nodeC, ExpectedMaxFlow: "1000"}},
+			{Node: nodeB, ExpectedMaxFlow: vtcp.NewAmount(1000)},
+			{Node: nodeC, ExpectedMaxFlow: vtcp.NewAmount(1000)}},
 		testconfig.Equivalent)
 
 	nodeA.CreateTransactionCheckStatus(t, nodeB, testconfig.Equivalent, "1200", vtcp.StatusInsufficientFunds)
 	nodeA.CheckMaxFlowBatch(t,
 		[]vtcp.MaxFlowBatchCheck{
-			{Node: nodeB, ExpectedMaxFlow: "1000"},
-			{Node: nodeC, ExpectedMaxFlow: "1000"}},
+			{Node: nodeB, ExpectedMaxFlow: vtcp.NewAmount(1000)},
+			{Node: nodeC, ExpectedMaxFlow: vtcp.NewAmount(1000)}},
 		testconfig.Equivalent)
 }
 
@@ -279,6 +279,7 @@ func Test5bLostMessageWithSignatureToCoordinator(t *testing.T) {
 	}
 
 	cluster.RunNodes(ctx, t, []*vtcp.Node{nodeA, nodeB}, false)
+	logs := cluster.WatchLogs(t)
 
 	// Common setup based on Python's prepare_topology
 	nodeB.CreateChannelAndSettlementLineAndCheck(t, nodeA, testconfig.Equivalent, "1000")
@@ -293,10 +294,9 @@ func Test5bLostMessageWithSignatureToCoordinator(t *testing.T) {
 		t.Fatalf("CreateTransactionCheckStatus failed unexpectedly: %v", err)
 	}
 	// Python original has extensive checks and sleeps here.
-	time.Sleep(vtcp.WaitingParticipantsVotesSec * time.Second)
+	logs.WaitForLog(nodeB, vtcp.LogRecovering(uuid), vtcp.RecoveringTimeout)
 	nodeA.CheckSettlementLineForSync(t, nodeB, testconfig.Equivalent)
 	nodeA.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, false)
-	nodeB.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, true)
 	nodeA.CheckPaymentTransaction(t, "", 0, 0, 0, 0)
 	nodeB.CheckPaymentTransaction(t, vtcp.PaymentObservingStateNoInfo, 1, 0, 1, 0)
 	nodeA.CheckSerializedTransaction(t, false, 0)
@@ -330,6 +330,7 @@ func Test5cLostMessageWithSignatureToReceiver(t *testing.T) {
 	}
 
 	cluster.RunNodes(ctx, t, []*vtcp.Node{nodeA, nodeB}, false)
+	logs := cluster.WatchLogs(t)
 
 	// Common setup based on Python's prepare_topology
 	nodeB.CreateChannelAndSettlementLineAndCheck(t, nodeA, testconfig.Equivalent, "1000")
@@ -344,10 +345,9 @@ func Test5cLostMessageWithSignatureToReceiver(t *testing.T) {
 		t.Fatalf("CreateTransactionCheckStatus failed unexpectedly: %v", err)
 	}
 	// Python original has extensive checks and sleeps here.
-	time.Sleep(vtcp.WaitingParticipantsVotesSec * time.Second)
+	logs.WaitForLog(nodeB, vtcp.LogRecovering(uuid), vtcp.RecoveringTimeout)
 	nodeA.CheckSettlementLineForSync(t, nodeB, testconfig.Equivalent)
 	nodeA.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, false)
-	nodeB.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, true)
 	nodeA.CheckPaymentTransaction(t, "", 0, 0, 0, 0)
 	nodeB.CheckPaymentTransaction(t, vtcp.PaymentObservingStateNoInfo, 1, 0, 1, 0)
 	nodeA.CheckSerializedTransaction(t, false, 0)
@@ -395,6 +395,7 @@ func Test6bReceiverCrashAfterSignBeforeSending(t *testing.T) {
 	}
 
 	cluster.RunNodes(ctx, t, []*vtcp.Node{nodeA, nodeB}, false)
+	logs := cluster.WatchLogs(t)
 
 	// Common setup based on Python's prepare_topology
 	nodeB.CreateChannelAndSettlementLineAndCheck(t, nodeA, testconfig.Equivalent, "1000")
@@ -409,10 +410,9 @@ func Test6bReceiverCrashAfterSignBeforeSending(t *testing.T) {
 		t.Fatalf("CreateTransactionCheckStatus failed unexpectedly: %v", err)
 	}
 
-	time.Sleep(vtcp.WaitingParticipantsVotesSec * time.Second)
+	logs.WaitForLog(nodeB, vtcp.LogRecovering(uuid), vtcp.RecoveringTimeout)
 	nodeA.CheckSettlementLineForSync(t, nodeB, testconfig.Equivalent)
 	nodeA.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, false)
-	nodeB.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, true)
 	nodeA.CheckPaymentTransaction(t, "", 0, 0, 0, 0)
 	nodeB.CheckPaymentTransaction(t, vtcp.PaymentObservingStateNoInfo, 1, 0, 1, 0)
 	nodeA.CheckSerializedTransaction(t, false, 0)
@@ -446,6 +446,7 @@ func Test6cReceiverCrashAfterVotesReceivingBeforeCommitting(t *testing.T) {
 	}
 
 	cluster.RunNodes(ctx, t, []*vtcp.Node{nodeA, nodeB}, false)
+	logs := cluster.WatchLogs(t)
 
 	// Common setup based on Python's prepare_topology
 	nodeB.CreateChannelAndSettlementLineAndCheck(t, nodeA, testconfig.Equivalent, "1000")
@@ -459,10 +460,9 @@ func Test6cReceiverCrashAfterVotesReceivingBeforeCommitting(t *testing.T) {
 		t.Fatalf("CreateTransactionCheckStatus failed unexpectedly: %v", err)
 	}
 
-	time.Sleep(vtcp.WaitingParticipantsVotesSec * time.Second)
+	logs.WaitForLog(nodeB, vtcp.LogRecovering(uuid), vtcp.RecoveringTimeout)
 	nodeA.CheckSettlementLineForSync(t, nodeB, testconfig.Equivalent)
 	nodeA.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, false)
-	nodeB.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, true)
 	nodeA.CheckPaymentTransaction(t, vtcp.PaymentObservingStateNoInfo, 1, 2, 0, 1)
 	nodeB.CheckPaymentTransaction(t, vtcp.PaymentObservingStateNoInfo, 1, 2, 1, 0)
 	nodeA.CheckSerializedTransaction(t, false, 0)
@@ -509,6 +509,7 @@ func Test7bCoordinatorCrashAfterSendingMessageOnVoting(t *testing.T) {
 	}
 
 	cluster.RunNodes(ctx, t, []*vtcp.Node{nodeA, nodeB}, false)
+	logs := cluster.WatchLogs(t)
 
 	// Common setup based on Python's prepare_topology
 	nodeB.CreateChannelAndSettlementLineAndCheck(t, nodeA, testconfig.Equivalent, "1000")
@@ -523,10 +524,9 @@ func Test7bCoordinatorCrashAfterSendingMessageOnVoting(t *testing.T) {
 		t.Fatalf("CreateTransactionCheckStatus failed unexpectedly: %v", err)
 	}
 
-	time.Sleep(vtcp.WaitingParticipantsVotesSec * time.Second)
+	logs.WaitForLog(nodeB, vtcp.LogRecovering(uuid), vtcp.RecoveringTimeout)
 	nodeA.CheckSettlementLineForSync(t, nodeB, testconfig.Equivalent)
 	nodeA.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, false)
-	nodeB.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, true)
 	nodeA.CheckPaymentTransaction(t, "", 0, 0, 0, 0)
 	nodeB.CheckPaymentTransaction(t, vtcp.PaymentObservingStateNoInfo, 1, 0, 1, 0)
 	nodeA.CheckSerializedTransaction(t, false, 0)
@@ -560,6 +560,7 @@ func Test7cCoordinatorCrashAfterReceivingMessageWithSignature(t *testing.T) {
 	}
 
 	cluster.RunNodes(ctx, t, []*vtcp.Node{nodeA, nodeB}, false)
+	logs := cluster.WatchLogs(t)
 
 	// Common setup based on Python's prepare_topology
 	nodeB.CreateChannelAndSettlementLineAndCheck(t, nodeA, testconfig.Equivalent, "1000")
@@ -574,10 +575,9 @@ func Test7cCoordinatorCrashAfterReceivingMessageWithSignature(t *testing.T) {
 		t.Fatalf("CreateTransactionCheckStatus failed unexpectedly: %v", err)
 	}
 
-	time.Sleep(vtcp.WaitingParticipantsVotesSec * time.Second)
+	logs.WaitForLog(nodeB, vtcp.LogRecovering(uuid), vtcp.RecoveringTimeout)
 	nodeA.CheckSettlementLineForSync(t, nodeB, testconfig.Equivalent)
 	nodeA.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, false)
-	nodeB.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, true)
 	nodeA.CheckPaymentTransaction(t, "", 0, 0, 0, 0)
 	nodeB.CheckPaymentTransaction(t, vtcp.PaymentObservingStateNoInfo, 1, 0, 1, 0)
 	nodeA.CheckSerializedTransaction(t, false, 0)
@@ -611,6 +611,7 @@ func Test7dCoordinatorCrashAfterApprovingBeforeSendingMessageWithSignature(t *te
 	}
 
 	cluster.RunNodes(ctx, t, []*vtcp.Node{nodeA, nodeB}, false)
+	logs := cluster.WatchLogs(t)
 
 	// Common setup based on Python's prepare_topology
 	nodeB.CreateChannelAndSettlementLineAndCheck(t, nodeA, testconfig.Equivalent, "1000")
@@ -624,10 +625,9 @@ func Test7dCoordinatorCrashAfterApprovingBeforeSendingMessageWithSignature(t *te
 		t.Fatalf("CreateTransactionCheckStatus failed unexpectedly: %v", err)
 	}
 
-	time.Sleep(vtcp.WaitingParticipantsVotesSec * time.Second)
+	logs.WaitForLog(nodeB, vtcp.LogRecovering(uuid), vtcp.RecoveringTimeout)
 	nodeA.CheckSettlementLineForSync(t, nodeB, testconfig.Equivalent)
 	nodeA.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, false)
-	nodeB.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, true)
 	nodeA.CheckPaymentTransaction(t, vtcp.PaymentObservingStateNoInfo, 1, 2, 0, 1)
 	nodeB.CheckPaymentTransaction(t, vtcp.PaymentObservingStateNoInfo, 1, 2, 1, 0)
 	nodeA.CheckSerializedTransaction(t, false, 0)
@@ -700,6 +700,7 @@ func Test8cReceiverProcessCrashAfterVotesReceivingBeforeCommitting(t *testing.T)
 	}
 
 	cluster.RunNodes(ctx, t, []*vtcp.Node{nodeA, nodeB}, false)
+	logs := cluster.WatchLogs(t)
 
 	// Common setup based on Python's prepare_topology
 	nodeB.CreateChannelAndSettlementLineAndCheck(t, nodeA, testconfig.Equivalent, "1000")
@@ -714,10 +715,9 @@ func Test8cReceiverProcessCrashAfterVotesReceivingBeforeCommitting(t *testing.T)
 		t.Fatalf("CreateTransactionCheckStatus failed unexpectedly: %v", err)
 	}
 
-	time.Sleep(vtcp.WaitingParticipantsVotesSec * time.Second)
+	logs.WaitForLog(nodeB, vtcp.LogRecovering(uuid), vtcp.RecoveringTimeout)
 	nodeA.CheckSettlementLineForSync(t, nodeB, testconfig.Equivalent)
 	nodeA.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, false)
-	nodeB.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, true)
 	nodeA.CheckPaymentTransaction(t, "", 0, 0, 0, 0)
 	nodeB.CheckPaymentTransaction(t, vtcp.PaymentObservingStateNoInfo, 1, 0, 1, 0)
 	nodeA.CheckSerializedTransaction(t, false, 0)
@@ -814,6 +814,7 @@ func Test9cCoordinatorProcessCrashAfterReceivingMessageWithSignature(t *testing.
 	}
 
 	cluster.RunNodes(ctx, t, []*vtcp.Node{nodeA, nodeB}, false)
+	logs := cluster.WatchLogs(t)
 
 	// Common setup based on Python's prepare_topology
 	nodeB.CreateChannelAndSettlementLineAndCheck(t, nodeA, testconfig.Equivalent, "1000")
@@ -825,10 +826,9 @@ func Test9cCoordinatorProcessCrashAfterReceivingMessageWithSignature(t *testing.
 		t.Fatalf("CreateTransactionCheckStatus failed unexpectedly: %v", err)
 	}
 
-	time.Sleep(vtcp.WaitingParticipantsVotesSec * time.Second)
+	logs.WaitForLog(nodeB, vtcp.LogRecovering(uuid), vtcp.RecoveringTimeout)
 	nodeA.CheckSettlementLineForSync(t, nodeB, testconfig.Equivalent)
 	nodeA.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, false)
-	nodeB.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, true)
 	nodeA.CheckPaymentTransaction(t, "", 0, 0, 0, 1)
 	nodeB.CheckPaymentTransaction(t, vtcp.PaymentObservingStateNoInfo, 1, 0, 1, 0)
 	nodeA.CheckSerializedTransaction(t, false, 0)
@@ -862,6 +862,7 @@ func Test9dCoordinatorProcessCrashAfterApprovingBeforeSendingMessageWithSignatur
 	}
 
 	cluster.RunNodes(ctx, t, []*vtcp.Node{nodeA, nodeB}, false)
+	logs := cluster.WatchLogs(t)
 
 	// Common setup based on Python's prepare_topology
 	nodeB.CreateChannelAndSettlementLineAndCheck(t, nodeA, testconfig.Equivalent, "1000")
@@ -873,10 +874,9 @@ func Test9dCoordinatorProcessCrashAfterApprovingBeforeSendingMessageWithSignatur
 		t.Fatalf("CreateTransactionCheckStatus failed unexpectedly: %v", err)
 	}
 
-	time.Sleep(vtcp.WaitingParticipantsVotesSec * time.Second)
+	logs.WaitForLog(nodeB, vtcp.LogRecovering(uuid), vtcp.RecoveringTimeout)
 	nodeA.CheckSettlementLineForSync(t, nodeB, testconfig.Equivalent)
 	nodeA.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, false)
-	nodeB.CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, true)
 	nodeA.CheckPaymentTransaction(t, vtcp.PaymentObservingStateNoInfo, 1, 2, 0, 1)
 	nodeB.CheckPaymentTransaction(t, vtcp.PaymentObservingStateNoInfo, 1, 2, 1, 0)
 	nodeA.CheckSerializedTransaction(t, false, 0)
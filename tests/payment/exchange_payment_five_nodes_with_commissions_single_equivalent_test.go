@@ -90,7 +90,7 @@ func Test2ExchangePaymentFiveNodesWithCommissionsSingleEquivalent(t *testing.T)
 }
 
 func Test3ExchangePaymentFiveNodesWithCommissionsSingleEquivalent(t *testing.T) {
-	nodes, _ := setupNodesForExchangePaymentFiveNodesWithCommissionsSingleEquivalentTest(t)
+	nodes, cluster := setupNodesForExchangePaymentFiveNodesWithCommissionsSingleEquivalentTest(t)
 
 	nodes[1].SetCommissions([]vtcp.CommissionPair{
 		{Equivalent: testconfig.Equivalent, Amount: 10},
@@ -104,7 +104,8 @@ func Test3ExchangePaymentFiveNodesWithCommissionsSingleEquivalent(t *testing.T)
 		{Equivalent: testconfig.Equivalent, Amount: 3},
 	})
 
-	nodes[0].CreateExchangeTransactionCheckStatus(t, nodes[4], testconfig.Equivalent, "100", testconfig.Equivalent, vtcp.NoMaxAllowablePaymentAmount, vtcp.StatusOK)
+	before := cluster.SnapshotBalancesAndCheck(t, testconfig.Equivalent)
+	transactionUUID, _ := nodes[0].CreateExchangeTransactionCheckStatus(t, nodes[4], testconfig.Equivalent, "100", testconfig.Equivalent, vtcp.NoMaxAllowablePaymentAmount, vtcp.StatusOK)
 
 	vtcp.CheckSettlementLineForSyncBatch(t, nodes, testconfig.Equivalent, 2)
 	nodes[0].CheckActiveSettlementLine(t, nodes[1], testconfig.Equivalent, "0", "3000", "-119")
@@ -112,5 +113,13 @@ func Test3ExchangePaymentFiveNodesWithCommissionsSingleEquivalent(t *testing.T)
 	nodes[2].CheckActiveSettlementLine(t, nodes[3], testconfig.Equivalent, "0", "250", "-103")
 	nodes[3].CheckActiveSettlementLine(t, nodes[4], testconfig.Equivalent, "0", "500", "-100")
 
+	cluster.CheckCommissions(t, vtcp.CommissionPayment{
+		Coordinator:     nodes[0],
+		Receiver:        nodes[4],
+		Equivalent:      testconfig.Equivalent,
+		Amount:          vtcp.NewAmount(100),
+		TransactionUUID: transactionUUID,
+	}, before, cluster.SnapshotBalancesAndCheck(t, testconfig.Equivalent))
+
 	nodes[0].CheckExchangeMaxFlow(t, nodes[4], testconfig.Equivalent, []string{testconfig.Equivalent}, "144")
 }
@@ -340,6 +340,7 @@ func Test7cLostMsgWithPublicKeyHashFromIntermediateNodeToParticipantsExchange(t
 
 func Test7dLostMsgWithSignatureFromCoordinatorToAllIntermediateNodesExchange(t *testing.T) {
 	nodes := setupNodesForDirectPaymentSevenNodesTest(t)
+	logs := vtcp.NewLogWatcher(t, nodes...)
 
 	nodes[0].SetTestingFlag(t, vtcp.FlagForbidSendMessageVoteConsistency, "", "")
 
@@ -347,12 +348,11 @@ func Test7dLostMsgWithSignatureFromCoordinatorToAllIntermediateNodesExchange(t *
 	if err != nil {
 		t.Fatalf("CreateTransactionCheckStatus failed for node1: %v", err)
 	}
-	time.Sleep(vtcp.WaitingParticipantsVotesSec * time.Second)
+	logs.WaitForLog(nodes[1], vtcp.LogRecovering(uuid), vtcp.RecoveringTimeout)
 
 	vtcp.CheckSettlementLineForSyncBatch(t, nodes, testconfig.Equivalent, vtcp.WaitingParticipantsVotesSec)
 
 	nodes[0].CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, false)
-	nodes[1].CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, true)
 	nodes[2].CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, true)
 	nodes[3].CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, true)
 	nodes[4].CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, true)
@@ -381,16 +381,16 @@ func Test7dLostMsgWithSignatureFromCoordinatorToAllIntermediateNodesExchange(t *
 
 func Test7eLostMsgWithSignatureFromCoordinatorToAllIntermediateNodesAlsoOnRecoveryExchange(t *testing.T) {
 	nodes := setupNodesForDirectPaymentSevenNodesTest(t)
+	logs := vtcp.NewLogWatcher(t, nodes...)
 
 	nodes[0].SetTestingFlag(t, vtcp.FlagForbidSendMessageVoteConsistency, "", "")
 	uuid, err := nodes[0].CreateExchangeTransactionCheckStatus(t, nodes[6], testconfig.Equivalent, "1000", testconfig.Equivalent, vtcp.NoMaxAllowablePaymentAmount, vtcp.StatusOK)
 	if err != nil {
 		t.Fatalf("CreateTransactionCheckStatus failed for node1: %v", err)
 	}
-	time.Sleep(vtcp.WaitingParticipantsVotesSec * time.Second)
+	logs.WaitForLog(nodes[1], vtcp.LogRecovering(uuid), vtcp.RecoveringTimeout)
 
 	nodes[0].CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, false)
-	nodes[1].CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, true)
 	nodes[2].CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, true)
 	nodes[3].CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, true)
 	nodes[4].CheckNodeForLogMessage(t, uuid, vtcp.LogMessageRecoveringLogMessage, true)
@@ -56,12 +56,20 @@ func setupNodesForSeveralPathExchangeCoordinatorBranchingTest(t *testing.T) ([]*
 }
 
 func Test1ExchangeCoordinatorBranchingNormalAmount(t *testing.T) {
-	nodes, _ := setupNodesForSeveralPathExchangeCoordinatorBranchingTest(t)
+	nodes, cluster := setupNodesForSeveralPathExchangeCoordinatorBranchingTest(t)
 
+	before := cluster.SnapshotBalancesAndCheck(t, testconfig.Equivalent)
 	nodes[0].CreateExchangeTransactionCheckStatus(t, nodes[5], testconfig.Equivalent, "1000", testconfig.Equivalent, vtcp.NoMaxAllowablePaymentAmount, vtcp.StatusOK)
 
 	vtcp.CheckSettlementLineForSyncBatch(t, nodes, testconfig.Equivalent, vtcp.WaitingParticipantsVotesSec)
 	nodes[0].CheckExchangeMaxFlow(t, nodes[5], testconfig.Equivalent, []string{testconfig.Equivalent}, "0")
+
+	// The whole max flow is used, so every path is saturated
+	expected := vtcp.PathDeltas(vtcp.NewAmount(1000), nodes[0], nodes[1])
+	vtcp.AddPathDeltas(expected, vtcp.NewAmount(500), nodes[1], nodes[5])
+	vtcp.AddPathDeltas(expected, vtcp.NewAmount(200), nodes[1], nodes[2], nodes[5])
+	vtcp.AddPathDeltas(expected, vtcp.NewAmount(300), nodes[1], nodes[3], nodes[4], nodes[5])
+	vtcp.AssertDeltas(t, before, cluster.SnapshotBalancesAndCheck(t, testconfig.Equivalent), expected)
 }
 
 func Test2aExchangeCoordinatorBranchingSeveralRunNextNeighborResponseProcessingStageLostMessageMiddlePathPaymentPassed(t *testing.T) {
@@ -75,17 +75,17 @@ func TestEstimatePaymentReceiveBranchingWithCommission(t *testing.T) {
 	_, _ = nodes[0].GetExchangeMaxFlow(t, nodes[5], testconfig.Equivalent, []string{testconfig.Equivalent})
 
 	// Receive -> Payment: want to deliver 990, expect ~1000 due to single commission 10 once across paths
-	nodes[0].CheckEstimatePaymentForReceiveAmount(t, nodes[5], "990", testconfig.Equivalent, testconfig.Equivalent, "1000", vtcp.StatusOK)
+	nodes[0].CheckEstimatePaymentForReceiveAmount(t, nodes[5], "990", testconfig.Equivalent, testconfig.Equivalent, vtcp.NewAmount(1000), vtcp.StatusOK)
 
 	// Payment -> Receive: pay 1000, expect ~990 after commission
-	nodes[0].CheckEstimateReceiveForPaymentAmount(t, nodes[5], "1000", testconfig.Equivalent, testconfig.Equivalent, "990", vtcp.StatusOK)
+	nodes[0].CheckEstimateReceiveForPaymentAmount(t, nodes[5], "1000", testconfig.Equivalent, testconfig.Equivalent, vtcp.NewAmount(990), vtcp.StatusOK)
 
-	nodes[0].CheckEstimatePaymentForReceiveAmount(t, nodes[5], "500", testconfig.Equivalent, testconfig.Equivalent, "510", vtcp.StatusOK)
-	nodes[0].CheckEstimateReceiveForPaymentAmount(t, nodes[5], "500", testconfig.Equivalent, testconfig.Equivalent, "490", vtcp.StatusOK)
+	nodes[0].CheckEstimatePaymentForReceiveAmount(t, nodes[5], "500", testconfig.Equivalent, testconfig.Equivalent, vtcp.NewAmount(510), vtcp.StatusOK)
+	nodes[0].CheckEstimateReceiveForPaymentAmount(t, nodes[5], "500", testconfig.Equivalent, testconfig.Equivalent, vtcp.NewAmount(490), vtcp.StatusOK)
 
-	nodes[0].CheckEstimatePaymentForReceiveAmount(t, nodes[5], "1000", testconfig.Equivalent, testconfig.Equivalent, "0", vtcp.StatusInsufficientFunds)
-	nodes[0].CheckEstimateReceiveForPaymentAmount(t, nodes[5], "1100", testconfig.Equivalent, testconfig.Equivalent, "0", vtcp.StatusInsufficientFunds)
+	nodes[0].CheckEstimatePaymentForReceiveAmount(t, nodes[5], "1000", testconfig.Equivalent, testconfig.Equivalent, vtcp.Amount{}, vtcp.StatusInsufficientFunds)
+	nodes[0].CheckEstimateReceiveForPaymentAmount(t, nodes[5], "1100", testconfig.Equivalent, testconfig.Equivalent, vtcp.Amount{}, vtcp.StatusInsufficientFunds)
 
-	nodes[0].CheckEstimatePaymentForReceiveAmount(t, nodes[4], "990", testconfig.Equivalent, testconfig.Equivalent, "0", vtcp.StatusNoPaymentRoutes)
-	nodes[0].CheckEstimateReceiveForPaymentAmount(t, nodes[4], "1000", testconfig.Equivalent, testconfig.Equivalent, "0", vtcp.StatusNoPaymentRoutes)
+	nodes[0].CheckEstimatePaymentForReceiveAmount(t, nodes[4], "990", testconfig.Equivalent, testconfig.Equivalent, vtcp.Amount{}, vtcp.StatusNoPaymentRoutes)
+	nodes[0].CheckEstimateReceiveForPaymentAmount(t, nodes[4], "1000", testconfig.Equivalent, testconfig.Equivalent, vtcp.Amount{}, vtcp.StatusNoPaymentRoutes)
 }
@@ -58,21 +58,21 @@ func TestEstimateExchangeMinMaxLimits(t *testing.T) {
 	_, _ = nodes[0].GetExchangeMaxFlow(t, nodes[2], testconfig.Equivalent, []string{testconfig.ExchangeEquivalent})
 
 	// Below min: receive=50 (2002) requires ~33 in 1001 < 100 -> expect 412
-	nodes[0].CheckEstimatePaymentForReceiveAmount(t, nodes[2], "50", testconfig.ExchangeEquivalent, testconfig.Equivalent, "", vtcp.StatusInsufficientFunds)
-	nodes[0].CheckEstimateReceiveForPaymentAmount(t, nodes[2], "50", testconfig.ExchangeEquivalent, testconfig.Equivalent, "", vtcp.StatusInsufficientFunds)
+	nodes[0].CheckEstimatePaymentForReceiveAmount(t, nodes[2], "50", testconfig.ExchangeEquivalent, testconfig.Equivalent, vtcp.Amount{}, vtcp.StatusInsufficientFunds)
+	nodes[0].CheckEstimateReceiveForPaymentAmount(t, nodes[2], "50", testconfig.ExchangeEquivalent, testconfig.Equivalent, vtcp.Amount{}, vtcp.StatusInsufficientFunds)
 
 	// At cap: payment=600 (1001) capped to 500 -> output 500*1.5=750 (2002)
-	nodes[0].CheckEstimateReceiveForPaymentAmount(t, nodes[2], "500", testconfig.ExchangeEquivalent, testconfig.Equivalent, "750", vtcp.StatusOK)
-	nodes[0].CheckEstimatePaymentForReceiveAmount(t, nodes[2], "750", testconfig.ExchangeEquivalent, testconfig.Equivalent, "500", vtcp.StatusOK)
+	nodes[0].CheckEstimateReceiveForPaymentAmount(t, nodes[2], "500", testconfig.ExchangeEquivalent, testconfig.Equivalent, vtcp.NewAmount(750), vtcp.StatusOK)
+	nodes[0].CheckEstimatePaymentForReceiveAmount(t, nodes[2], "750", testconfig.ExchangeEquivalent, testconfig.Equivalent, vtcp.NewAmount(500), vtcp.StatusOK)
 
 	// Missing cache for different pair: try swapped equivalents should be 462 (no cached paths)
-	nodes[0].CheckEstimateReceiveForPaymentAmount(t, nodes[2], "100", testconfig.Equivalent, testconfig.ExchangeEquivalent, "", vtcp.StatusNoPaymentRoutes) // Use API mapping in CLI docs (462 -> 404), but engine returns 462; HTTP layer may map. Here we expect engine code 462.
+	nodes[0].CheckEstimateReceiveForPaymentAmount(t, nodes[2], "100", testconfig.Equivalent, testconfig.ExchangeEquivalent, vtcp.Amount{}, vtcp.StatusNoPaymentRoutes) // Use API mapping in CLI docs (462 -> 404), but engine returns 462; HTTP layer may map. Here we expect engine code 462.
 
 	// On boundaries: expect OK
-	nodes[0].CheckEstimateReceiveForPaymentAmount(t, nodes[2], "900", testconfig.ExchangeEquivalent, testconfig.Equivalent, "1350", vtcp.StatusOK)
-	nodes[0].CheckEstimatePaymentForReceiveAmount(t, nodes[2], "1350", testconfig.ExchangeEquivalent, testconfig.Equivalent, "900", vtcp.StatusOK)
+	nodes[0].CheckEstimateReceiveForPaymentAmount(t, nodes[2], "900", testconfig.ExchangeEquivalent, testconfig.Equivalent, vtcp.NewAmount(1350), vtcp.StatusOK)
+	nodes[0].CheckEstimatePaymentForReceiveAmount(t, nodes[2], "1350", testconfig.ExchangeEquivalent, testconfig.Equivalent, vtcp.NewAmount(900), vtcp.StatusOK)
 
 	// Above max: expect insufficient funds
-	nodes[0].CheckEstimateReceiveForPaymentAmount(t, nodes[2], "950", testconfig.ExchangeEquivalent, testconfig.Equivalent, "", vtcp.StatusInsufficientFunds)
-	nodes[0].CheckEstimatePaymentForReceiveAmount(t, nodes[2], "1400", testconfig.ExchangeEquivalent, testconfig.Equivalent, "", vtcp.StatusInsufficientFunds)
+	nodes[0].CheckEstimateReceiveForPaymentAmount(t, nodes[2], "950", testconfig.ExchangeEquivalent, testconfig.Equivalent, vtcp.Amount{}, vtcp.StatusInsufficientFunds)
+	nodes[0].CheckEstimatePaymentForReceiveAmount(t, nodes[2], "1400", testconfig.ExchangeEquivalent, testconfig.Equivalent, vtcp.Amount{}, vtcp.StatusInsufficientFunds)
 }
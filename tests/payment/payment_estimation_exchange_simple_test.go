@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 	"fmt"
+	"math/big"
 	"testing"
+	"time"
 
 	vtcp "github.com/vTCP-Foundation/vtcpd-test-suite/pkg/testsuite"
 	"github.com/vTCP-Foundation/vtcpd-test-suite/tests/testconfig"
@@ -13,6 +15,14 @@ var (
 	paymentEstimationExchangeSimpleNextNodeIndex = 1
 )
 
+// Settlement line capacities of the simple exchange path
+var (
+	// A -> B and B -> X, in the payer's equivalent
+	exchangeSimplePayerLineCapacity = vtcp.NewAmount(2000)
+	// X -> C, in the receiver's equivalent
+	exchangeSimpleReceiverLineCapacity = vtcp.NewAmount(4000)
+)
+
 func getNextIPForPaymentEstimationExchangeSimple() string {
 	ip := fmt.Sprintf("%s%d", testconfig.StaticContainerIPPartForPaymentEstimationExchangeSimple, paymentEstimationExchangeSimpleNextNodeIndex)
 	paymentEstimationExchangeSimpleNextNodeIndex++
@@ -42,15 +52,23 @@ func setupNodesForPaymentEstimationExchangeSimple(t *testing.T) ([]*vtcp.Node, *
 	nodes[3].OpenChannelAndCheck(t, nodes[2]) // C-X
 
 	// Setup settlement lines in sender equivalent (1001) along A->B->X
-	nodes[1].CreateAndSetSettlementLineAndCheck(t, nodes[0], testconfig.ExchangeEquivalent, "2000")
-	nodes[2].CreateAndSetSettlementLineAndCheck(t, nodes[1], testconfig.ExchangeEquivalent, "2000")
+	nodes[1].CreateAndSetSettlementLineAndCheck(t, nodes[0], testconfig.ExchangeEquivalent, exchangeSimplePayerLineCapacity.String())
+	nodes[2].CreateAndSetSettlementLineAndCheck(t, nodes[1], testconfig.ExchangeEquivalent, exchangeSimplePayerLineCapacity.String())
 
 	// Setup settlement line in receiver equivalent (2002) along X->C
-	nodes[3].CreateAndSetSettlementLineAndCheck(t, nodes[2], testconfig.Equivalent, "4000")
+	nodes[3].CreateAndSetSettlementLineAndCheck(t, nodes[2], testconfig.Equivalent, exchangeSimpleReceiverLineCapacity.String())
 
 	return nodes, cluster
 }
 
+// findExchangePaths runs the exchange max-flow calculation from A to C. It caches the optimal paths the
+// estimation API works on, so it has to run after every change of the rate and before the estimations.
+func findExchangePaths(t *testing.T, nodes []*vtcp.Node) {
+	if _, err := nodes[0].GetExchangeMaxFlow(t, nodes[3], testconfig.Equivalent, []string{testconfig.ExchangeEquivalent}); err != nil {
+		t.Fatalf("failed to get exchange max-flow: %v", err)
+	}
+}
+
 // TestEstimateReceiveAndPayment_SimpleExchange_NoCommissions verifies estimation for a simple exchange path with rate 2.0.
 func TestEstimateReceiveAndPaymentSimpleExchangeWithCommissions(t *testing.T) {
 	nodes, _ := setupNodesForPaymentEstimationExchangeSimple(t)
@@ -62,15 +80,68 @@ func TestEstimateReceiveAndPaymentSimpleExchangeWithCommissions(t *testing.T) {
 		{Equivalent: testconfig.ExchangeEquivalent, Amount: 10},
 	})
 
-	// Trigger exchange max-flow to populate cached optimal paths
-	_, _ = nodes[0].GetExchangeMaxFlow(t, nodes[3], testconfig.Equivalent, []string{testconfig.ExchangeEquivalent})
+	findExchangePaths(t, nodes)
 
 	// Payment -> Receive: 200 (1001) -> expect 400 (2002)
-	nodes[0].CheckEstimateReceiveForPaymentAmount(t, nodes[3], "200", testconfig.ExchangeEquivalent, testconfig.Equivalent, "380", vtcp.StatusOK)
+	nodes[0].CheckEstimateReceiveForPaymentAmount(t, nodes[3], "200", testconfig.ExchangeEquivalent, testconfig.Equivalent, vtcp.NewAmount(380), vtcp.StatusOK)
 
 	// Receive -> Payment: need to deliver 400 (2002) -> expect 200 (1001)
-	nodes[0].CheckEstimatePaymentForReceiveAmount(t, nodes[3], "400", testconfig.ExchangeEquivalent, testconfig.Equivalent, "210", vtcp.StatusOK)
+	nodes[0].CheckEstimatePaymentForReceiveAmount(t, nodes[3], "400", testconfig.ExchangeEquivalent, testconfig.Equivalent, vtcp.NewAmount(210), vtcp.StatusOK)
+
+	nodes[0].CheckEstimateReceiveForPaymentAmount(t, nodes[3], "2100", testconfig.ExchangeEquivalent, testconfig.Equivalent, vtcp.Amount{}, vtcp.StatusInsufficientFunds)
+	nodes[0].CheckEstimateReceiveForPaymentAmount(t, nodes[3], "4000", testconfig.ExchangeEquivalent, testconfig.Equivalent, vtcp.Amount{}, vtcp.StatusInsufficientFunds)
+}
 
-	nodes[0].CheckEstimateReceiveForPaymentAmount(t, nodes[3], "2100", testconfig.ExchangeEquivalent, testconfig.Equivalent, "", vtcp.StatusInsufficientFunds)
-	nodes[0].CheckEstimateReceiveForPaymentAmount(t, nodes[3], "4000", testconfig.ExchangeEquivalent, testconfig.Equivalent, "", vtcp.StatusInsufficientFunds)
+// TestEstimateSimpleExchangeOverRates compares both estimations with the exchange rate model for a range of rates,
+// limits and amounts, on the same path A -> B(commission) -> X(exchange) -> C.
+func TestEstimateSimpleExchangeOverRates(t *testing.T) {
+	nodes, _ := setupNodesForPaymentEstimationExchangeSimple(t)
+
+	nodes[1].SetCommissions([]vtcp.CommissionPair{
+		{Equivalent: testconfig.ExchangeEquivalent, Amount: 7},
+	})
+
+	min, max := vtcp.NewAmount(50), vtcp.NewAmount(1500)
+	rates := []vtcp.ExchangeRate{
+		vtcp.NewExchangeRate(testconfig.ExchangeEquivalent, testconfig.Equivalent, 2, 0),
+		vtcp.NewExchangeRate(testconfig.ExchangeEquivalent, testconfig.Equivalent, 15, 1),
+		vtcp.NewExchangeRate(testconfig.ExchangeEquivalent, testconfig.Equivalent, 333, 3),
+		vtcp.NewExchangeRate(testconfig.ExchangeEquivalent, testconfig.Equivalent, 7, 2),
+		{From: testconfig.ExchangeEquivalent, To: testconfig.Equivalent, Value: big.NewInt(125), Shift: 2, Min: &min, Max: &max},
+	}
+	amounts := []int64{30, 101, 499, 1000, 1999}
+
+	for _, rate := range rates {
+		t.Run(fmt.Sprintf("value=%s,shift=%d", rate.Value, rate.Shift), func(t *testing.T) {
+			nodes[2].SetExchangeRateFromModel(t, rate, vtcp.StatusOK)
+			findExchangePaths(t, nodes)
+
+			path := vtcp.ExchangePath{
+				Coordinator: nodes[0],
+				Receiver:    nodes[3],
+				Hops: []vtcp.ExchangeHop{
+					vtcp.NewExchangeHop(nodes[1], testconfig.ExchangeEquivalent, nil),
+					vtcp.NewExchangeHop(nodes[2], testconfig.ExchangeEquivalent, &rate),
+				},
+				PayerEquivalent: testconfig.ExchangeEquivalent,
+				Capacities: map[vtcp.BalanceEdge]vtcp.Amount{
+					vtcp.Edge(nodes[0], nodes[1]): exchangeSimplePayerLineCapacity,
+					vtcp.Edge(nodes[1], nodes[2]): exchangeSimplePayerLineCapacity,
+					vtcp.Edge(nodes[2], nodes[3]): exchangeSimpleReceiverLineCapacity,
+				},
+			}
+
+			for _, value := range amounts {
+				amount := vtcp.NewAmount(value)
+
+				receive, err := path.EstimateReceive(amount, time.Now())
+				nodes[0].CheckEstimateReceiveForPaymentAmount(t, nodes[3], amount.String(), testconfig.ExchangeEquivalent,
+					testconfig.Equivalent, receive, vtcp.ExpectedEstimateStatus(err))
+
+				payment, err := path.EstimatePayment(amount, time.Now())
+				nodes[0].CheckEstimatePaymentForReceiveAmount(t, nodes[3], amount.String(), testconfig.ExchangeEquivalent,
+					testconfig.Equivalent, payment, vtcp.ExpectedEstimateStatus(err))
+			}
+		})
+	}
 }
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	vtcp "github.com/vTCP-Foundation/vtcpd-test-suite/pkg/testsuite"
+	"github.com/vTCP-Foundation/vtcpd-test-suite/tests/testconfig"
+)
+
+const (
+	// FAULT_MATRIX_ENV enables the fault matrix: it runs a cluster per cell, which takes about twenty minutes
+	FAULT_MATRIX_ENV = "VTCP_FAULT_MATRIX"
+)
+
+var (
+	paymentFaultMatrixNextNodeIndex = 1
+)
+
+func getNextIPForPaymentFaultMatrixTest() string {
+	ip := fmt.Sprintf("%s%d", testconfig.StaticContainerIPPartForPaymentFaultMatrix, paymentFaultMatrixNextNodeIndex)
+	paymentFaultMatrixNextNodeIndex++
+	return ip
+}
+
+// Every cell of the matrix gets a fresh cluster, the node addresses are reused.
+func setupNodesForPaymentFaultMatrixTest(t *testing.T) ([]*vtcp.Node, *vtcp.Cluster) {
+	paymentFaultMatrixNextNodeIndex = 1
+	nodes := make([]*vtcp.Node, 5)
+	for i := range 5 {
+		nodes[i] = vtcp.NewNode(t, getNextIPForPaymentFaultMatrixTest(), fmt.Sprintf("node%d", i+1))
+	}
+
+	ctx := context.Background()
+	cluster, err := vtcp.NewCluster(ctx, t, &testconfig.GSettings)
+	if err != nil {
+		t.Fatalf("failed to create cluster: %v", err)
+	}
+
+	cluster.RunNodes(ctx, t, nodes, false)
+
+	nodes[1].OpenChannelAndCheck(t, nodes[0])
+	nodes[2].OpenChannelAndCheck(t, nodes[1])
+	nodes[3].OpenChannelAndCheck(t, nodes[2])
+	nodes[4].OpenChannelAndCheck(t, nodes[3])
+
+	nodes[1].CreateAndSetSettlementLineAndCheck(t, nodes[0], testconfig.Equivalent, "1000")
+	nodes[2].CreateAndSetSettlementLineAndCheck(t, nodes[1], testconfig.Equivalent, "1000")
+	nodes[3].CreateAndSetSettlementLineAndCheck(t, nodes[2], testconfig.Equivalent, "1000")
+	nodes[4].CreateAndSetSettlementLineAndCheck(t, nodes[3], testconfig.Equivalent, "1000")
+
+	return nodes, cluster
+}
+
+func TestPaymentFaultMatrix(t *testing.T) {
+	if testing.Short() || os.Getenv(FAULT_MATRIX_ENV) == "" {
+		t.Skipf("fault matrix is opt-in, set %s=1 to run it", FAULT_MATRIX_ENV)
+	}
+	vtcp.RunFaultMatrix(t, vtcp.FaultMatrix{
+		Setup: setupNodesForPaymentFaultMatrixTest,
+		Roles: []vtcp.FaultRole{
+			{Name: vtcp.FaultRoleCoordinator, NodeIndex: 0},
+			{Name: vtcp.FaultRoleIntermediate, NodeIndex: 2},
+			{Name: vtcp.FaultRoleReceiver, NodeIndex: 4},
+		},
+		Flags: []uint64{
+			vtcp.FlagForbidSendMessageFinalPathConfig,
+			vtcp.FlagForbidSendMessageFinalAmountClarification,
+			vtcp.FlagForbidSendMessageVoteStage,
+			vtcp.FlagForbidSendMessageVoteConsistency,
+			vtcp.FlagThrowExceptionVote,
+			vtcp.FlagThrowExceptionVoteConsistency,
+		},
+		Coordinator: 0,
+		Receiver:    4,
+		Equivalent:  testconfig.Equivalent,
+		Amount:      "500",
+		SettleTime:  (vtcp.WaitingParticipantsVotesSec + 15) * time.Second,
+		ReportDir:   testconfig.GSettings.ReportDir,
+	})
+}
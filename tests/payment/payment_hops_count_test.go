@@ -20,7 +20,7 @@ func getNextIPForPaymentHopsCountTest() string {
 }
 
 func TestPaymentHopsCount1(t *testing.T) {
-	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1")
+	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1", vtcp.WithMaxHops(1))
 	node2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_2")
 	node3 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_3")
 	node4 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_4")
@@ -33,8 +33,6 @@ func TestPaymentHopsCount1(t *testing.T) {
 
 	cluster.RunNodes(ctx, t, []*vtcp.Node{node1, node2, node3, node4}, false)
 
-	node1.SetHopsCount(1)
-
 	node1.OpenChannelAndCheck(t, node2)
 	node2.OpenChannelAndCheck(t, node3)
 	node3.OpenChannelAndCheck(t, node4)
@@ -50,7 +48,7 @@ func TestPaymentHopsCount1(t *testing.T) {
 }
 
 func TestPaymentHopsCount11(t *testing.T) {
-	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1")
+	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1", vtcp.WithMaxHops(0))
 	node2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_2")
 	node3 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_3")
 	node4 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_4")
@@ -63,8 +61,6 @@ func TestPaymentHopsCount11(t *testing.T) {
 
 	cluster.RunNodes(ctx, t, []*vtcp.Node{node1, node2, node3, node4}, false)
 
-	node1.SetHopsCount(0)
-
 	node1.OpenChannelAndCheck(t, node2)
 	node2.OpenChannelAndCheck(t, node3)
 	node3.OpenChannelAndCheck(t, node4)
@@ -79,7 +75,7 @@ func TestPaymentHopsCount11(t *testing.T) {
 }
 
 func TestPaymentHopsCount12(t *testing.T) {
-	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1")
+	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1", vtcp.WithMaxHops(0))
 	node2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_2")
 	node3 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_3")
 	node4 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_4")
@@ -93,8 +89,6 @@ func TestPaymentHopsCount12(t *testing.T) {
 
 	cluster.RunNodes(ctx, t, []*vtcp.Node{node1, node2, node3, node4, node5}, false)
 
-	node1.SetHopsCount(0)
-
 	node1.OpenChannelAndCheck(t, node2)
 	node2.OpenChannelAndCheck(t, node3)
 	node3.OpenChannelAndCheck(t, node4)
@@ -109,7 +103,7 @@ func TestPaymentHopsCount12(t *testing.T) {
 }
 
 func TestPaymentHopsCount2(t *testing.T) {
-	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1")
+	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1", vtcp.WithMaxHops(2))
 	node2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_2")
 	node3 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_3")
 	node4 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_4")
@@ -123,8 +117,6 @@ func TestPaymentHopsCount2(t *testing.T) {
 
 	cluster.RunNodes(ctx, t, []*vtcp.Node{node1, node2, node3, node4, node5}, false)
 
-	node1.SetHopsCount(2)
-
 	node1.OpenChannelAndCheck(t, node2)
 	node2.OpenChannelAndCheck(t, node3)
 	node3.OpenChannelAndCheck(t, node4)
@@ -151,7 +143,7 @@ func TestPaymentHopsCount2(t *testing.T) {
 }
 
 func TestPaymentHopsCount3(t *testing.T) {
-	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1")
+	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1", vtcp.WithMaxHops(3))
 	node2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_2")
 	node3 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_3")
 	node4 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_4")
@@ -166,8 +158,6 @@ func TestPaymentHopsCount3(t *testing.T) {
 
 	cluster.RunNodes(ctx, t, []*vtcp.Node{node1, node2, node3, node4, node5, node6}, false)
 
-	node1.SetHopsCount(3)
-
 	node1.OpenChannelAndCheck(t, node2)
 	node2.OpenChannelAndCheck(t, node3)
 	node3.OpenChannelAndCheck(t, node4)
@@ -184,7 +174,7 @@ func TestPaymentHopsCount3(t *testing.T) {
 }
 
 func TestPaymentHopsCount51(t *testing.T) {
-	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1")
+	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1", vtcp.WithMaxHops(5))
 	node2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_2")
 	node3 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_3")
 	node4 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_4")
@@ -200,8 +190,6 @@ func TestPaymentHopsCount51(t *testing.T) {
 
 	cluster.RunNodes(ctx, t, []*vtcp.Node{node1, node2, node3, node4, node5, node6, node7}, false)
 
-	node1.SetHopsCount(5)
-
 	node1.OpenChannelAndCheck(t, node2)
 	node2.OpenChannelAndCheck(t, node3)
 	node3.OpenChannelAndCheck(t, node4)
@@ -220,7 +208,7 @@ func TestPaymentHopsCount51(t *testing.T) {
 }
 
 func TestPaymentHopsCount52(t *testing.T) {
-	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1")
+	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1", vtcp.WithMaxHops(4))
 	node2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_2")
 	node3 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_3")
 	node4 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_4")
@@ -236,8 +224,6 @@ func TestPaymentHopsCount52(t *testing.T) {
 
 	cluster.RunNodes(ctx, t, []*vtcp.Node{node1, node2, node3, node4, node5, node6, node7}, false)
 
-	node1.SetHopsCount(4)
-
 	node1.OpenChannelAndCheck(t, node2)
 	node2.OpenChannelAndCheck(t, node3)
 	node3.OpenChannelAndCheck(t, node4)
@@ -256,10 +242,10 @@ func TestPaymentHopsCount52(t *testing.T) {
 }
 
 func TestPayment1HopsCountHop11(t *testing.T) {
-	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1")
+	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1", vtcp.WithMaxHops(2))
 	node2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_2")
-	hop1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_1")
-	hop2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_2")
+	hop1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_1", vtcp.WithHub(testconfig.Equivalent))
+	hop2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_2", vtcp.WithHub(testconfig.Equivalent))
 
 	ctx := context.Background()
 	cluster, err := vtcp.NewCluster(ctx, t, &testconfig.GSettings)
@@ -269,10 +255,6 @@ func TestPayment1HopsCountHop11(t *testing.T) {
 
 	cluster.RunNodes(ctx, t, []*vtcp.Node{node1, node2, hop1, hop2}, false)
 
-	node1.SetHopsCount(2)
-	hop1.MakeHub(testconfig.Equivalent)
-	hop2.MakeHub(testconfig.Equivalent)
-
 	node1.OpenChannelAndCheck(t, hop1)
 	hop1.OpenChannelAndCheck(t, hop2)
 	hop2.OpenChannelAndCheck(t, node2)
@@ -285,10 +267,10 @@ func TestPayment1HopsCountHop11(t *testing.T) {
 }
 
 func TestPayment1HopsCountHop12(t *testing.T) {
-	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1")
+	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1", vtcp.WithMaxHops(1))
 	node2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_2")
-	hop1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_1")
-	hop2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_2")
+	hop1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_1", vtcp.WithHub(testconfig.Equivalent))
+	hop2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_2", vtcp.WithHub(testconfig.Equivalent))
 
 	ctx := context.Background()
 	cluster, err := vtcp.NewCluster(ctx, t, &testconfig.GSettings)
@@ -298,10 +280,6 @@ func TestPayment1HopsCountHop12(t *testing.T) {
 
 	cluster.RunNodes(ctx, t, []*vtcp.Node{node1, node2, hop1, hop2}, false)
 
-	node1.SetHopsCount(1)
-	hop1.MakeHub(testconfig.Equivalent)
-	hop2.MakeHub(testconfig.Equivalent)
-
 	node1.OpenChannelAndCheck(t, hop1)
 	hop1.OpenChannelAndCheck(t, hop2)
 	hop2.OpenChannelAndCheck(t, node2)
@@ -314,10 +292,10 @@ func TestPayment1HopsCountHop12(t *testing.T) {
 }
 
 func TestPayment1HopsCountHop13(t *testing.T) {
-	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1")
+	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1", vtcp.WithMaxHops(0))
 	node2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_2")
-	hop1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_1")
-	hop2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_2")
+	hop1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_1", vtcp.WithHub(testconfig.Equivalent))
+	hop2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_2", vtcp.WithHub(testconfig.Equivalent))
 
 	ctx := context.Background()
 	cluster, err := vtcp.NewCluster(ctx, t, &testconfig.GSettings)
@@ -327,10 +305,6 @@ func TestPayment1HopsCountHop13(t *testing.T) {
 
 	cluster.RunNodes(ctx, t, []*vtcp.Node{node1, node2, hop1, hop2}, false)
 
-	node1.SetHopsCount(0)
-	hop1.MakeHub(testconfig.Equivalent)
-	hop2.MakeHub(testconfig.Equivalent)
-
 	node1.OpenChannelAndCheck(t, hop1)
 	hop1.OpenChannelAndCheck(t, hop2)
 	hop2.OpenChannelAndCheck(t, node2)
@@ -343,11 +317,11 @@ func TestPayment1HopsCountHop13(t *testing.T) {
 }
 
 func TestPayment2HopsCountHop21(t *testing.T) {
-	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1")
+	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1", vtcp.WithMaxHops(3))
 	node2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_2")
-	hop1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_1")
-	hop2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_2")
-	hop3 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_3")
+	hop1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_1", vtcp.WithHub(testconfig.Equivalent))
+	hop2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_2", vtcp.WithHub(testconfig.Equivalent))
+	hop3 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_3", vtcp.WithHub(testconfig.Equivalent))
 
 	ctx := context.Background()
 	cluster, err := vtcp.NewCluster(ctx, t, &testconfig.GSettings)
@@ -357,11 +331,6 @@ func TestPayment2HopsCountHop21(t *testing.T) {
 
 	cluster.RunNodes(ctx, t, []*vtcp.Node{node1, node2, hop1, hop2, hop3}, false)
 
-	node1.SetHopsCount(3)
-	hop1.MakeHub(testconfig.Equivalent)
-	hop2.MakeHub(testconfig.Equivalent)
-	hop3.MakeHub(testconfig.Equivalent)
-
 	node1.OpenChannelAndCheck(t, hop1)
 	hop1.OpenChannelAndCheck(t, hop2)
 	hop2.OpenChannelAndCheck(t, hop3)
@@ -376,11 +345,11 @@ func TestPayment2HopsCountHop21(t *testing.T) {
 }
 
 func TestPayment2HopsCountHop22(t *testing.T) {
-	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1")
+	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1", vtcp.WithMaxHops(2))
 	node2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_2")
-	hop1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_1")
-	hop2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_2")
-	hop3 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_3")
+	hop1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_1", vtcp.WithHub(testconfig.Equivalent))
+	hop2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_2", vtcp.WithHub(testconfig.Equivalent))
+	hop3 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_3", vtcp.WithHub(testconfig.Equivalent))
 
 	ctx := context.Background()
 	cluster, err := vtcp.NewCluster(ctx, t, &testconfig.GSettings)
@@ -390,11 +359,6 @@ func TestPayment2HopsCountHop22(t *testing.T) {
 
 	cluster.RunNodes(ctx, t, []*vtcp.Node{node1, node2, hop1, hop2, hop3}, false)
 
-	node1.SetHopsCount(2)
-	hop1.MakeHub(testconfig.Equivalent)
-	hop2.MakeHub(testconfig.Equivalent)
-	hop3.MakeHub(testconfig.Equivalent)
-
 	node1.OpenChannelAndCheck(t, hop1)
 	hop1.OpenChannelAndCheck(t, hop2)
 	hop2.OpenChannelAndCheck(t, hop3)
@@ -409,12 +373,12 @@ func TestPayment2HopsCountHop22(t *testing.T) {
 }
 
 func TestPayment3HopsCountHop31(t *testing.T) {
-	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1")
+	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1", vtcp.WithMaxHops(4))
 	node2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_2")
-	hop1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_1")
-	hop2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_2")
-	hop3 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_3")
-	hop4 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_4")
+	hop1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_1", vtcp.WithHub(testconfig.Equivalent))
+	hop2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_2", vtcp.WithHub(testconfig.Equivalent))
+	hop3 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_3", vtcp.WithHub(testconfig.Equivalent))
+	hop4 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_4", vtcp.WithHub(testconfig.Equivalent))
 
 	ctx := context.Background()
 	cluster, err := vtcp.NewCluster(ctx, t, &testconfig.GSettings)
@@ -424,12 +388,6 @@ func TestPayment3HopsCountHop31(t *testing.T) {
 
 	cluster.RunNodes(ctx, t, []*vtcp.Node{node1, node2, hop1, hop2, hop3, hop4}, false)
 
-	node1.SetHopsCount(4)
-	hop1.MakeHub(testconfig.Equivalent)
-	hop2.MakeHub(testconfig.Equivalent)
-	hop3.MakeHub(testconfig.Equivalent)
-	hop4.MakeHub(testconfig.Equivalent)
-
 	node1.OpenChannelAndCheck(t, hop1)
 	hop1.OpenChannelAndCheck(t, hop2)
 	hop2.OpenChannelAndCheck(t, hop3)
@@ -446,12 +404,12 @@ func TestPayment3HopsCountHop31(t *testing.T) {
 }
 
 func TestPayment3HopsCountHop32(t *testing.T) {
-	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1")
+	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1", vtcp.WithMaxHops(3))
 	node2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_2")
-	hop1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_1")
-	hop2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_2")
-	hop3 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_3")
-	hop4 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_4")
+	hop1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_1", vtcp.WithHub(testconfig.Equivalent))
+	hop2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_2", vtcp.WithHub(testconfig.Equivalent))
+	hop3 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_3", vtcp.WithHub(testconfig.Equivalent))
+	hop4 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_4", vtcp.WithHub(testconfig.Equivalent))
 
 	ctx := context.Background()
 	cluster, err := vtcp.NewCluster(ctx, t, &testconfig.GSettings)
@@ -461,12 +419,6 @@ func TestPayment3HopsCountHop32(t *testing.T) {
 
 	cluster.RunNodes(ctx, t, []*vtcp.Node{node1, node2, hop1, hop2, hop3, hop4}, false)
 
-	node1.SetHopsCount(3)
-	hop1.MakeHub(testconfig.Equivalent)
-	hop2.MakeHub(testconfig.Equivalent)
-	hop3.MakeHub(testconfig.Equivalent)
-	hop4.MakeHub(testconfig.Equivalent)
-
 	node1.OpenChannelAndCheck(t, hop1)
 	hop1.OpenChannelAndCheck(t, hop2)
 	hop2.OpenChannelAndCheck(t, hop3)
@@ -483,13 +435,13 @@ func TestPayment3HopsCountHop32(t *testing.T) {
 }
 
 func TestPayment4HopsCountHop41(t *testing.T) {
-	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1")
+	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1", vtcp.WithMaxHops(5))
 	node2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_2")
-	hop1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_1")
-	hop2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_2")
-	hop3 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_3")
-	hop4 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_4")
-	hop5 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_5")
+	hop1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_1", vtcp.WithHub(testconfig.Equivalent))
+	hop2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_2", vtcp.WithHub(testconfig.Equivalent))
+	hop3 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_3", vtcp.WithHub(testconfig.Equivalent))
+	hop4 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_4", vtcp.WithHub(testconfig.Equivalent))
+	hop5 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_5", vtcp.WithHub(testconfig.Equivalent))
 
 	ctx := context.Background()
 	cluster, err := vtcp.NewCluster(ctx, t, &testconfig.GSettings)
@@ -499,13 +451,6 @@ func TestPayment4HopsCountHop41(t *testing.T) {
 
 	cluster.RunNodes(ctx, t, []*vtcp.Node{node1, node2, hop1, hop2, hop3, hop4, hop5}, false)
 
-	node1.SetHopsCount(5)
-	hop1.MakeHub(testconfig.Equivalent)
-	hop2.MakeHub(testconfig.Equivalent)
-	hop3.MakeHub(testconfig.Equivalent)
-	hop4.MakeHub(testconfig.Equivalent)
-	hop5.MakeHub(testconfig.Equivalent)
-
 	node1.OpenChannelAndCheck(t, hop1)
 	hop1.OpenChannelAndCheck(t, hop2)
 	hop2.OpenChannelAndCheck(t, hop3)
@@ -524,13 +469,13 @@ func TestPayment4HopsCountHop41(t *testing.T) {
 }
 
 func TestPayment4HopsCountHop42(t *testing.T) {
-	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1")
+	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1", vtcp.WithMaxHops(4))
 	node2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_2")
-	hop1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_1")
-	hop2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_2")
-	hop3 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_3")
-	hop4 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_4")
-	hop5 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_5")
+	hop1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_1", vtcp.WithHub(testconfig.Equivalent))
+	hop2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_2", vtcp.WithHub(testconfig.Equivalent))
+	hop3 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_3", vtcp.WithHub(testconfig.Equivalent))
+	hop4 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_4", vtcp.WithHub(testconfig.Equivalent))
+	hop5 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_5", vtcp.WithHub(testconfig.Equivalent))
 
 	ctx := context.Background()
 	cluster, err := vtcp.NewCluster(ctx, t, &testconfig.GSettings)
@@ -540,13 +485,6 @@ func TestPayment4HopsCountHop42(t *testing.T) {
 
 	cluster.RunNodes(ctx, t, []*vtcp.Node{node1, node2, hop1, hop2, hop3, hop4, hop5}, false)
 
-	node1.SetHopsCount(4)
-	hop1.MakeHub(testconfig.Equivalent)
-	hop2.MakeHub(testconfig.Equivalent)
-	hop3.MakeHub(testconfig.Equivalent)
-	hop4.MakeHub(testconfig.Equivalent)
-	hop5.MakeHub(testconfig.Equivalent)
-
 	node1.OpenChannelAndCheck(t, hop1)
 	hop1.OpenChannelAndCheck(t, hop2)
 	hop2.OpenChannelAndCheck(t, hop3)
@@ -565,14 +503,14 @@ func TestPayment4HopsCountHop42(t *testing.T) {
 }
 
 func TestPayment5HopsCountHop(t *testing.T) {
-	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1")
+	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1", vtcp.WithMaxHops(5))
 	node2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_2")
-	hop1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_1")
-	hop2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_2")
-	hop3 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_3")
-	hop4 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_4")
-	hop5 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_5")
-	hop6 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_6")
+	hop1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_1", vtcp.WithHub(testconfig.Equivalent))
+	hop2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_2", vtcp.WithHub(testconfig.Equivalent))
+	hop3 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_3", vtcp.WithHub(testconfig.Equivalent))
+	hop4 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_4", vtcp.WithHub(testconfig.Equivalent))
+	hop5 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_5", vtcp.WithHub(testconfig.Equivalent))
+	hop6 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_6", vtcp.WithHub(testconfig.Equivalent))
 
 	ctx := context.Background()
 	cluster, err := vtcp.NewCluster(ctx, t, &testconfig.GSettings)
@@ -582,14 +520,6 @@ func TestPayment5HopsCountHop(t *testing.T) {
 
 	cluster.RunNodes(ctx, t, []*vtcp.Node{node1, node2, hop1, hop2, hop3, hop4, hop5, hop6}, false)
 
-	node1.SetHopsCount(5)
-	hop1.MakeHub(testconfig.Equivalent)
-	hop2.MakeHub(testconfig.Equivalent)
-	hop3.MakeHub(testconfig.Equivalent)
-	hop4.MakeHub(testconfig.Equivalent)
-	hop5.MakeHub(testconfig.Equivalent)
-	hop6.MakeHub(testconfig.Equivalent)
-
 	node1.OpenChannelAndCheck(t, hop1)
 	hop1.OpenChannelAndCheck(t, hop2)
 	hop2.OpenChannelAndCheck(t, hop3)
@@ -610,13 +540,13 @@ func TestPayment5HopsCountHop(t *testing.T) {
 }
 
 func TestPayment6HopsCountHop5(t *testing.T) {
-	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1")
+	node1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_1", vtcp.WithMaxHops(5))
 	node2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_2")
 	node3 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "node_3")
-	hop1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_1")
-	hop2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_2")
-	hop3 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_3")
-	hop4 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_4")
+	hop1 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_1", vtcp.WithHub(testconfig.Equivalent))
+	hop2 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_2", vtcp.WithHub(testconfig.Equivalent))
+	hop3 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_3", vtcp.WithHub(testconfig.Equivalent))
+	hop4 := vtcp.NewNode(t, getNextIPForPaymentHopsCountTest(), "hop_4", vtcp.WithHub(testconfig.Equivalent))
 
 	ctx := context.Background()
 	cluster, err := vtcp.NewCluster(ctx, t, &testconfig.GSettings)
@@ -626,12 +556,6 @@ func TestPayment6HopsCountHop5(t *testing.T) {
 
 	cluster.RunNodes(ctx, t, []*vtcp.Node{node1, node2, node3, hop1, hop2, hop3, hop4}, false)
 
-	node1.SetHopsCount(5)
-	hop1.MakeHub(testconfig.Equivalent)
-	hop2.MakeHub(testconfig.Equivalent)
-	hop3.MakeHub(testconfig.Equivalent)
-	hop4.MakeHub(testconfig.Equivalent)
-
 	node1.OpenChannelAndCheck(t, hop1)
 	hop1.OpenChannelAndCheck(t, hop2)
 	hop2.OpenChannelAndCheck(t, node2)
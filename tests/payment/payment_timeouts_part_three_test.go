@@ -34,6 +34,7 @@ func setupNodesForPaymentTimeoutsPartThreeTest(t *testing.T) ([]*vtcp.Node, *vtc
 	}
 
 	cluster.RunNodes(ctx, t, nodes, false)
+	cluster.LogTimelineOnFailure(t)
 
 	nodes[1].OpenChannelAndCheck(t, nodes[0])
 	nodes[3].OpenChannelAndCheck(t, nodes[2])
@@ -59,11 +59,11 @@ func Test1WithCoordinatorSettlementLineCommon(t *testing.T) {
 	nodes[8].CreateAndSetSettlementLineAndCheck(t, nodes[1], testconfig.Equivalent, "100")
 
 	expectedMaxFlows := []vtcp.MaxFlowBatchCheck{
-		{Node: nodes[2], ExpectedMaxFlow: "200"},
-		{Node: nodes[4], ExpectedMaxFlow: "100"},
-		{Node: nodes[5], ExpectedMaxFlow: "300"},
-		{Node: nodes[7], ExpectedMaxFlow: "200"},
-		{Node: nodes[8], ExpectedMaxFlow: "100"},
+		{Node: nodes[2], ExpectedMaxFlow: vtcp.NewAmount(200)},
+		{Node: nodes[4], ExpectedMaxFlow: vtcp.NewAmount(100)},
+		{Node: nodes[5], ExpectedMaxFlow: vtcp.NewAmount(300)},
+		{Node: nodes[7], ExpectedMaxFlow: vtcp.NewAmount(200)},
+		{Node: nodes[8], ExpectedMaxFlow: vtcp.NewAmount(100)},
 	}
 	nodes[0].CheckMaxFlowBatch(t, expectedMaxFlows, testconfig.Equivalent)
 
@@ -79,11 +79,11 @@ func Test1WithCoordinatorSettlementLineCommon(t *testing.T) {
 	}
 
 	expectedMaxFlows = []vtcp.MaxFlowBatchCheck{
-		{Node: nodes[2], ExpectedMaxFlow: "0"},
-		{Node: nodes[4], ExpectedMaxFlow: "0"},
-		{Node: nodes[5], ExpectedMaxFlow: "0"},
-		{Node: nodes[7], ExpectedMaxFlow: "0"},
-		{Node: nodes[8], ExpectedMaxFlow: "0"},
+		{Node: nodes[2], ExpectedMaxFlow: vtcp.NewAmount(0)},
+		{Node: nodes[4], ExpectedMaxFlow: vtcp.NewAmount(0)},
+		{Node: nodes[5], ExpectedMaxFlow: vtcp.NewAmount(0)},
+		{Node: nodes[7], ExpectedMaxFlow: vtcp.NewAmount(0)},
+		{Node: nodes[8], ExpectedMaxFlow: vtcp.NewAmount(0)},
 	}
 	nodes[0].CheckMaxFlowBatch(t, expectedMaxFlows, testconfig.Equivalent)
 
@@ -1,6 +1,12 @@
 package testconfig
 
 import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"testing"
+
 	"github.com/vTCP-Foundation/vtcpd-test-suite/internal/conf"
 	vtcp "github.com/vTCP-Foundation/vtcpd-test-suite/pkg/testsuite"
 )
@@ -47,6 +53,12 @@ var (
 	StaticContainerIPPartForExchangePaymentFiveNodesWithCommissions                 = "172.18.38."
 	StaticContainerIPPartForExchangePaymentFiveNodesWithCommissionsSingleEquivalent = "172.18.39."
 	StaticContainerIPPartForExchangePaymentOneNodeSeveralPaths                      = "172.18.40."
+	StaticContainerIPPartForPaymentFaultMatrix                                      = "172.18.41."
+	StaticContainerIPPartForNodeUpgradeTest                                         = "172.18.42."
+	StaticContainerIPPartForAPIFuzzing                                              = "172.18.43."
+
+	// PreviousNodeImageName is the image of the previous vtcpd release, upgrade tests are skipped if it's empty.
+	PreviousNodeImageName string
 
 	Equivalent                   = "2002"
 	ExchangeEquivalent           = "1001"
@@ -54,11 +66,39 @@ var (
 	ConfigFilePathInContainer    = "/vtcp/vtcpd/conf.json"
 )
 
-func init() {
-	configFromInternalConf := conf.GetConfig()
+// Setup loads the configuration into GSettings and PreviousNodeImageName and checks it.
+func Setup(ctx context.Context) error {
+	configFromInternalConf, err := conf.GetConfig()
+	if err != nil {
+		return fmt.Errorf("invalid test suite configuration: %w", err)
+	}
 	GSettings = vtcp.ClusterSettings{
-		NodeImageName: configFromInternalConf.NodeImageName,
-		NetworkName:   configFromInternalConf.NetworkName,
-		SudoPassword:  configFromInternalConf.SudoPassword,
+		NodeImageName:  configFromInternalConf.NodeImageName,
+		NetworkName:    configFromInternalConf.NetworkName,
+		SudoPassword:   configFromInternalConf.SudoPassword,
+		ReportDir:      configFromInternalConf.ReportDir,
+		DatabaseConfig: configFromInternalConf.DatabaseConfig,
+		StepTimeout:    configFromInternalConf.StepTimeout,
+	}
+	PreviousNodeImageName = configFromInternalConf.PreviousNodeImageName
+
+	if err := GSettings.Validate(ctx); err != nil {
+		return err
+	}
+	if PreviousNodeImageName != "" {
+		if err := vtcp.CheckImageExists(ctx, PreviousNodeImageName); err != nil {
+			return fmt.Errorf("previous node image: %w", err)
+		}
+	}
+	return nil
+}
+
+// Main is the TestMain of the test packages. The settings are checked before any test runs, so that a wrong
+// image or network name stops the run instead of ending up in a docker error in the middle of a test.
+func Main(m *testing.M) {
+	if err := Setup(context.Background()); err != nil {
+		log.Printf("ERROR: %v", err)
+		os.Exit(1)
 	}
+	os.Exit(m.Run())
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	vtcp "github.com/vTCP-Foundation/vtcpd-test-suite/pkg/testsuite"
+	"github.com/vTCP-Foundation/vtcpd-test-suite/tests/testconfig"
+)
+
+var (
+	nodeUpgradeNextNodeIndex = 1
+)
+
+func getNextIPForNodeUpgradeTest() string {
+	ip := fmt.Sprintf("%s%d", testconfig.StaticContainerIPPartForNodeUpgradeTest, nodeUpgradeNextNodeIndex)
+	nodeUpgradeNextNodeIndex++
+	return ip
+}
+
+// setupNodesForNodeUpgradeTest runs a chain of three nodes, images[i] is the image of the i-th node
+// (empty for the current build).
+func setupNodesForNodeUpgradeTest(t *testing.T, images [3]string) ([]*vtcp.Node, *vtcp.Cluster) {
+	if testconfig.PreviousNodeImageName == "" {
+		t.Skip("previous node image is not configured (previousNodeImageName / VTCP_PREVIOUS_NODE_IMAGE)")
+	}
+
+	nodes := make([]*vtcp.Node, 3)
+	for i := range 3 {
+		nodes[i] = vtcp.NewNode(t, getNextIPForNodeUpgradeTest(), fmt.Sprintf("node%d", i+1))
+		nodes[i].Image = images[i]
+	}
+
+	ctx := context.Background()
+	cluster, err := vtcp.NewCluster(ctx, t, &testconfig.GSettings)
+	if err != nil {
+		t.Fatalf("failed to create cluster: %v", err)
+	}
+
+	cluster.RunNodes(ctx, t, nodes, false)
+
+	nodes[1].OpenChannelAndCheck(t, nodes[0])
+	nodes[2].OpenChannelAndCheck(t, nodes[1])
+
+	nodes[1].CreateAndSetSettlementLineAndCheck(t, nodes[0], testconfig.Equivalent, "1000")
+	nodes[2].CreateAndSetSettlementLineAndCheck(t, nodes[1], testconfig.Equivalent, "1000")
+
+	return nodes, cluster
+}
+
+func TestNodeUpgradeOldCoordinatorNewReceiver(t *testing.T) {
+	nodes, _ := setupNodesForNodeUpgradeTest(t, [3]string{testconfig.PreviousNodeImageName, "", ""})
+
+	nodes[0].CreateTransactionCheckStatus(t, nodes[2], testconfig.Equivalent, "300", vtcp.StatusOK)
+
+	vtcp.CheckSettlementLineForSyncBatch(t, nodes, testconfig.Equivalent, 0)
+	nodes[0].CheckMaxFlow(t, nodes[2], testconfig.Equivalent, "700")
+}
+
+func TestNodeUpgradeNewCoordinatorOldReceiver(t *testing.T) {
+	nodes, _ := setupNodesForNodeUpgradeTest(t, [3]string{"", "", testconfig.PreviousNodeImageName})
+
+	nodes[0].CreateTransactionCheckStatus(t, nodes[2], testconfig.Equivalent, "300", vtcp.StatusOK)
+
+	vtcp.CheckSettlementLineForSyncBatch(t, nodes, testconfig.Equivalent, 0)
+	nodes[0].CheckMaxFlow(t, nodes[2], testconfig.Equivalent, "700")
+}
+
+func TestNodeUpgradeKeepsStorage(t *testing.T) {
+	previous := testconfig.PreviousNodeImageName
+	nodes, cluster := setupNodesForNodeUpgradeTest(t, [3]string{previous, previous, previous})
+
+	nodes[0].CreateTransactionCheckStatus(t, nodes[2], testconfig.Equivalent, "300", vtcp.StatusOK)
+	vtcp.CheckSettlementLineForSyncBatch(t, nodes, testconfig.Equivalent, 0)
+
+	// The current build has to load the databases written by the previous release
+	for _, node := range nodes {
+		if err := cluster.UpgradeNode(context.Background(), node, testconfig.GSettings.NodeImageName); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+
+	vtcp.CheckSettlementLineForSyncBatch(t, nodes, testconfig.Equivalent, 0)
+	nodes[0].CheckMaxFlow(t, nodes[2], testconfig.Equivalent, "700")
+
+	nodes[0].CreateTransactionCheckStatus(t, nodes[2], testconfig.Equivalent, "200", vtcp.StatusOK)
+	vtcp.CheckSettlementLineForSyncBatch(t, nodes, testconfig.Equivalent, 0)
+	nodes[0].CheckMaxFlow(t, nodes[2], testconfig.Equivalent, "500")
+}